@@ -25,15 +25,22 @@ type KubectlStorageOSConfigSpec struct {
 	StackTrace                  bool `json:"stackTrace,omitempty"`
 	SkipNamespaceDeletion       bool `json:"skipNamespaceDeletion,omitempty"`
 	SkipExistingWorkloadCheck   bool `json:"skipExistingWorkloadCheck,omitempty"`
+	QuiesceWorkloads            bool `json:"quiesceWorkloads,omitempty"`
+	Reinstall                   bool `json:"reinstall,omitempty"`
 	SkipStorageOSCluster        bool `json:"skipStorageOSCluster,omitempty"`
 	IncludeEtcd                 bool `json:"includeEtcd,omitempty"`
 	IncludeLocalPathProvisioner bool `json:"includeLocalPathProvisioner,omitempty"`
 	Verbose                     bool `json:"verbose,omitempty"`
 
+	// Channel selects the releases the latest versions are looked up from: stable, prerelease
+	// or develop
+	Channel string `json:"channel,omitempty"`
+
 	// INSERT ADDITIONAL SPEC FIELDS - desired state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
 	Install   Install   `json:"install,omitempty"`
 	Uninstall Uninstall `json:"uninstall,omitempty"`
+	Backup    Backup    `json:"backup,omitempty"`
 }
 
 // GetOperatorNamespace tries to figure out operator namespace
@@ -97,9 +104,22 @@ type Install struct {
 	PortalSecret                    string `json:"portalSecret,omitempty"`
 	PortalTenantID                  string `json:"portalTenantID,omitempty"`
 	PortalAPIURL                    string `json:"portalAPIURL,omitempty"`
+	SkipPortalCredentialsValidation bool   `json:"skipPortalCredentialsValidation,omitempty"`
+	AllowInsecurePortalURL          bool   `json:"allowInsecurePortalURL,omitempty"`
+	SkipCompatibilityCheck          bool   `json:"skipCompatibilityCheck,omitempty"`
 	LocalPathProvisionerYaml        string `json:"localPathProvisionerYaml,omitempty"`
 	EnableMetrics                   *bool  `json:"enableMetrics,omitempty"`
 	MarkTestCluster                 bool   `json:"markTestCluster,omitempty"`
+	EtcdShellImage                  string `json:"etcdShellImage,omitempty"`
+	EtcdShellImagePullSecrets       string `json:"etcdShellImagePullSecrets,omitempty"`
+	EtcdShellTolerations            string `json:"etcdShellTolerations,omitempty"`
+	EtcdShellNodeSelector           string `json:"etcdShellNodeSelector,omitempty"`
+	EtcdShellRunAsUser              string `json:"etcdShellRunAsUser,omitempty"`
+	EtcdShellRunAsGroup             string `json:"etcdShellRunAsGroup,omitempty"`
+	EtcdShellFSGroup                string `json:"etcdShellFSGroup,omitempty"`
+	EtcdShellRunAsNonRoot           bool   `json:"etcdShellRunAsNonRoot,omitempty"`
+	EtcdShellSeccompProfile         string `json:"etcdShellSeccompProfile,omitempty"`
+	PortalShellImage                string `json:"portalShellImage,omitempty"`
 }
 
 // Uninstall defines options for cli uninstall subcommand
@@ -114,6 +134,14 @@ type Uninstall struct {
 	EtcdOperatorYaml                string `json:"etcdOperatorYaml,omitempty"`
 	EtcdClusterYaml                 string `json:"etcdClusterYaml,omitempty"`
 	LocalPathProvisionerYaml        string `json:"localPathProvisionerYaml,omitempty"`
+	RetainVolumes                   bool   `json:"retainVolumes,omitempty"`
+}
+
+// Backup defines options for the encryption of secrets in local backups
+type Backup struct {
+	PassphraseFile string `json:"passphraseFile,omitempty"`
+	Recipient      string `json:"recipient,omitempty"`
+	IdentityFile   string `json:"identityFile,omitempty"`
 }
 
 type InstallerMeta struct {
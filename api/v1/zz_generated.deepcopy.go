@@ -25,6 +25,21 @@ import (
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Backup) DeepCopyInto(out *Backup) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Backup.
+func (in *Backup) DeepCopy() *Backup {
+	if in == nil {
+		return nil
+	}
+	out := new(Backup)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Install) DeepCopyInto(out *Install) {
 	*out = *in
@@ -125,6 +140,7 @@ func (in *KubectlStorageOSConfigSpec) DeepCopyInto(out *KubectlStorageOSConfigSp
 	*out = *in
 	in.Install.DeepCopyInto(&out.Install)
 	out.Uninstall = in.Uninstall
+	out.Backup = in.Backup
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KubectlStorageOSConfigSpec.
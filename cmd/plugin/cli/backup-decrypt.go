@@ -0,0 +1,63 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	apiv1 "github.com/storageos/kubectl-storageos/api/v1"
+	"github.com/storageos/kubectl-storageos/pkg/installer"
+	"github.com/storageos/kubectl-storageos/pkg/logger"
+	pluginutils "github.com/storageos/kubectl-storageos/pkg/utils"
+)
+
+const backupDecrypt = "decrypt"
+
+func BackupDecryptCmd() *cobra.Command {
+	var err error
+	var traceError bool
+	var output string
+	pluginLogger := logger.NewLogger()
+	cmd := &cobra.Command{
+		Use:          backupDecrypt + " [name]",
+		Args:         cobra.MaximumNArgs(1),
+		Short:        "Decrypt the secrets of a backup",
+		Long:         `Decrypt the encrypted secrets of a backup, the latest if no name is given, for manual recovery. The secrets are printed as a single manifest unless --output is set.`,
+		SilenceUsage: true,
+		Run: func(cmd *cobra.Command, args []string) {
+			defer pluginutils.ConvertPanicToError(func(e error) {
+				err = e
+			})
+
+			config := &apiv1.KubectlStorageOSConfig{}
+			if err = setBackupValues(cmd, config); err != nil {
+				return
+			}
+			setBackupEncryptionValues(cmd, config)
+
+			traceError = config.Spec.StackTrace
+
+			err = backupDecryptCmd(config, backupNameArg(args), output, pluginLogger)
+		},
+		PostRunE: func(cmd *cobra.Command, args []string) error {
+			if err := pluginutils.HandleError(backupDecrypt, err, traceError); err != nil {
+				pluginLogger.Error(fmt.Sprintf("%s%s", backupDecrypt, " has failed"))
+				return err
+			}
+			return nil
+		},
+	}
+	addBackupFlags(cmd)
+	addBackupEncryptionFlags(cmd)
+	cmd.Flags().StringVarP(&output, installer.OutputFlag, "o", "", "directory to write the decrypted files to")
+
+	return cmd
+}
+
+func backupDecryptCmd(config *apiv1.KubectlStorageOSConfig, name, output string, log *logger.Logger) error {
+	cliInstaller, err := newBackupInstaller(config, log)
+	if err != nil {
+		return err
+	}
+
+	return cliInstaller.DecryptBackup(name, output)
+}
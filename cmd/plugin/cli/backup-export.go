@@ -0,0 +1,61 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	apiv1 "github.com/storageos/kubectl-storageos/api/v1"
+	"github.com/storageos/kubectl-storageos/pkg/installer"
+	"github.com/storageos/kubectl-storageos/pkg/logger"
+	pluginutils "github.com/storageos/kubectl-storageos/pkg/utils"
+)
+
+const backupExport = "export"
+
+func BackupExportCmd() *cobra.Command {
+	var err error
+	var traceError bool
+	var output string
+	pluginLogger := logger.NewLogger()
+	cmd := &cobra.Command{
+		Use:          backupExport + " [name]",
+		Args:         cobra.MaximumNArgs(1),
+		Short:        "Export a backup to a tarball",
+		Long:         `Export a backup, the latest if no name is given, to a gzipped tarball`,
+		SilenceUsage: true,
+		Run: func(cmd *cobra.Command, args []string) {
+			defer pluginutils.ConvertPanicToError(func(e error) {
+				err = e
+			})
+
+			config := &apiv1.KubectlStorageOSConfig{}
+			if err = setBackupValues(cmd, config); err != nil {
+				return
+			}
+
+			traceError = config.Spec.StackTrace
+
+			err = backupExportCmd(config, backupNameArg(args), output, pluginLogger)
+		},
+		PostRunE: func(cmd *cobra.Command, args []string) error {
+			if err := pluginutils.HandleError(backupExport, err, traceError); err != nil {
+				pluginLogger.Error(fmt.Sprintf("%s%s", backupExport, " has failed"))
+				return err
+			}
+			return nil
+		},
+	}
+	addBackupFlags(cmd)
+	cmd.Flags().StringVarP(&output, installer.OutputFlag, "o", "", "path of the tarball, defaults to storageos-backup-<cluster-id>-<name>.tar.gz in the working directory")
+
+	return cmd
+}
+
+func backupExportCmd(config *apiv1.KubectlStorageOSConfig, name, output string, log *logger.Logger) error {
+	cliInstaller, err := newBackupInstaller(config, log)
+	if err != nil {
+		return err
+	}
+
+	return cliInstaller.ExportBackup(name, output)
+}
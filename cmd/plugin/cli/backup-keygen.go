@@ -0,0 +1,57 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	apiv1 "github.com/storageos/kubectl-storageos/api/v1"
+	"github.com/storageos/kubectl-storageos/pkg/installer"
+	"github.com/storageos/kubectl-storageos/pkg/logger"
+	pluginutils "github.com/storageos/kubectl-storageos/pkg/utils"
+)
+
+const (
+	backupKeygen = "keygen"
+
+	defaultBackupKeyFile = "storageos-backup.key"
+)
+
+func BackupKeygenCmd() *cobra.Command {
+	var err error
+	var traceError bool
+	var output string
+	pluginLogger := logger.NewLogger()
+	cmd := &cobra.Command{
+		Use:          backupKeygen,
+		Args:         cobra.MinimumNArgs(0),
+		Short:        "Generate a key pair to encrypt backups",
+		Long:         `Generate an X25519 key pair to encrypt the secrets of backups. The private key is written to --output and the public key is printed, to be passed to --backup-recipient.`,
+		SilenceUsage: true,
+		Run: func(cmd *cobra.Command, args []string) {
+			defer pluginutils.ConvertPanicToError(func(e error) {
+				err = e
+			})
+
+			config := &apiv1.KubectlStorageOSConfig{}
+			if err = setBackupValues(cmd, config); err != nil {
+				return
+			}
+
+			traceError = config.Spec.StackTrace
+
+			pluginLogger.Verbose = config.Spec.Verbose
+			err = installer.GenerateBackupKey(output, pluginLogger)
+		},
+		PostRunE: func(cmd *cobra.Command, args []string) error {
+			if err := pluginutils.HandleError(backupKeygen, err, traceError); err != nil {
+				pluginLogger.Error(fmt.Sprintf("%s%s", backupKeygen, " has failed"))
+				return err
+			}
+			return nil
+		},
+	}
+	addBackupFlags(cmd)
+	cmd.Flags().StringVarP(&output, installer.OutputFlag, "o", defaultBackupKeyFile, "path to write the private key to")
+
+	return cmd
+}
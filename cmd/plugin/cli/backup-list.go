@@ -0,0 +1,58 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	apiv1 "github.com/storageos/kubectl-storageos/api/v1"
+	"github.com/storageos/kubectl-storageos/pkg/logger"
+	pluginutils "github.com/storageos/kubectl-storageos/pkg/utils"
+)
+
+const backupList = "list"
+
+func BackupListCmd() *cobra.Command {
+	var err error
+	var traceError bool
+	pluginLogger := logger.NewLogger()
+	cmd := &cobra.Command{
+		Use:          backupList,
+		Args:         cobra.NoArgs,
+		Short:        "List backups of the current cluster",
+		Long:         `List the backups of the current cluster, oldest first`,
+		SilenceUsage: true,
+		Run: func(cmd *cobra.Command, args []string) {
+			defer pluginutils.ConvertPanicToError(func(e error) {
+				err = e
+			})
+
+			config := &apiv1.KubectlStorageOSConfig{}
+			if err = setBackupValues(cmd, config); err != nil {
+				return
+			}
+
+			traceError = config.Spec.StackTrace
+
+			err = backupListCmd(config, pluginLogger)
+		},
+		PostRunE: func(cmd *cobra.Command, args []string) error {
+			if err := pluginutils.HandleError(backupList, err, traceError); err != nil {
+				pluginLogger.Error(fmt.Sprintf("%s%s", backupList, " has failed"))
+				return err
+			}
+			return nil
+		},
+	}
+	addBackupFlags(cmd)
+
+	return cmd
+}
+
+func backupListCmd(config *apiv1.KubectlStorageOSConfig, log *logger.Logger) error {
+	cliInstaller, err := newBackupInstaller(config, log)
+	if err != nil {
+		return err
+	}
+
+	return cliInstaller.ListBackups()
+}
@@ -0,0 +1,66 @@
+package cli
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	apiv1 "github.com/storageos/kubectl-storageos/api/v1"
+	"github.com/storageos/kubectl-storageos/pkg/installer"
+	"github.com/storageos/kubectl-storageos/pkg/logger"
+	pluginutils "github.com/storageos/kubectl-storageos/pkg/utils"
+)
+
+const backupPrune = "prune"
+
+func BackupPruneCmd() *cobra.Command {
+	var err error
+	var traceError bool
+	var keep int
+	var olderThan time.Duration
+	var dryRun bool
+	pluginLogger := logger.NewLogger()
+	cmd := &cobra.Command{
+		Use:          backupPrune,
+		Args:         cobra.NoArgs,
+		Short:        "Delete old backups",
+		Long:         `Delete backups beyond the most recent --keep or older than --older-than. The latest backup is never deleted, as upgrade reads from it.`,
+		SilenceUsage: true,
+		Run: func(cmd *cobra.Command, args []string) {
+			defer pluginutils.ConvertPanicToError(func(e error) {
+				err = e
+			})
+
+			config := &apiv1.KubectlStorageOSConfig{}
+			if err = setBackupValues(cmd, config); err != nil {
+				return
+			}
+
+			traceError = config.Spec.StackTrace
+
+			err = backupPruneCmd(config, keep, olderThan, dryRun, pluginLogger)
+		},
+		PostRunE: func(cmd *cobra.Command, args []string) error {
+			if err := pluginutils.HandleError(backupPrune, err, traceError); err != nil {
+				pluginLogger.Error(fmt.Sprintf("%s%s", backupPrune, " has failed"))
+				return err
+			}
+			return nil
+		},
+	}
+	addBackupFlags(cmd)
+	cmd.Flags().IntVar(&keep, installer.KeepFlag, 0, "number of most recent backups to keep")
+	cmd.Flags().DurationVar(&olderThan, installer.OlderThanFlag, 0, "delete backups older than this duration, eg. 720h")
+	cmd.Flags().BoolVar(&dryRun, installer.DryRunFlag, false, "print the backups to be deleted without deleting them")
+
+	return cmd
+}
+
+func backupPruneCmd(config *apiv1.KubectlStorageOSConfig, keep int, olderThan time.Duration, dryRun bool, log *logger.Logger) error {
+	cliInstaller, err := newBackupInstaller(config, log)
+	if err != nil {
+		return err
+	}
+
+	return cliInstaller.PruneBackups(keep, olderThan, dryRun)
+}
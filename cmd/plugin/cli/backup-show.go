@@ -0,0 +1,58 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	apiv1 "github.com/storageos/kubectl-storageos/api/v1"
+	"github.com/storageos/kubectl-storageos/pkg/logger"
+	pluginutils "github.com/storageos/kubectl-storageos/pkg/utils"
+)
+
+const backupShow = "show"
+
+func BackupShowCmd() *cobra.Command {
+	var err error
+	var traceError bool
+	pluginLogger := logger.NewLogger()
+	cmd := &cobra.Command{
+		Use:          backupShow + " [name]",
+		Args:         cobra.MaximumNArgs(1),
+		Short:        "Show a backup and verify its checksums",
+		Long:         `Show the metadata of a backup, the latest if no name is given, and verify the checksum of each of its files`,
+		SilenceUsage: true,
+		Run: func(cmd *cobra.Command, args []string) {
+			defer pluginutils.ConvertPanicToError(func(e error) {
+				err = e
+			})
+
+			config := &apiv1.KubectlStorageOSConfig{}
+			if err = setBackupValues(cmd, config); err != nil {
+				return
+			}
+
+			traceError = config.Spec.StackTrace
+
+			err = backupShowCmd(config, backupNameArg(args), pluginLogger)
+		},
+		PostRunE: func(cmd *cobra.Command, args []string) error {
+			if err := pluginutils.HandleError(backupShow, err, traceError); err != nil {
+				pluginLogger.Error(fmt.Sprintf("%s%s", backupShow, " has failed"))
+				return err
+			}
+			return nil
+		},
+	}
+	addBackupFlags(cmd)
+
+	return cmd
+}
+
+func backupShowCmd(config *apiv1.KubectlStorageOSConfig, name string, log *logger.Logger) error {
+	cliInstaller, err := newBackupInstaller(config, log)
+	if err != nil {
+		return err
+	}
+
+	return cliInstaller.ShowBackup(name)
+}
@@ -0,0 +1,87 @@
+package cli
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	apiv1 "github.com/storageos/kubectl-storageos/api/v1"
+	"github.com/storageos/kubectl-storageos/pkg/installer"
+	"github.com/storageos/kubectl-storageos/pkg/logger"
+)
+
+const backup = "backup"
+
+func BackupCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:          backup,
+		Args:         cobra.MinimumNArgs(0),
+		Short:        "Manage local backups of StorageOS",
+		Long:         `Manage the backups of StorageOS manifests written to $HOME/.kube/storageos before uninstall and upgrade`,
+		SilenceUsage: true,
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Help()
+		},
+	}
+
+	cmd.AddCommand(BackupListCmd())
+	cmd.AddCommand(BackupShowCmd())
+	cmd.AddCommand(BackupPruneCmd())
+	cmd.AddCommand(BackupExportCmd())
+	cmd.AddCommand(BackupDecryptCmd())
+	cmd.AddCommand(BackupKeygenCmd())
+
+	return cmd
+}
+
+// addBackupFlags adds the flags shared by the backup subcommands
+func addBackupFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool(installer.StackTraceFlag, false, "print stack trace of error")
+	cmd.Flags().BoolP(installer.VerboseFlag, "v", false, "verbose logging")
+}
+
+// setBackupValues sets the values of the flags added by addBackupFlags in config
+func setBackupValues(cmd *cobra.Command, config *apiv1.KubectlStorageOSConfig) error {
+	var err error
+	config.Spec.StackTrace, err = cmd.Flags().GetBool(installer.StackTraceFlag)
+	if err != nil {
+		return err
+	}
+	config.Spec.Verbose, err = cmd.Flags().GetBool(installer.VerboseFlag)
+
+	return err
+}
+
+// addBackupEncryptionFlags adds the flags of the keys encrypting and decrypting the secrets of backups
+func addBackupEncryptionFlags(cmd *cobra.Command) {
+	cmd.Flags().String(installer.BackupPassphraseFileFlag, "", "path of a file holding the passphrase encrypting the secrets of backups")
+	cmd.Flags().String(installer.BackupRecipientFlag, "", "base64 X25519 public key to encrypt the secrets of backups to, see backup keygen")
+	cmd.Flags().String(installer.BackupIdentityFlag, "", "path of the X25519 private key file decrypting the secrets of backups")
+}
+
+// setBackupEncryptionValues sets the values of the flags added by addBackupEncryptionFlags in config
+func setBackupEncryptionValues(cmd *cobra.Command, config *apiv1.KubectlStorageOSConfig) {
+	config.Spec.Backup.PassphraseFile = cmd.Flags().Lookup(installer.BackupPassphraseFileFlag).Value.String()
+	config.Spec.Backup.Recipient = cmd.Flags().Lookup(installer.BackupRecipientFlag).Value.String()
+	config.Spec.Backup.IdentityFile = cmd.Flags().Lookup(installer.BackupIdentityFlag).Value.String()
+}
+
+// setBackupEncryptionConfigValues sets the backup encryption values of the config file in config
+func setBackupEncryptionConfigValues(config *apiv1.KubectlStorageOSConfig) {
+	config.Spec.Backup.PassphraseFile = viper.GetString(installer.BackupPassphraseFileConfig)
+	config.Spec.Backup.Recipient = viper.GetString(installer.BackupRecipientConfig)
+	config.Spec.Backup.IdentityFile = viper.GetString(installer.BackupIdentityConfig)
+}
+
+// newBackupInstaller returns the installer of the backup subcommands
+func newBackupInstaller(config *apiv1.KubectlStorageOSConfig, log *logger.Logger) (*installer.Installer, error) {
+	log.Verbose = config.Spec.Verbose
+
+	return installer.NewBackupInstaller(config, log)
+}
+
+// backupNameArg returns the backup name of args, defaulting to the latest backup
+func backupNameArg(args []string) string {
+	if len(args) == 0 {
+		return "latest"
+	}
+	return args[0]
+}
@@ -0,0 +1,83 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	apiv1 "github.com/storageos/kubectl-storageos/api/v1"
+	"github.com/storageos/kubectl-storageos/pkg/installer"
+	"github.com/storageos/kubectl-storageos/pkg/logger"
+	pluginutils "github.com/storageos/kubectl-storageos/pkg/utils"
+)
+
+const cleanup = "cleanup"
+
+func CleanupCmd() *cobra.Command {
+	var err error
+	var traceError bool
+	var yes bool
+	pluginLogger := logger.NewLogger()
+	cmd := &cobra.Command{
+		Use:          cleanup,
+		Args:         cobra.NoArgs,
+		Short:        "Remove StorageOS cluster-scoped resources left behind",
+		Long:         `Discover the cluster-scoped resources of StorageOS left behind by a failed uninstall, such as CRDs, CSI drivers, webhooks, cluster roles and bindings, priority classes and storage classes provisioned by csi.storageos.com. They are found by label, provisioner and name across all API groups, listed and deleted once confirmed. Persistent volumes are never deleted.`,
+		SilenceUsage: true,
+		Run: func(cmd *cobra.Command, args []string) {
+			defer pluginutils.ConvertPanicToError(func(e error) {
+				err = e
+			})
+
+			config := &apiv1.KubectlStorageOSConfig{}
+			if err = setCleanupValues(cmd, config); err != nil {
+				return
+			}
+
+			traceError = config.Spec.StackTrace
+
+			err = cleanupCmd(config, yes, pluginLogger)
+		},
+		PostRunE: func(cmd *cobra.Command, args []string) error {
+			if err := pluginutils.HandleError(cleanup, err, traceError); err != nil {
+				pluginLogger.Error(fmt.Sprintf("%s%s", cleanup, " has failed"))
+				return err
+			}
+			return nil
+		},
+	}
+	cmd.Flags().Bool(installer.StackTraceFlag, false, "print stack trace of error")
+	cmd.Flags().BoolP(installer.VerboseFlag, "v", false, "verbose logging")
+	cmd.Flags().BoolVarP(&yes, installer.YesFlag, "y", false, "delete the resources found without confirmation")
+
+	return cmd
+}
+
+func cleanupCmd(config *apiv1.KubectlStorageOSConfig, yes bool, log *logger.Logger) error {
+	log.Verbose = config.Spec.Verbose
+
+	cliInstaller, err := installer.NewCleanupInstaller(config, log)
+	if err != nil {
+		return err
+	}
+
+	return cliInstaller.Cleanup(func() (bool, error) {
+		if yes {
+			return true, nil
+		}
+		return cleanupPrompt(log)
+	})
+}
+
+func setCleanupValues(cmd *cobra.Command, config *apiv1.KubectlStorageOSConfig) error {
+	var err error
+	config.Spec.StackTrace, err = cmd.Flags().GetBool(installer.StackTraceFlag)
+	if err != nil {
+		return err
+	}
+	config.Spec.Verbose, err = cmd.Flags().GetBool(installer.VerboseFlag)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
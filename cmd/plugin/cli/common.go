@@ -9,6 +9,8 @@ import (
 	"github.com/pkg/errors"
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
+	apiv1 "github.com/storageos/kubectl-storageos/api/v1"
+	"github.com/storageos/kubectl-storageos/pkg/installer"
 	"github.com/storageos/kubectl-storageos/pkg/logger"
 	pluginutils "github.com/storageos/kubectl-storageos/pkg/utils"
 	"github.com/storageos/kubectl-storageos/pkg/version"
@@ -42,6 +44,25 @@ func skipNamespaceDeletionPrompt(log *logger.Logger) (bool, error) {
 	log.Warn("Protected namespaces (default, kube-system, kube-node-lease, kube-public) cannot be deleted by kubectl-storageos.")
 	log.Prompt("Please confirm namespace deletion.")
 
+	return yesNoPrompt("Skip namespace deletion [y/N]", log)
+}
+
+// cleanupPrompt uses promptui to prompt the user to confirm the deletion of the resources found by cleanup
+func cleanupPrompt(log *logger.Logger) (bool, error) {
+	log.Prompt("Please confirm deletion of the resources above.")
+
+	return yesNoPrompt("Delete resources [y/N]", log)
+}
+
+// rollbackPrompt uses promptui to prompt the user to confirm the rollback announced above
+func rollbackPrompt(log *logger.Logger) (bool, error) {
+	log.Prompt("Please confirm the rollback, the installed StorageOS will be uninstalled.")
+
+	return yesNoPrompt("Roll back [y/N]", log)
+}
+
+// yesNoPrompt uses promptui to prompt the user to answer label with yes or no, defaulting to no
+func yesNoPrompt(label string, log *logger.Logger) (bool, error) {
 	yesValues := map[string]bool{
 		"y":   true,
 		"yes": true,
@@ -64,7 +85,7 @@ func skipNamespaceDeletionPrompt(log *logger.Logger) (bool, error) {
 		return nil
 	}
 	prompt := promptui.Prompt{
-		Label:    "Skip namespace deletion [y/N]",
+		Label:    label,
 		Validate: validate,
 	}
 
@@ -140,6 +161,43 @@ func versionSupportsFeature(existingOperatorVersion, featureFirstSupportedVersio
 	return nil
 }
 
+// validatePortalCredentials checks that the portal credentials are set and, unless skipped, that the
+// portal issues a token for them
+func validatePortalCredentials(configInstall apiv1.Install, log *logger.Logger) error {
+	if err := installer.FlagsAreSet(map[string]string{
+		installer.PortalClientIDFlag: configInstall.PortalClientID,
+		installer.PortalSecretFlag:   configInstall.PortalSecret,
+		installer.PortalTenantIDFlag: configInstall.PortalTenantID,
+		installer.PortalAPIURLFlag:   configInstall.PortalAPIURL,
+	}); err != nil {
+		return err
+	}
+	if configInstall.SkipPortalCredentialsValidation {
+		return nil
+	}
+
+	if err := installer.CheckPortalCredentials(configInstall, log); err != nil {
+		return fmt.Errorf("%v\n\n\tPortal manager has not been installed.", err)
+	}
+
+	return nil
+}
+
+// setReleaseChannel selects the release channel the latest versions are looked up from, an empty
+// name keeps the default channel
+func setReleaseChannel(name string) error {
+	if name == "" {
+		return nil
+	}
+	channel, err := version.ParseChannel(name)
+	if err != nil {
+		return err
+	}
+	version.SetReleaseChannel(channel)
+
+	return nil
+}
+
 func validateResourceLimit(resourceLimit string) error {
 	_, err := resource.ParseQuantity(resourceLimit)
 	if err != nil {
@@ -0,0 +1,115 @@
+package cli
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	apiv1 "github.com/storageos/kubectl-storageos/api/v1"
+	"github.com/storageos/kubectl-storageos/pkg/consts"
+	"github.com/storageos/kubectl-storageos/pkg/installer"
+	"github.com/storageos/kubectl-storageos/pkg/logger"
+	pluginutils "github.com/storageos/kubectl-storageos/pkg/utils"
+)
+
+const (
+	etcdCreateSecret = "create-secret"
+
+	defaultCertValidity = 365 * 24 * time.Hour
+)
+
+func EtcdCreateSecretCmd() *cobra.Command {
+	var err error
+	var traceError bool
+	pluginLogger := logger.NewLogger()
+	cmd := &cobra.Command{
+		Use:          etcdCreateSecret,
+		Args:         cobra.MinimumNArgs(0),
+		Short:        "Create the ETCD TLS client secret",
+		Long:         `Create the ETCD TLS client secret from existing CA, certificate and key files, or from a generated self-signed CA and client certificate`,
+		SilenceUsage: true,
+		PreRun:       func(cmd *cobra.Command, args []string) {},
+		Run: func(cmd *cobra.Command, args []string) {
+			defer pluginutils.ConvertPanicToError(func(e error) {
+				err = e
+			})
+
+			config := &apiv1.KubectlStorageOSConfig{}
+			opts := installer.EtcdSecretOptions{}
+			if err = setEtcdCreateSecretValues(cmd, config, &opts); err != nil {
+				return
+			}
+
+			traceError = config.Spec.StackTrace
+
+			err = etcdCreateSecretCmd(config, opts, pluginLogger)
+		},
+		PostRunE: func(cmd *cobra.Command, args []string) error {
+			if err := pluginutils.HandleError(etcdCreateSecret, err, traceError); err != nil {
+				pluginLogger.Error(fmt.Sprintf("%s%s", etcdCreateSecret, " has failed"))
+				return err
+			}
+			pluginLogger.Success("ETCD secret created successfully.")
+			return nil
+		},
+	}
+	cmd.Flags().Bool(installer.StackTraceFlag, false, "print stack trace of error")
+	cmd.Flags().BoolP(installer.VerboseFlag, "v", false, "verbose logging")
+	cmd.Flags().String(installer.EtcdSecretNameFlag, consts.EtcdSecretName, "name of etcd secret to be created")
+	cmd.Flags().String(installer.StosClusterNSFlag, consts.NewOperatorNamespace, "namespace of storageos cluster")
+	cmd.Flags().String(installer.EtcdCACertFlag, "", "path to the CA certificate of the etcd cluster")
+	cmd.Flags().String(installer.EtcdClientCertFlag, "", "path to the etcd client certificate")
+	cmd.Flags().String(installer.EtcdClientKeyFlag, "", "path to the etcd client key")
+	cmd.Flags().Bool(installer.GenerateCertsFlag, false, "generate a self-signed CA and client certificate instead of reading existing files")
+	cmd.Flags().Duration(installer.CertValidityFlag, defaultCertValidity, "validity of generated certificates")
+	cmd.Flags().String(installer.CertOutputDirFlag, "", "directory to write generated certificates and keys to")
+
+	return cmd
+}
+
+func etcdCreateSecretCmd(config *apiv1.KubectlStorageOSConfig, opts installer.EtcdSecretOptions, log *logger.Logger) error {
+	log.Verbose = config.Spec.Verbose
+
+	// the generated CA key is never stored in-cluster, so without it the etcd server certificates
+	// could not be signed by the CA that storageos trusts
+	if opts.Generate && opts.OutputDir == "" {
+		return fmt.Errorf("--%s must be set when --%s is set", installer.CertOutputDirFlag, installer.GenerateCertsFlag)
+	}
+
+	cliInstaller, err := installer.NewEtcdInstaller(config, log)
+	if err != nil {
+		return err
+	}
+
+	log.Commencing(etcdCreateSecret)
+	return cliInstaller.CreateEtcdSecret(opts)
+}
+
+func setEtcdCreateSecretValues(cmd *cobra.Command, config *apiv1.KubectlStorageOSConfig, opts *installer.EtcdSecretOptions) error {
+	var err error
+	config.Spec.StackTrace, err = cmd.Flags().GetBool(installer.StackTraceFlag)
+	if err != nil {
+		return err
+	}
+	config.Spec.Verbose, err = cmd.Flags().GetBool(installer.VerboseFlag)
+	if err != nil {
+		return err
+	}
+	opts.Generate, err = cmd.Flags().GetBool(installer.GenerateCertsFlag)
+	if err != nil {
+		return err
+	}
+	opts.Validity, err = cmd.Flags().GetDuration(installer.CertValidityFlag)
+	if err != nil {
+		return err
+	}
+	opts.Name = cmd.Flags().Lookup(installer.EtcdSecretNameFlag).Value.String()
+	opts.Namespace = cmd.Flags().Lookup(installer.StosClusterNSFlag).Value.String()
+	opts.CACertPath = cmd.Flags().Lookup(installer.EtcdCACertFlag).Value.String()
+	opts.CertPath = cmd.Flags().Lookup(installer.EtcdClientCertFlag).Value.String()
+	opts.KeyPath = cmd.Flags().Lookup(installer.EtcdClientKeyFlag).Value.String()
+	opts.OutputDir = cmd.Flags().Lookup(installer.CertOutputDirFlag).Value.String()
+	config.Spec.Install.StorageOSClusterNamespace = opts.Namespace
+
+	return nil
+}
@@ -0,0 +1,82 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	apiv1 "github.com/storageos/kubectl-storageos/api/v1"
+	"github.com/storageos/kubectl-storageos/pkg/installer"
+	"github.com/storageos/kubectl-storageos/pkg/logger"
+	pluginutils "github.com/storageos/kubectl-storageos/pkg/utils"
+)
+
+const etcdMaintain = "maintain"
+
+func EtcdMaintainCmd() *cobra.Command {
+	var err error
+	var traceError bool
+	var clearAlarms bool
+	pluginLogger := logger.NewLogger()
+	cmd := &cobra.Command{
+		Use:          etcdMaintain,
+		Args:         cobra.MinimumNArgs(0),
+		Short:        "Compact and defragment the ETCD cluster",
+		Long:         `Compact the ETCD key space to the current revision and defragment members one at a time, checking the health of each member before moving on to the next. Either --etcd-endpoints or the ETCD cluster installed with --include-etcd is maintained.`,
+		SilenceUsage: true,
+		PreRun:       func(cmd *cobra.Command, args []string) {},
+		Run: func(cmd *cobra.Command, args []string) {
+			defer pluginutils.ConvertPanicToError(func(e error) {
+				err = e
+			})
+
+			config := &apiv1.KubectlStorageOSConfig{}
+			if clearAlarms, err = setEtcdMaintainValues(cmd, config); err != nil {
+				return
+			}
+
+			traceError = config.Spec.StackTrace
+
+			err = etcdMaintainCmd(config, clearAlarms, pluginLogger)
+		},
+		PostRunE: func(cmd *cobra.Command, args []string) error {
+			if err := pluginutils.HandleError(etcdMaintain, err, traceError); err != nil {
+				pluginLogger.Error(fmt.Sprintf("%s%s", etcdMaintain, " has failed"))
+				return err
+			}
+			pluginLogger.Success("ETCD maintenance completed successfully.")
+			return nil
+		},
+	}
+	addEtcdClusterFlags(cmd)
+	cmd.Flags().String(installer.EtcdEndpointsFlag, "", "endpoints of etcd members to maintain, defaults to the members of the etcd cluster in --etcd-namespace")
+	cmd.Flags().Bool(installer.EtcdTLSEnabledFlag, false, "etcd endpoints are tls enabled")
+	cmd.Flags().Bool(installer.ClearAlarmsFlag, false, "clear the NOSPACE alarm once space has been reclaimed")
+
+	return cmd
+}
+
+func etcdMaintainCmd(config *apiv1.KubectlStorageOSConfig, clearAlarms bool, log *logger.Logger) error {
+	log.Verbose = config.Spec.Verbose
+
+	cliInstaller, err := installer.NewEtcdInstaller(config, log)
+	if err != nil {
+		return err
+	}
+
+	log.Commencing(etcdMaintain)
+	return cliInstaller.MaintainEtcd(clearAlarms)
+}
+
+func setEtcdMaintainValues(cmd *cobra.Command, config *apiv1.KubectlStorageOSConfig) (bool, error) {
+	if err := setEtcdClusterValues(cmd, config); err != nil {
+		return false, err
+	}
+	var err error
+	config.Spec.Install.EtcdTLSEnabled, err = cmd.Flags().GetBool(installer.EtcdTLSEnabledFlag)
+	if err != nil {
+		return false, err
+	}
+	config.Spec.Install.EtcdEndpoints = cmd.Flags().Lookup(installer.EtcdEndpointsFlag).Value.String()
+
+	return cmd.Flags().GetBool(installer.ClearAlarmsFlag)
+}
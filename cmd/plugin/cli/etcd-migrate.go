@@ -0,0 +1,102 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	apiv1 "github.com/storageos/kubectl-storageos/api/v1"
+	"github.com/storageos/kubectl-storageos/pkg/consts"
+	"github.com/storageos/kubectl-storageos/pkg/installer"
+	"github.com/storageos/kubectl-storageos/pkg/logger"
+	pluginutils "github.com/storageos/kubectl-storageos/pkg/utils"
+)
+
+const etcdMigrate = "migrate"
+
+func EtcdMigrateCmd() *cobra.Command {
+	var err error
+	var traceError bool
+	pluginLogger := logger.NewLogger()
+	cmd := &cobra.Command{
+		Use:          etcdMigrate,
+		Args:         cobra.MinimumNArgs(0),
+		Short:        "Migrate StorageOS to another ETCD cluster",
+		Long:         `Migrate StorageOS to the empty ETCD cluster of --to-endpoints. StorageOS is stopped, the current ETCD is snapshotted and its keys are copied into the target ETCD, the StorageOS cluster is switched to the target endpoints and TLS secret and started. If any step fails, the migration is rolled back.`,
+		SilenceUsage: true,
+		PreRun:       func(cmd *cobra.Command, args []string) {},
+		Run: func(cmd *cobra.Command, args []string) {
+			defer pluginutils.ConvertPanicToError(func(e error) {
+				err = e
+			})
+
+			config := &apiv1.KubectlStorageOSConfig{}
+			if err = setEtcdMigrateValues(cmd, config); err != nil {
+				return
+			}
+
+			traceError = config.Spec.StackTrace
+
+			err = etcdMigrateCmd(config, pluginLogger)
+		},
+		PostRunE: func(cmd *cobra.Command, args []string) error {
+			if err := pluginutils.HandleError(etcdMigrate, err, traceError); err != nil {
+				pluginLogger.Error(fmt.Sprintf("%s%s", etcdMigrate, " has failed"))
+				return err
+			}
+			pluginLogger.Success("ETCD migrated successfully.")
+			return nil
+		},
+	}
+	cmd.Flags().Bool(installer.StackTraceFlag, false, "print stack trace of error")
+	cmd.Flags().BoolP(installer.VerboseFlag, "v", false, "verbose logging")
+	cmd.Flags().String(installer.ToEndpointsFlag, "", "endpoints of the empty etcd cluster to migrate to")
+	cmd.Flags().Bool(installer.EtcdTLSEnabledFlag, false, "etcd endpoints to migrate to are tls enabled")
+	cmd.Flags().String(installer.EtcdSecretNameFlag, consts.EtcdSecretName, "name of etcd secret of the endpoints to migrate to in storageos cluster namespace")
+	cmd.Flags().String(installer.StosOperatorNSFlag, consts.NewOperatorNamespace, "namespace of storageos operator")
+	cmd.Flags().Bool(installer.SkipEtcdEndpointsValFlag, false, "skip health check of the endpoints to migrate to")
+	cmd.Flags().Bool(installer.SkipExistingWorkloadCheckFlag, false, "skip check for workloads using storageos volumes")
+	addEtcdShellFlags(cmd)
+
+	return cmd
+}
+
+func etcdMigrateCmd(config *apiv1.KubectlStorageOSConfig, log *logger.Logger) error {
+	log.Verbose = config.Spec.Verbose
+
+	cliInstaller, err := installer.NewEtcdInstaller(config, log)
+	if err != nil {
+		return err
+	}
+
+	log.Commencing(etcdMigrate)
+	return cliInstaller.MigrateEtcd()
+}
+
+func setEtcdMigrateValues(cmd *cobra.Command, config *apiv1.KubectlStorageOSConfig) error {
+	var err error
+	config.Spec.StackTrace, err = cmd.Flags().GetBool(installer.StackTraceFlag)
+	if err != nil {
+		return err
+	}
+	config.Spec.Verbose, err = cmd.Flags().GetBool(installer.VerboseFlag)
+	if err != nil {
+		return err
+	}
+	config.Spec.Install.EtcdTLSEnabled, err = cmd.Flags().GetBool(installer.EtcdTLSEnabledFlag)
+	if err != nil {
+		return err
+	}
+	config.Spec.Install.SkipEtcdEndpointsValidation, err = cmd.Flags().GetBool(installer.SkipEtcdEndpointsValFlag)
+	if err != nil {
+		return err
+	}
+	config.Spec.SkipExistingWorkloadCheck, err = cmd.Flags().GetBool(installer.SkipExistingWorkloadCheckFlag)
+	if err != nil {
+		return err
+	}
+	config.Spec.Install.EtcdEndpoints = cmd.Flags().Lookup(installer.ToEndpointsFlag).Value.String()
+	config.Spec.Install.EtcdSecretName = cmd.Flags().Lookup(installer.EtcdSecretNameFlag).Value.String()
+	config.Spec.Install.StorageOSOperatorNamespace = cmd.Flags().Lookup(installer.StosOperatorNSFlag).Value.String()
+
+	return setEtcdShellValues(cmd, config)
+}
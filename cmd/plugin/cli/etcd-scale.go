@@ -0,0 +1,75 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	apiv1 "github.com/storageos/kubectl-storageos/api/v1"
+	"github.com/storageos/kubectl-storageos/pkg/installer"
+	"github.com/storageos/kubectl-storageos/pkg/logger"
+	pluginutils "github.com/storageos/kubectl-storageos/pkg/utils"
+)
+
+const etcdScale = "scale"
+
+func EtcdScaleCmd() *cobra.Command {
+	var err error
+	var traceError bool
+	pluginLogger := logger.NewLogger()
+	cmd := &cobra.Command{
+		Use:          etcdScale,
+		Args:         cobra.MinimumNArgs(0),
+		Short:        "Scale the ETCD cluster installed with --include-etcd",
+		Long:         `Scale the ETCD cluster installed with --include-etcd. Only quorum-safe transitions to an odd number of members are allowed, the health of all members is checked before and after scaling.`,
+		SilenceUsage: true,
+		PreRun:       func(cmd *cobra.Command, args []string) {},
+		Run: func(cmd *cobra.Command, args []string) {
+			defer pluginutils.ConvertPanicToError(func(e error) {
+				err = e
+			})
+
+			config := &apiv1.KubectlStorageOSConfig{}
+			if err = setEtcdScaleValues(cmd, config); err != nil {
+				return
+			}
+
+			traceError = config.Spec.StackTrace
+
+			err = etcdScaleCmd(config, pluginLogger)
+		},
+		PostRunE: func(cmd *cobra.Command, args []string) error {
+			if err := pluginutils.HandleError(etcdScale, err, traceError); err != nil {
+				pluginLogger.Error(fmt.Sprintf("%s%s", etcdScale, " has failed"))
+				return err
+			}
+			pluginLogger.Success("ETCD cluster scaled successfully.")
+			return nil
+		},
+	}
+	addEtcdClusterFlags(cmd)
+	cmd.Flags().String(installer.ReplicasFlag, "", "desired number of etcd members, must be odd")
+	cmd.MarkFlagRequired(installer.ReplicasFlag)
+
+	return cmd
+}
+
+func etcdScaleCmd(config *apiv1.KubectlStorageOSConfig, log *logger.Logger) error {
+	log.Verbose = config.Spec.Verbose
+
+	cliInstaller, err := installer.NewEtcdInstaller(config, log)
+	if err != nil {
+		return err
+	}
+
+	log.Commencing(etcdScale)
+	return cliInstaller.ScaleEtcdCluster()
+}
+
+func setEtcdScaleValues(cmd *cobra.Command, config *apiv1.KubectlStorageOSConfig) error {
+	if err := setEtcdClusterValues(cmd, config); err != nil {
+		return err
+	}
+	config.Spec.Install.EtcdReplicas = cmd.Flags().Lookup(installer.ReplicasFlag).Value.String()
+
+	return nil
+}
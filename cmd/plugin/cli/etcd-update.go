@@ -0,0 +1,89 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	apiv1 "github.com/storageos/kubectl-storageos/api/v1"
+	"github.com/storageos/kubectl-storageos/pkg/installer"
+	"github.com/storageos/kubectl-storageos/pkg/logger"
+	pluginutils "github.com/storageos/kubectl-storageos/pkg/utils"
+)
+
+const etcdUpdate = "update"
+
+func EtcdUpdateCmd() *cobra.Command {
+	var err error
+	var traceError bool
+	pluginLogger := logger.NewLogger()
+	cmd := &cobra.Command{
+		Use:          etcdUpdate,
+		Args:         cobra.MinimumNArgs(0),
+		Short:        "Update resources and topology of the ETCD cluster installed with --include-etcd",
+		Long:         `Update the cpu limit, memory limit and anti-affinity topology key of the ETCD cluster installed with --include-etcd. The health of all members is checked before and after the update.`,
+		SilenceUsage: true,
+		PreRun:       func(cmd *cobra.Command, args []string) {},
+		Run: func(cmd *cobra.Command, args []string) {
+			defer pluginutils.ConvertPanicToError(func(e error) {
+				err = e
+			})
+
+			config := &apiv1.KubectlStorageOSConfig{}
+			if err = setEtcdUpdateValues(cmd, config); err != nil {
+				return
+			}
+
+			traceError = config.Spec.StackTrace
+
+			err = etcdUpdateCmd(config, pluginLogger)
+		},
+		PostRunE: func(cmd *cobra.Command, args []string) error {
+			if err := pluginutils.HandleError(etcdUpdate, err, traceError); err != nil {
+				pluginLogger.Error(fmt.Sprintf("%s%s", etcdUpdate, " has failed"))
+				return err
+			}
+			pluginLogger.Success("ETCD cluster updated successfully.")
+			return nil
+		},
+	}
+	addEtcdClusterFlags(cmd)
+	cmd.Flags().String(installer.EtcdCPULimitFlag, "", "cpu resource limit for the etcd pods")
+	cmd.Flags().String(installer.EtcdMemoryLimitFlag, "", "memory resource limit for the etcd pods")
+	cmd.Flags().String(installer.EtcdTopologyKeyFlag, "", "the topology key to use for the preferred anti-affinity for the etcd pods")
+
+	return cmd
+}
+
+func etcdUpdateCmd(config *apiv1.KubectlStorageOSConfig, log *logger.Logger) error {
+	log.Verbose = config.Spec.Verbose
+
+	if config.Spec.Install.EtcdMemoryLimit != "" {
+		if err := validateResourceLimit(config.Spec.Install.EtcdMemoryLimit); err != nil {
+			return err
+		}
+	}
+	if config.Spec.Install.EtcdCPULimit != "" {
+		if err := validateResourceLimit(config.Spec.Install.EtcdCPULimit); err != nil {
+			return err
+		}
+	}
+
+	cliInstaller, err := installer.NewEtcdInstaller(config, log)
+	if err != nil {
+		return err
+	}
+
+	log.Commencing(etcdUpdate)
+	return cliInstaller.UpdateEtcdCluster()
+}
+
+func setEtcdUpdateValues(cmd *cobra.Command, config *apiv1.KubectlStorageOSConfig) error {
+	if err := setEtcdClusterValues(cmd, config); err != nil {
+		return err
+	}
+	config.Spec.Install.EtcdCPULimit = cmd.Flags().Lookup(installer.EtcdCPULimitFlag).Value.String()
+	config.Spec.Install.EtcdMemoryLimit = cmd.Flags().Lookup(installer.EtcdMemoryLimitFlag).Value.String()
+	config.Spec.Install.EtcdTopologyKey = cmd.Flags().Lookup(installer.EtcdTopologyKeyFlag).Value.String()
+
+	return nil
+}
@@ -0,0 +1,109 @@
+package cli
+
+import (
+	"github.com/spf13/cobra"
+	apiv1 "github.com/storageos/kubectl-storageos/api/v1"
+	"github.com/storageos/kubectl-storageos/pkg/consts"
+	"github.com/storageos/kubectl-storageos/pkg/installer"
+)
+
+const etcd = "etcd"
+
+func EtcdCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:          etcd,
+		Args:         cobra.MinimumNArgs(0),
+		Short:        "Manage the ETCD backend of StorageOS",
+		Long:         `Manage the ETCD backend of StorageOS`,
+		SilenceUsage: true,
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Help()
+		},
+	}
+
+	cmd.AddCommand(EtcdCreateSecretCmd())
+	cmd.AddCommand(EtcdScaleCmd())
+	cmd.AddCommand(EtcdUpdateCmd())
+	cmd.AddCommand(EtcdMaintainCmd())
+	cmd.AddCommand(EtcdMigrateCmd())
+
+	return cmd
+}
+
+// addEtcdClusterFlags adds the flags shared by subcommands operating on the operator-managed etcd
+// cluster, including those of the etcd shell pod used to check the health of its members.
+func addEtcdClusterFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool(installer.StackTraceFlag, false, "print stack trace of error")
+	cmd.Flags().BoolP(installer.VerboseFlag, "v", false, "verbose logging")
+	cmd.Flags().String(installer.EtcdNamespaceFlag, consts.EtcdOperatorNamespace, "namespace of etcd operator and cluster")
+	cmd.Flags().String(installer.StosClusterNSFlag, consts.NewOperatorNamespace, "namespace of storageos cluster, the etcd health check runs here")
+	cmd.Flags().String(installer.EtcdSecretNameFlag, consts.EtcdSecretName, "name of etcd secret in storageos cluster namespace")
+	cmd.Flags().Bool(installer.SkipEtcdEndpointsValFlag, false, "skip health check of etcd members")
+	addEtcdShellFlags(cmd)
+}
+
+// addEtcdShellFlags adds the flags of the etcd shell pod used to run etcdctl
+func addEtcdShellFlags(cmd *cobra.Command) {
+	cmd.Flags().String(installer.EtcdShellImageFlag, "", "image of the pod used to check etcd health")
+	addShellPodFlags(cmd, "the pod used to check etcd health")
+}
+
+// addShellPodFlags adds the flags scheduling and securing every shell pod to cmd, pod describing
+// the shell pods of cmd
+func addShellPodFlags(cmd *cobra.Command, pod string) {
+	cmd.Flags().String(installer.EtcdShellImagePullSecretsFlag, "", "comma separated image pull secrets of "+pod)
+	cmd.Flags().String(installer.EtcdShellTolerationsFlag, "", "comma separated tolerations (key[=value][:effect]) of "+pod)
+	cmd.Flags().String(installer.EtcdShellNodeSelectorFlag, "", "comma separated node selector (key=value) of "+pod)
+	cmd.Flags().String(installer.EtcdShellRunAsUserFlag, "", "non-root user ID to run "+pod+" with the restricted pod security standard")
+	cmd.Flags().String(installer.EtcdShellRunAsGroupFlag, "", "group ID to run "+pod+", defaults to the user ID if set")
+	cmd.Flags().String(installer.EtcdShellFSGroupFlag, "", "fs group ID of "+pod)
+	cmd.Flags().Bool(installer.EtcdShellRunAsNonRootFlag, false, "require "+pod+" to run as non-root, with a user assigned by the cluster if no user ID is set")
+	cmd.Flags().String(installer.EtcdShellSeccompProfileFlag, "", "seccomp profile of "+pod+": RuntimeDefault, Unconfined or Localhost/<profile>")
+}
+
+// setEtcdClusterValues sets the values of the flags added by addEtcdClusterFlags in config
+func setEtcdClusterValues(cmd *cobra.Command, config *apiv1.KubectlStorageOSConfig) error {
+	var err error
+	config.Spec.StackTrace, err = cmd.Flags().GetBool(installer.StackTraceFlag)
+	if err != nil {
+		return err
+	}
+	config.Spec.Verbose, err = cmd.Flags().GetBool(installer.VerboseFlag)
+	if err != nil {
+		return err
+	}
+	config.Spec.Install.SkipEtcdEndpointsValidation, err = cmd.Flags().GetBool(installer.SkipEtcdEndpointsValFlag)
+	if err != nil {
+		return err
+	}
+	config.Spec.Install.EtcdNamespace = cmd.Flags().Lookup(installer.EtcdNamespaceFlag).Value.String()
+	config.Spec.Install.StorageOSClusterNamespace = cmd.Flags().Lookup(installer.StosClusterNSFlag).Value.String()
+	config.Spec.Install.EtcdSecretName = cmd.Flags().Lookup(installer.EtcdSecretNameFlag).Value.String()
+
+	return setEtcdShellValues(cmd, config)
+}
+
+// setEtcdShellValues sets the values of the flags added by addEtcdShellFlags in config
+func setEtcdShellValues(cmd *cobra.Command, config *apiv1.KubectlStorageOSConfig) error {
+	config.Spec.Install.EtcdShellImage = cmd.Flags().Lookup(installer.EtcdShellImageFlag).Value.String()
+
+	return setShellPodValues(cmd, config)
+}
+
+// setShellPodValues sets the values of the flags added by addShellPodFlags in config
+func setShellPodValues(cmd *cobra.Command, config *apiv1.KubectlStorageOSConfig) error {
+	var err error
+	config.Spec.Install.EtcdShellImagePullSecrets = cmd.Flags().Lookup(installer.EtcdShellImagePullSecretsFlag).Value.String()
+	config.Spec.Install.EtcdShellTolerations = cmd.Flags().Lookup(installer.EtcdShellTolerationsFlag).Value.String()
+	config.Spec.Install.EtcdShellNodeSelector = cmd.Flags().Lookup(installer.EtcdShellNodeSelectorFlag).Value.String()
+	config.Spec.Install.EtcdShellRunAsUser = cmd.Flags().Lookup(installer.EtcdShellRunAsUserFlag).Value.String()
+	config.Spec.Install.EtcdShellRunAsGroup = cmd.Flags().Lookup(installer.EtcdShellRunAsGroupFlag).Value.String()
+	config.Spec.Install.EtcdShellFSGroup = cmd.Flags().Lookup(installer.EtcdShellFSGroupFlag).Value.String()
+	config.Spec.Install.EtcdShellRunAsNonRoot, err = cmd.Flags().GetBool(installer.EtcdShellRunAsNonRootFlag)
+	if err != nil {
+		return err
+	}
+	config.Spec.Install.EtcdShellSeccompProfile = cmd.Flags().Lookup(installer.EtcdShellSeccompProfileFlag).Value.String()
+
+	return nil
+}
@@ -60,6 +60,8 @@ func InstallPortalCmd() *cobra.Command {
 	cmd.Flags().String(installer.PortalClientIDFlag, "", "storageos portal client id (plaintext)")
 	cmd.Flags().String(installer.PortalSecretFlag, "", "storageos portal secret (plaintext)")
 	cmd.Flags().String(installer.PortalTenantIDFlag, "", "storageos portal tenant id")
+	cmd.Flags().Bool(installer.SkipPortalCredentialsValFlag, false, "skip validation of the portal credentials against the portal api url")
+	cmd.Flags().Bool(installer.AllowInsecurePortalURLFlag, false, "allow a plaintext http portal api url, which sends the portal secret unencrypted")
 	cmd.Flags().String(installer.PortalAPIURLFlag, "", "storageos portal url")
 
 	viper.BindPFlags(cmd.Flags())
@@ -77,12 +79,7 @@ func installPortalCmd(config *apiv1.KubectlStorageOSConfig, log *logger.Logger)
 	if err := versionSupportsFeature(existingOperatorVersion, consts.PortalManagerFirstSupportedVersion); err != nil {
 		return err
 	}
-	if err := installer.FlagsAreSet(map[string]string{
-		installer.PortalClientIDFlag: config.Spec.Install.PortalClientID,
-		installer.PortalSecretFlag:   config.Spec.Install.PortalSecret,
-		installer.PortalTenantIDFlag: config.Spec.Install.PortalTenantID,
-		installer.PortalAPIURLFlag:   config.Spec.Install.PortalAPIURL,
-	}); err != nil {
+	if err := validatePortalCredentials(config.Spec.Install, log); err != nil {
 		return err
 	}
 
@@ -128,6 +125,14 @@ func setInstallPortalValues(cmd *cobra.Command, config *apiv1.KubectlStorageOSCo
 		config.Spec.Install.PortalClientID = cmd.Flags().Lookup(installer.PortalClientIDFlag).Value.String()
 		config.Spec.Install.PortalSecret = cmd.Flags().Lookup(installer.PortalSecretFlag).Value.String()
 		config.Spec.Install.PortalAPIURL = cmd.Flags().Lookup(installer.PortalAPIURLFlag).Value.String()
+		config.Spec.Install.SkipPortalCredentialsValidation, err = cmd.Flags().GetBool(installer.SkipPortalCredentialsValFlag)
+		if err != nil {
+			return err
+		}
+		config.Spec.Install.AllowInsecurePortalURL, err = cmd.Flags().GetBool(installer.AllowInsecurePortalURLFlag)
+		if err != nil {
+			return err
+		}
 		config.Spec.Install.PortalTenantID = cmd.Flags().Lookup(installer.PortalTenantIDFlag).Value.String()
 		return nil
 	}
@@ -140,6 +145,8 @@ func setInstallPortalValues(cmd *cobra.Command, config *apiv1.KubectlStorageOSCo
 	config.Spec.Install.PortalClientID = viper.GetString(installer.PortalClientIDConfig)
 	config.Spec.Install.PortalSecret = viper.GetString(installer.PortalSecretConfig)
 	config.Spec.Install.PortalAPIURL = viper.GetString(installer.PortalAPIURLConfig)
+	config.Spec.Install.SkipPortalCredentialsValidation = viper.GetBool(installer.SkipPortalCredentialsValConfig)
+	config.Spec.Install.AllowInsecurePortalURL = viper.GetBool(installer.AllowInsecurePortalURLConfig)
 	config.Spec.Install.PortalTenantID = viper.GetString(installer.PortalTenantIDConfig)
 	return nil
 }
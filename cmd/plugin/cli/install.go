@@ -56,6 +56,7 @@ func InstallCmd() *cobra.Command {
 	cmd.Flags().Bool(installer.DryRunFlag, false, "no installation performed, installation manifests stored locally at \"./storageos-dry-run\"")
 	cmd.Flags().String(installer.StosVersionFlag, "", "version of storageos operator")
 	cmd.Flags().String(installer.EtcdOperatorVersionFlag, "", "version of etcd operator")
+	cmd.Flags().String(installer.ChannelFlag, "", "release channel to look up the latest versions from: stable, prerelease or develop")
 	cmd.Flags().String(installer.K8sVersionFlag, "", "version of kubernetes cluster")
 	cmd.Flags().String(installer.StosOperatorYamlFlag, "", "storageos-operator.yaml path or url")
 	cmd.Flags().String(installer.StosClusterYamlFlag, "", "storageos-cluster.yaml path or url")
@@ -87,10 +88,21 @@ func InstallCmd() *cobra.Command {
 	cmd.Flags().String(installer.PortalClientIDFlag, "", "storageos portal client id (plaintext)")
 	cmd.Flags().String(installer.PortalSecretFlag, "", "storageos portal secret (plaintext)")
 	cmd.Flags().String(installer.PortalTenantIDFlag, "", "storageos portal tenant id")
+	cmd.Flags().Bool(installer.SkipPortalCredentialsValFlag, false, "skip validation of the portal credentials against the portal api url")
+	cmd.Flags().Bool(installer.AllowInsecurePortalURLFlag, false, "allow a plaintext http portal api url, which sends the portal secret unencrypted")
 	cmd.Flags().String(installer.PortalAPIURLFlag, "", "storageos portal api url")
 	cmd.Flags().Bool(installer.IncludeLocalPathProvisionerFlag, false, "install the local path provisioner storage class")
 	cmd.Flags().String(installer.LocalPathProvisionerYamlFlag, "", "local-path-provisioner.yaml path or url")
 	cmd.Flags().Bool(installer.EnableMetricsFlag, false, "enable metrics exporter")
+	cmd.Flags().String(installer.EtcdShellImageFlag, "", "image of the pod used to validate etcd endpoints")
+	cmd.Flags().String(installer.EtcdShellImagePullSecretsFlag, "", "comma separated image pull secrets of the pod used to validate etcd endpoints")
+	cmd.Flags().String(installer.EtcdShellTolerationsFlag, "", "comma separated tolerations (key[=value][:effect]) of the pod used to validate etcd endpoints")
+	cmd.Flags().String(installer.EtcdShellNodeSelectorFlag, "", "comma separated node selector (key=value) of the pod used to validate etcd endpoints")
+	cmd.Flags().String(installer.EtcdShellRunAsUserFlag, "", "non-root user ID to run the pod used to validate etcd endpoints with the restricted pod security standard")
+	cmd.Flags().String(installer.EtcdShellRunAsGroupFlag, "", "group ID to run the pod used to validate etcd endpoints, defaults to the user ID if set")
+	cmd.Flags().String(installer.EtcdShellFSGroupFlag, "", "fs group ID of the pod used to validate etcd endpoints")
+	cmd.Flags().Bool(installer.EtcdShellRunAsNonRootFlag, false, "require the pod used to validate etcd endpoints to run as non-root, with a user assigned by the cluster if no user ID is set")
+	cmd.Flags().String(installer.EtcdShellSeccompProfileFlag, "", "seccomp profile of the pod used to validate etcd endpoints: RuntimeDefault, Unconfined or Localhost/<profile>")
 	cmd.Flags().Bool(installer.TestClusterFlag, false, "mark the cluster being created as a test cluster")
 	cmd.Flags().MarkHidden(installer.TestClusterFlag)
 
@@ -101,6 +113,9 @@ func InstallCmd() *cobra.Command {
 
 func installCmd(config *apiv1.KubectlStorageOSConfig, log *logger.Logger) error {
 	log.Verbose = config.Spec.Verbose
+	if err := setReleaseChannel(config.Spec.Channel); err != nil {
+		return err
+	}
 	if config.Spec.Install.AdminPassword != "" {
 		if err := validatePassword(config.Spec.Install.AdminPassword); err != nil {
 			return err
@@ -146,17 +161,11 @@ func installCmd(config *apiv1.KubectlStorageOSConfig, log *logger.Logger) error
 		if err := versionSupportsFeature(config.Spec.Install.StorageOSVersion, consts.PortalManagerFirstSupportedVersion); err != nil {
 			return fmt.Errorf("failed to install portal manager: %w", err)
 		}
-		if err := installer.FlagsAreSet(map[string]string{
-			installer.PortalClientIDFlag: config.Spec.Install.PortalClientID,
-			installer.PortalSecretFlag:   config.Spec.Install.PortalSecret,
-			installer.PortalTenantIDFlag: config.Spec.Install.PortalTenantID,
-			installer.PortalAPIURLFlag:   config.Spec.Install.PortalAPIURL,
-		}); err != nil {
+		if err := validatePortalCredentials(config.Spec.Install, log); err != nil {
 			return err
 		}
 		// TODO: Do we need to add a --portal-manager-version flag?
-		// for now, there is no released version so default to 'develop'
-		version.SetPortalManagerLatestSupportedVersion("develop")
+		// for now, there is no released version so every channel installs 'develop'
 	}
 
 	var err error
@@ -262,6 +271,7 @@ func setInstallValues(cmd *cobra.Command, config *apiv1.KubectlStorageOSConfig)
 			return err
 		}
 
+		config.Spec.Channel = cmd.Flags().Lookup(installer.ChannelFlag).Value.String()
 		config.Spec.Install.StorageOSVersion = cmd.Flags().Lookup(installer.StosVersionFlag).Value.String()
 		config.Spec.Install.EtcdOperatorVersion = cmd.Flags().Lookup(installer.EtcdOperatorVersionFlag).Value.String()
 		config.Spec.Install.KubernetesVersion = cmd.Flags().Lookup(installer.K8sVersionFlag).Value.String()
@@ -285,12 +295,32 @@ func setInstallValues(cmd *cobra.Command, config *apiv1.KubectlStorageOSConfig)
 		config.Spec.Install.PortalSecret = cmd.Flags().Lookup(installer.PortalSecretFlag).Value.String()
 		config.Spec.Install.PortalTenantID = cmd.Flags().Lookup(installer.PortalTenantIDFlag).Value.String()
 		config.Spec.Install.PortalAPIURL = cmd.Flags().Lookup(installer.PortalAPIURLFlag).Value.String()
+		config.Spec.Install.SkipPortalCredentialsValidation, err = cmd.Flags().GetBool(installer.SkipPortalCredentialsValFlag)
+		if err != nil {
+			return err
+		}
+		config.Spec.Install.AllowInsecurePortalURL, err = cmd.Flags().GetBool(installer.AllowInsecurePortalURLFlag)
+		if err != nil {
+			return err
+		}
 		config.Spec.Install.LocalPathProvisionerYaml = cmd.Flags().Lookup(installer.LocalPathProvisionerYamlFlag).Value.String()
 		config.Spec.Install.EtcdTopologyKey = cmd.Flags().Lookup(installer.EtcdTopologyKeyFlag).Value.String()
 		config.Spec.Install.EtcdCPULimit = cmd.Flags().Lookup(installer.EtcdCPULimitFlag).Value.String()
 		config.Spec.Install.EtcdMemoryLimit = cmd.Flags().Lookup(installer.EtcdMemoryLimitFlag).Value.String()
 		config.Spec.Install.EtcdReplicas = cmd.Flags().Lookup(installer.EtcdReplicasFlag).Value.String()
 		config.Spec.Install.EtcdVersionTag = cmd.Flags().Lookup(installer.EtcdVersionTag).Value.String()
+		config.Spec.Install.EtcdShellImage = cmd.Flags().Lookup(installer.EtcdShellImageFlag).Value.String()
+		config.Spec.Install.EtcdShellImagePullSecrets = cmd.Flags().Lookup(installer.EtcdShellImagePullSecretsFlag).Value.String()
+		config.Spec.Install.EtcdShellTolerations = cmd.Flags().Lookup(installer.EtcdShellTolerationsFlag).Value.String()
+		config.Spec.Install.EtcdShellNodeSelector = cmd.Flags().Lookup(installer.EtcdShellNodeSelectorFlag).Value.String()
+		config.Spec.Install.EtcdShellRunAsUser = cmd.Flags().Lookup(installer.EtcdShellRunAsUserFlag).Value.String()
+		config.Spec.Install.EtcdShellRunAsGroup = cmd.Flags().Lookup(installer.EtcdShellRunAsGroupFlag).Value.String()
+		config.Spec.Install.EtcdShellFSGroup = cmd.Flags().Lookup(installer.EtcdShellFSGroupFlag).Value.String()
+		config.Spec.Install.EtcdShellRunAsNonRoot, err = cmd.Flags().GetBool(installer.EtcdShellRunAsNonRootFlag)
+		if err != nil {
+			return err
+		}
+		config.Spec.Install.EtcdShellSeccompProfile = cmd.Flags().Lookup(installer.EtcdShellSeccompProfileFlag).Value.String()
 		config.InstallerMeta.StorageOSSecretYaml = ""
 
 		return nil
@@ -304,6 +334,7 @@ func setInstallValues(cmd *cobra.Command, config *apiv1.KubectlStorageOSConfig)
 	config.Spec.Install.Wait = viper.GetBool(installer.WaitConfig)
 	config.Spec.Install.DryRun = viper.GetBool(installer.DryRunConfig)
 	config.Spec.Install.EnableMetrics = GetBoolIfConfigSet(installer.EnableMetricsConfig)
+	config.Spec.Channel = viper.GetString(installer.ChannelConfig)
 	config.Spec.Install.StorageOSVersion = viper.GetString(installer.StosVersionConfig)
 	config.Spec.Install.EtcdOperatorVersion = viper.GetString(installer.EtcdOperatorVersionConfig)
 	config.Spec.Install.KubernetesVersion = viper.GetString(installer.K8sVersionConfig)
@@ -330,6 +361,8 @@ func setInstallValues(cmd *cobra.Command, config *apiv1.KubectlStorageOSConfig)
 	config.Spec.Install.PortalSecret = viper.GetString(installer.PortalSecretConfig)
 	config.Spec.Install.PortalTenantID = viper.GetString(installer.PortalTenantIDConfig)
 	config.Spec.Install.PortalAPIURL = viper.GetString(installer.PortalAPIURLConfig)
+	config.Spec.Install.SkipPortalCredentialsValidation = viper.GetBool(installer.SkipPortalCredentialsValConfig)
+	config.Spec.Install.AllowInsecurePortalURL = viper.GetBool(installer.AllowInsecurePortalURLConfig)
 	config.InstallerMeta.StorageOSSecretYaml = ""
 	config.Spec.IncludeLocalPathProvisioner = viper.GetBool(installer.IncludeLocalPathProvisionerConfig)
 	config.Spec.Install.LocalPathProvisionerYaml = viper.GetString(installer.InstallLocalPathProvisionerYamlConfig)
@@ -338,6 +371,15 @@ func setInstallValues(cmd *cobra.Command, config *apiv1.KubectlStorageOSConfig)
 	config.Spec.Install.EtcdReplicas = viper.GetString(installer.EtcdReplicasConfig)
 	config.Spec.Install.EtcdTopologyKey = viper.GetString(installer.EtcdTopologyKeyConfig)
 	config.Spec.Install.MarkTestCluster = viper.GetBool(installer.TestClusterConfig)
+	config.Spec.Install.EtcdShellImage = viper.GetString(installer.EtcdShellImageConfig)
+	config.Spec.Install.EtcdShellImagePullSecrets = viper.GetString(installer.EtcdShellImagePullSecretsConfig)
+	config.Spec.Install.EtcdShellTolerations = viper.GetString(installer.EtcdShellTolerationsConfig)
+	config.Spec.Install.EtcdShellNodeSelector = viper.GetString(installer.EtcdShellNodeSelectorConfig)
+	config.Spec.Install.EtcdShellRunAsUser = viper.GetString(installer.EtcdShellRunAsUserConfig)
+	config.Spec.Install.EtcdShellRunAsGroup = viper.GetString(installer.EtcdShellRunAsGroupConfig)
+	config.Spec.Install.EtcdShellFSGroup = viper.GetString(installer.EtcdShellFSGroupConfig)
+	config.Spec.Install.EtcdShellRunAsNonRoot = viper.GetBool(installer.EtcdShellRunAsNonRootConfig)
+	config.Spec.Install.EtcdShellSeccompProfile = viper.GetString(installer.EtcdShellSeccompProfileConfig)
 
 	return nil
 }
@@ -0,0 +1,117 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	apiv1 "github.com/storageos/kubectl-storageos/api/v1"
+	"github.com/storageos/kubectl-storageos/pkg/consts"
+	"github.com/storageos/kubectl-storageos/pkg/installer"
+	"github.com/storageos/kubectl-storageos/pkg/logger"
+	pluginutils "github.com/storageos/kubectl-storageos/pkg/utils"
+	"github.com/storageos/kubectl-storageos/pkg/version"
+)
+
+const portalRotateCredentials = "portal rotate-credentials"
+
+func PortalRotateCredentialsCmd() *cobra.Command {
+	var err error
+	var traceError bool
+	pluginLogger := logger.NewLogger()
+	cmd := &cobra.Command{
+		Use:          "rotate-credentials",
+		Args:         cobra.NoArgs,
+		Short:        "Regenerate the portal client secret and restart portal manager",
+		Long:         `Regenerate the storageos-portal-client secret with a new portal secret, restart portal manager and check from a pod in the StorageOS cluster namespace that the portal accepts the new credentials. The client ID, tenant ID and portal URL default to those of the existing secret, which is written to a new backup before it is replaced.`,
+		SilenceUsage: true,
+		Run: func(cmd *cobra.Command, args []string) {
+			defer pluginutils.ConvertPanicToError(func(e error) {
+				err = e
+			})
+
+			config := &apiv1.KubectlStorageOSConfig{}
+			if err = setPortalRotateCredentialsValues(cmd, config); err != nil {
+				return
+			}
+
+			traceError = config.Spec.StackTrace
+
+			err = portalRotateCredentialsCmd(config, pluginLogger)
+		},
+		PostRunE: func(cmd *cobra.Command, args []string) error {
+			if err := pluginutils.HandleError(portalRotateCredentials, err, traceError); err != nil {
+				pluginLogger.Error(fmt.Sprintf("%s%s", portalRotateCredentials, " has failed"))
+				return err
+			}
+			pluginLogger.Success("Portal credentials rotated successfully.")
+			return nil
+		},
+	}
+	cmd.Flags().Bool(installer.StackTraceFlag, false, "print stack trace of error")
+	cmd.Flags().BoolP(installer.VerboseFlag, "v", false, "verbose logging")
+	cmd.Flags().String(installer.StosOperatorNSFlag, consts.NewOperatorNamespace, "namespace of storageos operator")
+	cmd.Flags().String(installer.PortalSecretFlag, "", "new storageos portal secret (plaintext)")
+	cmd.Flags().String(installer.PortalClientIDFlag, "", "storageos portal client id (plaintext), defaults to the current client id")
+	cmd.Flags().String(installer.PortalTenantIDFlag, "", "storageos portal tenant id, defaults to the current tenant id")
+	cmd.Flags().String(installer.PortalAPIURLFlag, "", "storageos portal url, defaults to the current url")
+	cmd.Flags().Bool(installer.SkipPortalCredentialsValFlag, false, "skip validation of the new portal credentials against the portal api url before rotating them")
+	cmd.Flags().Bool(installer.AllowInsecurePortalURLFlag, false, "allow a plaintext http portal api url, which sends the portal secret unencrypted")
+	cmd.Flags().String(installer.PortalShellImageFlag, "", "image of the pod used to check the portal connectivity, must provide sh and curl")
+	addShellPodFlags(cmd, "the pod used to check the portal connectivity")
+	addBackupEncryptionFlags(cmd)
+
+	return cmd
+}
+
+func portalRotateCredentialsCmd(config *apiv1.KubectlStorageOSConfig, log *logger.Logger) error {
+	log.Verbose = config.Spec.Verbose
+	if err := installer.FlagsAreSet(map[string]string{installer.PortalSecretFlag: config.Spec.Install.PortalSecret}); err != nil {
+		return err
+	}
+
+	existingOperatorVersion, err := version.GetExistingOperatorVersion(config.Spec.Install.StorageOSOperatorNamespace)
+	if err != nil {
+		return err
+	}
+	if err := versionSupportsFeature(existingOperatorVersion, consts.PortalManagerFirstSupportedVersion); err != nil {
+		return err
+	}
+
+	cliInstaller, err := installer.NewPortalManagerInstaller(config, true, log)
+	if err != nil {
+		return err
+	}
+
+	log.Commencing(portalRotateCredentials)
+
+	return cliInstaller.RotatePortalCredentials()
+}
+
+func setPortalRotateCredentialsValues(cmd *cobra.Command, config *apiv1.KubectlStorageOSConfig) error {
+	var err error
+	config.Spec.StackTrace, err = cmd.Flags().GetBool(installer.StackTraceFlag)
+	if err != nil {
+		return err
+	}
+	config.Spec.Verbose, err = cmd.Flags().GetBool(installer.VerboseFlag)
+	if err != nil {
+		return err
+	}
+	config.Spec.Install.StorageOSOperatorNamespace = cmd.Flags().Lookup(installer.StosOperatorNSFlag).Value.String()
+	config.Spec.Install.PortalSecret = cmd.Flags().Lookup(installer.PortalSecretFlag).Value.String()
+	config.Spec.Install.PortalClientID = cmd.Flags().Lookup(installer.PortalClientIDFlag).Value.String()
+	config.Spec.Install.PortalTenantID = cmd.Flags().Lookup(installer.PortalTenantIDFlag).Value.String()
+	config.Spec.Install.PortalAPIURL = cmd.Flags().Lookup(installer.PortalAPIURLFlag).Value.String()
+	config.Spec.Install.SkipPortalCredentialsValidation, err = cmd.Flags().GetBool(installer.SkipPortalCredentialsValFlag)
+	if err != nil {
+		return err
+	}
+	config.Spec.Install.AllowInsecurePortalURL, err = cmd.Flags().GetBool(installer.AllowInsecurePortalURLFlag)
+	if err != nil {
+		return err
+	}
+	config.Spec.Install.PortalShellImage = cmd.Flags().Lookup(installer.PortalShellImageFlag).Value.String()
+	setBackupEncryptionValues(cmd, config)
+
+	return setShellPodValues(cmd, config)
+}
@@ -0,0 +1,79 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	apiv1 "github.com/storageos/kubectl-storageos/api/v1"
+	"github.com/storageos/kubectl-storageos/pkg/installer"
+	"github.com/storageos/kubectl-storageos/pkg/logger"
+	pluginutils "github.com/storageos/kubectl-storageos/pkg/utils"
+)
+
+const portalStatus = "portal status"
+
+func PortalStatusCmd() *cobra.Command {
+	var err error
+	var traceError bool
+	pluginLogger := logger.NewLogger()
+	cmd := &cobra.Command{
+		Use:          "status",
+		Args:         cobra.NoArgs,
+		Short:        "Check that portal manager is configured, running and able to reach the portal",
+		Long:         `Check that portal manager is enabled in the StorageOS cluster, that the storageos-portal-client secret holds the CLIENT_ID, PASSWORD, URL and TENANT_ID fields, that the portal manager configmap is valid, that the portal manager pod is ready and has not logged errors, and, from a pod in the StorageOS cluster namespace, that the portal URL is reachable and accepts the client credentials. A verdict is printed for each check.`,
+		SilenceUsage: true,
+		Run: func(cmd *cobra.Command, args []string) {
+			defer pluginutils.ConvertPanicToError(func(e error) {
+				err = e
+			})
+
+			config := &apiv1.KubectlStorageOSConfig{}
+			if err = setPortalStatusValues(cmd, config); err != nil {
+				return
+			}
+
+			traceError = config.Spec.StackTrace
+
+			err = portalStatusCmd(config, pluginLogger)
+		},
+		PostRunE: func(cmd *cobra.Command, args []string) error {
+			if err := pluginutils.HandleError(portalStatus, err, traceError); err != nil {
+				pluginLogger.Error(fmt.Sprintf("%s%s", portalStatus, " has failed"))
+				return err
+			}
+			return nil
+		},
+	}
+	cmd.Flags().Bool(installer.StackTraceFlag, false, "print stack trace of error")
+	cmd.Flags().BoolP(installer.VerboseFlag, "v", false, "verbose logging")
+	cmd.Flags().String(installer.PortalShellImageFlag, "", "image of the pod used to check the portal connectivity, must provide sh and curl")
+	addShellPodFlags(cmd, "the pod used to check the portal connectivity")
+
+	return cmd
+}
+
+func portalStatusCmd(config *apiv1.KubectlStorageOSConfig, log *logger.Logger) error {
+	log.Verbose = config.Spec.Verbose
+
+	cliInstaller, err := installer.NewPortalStatusInstaller(config, log)
+	if err != nil {
+		return err
+	}
+
+	return cliInstaller.PortalStatus()
+}
+
+func setPortalStatusValues(cmd *cobra.Command, config *apiv1.KubectlStorageOSConfig) error {
+	var err error
+	config.Spec.StackTrace, err = cmd.Flags().GetBool(installer.StackTraceFlag)
+	if err != nil {
+		return err
+	}
+	config.Spec.Verbose, err = cmd.Flags().GetBool(installer.VerboseFlag)
+	if err != nil {
+		return err
+	}
+	config.Spec.Install.PortalShellImage = cmd.Flags().Lookup(installer.PortalShellImageFlag).Value.String()
+
+	return setShellPodValues(cmd, config)
+}
@@ -0,0 +1,25 @@
+package cli
+
+import (
+	"github.com/spf13/cobra"
+)
+
+const portal = "portal"
+
+func PortalCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:          portal,
+		Args:         cobra.MinimumNArgs(0),
+		Short:        "Manage the StorageOS Portal Manager",
+		Long:         `Manage the StorageOS Portal Manager`,
+		SilenceUsage: true,
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Help()
+		},
+	}
+
+	cmd.AddCommand(PortalStatusCmd())
+	cmd.AddCommand(PortalRotateCredentialsCmd())
+
+	return cmd
+}
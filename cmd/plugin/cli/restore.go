@@ -0,0 +1,87 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	apiv1 "github.com/storageos/kubectl-storageos/api/v1"
+	"github.com/storageos/kubectl-storageos/pkg/consts"
+	"github.com/storageos/kubectl-storageos/pkg/installer"
+	"github.com/storageos/kubectl-storageos/pkg/logger"
+	pluginutils "github.com/storageos/kubectl-storageos/pkg/utils"
+)
+
+const restore = "restore"
+
+func RestoreCmd() *cobra.Command {
+	var err error
+	var traceError bool
+	pluginLogger := logger.NewLogger()
+	cmd := &cobra.Command{
+		Use:          restore + " [backup-name]",
+		Args:         cobra.MaximumNArgs(1),
+		Short:        "Reinstall StorageOS from a backup",
+		Long:         `Reinstall StorageOS from a backup, the latest if no name is given. The StorageOS operator version recorded in the backup is installed, then the backed-up configmaps, secrets, storage classes and StorageOS cluster are re-applied as they were.`,
+		SilenceUsage: true,
+		Run: func(cmd *cobra.Command, args []string) {
+			defer pluginutils.ConvertPanicToError(func(e error) {
+				err = e
+			})
+
+			config := &apiv1.KubectlStorageOSConfig{}
+			if err = setRestoreValues(cmd, config); err != nil {
+				return
+			}
+
+			traceError = config.Spec.StackTrace
+
+			err = restoreCmd(config, backupNameArg(args), pluginLogger)
+		},
+		PostRunE: func(cmd *cobra.Command, args []string) error {
+			if err := pluginutils.HandleError(restore, err, traceError); err != nil {
+				pluginLogger.Error(fmt.Sprintf("%s%s", restore, " has failed"))
+				return err
+			}
+			pluginLogger.Success("StorageOS restored successfully.")
+			return nil
+		},
+	}
+	addBackupFlags(cmd)
+	cmd.Flags().Bool(installer.WaitFlag, false, "wait for storageos cluster to enter running phase")
+	cmd.Flags().String(installer.StosVersionFlag, "", "version of storageos operator, defaults to the version recorded in the backup")
+	cmd.Flags().String(installer.K8sVersionFlag, "", "version of kubernetes cluster")
+	cmd.Flags().String(installer.StosOperatorNSFlag, consts.NewOperatorNamespace, "namespace of storageos operator")
+	cmd.Flags().Bool(installer.SkipEtcdEndpointsValFlag, false, "skip validation of the etcd endpoints of the backed-up storageos cluster")
+	addEtcdShellFlags(cmd)
+	addBackupEncryptionFlags(cmd)
+
+	return cmd
+}
+
+func restoreCmd(config *apiv1.KubectlStorageOSConfig, name string, log *logger.Logger) error {
+	log.Verbose = config.Spec.Verbose
+
+	log.Commencing(restore)
+	return installer.Restore(config, name, log)
+}
+
+func setRestoreValues(cmd *cobra.Command, config *apiv1.KubectlStorageOSConfig) error {
+	if err := setBackupValues(cmd, config); err != nil {
+		return err
+	}
+	var err error
+	config.Spec.Install.Wait, err = cmd.Flags().GetBool(installer.WaitFlag)
+	if err != nil {
+		return err
+	}
+	config.Spec.Install.SkipEtcdEndpointsValidation, err = cmd.Flags().GetBool(installer.SkipEtcdEndpointsValFlag)
+	if err != nil {
+		return err
+	}
+	config.Spec.Install.StorageOSVersion = cmd.Flags().Lookup(installer.StosVersionFlag).Value.String()
+	config.Spec.Install.KubernetesVersion = cmd.Flags().Lookup(installer.K8sVersionFlag).Value.String()
+	config.Spec.Install.StorageOSOperatorNamespace = cmd.Flags().Lookup(installer.StosOperatorNSFlag).Value.String()
+	setBackupEncryptionValues(cmd, config)
+
+	return setEtcdShellValues(cmd, config)
+}
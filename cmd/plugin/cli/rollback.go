@@ -0,0 +1,130 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	apiv1 "github.com/storageos/kubectl-storageos/api/v1"
+	"github.com/storageos/kubectl-storageos/pkg/consts"
+	"github.com/storageos/kubectl-storageos/pkg/installer"
+	"github.com/storageos/kubectl-storageos/pkg/logger"
+	pluginutils "github.com/storageos/kubectl-storageos/pkg/utils"
+	pluginversion "github.com/storageos/kubectl-storageos/pkg/version"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+const rollback = "rollback"
+
+func RollbackCmd() *cobra.Command {
+	var err error
+	var traceError bool
+	var yes bool
+	pluginLogger := logger.NewLogger()
+	cmd := &cobra.Command{
+		Use:          rollback + " [backup-name]",
+		Args:         cobra.MaximumNArgs(1),
+		Short:        "Reinstall the StorageOS version installed before the last upgrade",
+		Long:         `Reinstall the StorageOS version installed before the last upgrade, from the backup taken by that upgrade or from the given backup. The backup is verified, then the installed StorageOS is uninstalled once confirmed and the operator version, configmaps, secrets, storage classes and StorageOS cluster of the backup are re-applied as they were. If StorageOS is not installed, after an interrupted upgrade for instance, the backup is restored straight away.`,
+		SilenceUsage: true,
+		Run: func(cmd *cobra.Command, args []string) {
+			defer pluginutils.ConvertPanicToError(func(e error) {
+				err = e
+			})
+
+			config := &apiv1.KubectlStorageOSConfig{}
+			if err = setRollbackValues(cmd, config); err != nil {
+				return
+			}
+
+			traceError = config.Spec.StackTrace
+
+			name := ""
+			if len(args) != 0 {
+				name = args[0]
+			}
+			err = rollbackCmd(config, name, cmd.Flags().Changed(installer.SkipNamespaceDeletionFlag), yes, pluginLogger)
+		},
+		PostRunE: func(cmd *cobra.Command, args []string) error {
+			if err := pluginutils.HandleError(rollback, err, traceError); err != nil {
+				pluginLogger.Error(fmt.Sprintf("%s%s", rollback, " has failed"))
+				return err
+			}
+			return nil
+		},
+	}
+	addBackupFlags(cmd)
+	cmd.Flags().BoolVarP(&yes, installer.YesFlag, "y", false, "roll back without confirmation")
+	cmd.Flags().Bool(installer.WaitFlag, false, "wait for storageos cluster to enter running phase")
+	cmd.Flags().String(installer.StosVersionFlag, "", "version of storageos operator, defaults to the version recorded in the backup")
+	cmd.Flags().String(installer.K8sVersionFlag, "", "version of kubernetes cluster")
+	cmd.Flags().String(installer.StosOperatorNSFlag, consts.NewOperatorNamespace, "namespace of storageos operator")
+	cmd.Flags().Bool(installer.SkipNamespaceDeletionFlag, false, "leaving namespaces untouched")
+	cmd.Flags().Bool(installer.SkipExistingWorkloadCheckFlag, false, "skip check for PVCs using storageos storage class during uninstall")
+	cmd.Flags().Bool(installer.SkipEtcdEndpointsValFlag, false, "skip validation of the etcd endpoints of the backed-up storageos cluster")
+	addEtcdShellFlags(cmd)
+	addBackupEncryptionFlags(cmd)
+
+	return cmd
+}
+
+func rollbackCmd(config *apiv1.KubectlStorageOSConfig, name string, skipNamespaceDeletionHasSet, yes bool, log *logger.Logger) error {
+	log.Verbose = config.Spec.Verbose
+
+	// StorageOS may have been uninstalled by an interrupted upgrade
+	currentVersion, err := pluginversion.GetExistingOperatorVersion(config.Spec.Uninstall.StorageOSOperatorNamespace)
+	if err != nil && !kerrors.IsNotFound(err) {
+		return err
+	}
+	if err == nil {
+		log.Successf("Discovered StorageOS cluster and operator version '%s'.", currentVersion)
+		// if skip namespace delete was not passed via flag, prompt user to enter manually
+		if !config.Spec.SkipNamespaceDeletion && !skipNamespaceDeletionHasSet {
+			config.Spec.SkipNamespaceDeletion, err = skipNamespaceDeletionPrompt(log)
+			if err != nil {
+				return err
+			}
+		}
+		pluginversion.SetOperatorLatestSupportedVersion(currentVersion)
+		if err = setVersionSpecificValues(config, currentVersion); err != nil {
+			return err
+		}
+	}
+
+	log.Commencing(rollback)
+	return installer.Rollback(config, name, currentVersion, func() (bool, error) {
+		if yes {
+			return true, nil
+		}
+		return rollbackPrompt(log)
+	}, log)
+}
+
+func setRollbackValues(cmd *cobra.Command, config *apiv1.KubectlStorageOSConfig) error {
+	if err := setBackupValues(cmd, config); err != nil {
+		return err
+	}
+	var err error
+	config.Spec.Install.Wait, err = cmd.Flags().GetBool(installer.WaitFlag)
+	if err != nil {
+		return err
+	}
+	config.Spec.SkipNamespaceDeletion, err = cmd.Flags().GetBool(installer.SkipNamespaceDeletionFlag)
+	if err != nil {
+		return err
+	}
+	config.Spec.SkipExistingWorkloadCheck, err = cmd.Flags().GetBool(installer.SkipExistingWorkloadCheckFlag)
+	if err != nil {
+		return err
+	}
+	config.Spec.Install.SkipEtcdEndpointsValidation, err = cmd.Flags().GetBool(installer.SkipEtcdEndpointsValFlag)
+	if err != nil {
+		return err
+	}
+	config.Spec.Install.StorageOSVersion = cmd.Flags().Lookup(installer.StosVersionFlag).Value.String()
+	config.Spec.Install.KubernetesVersion = cmd.Flags().Lookup(installer.K8sVersionFlag).Value.String()
+	config.Spec.Install.StorageOSOperatorNamespace = cmd.Flags().Lookup(installer.StosOperatorNSFlag).Value.String()
+	config.Spec.Uninstall.StorageOSOperatorNamespace = config.Spec.Install.StorageOSOperatorNamespace
+	setBackupEncryptionValues(cmd, config)
+
+	return setEtcdShellValues(cmd, config)
+}
@@ -46,6 +46,13 @@ func RootCmd() *cobra.Command {
 	cmd.AddCommand(UninstallPortalCmd())
 	cmd.AddCommand(EnablePortalCmd())
 	cmd.AddCommand(DisablePortalCmd())
+	cmd.AddCommand(PortalCmd())
+	cmd.AddCommand(EtcdCmd())
+	cmd.AddCommand(BackupCmd())
+	cmd.AddCommand(RestoreCmd())
+	cmd.AddCommand(RollbackCmd())
+	cmd.AddCommand(WorkloadsCmd())
+	cmd.AddCommand(CleanupCmd())
 	cmd.AddCommand(CompletionCmd)
 
 	return cmd
@@ -56,6 +56,7 @@ func UninstallPortalCmd() *cobra.Command {
 	cmd.Flags().BoolP(installer.VerboseFlag, "v", false, "verbose logging")
 	cmd.Flags().String(installer.StosConfigPathFlag, "", "path to look for kubectl-storageos-config.yaml")
 	cmd.Flags().String(installer.StosOperatorNSFlag, consts.NewOperatorNamespace, "namespace of storageos operator")
+	addBackupEncryptionFlags(cmd)
 
 	viper.BindPFlags(cmd.Flags())
 
@@ -110,11 +111,13 @@ func setUninstallPortalValues(cmd *cobra.Command, config *apiv1.KubectlStorageOS
 			return err
 		}
 		config.Spec.Install.StorageOSOperatorNamespace = cmd.Flags().Lookup(installer.StosOperatorNSFlag).Value.String()
+		setBackupEncryptionValues(cmd, config)
 		return nil
 	}
 	// config file read without error, set fields in new config object
 	config.Spec.StackTrace = viper.GetBool(installer.StackTraceConfig)
 	config.Spec.Verbose = viper.GetBool(installer.VerboseConfig)
 	config.Spec.Install.StorageOSOperatorNamespace = viper.GetString(installer.InstallStosOperatorNSConfig)
+	setBackupEncryptionConfigValues(config)
 	return nil
 }
@@ -0,0 +1,88 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	apiv1 "github.com/storageos/kubectl-storageos/api/v1"
+	"github.com/storageos/kubectl-storageos/pkg/installer"
+	"github.com/storageos/kubectl-storageos/pkg/logger"
+	pluginutils "github.com/storageos/kubectl-storageos/pkg/utils"
+	pluginversion "github.com/storageos/kubectl-storageos/pkg/version"
+)
+
+const upgradeEtcd = "upgrade etcd"
+
+func UpgradeEtcdCmd() *cobra.Command {
+	var err error
+	var traceError bool
+	pluginLogger := logger.NewLogger()
+	cmd := &cobra.Command{
+		Use:          etcd,
+		Args:         cobra.MinimumNArgs(0),
+		Short:        "Upgrade the ETCD operator and cluster installed with --include-etcd",
+		Long:         `Upgrade the ETCD operator and cluster installed with --include-etcd. The etcd operator is upgraded to --etcd-operator-version, the latest by default, keeping the arguments set at install time. If --etcd-version-tag is set, the etcd operator then replaces the etcd members one at a time with members of that version. The health of all members is checked before the upgrade and after each step, and a snapshot of ETCD is taken before anything changes. Downgrades are refused.`,
+		SilenceUsage: true,
+		PreRun:       func(cmd *cobra.Command, args []string) {},
+		Run: func(cmd *cobra.Command, args []string) {
+			defer pluginutils.ConvertPanicToError(func(e error) {
+				err = e
+			})
+
+			config := &apiv1.KubectlStorageOSConfig{}
+			if err = setUpgradeEtcdValues(cmd, config); err != nil {
+				return
+			}
+
+			traceError = config.Spec.StackTrace
+
+			err = upgradeEtcdCmd(config, pluginLogger)
+		},
+		PostRunE: func(cmd *cobra.Command, args []string) error {
+			if err := pluginutils.HandleError(upgradeEtcd, err, traceError); err != nil {
+				pluginLogger.Error(fmt.Sprintf("%s%s", upgradeEtcd, " has failed"))
+				return err
+			}
+			pluginLogger.Success("ETCD upgraded successfully.")
+			return nil
+		},
+	}
+	addEtcdClusterFlags(cmd)
+	cmd.Flags().String(installer.EtcdOperatorVersionFlag, "", "version of etcd operator to upgrade to, defaults to the latest")
+	cmd.Flags().String(installer.ChannelFlag, "", "release channel to look up the latest versions from: stable, prerelease or develop")
+	cmd.Flags().String(installer.EtcdOperatorYamlFlag, "", "etcd-operator.yaml path or url to upgrade the etcd operator with")
+	cmd.Flags().String(installer.EtcdVersionTag, "", "the docker tag for the version of etcd to upgrade to - must be in the format 1.2.3")
+
+	return cmd
+}
+
+func upgradeEtcdCmd(config *apiv1.KubectlStorageOSConfig, log *logger.Logger) error {
+	log.Verbose = config.Spec.Verbose
+	if err := setReleaseChannel(config.Spec.Channel); err != nil {
+		return err
+	}
+
+	if config.Spec.Install.EtcdOperatorVersion == "" && config.Spec.Install.EtcdOperatorYaml == "" {
+		config.Spec.Install.EtcdOperatorVersion = pluginversion.EtcdOperatorLatestSupportedVersion()
+	}
+
+	cliInstaller, err := installer.NewEtcdInstaller(config, log)
+	if err != nil {
+		return err
+	}
+
+	log.Commencing(upgradeEtcd)
+	return cliInstaller.UpgradeEtcd()
+}
+
+func setUpgradeEtcdValues(cmd *cobra.Command, config *apiv1.KubectlStorageOSConfig) error {
+	if err := setEtcdClusterValues(cmd, config); err != nil {
+		return err
+	}
+	config.Spec.Channel = cmd.Flags().Lookup(installer.ChannelFlag).Value.String()
+	config.Spec.Install.EtcdOperatorVersion = cmd.Flags().Lookup(installer.EtcdOperatorVersionFlag).Value.String()
+	config.Spec.Install.EtcdOperatorYaml = cmd.Flags().Lookup(installer.EtcdOperatorYamlFlag).Value.String()
+	config.Spec.Install.EtcdVersionTag = cmd.Flags().Lookup(installer.EtcdVersionTag).Value.String()
+
+	return nil
+}
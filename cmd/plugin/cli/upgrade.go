@@ -62,7 +62,13 @@ func UpgradeCmd() *cobra.Command {
 
 			traceError = installConfig.Spec.StackTrace
 
-			err = upgradeCmd(uninstallConfig, installConfig, pluginutils.HasFlagSet(installer.SkipNamespaceDeletionFlag), pluginLogger)
+			var resume bool
+			resume, err = cmd.Flags().GetBool(installer.ResumeFlag)
+			if err != nil {
+				return
+			}
+
+			err = upgradeCmd(uninstallConfig, installConfig, pluginutils.HasFlagSet(installer.SkipNamespaceDeletionFlag), resume, pluginLogger)
 		},
 		PostRunE: func(cmd *cobra.Command, args []string) error {
 			if err := pluginutils.HandleError(upgrade, err, traceError); err != nil {
@@ -77,7 +83,12 @@ func UpgradeCmd() *cobra.Command {
 	cmd.Flags().BoolP(installer.VerboseFlag, "v", false, "verbose logging")
 	cmd.Flags().Bool(installer.WaitFlag, false, "wait for storageos cluster to enter running phase")
 	cmd.Flags().Bool(installer.SkipExistingWorkloadCheckFlag, false, "skip check for PVCs using storageos storage class during upgrade")
+	cmd.Flags().Bool(installer.QuiesceWorkloadsFlag, false, "scale down deployments and statefulsets and suspend cronjobs using storageos volumes during upgrade, restoring them afterwards")
+	cmd.Flags().Bool(installer.ResumeFlag, false, "continue an interrupted upgrade from its last completed step")
+	cmd.Flags().Bool(installer.ReinstallFlag, false, "uninstall and reinstall storageos even if the operator can be upgraded in place")
+	cmd.Flags().Bool(installer.SkipCompatibilityCheckFlag, false, "upgrade even if the compatibility matrix does not support it, logging every incompatibility as a warning")
 	cmd.Flags().String(installer.StosVersionFlag, "", "version of storageos operator")
+	cmd.Flags().String(installer.ChannelFlag, "", "release channel to look up the latest versions from: stable, prerelease or develop")
 	cmd.Flags().String(installer.K8sVersionFlag, "", "version of kubernetes cluster")
 	cmd.Flags().Bool(installer.SkipNamespaceDeletionFlag, false, "leaving namespaces untouched")
 	cmd.Flags().Bool(installer.EnablePortalManagerFlag, false, "enable storageos portal manager during upgrade")
@@ -101,6 +112,15 @@ func UpgradeCmd() *cobra.Command {
 	cmd.Flags().Bool(installer.SkipEtcdEndpointsValFlag, false, "skip validation of etcd endpoints")
 	cmd.Flags().Bool(installer.SkipStosClusterFlag, false, "skip storageos cluster during upgrade")
 	cmd.Flags().Bool(installer.EtcdTLSEnabledFlag, false, "etcd cluster is tls enabled")
+	cmd.Flags().String(installer.EtcdShellImageFlag, "", "image of the pod used to validate etcd endpoints")
+	cmd.Flags().String(installer.EtcdShellImagePullSecretsFlag, "", "comma separated image pull secrets of the pod used to validate etcd endpoints")
+	cmd.Flags().String(installer.EtcdShellTolerationsFlag, "", "comma separated tolerations (key[=value][:effect]) of the pod used to validate etcd endpoints")
+	cmd.Flags().String(installer.EtcdShellNodeSelectorFlag, "", "comma separated node selector (key=value) of the pod used to validate etcd endpoints")
+	cmd.Flags().String(installer.EtcdShellRunAsUserFlag, "", "non-root user ID to run the pod used to validate etcd endpoints with the restricted pod security standard")
+	cmd.Flags().String(installer.EtcdShellRunAsGroupFlag, "", "group ID to run the pod used to validate etcd endpoints, defaults to the user ID if set")
+	cmd.Flags().String(installer.EtcdShellFSGroupFlag, "", "fs group ID of the pod used to validate etcd endpoints")
+	cmd.Flags().Bool(installer.EtcdShellRunAsNonRootFlag, false, "require the pod used to validate etcd endpoints to run as non-root, with a user assigned by the cluster if no user ID is set")
+	cmd.Flags().String(installer.EtcdShellSeccompProfileFlag, "", "seccomp profile of the pod used to validate etcd endpoints: RuntimeDefault, Unconfined or Localhost/<profile>")
 	cmd.Flags().String(installer.AdminUsernameFlag, "", "storageos admin username (plaintext)")
 	cmd.Flags().String(installer.AdminPasswordFlag, "", "storageos admin password (plaintext)")
 	cmd.Flags().String(installer.PortalClientIDFlag, "", "storageos portal client id (plaintext)")
@@ -108,14 +128,20 @@ func UpgradeCmd() *cobra.Command {
 	cmd.Flags().String(installer.PortalAPIURLFlag, "", "storageos portal api url")
 	cmd.Flags().String(installer.PortalTenantIDFlag, "", "storageos portal tenant id")
 	cmd.Flags().Bool(installer.EnableMetricsFlag, false, "enable metrics exporter")
+	addBackupEncryptionFlags(cmd)
 
 	viper.BindPFlags(cmd.Flags())
 
+	cmd.AddCommand(UpgradeEtcdCmd())
+
 	return cmd
 }
 
-func upgradeCmd(uninstallConfig *apiv1.KubectlStorageOSConfig, installConfig *apiv1.KubectlStorageOSConfig, skipNamespaceDeletionHasSet bool, log *logger.Logger) error {
+func upgradeCmd(uninstallConfig *apiv1.KubectlStorageOSConfig, installConfig *apiv1.KubectlStorageOSConfig, skipNamespaceDeletionHasSet, resume bool, log *logger.Logger) error {
 	log.Verbose = uninstallConfig.Spec.Verbose
+	if err := setReleaseChannel(installConfig.Spec.Channel); err != nil {
+		return err
+	}
 
 	if installConfig.Spec.Install.AdminPassword != "" {
 		if err := validatePassword(installConfig.Spec.Install.AdminPassword); err != nil {
@@ -123,6 +149,16 @@ func upgradeCmd(uninstallConfig *apiv1.KubectlStorageOSConfig, installConfig *ap
 		}
 	}
 
+	// the versions of a resumed upgrade are those of the interrupted one, StorageOS may be uninstalled
+	var existingVersion string
+	if resume {
+		var err error
+		existingVersion, installConfig.Spec.Install.StorageOSVersion, err = installer.UpgradeToResume(uninstallConfig, installConfig.Spec.Install.StorageOSVersion, log)
+		if err != nil {
+			return err
+		}
+	}
+
 	if installConfig.Spec.Install.StorageOSVersion == "" {
 		installConfig.Spec.Install.StorageOSVersion = version.OperatorLatestSupportedVersion()
 	}
@@ -141,37 +177,48 @@ func upgradeCmd(uninstallConfig *apiv1.KubectlStorageOSConfig, installConfig *ap
 
 	version.SetOperatorLatestSupportedVersion(installConfig.Spec.Install.StorageOSVersion)
 
-	// if skip namespace delete was not passed via flag or config, prompt user to enter manually
-	if !uninstallConfig.Spec.SkipNamespaceDeletion && !skipNamespaceDeletionHasSet {
+	if !resume {
+		if err := installer.CheckNoInterruptedUpgrade(uninstallConfig, log); err != nil {
+			return err
+		}
+
 		var err error
-		uninstallConfig.Spec.SkipNamespaceDeletion, err = skipNamespaceDeletionPrompt(log)
+		existingVersion, err = pluginversion.GetExistingOperatorVersion(uninstallConfig.Spec.Uninstall.StorageOSOperatorNamespace)
 		if err != nil {
 			return err
 		}
-	}
 
-	existingVersion, err := pluginversion.GetExistingOperatorVersion(uninstallConfig.Spec.Uninstall.StorageOSOperatorNamespace)
-	if err != nil {
-		return err
-	}
+		noUpgrade, err := pluginversion.VersionIsEqualTo(existingVersion, version.OperatorLatestSupportedVersion())
+		if err != nil {
+			return err
+		}
+		if noUpgrade {
+			log.Successf("StorageOS cluster and operator %s are already installed. No action required.", existingVersion)
+			return nil
+		}
+		log.Warnf("Discovered StorageOS cluster and operator version %s.", existingVersion)
+		installer.PrintUpgradeChangelog(existingVersion, version.OperatorLatestSupportedVersion(), log)
 
-	noUpgrade, err := pluginversion.VersionIsEqualTo(existingVersion, version.OperatorLatestSupportedVersion())
-	if err != nil {
-		return err
+		if err = installer.CheckUpgradeCompatibility(installConfig, existingVersion, log); err != nil {
+			return err
+		}
 	}
-	if noUpgrade {
-		log.Successf("StorageOS cluster and operator %s are already installed. No action required.", existingVersion)
-		return nil
+
+	// if skip namespace delete was not passed via flag or config, prompt user to enter manually
+	if !uninstallConfig.Spec.SkipNamespaceDeletion && !skipNamespaceDeletionHasSet {
+		var err error
+		uninstallConfig.Spec.SkipNamespaceDeletion, err = skipNamespaceDeletionPrompt(log)
+		if err != nil {
+			return err
+		}
 	}
-	log.Warnf("Discovered StorageOS cluster and operator version %s.", existingVersion)
 
-	err = setVersionSpecificValues(uninstallConfig, existingVersion)
-	if err != nil {
+	if err := setVersionSpecificValues(uninstallConfig, existingVersion); err != nil {
 		return err
 	}
 
 	log.Commencing(upgrade)
-	return installer.Upgrade(uninstallConfig, installConfig, existingVersion, log)
+	return installer.Upgrade(uninstallConfig, installConfig, existingVersion, resume, log)
 }
 
 func setUpgradeInstallValues(cmd *cobra.Command, config *apiv1.KubectlStorageOSConfig) error {
@@ -216,6 +263,10 @@ func setUpgradeInstallValues(cmd *cobra.Command, config *apiv1.KubectlStorageOSC
 		if err != nil {
 			return err
 		}
+		config.Spec.Install.SkipCompatibilityCheck, err = cmd.Flags().GetBool(installer.SkipCompatibilityCheckFlag)
+		if err != nil {
+			return err
+		}
 		config.Spec.Install.EtcdTLSEnabled, err = cmd.Flags().GetBool(installer.EtcdTLSEnabledFlag)
 		if err != nil {
 			return err
@@ -224,7 +275,9 @@ func setUpgradeInstallValues(cmd *cobra.Command, config *apiv1.KubectlStorageOSC
 		if err != nil {
 			return err
 		}
+		config.Spec.Channel = cmd.Flags().Lookup(installer.ChannelFlag).Value.String()
 		config.Spec.Install.StorageOSVersion = cmd.Flags().Lookup(installer.StosVersionFlag).Value.String()
+		config.Spec.Install.KubernetesVersion = cmd.Flags().Lookup(installer.K8sVersionFlag).Value.String()
 		config.Spec.Install.StorageOSOperatorYaml = cmd.Flags().Lookup(installStosOperatorYamlFlag).Value.String()
 		config.Spec.Install.StorageOSClusterYaml = cmd.Flags().Lookup(installStosClusterYamlFlag).Value.String()
 		config.Spec.Install.StorageOSPortalConfigYaml = cmd.Flags().Lookup(installStosPortalConfigYamlFlag).Value.String()
@@ -234,6 +287,18 @@ func setUpgradeInstallValues(cmd *cobra.Command, config *apiv1.KubectlStorageOSC
 		config.Spec.Install.StorageOSClusterNamespace = cmd.Flags().Lookup(installStosClusterNSFlag).Value.String()
 		config.Spec.Install.EtcdEndpoints = cmd.Flags().Lookup(installer.EtcdEndpointsFlag).Value.String()
 		config.Spec.Install.EtcdSecretName = cmd.Flags().Lookup(installer.EtcdSecretNameFlag).Value.String()
+		config.Spec.Install.EtcdShellImage = cmd.Flags().Lookup(installer.EtcdShellImageFlag).Value.String()
+		config.Spec.Install.EtcdShellImagePullSecrets = cmd.Flags().Lookup(installer.EtcdShellImagePullSecretsFlag).Value.String()
+		config.Spec.Install.EtcdShellTolerations = cmd.Flags().Lookup(installer.EtcdShellTolerationsFlag).Value.String()
+		config.Spec.Install.EtcdShellNodeSelector = cmd.Flags().Lookup(installer.EtcdShellNodeSelectorFlag).Value.String()
+		config.Spec.Install.EtcdShellRunAsUser = cmd.Flags().Lookup(installer.EtcdShellRunAsUserFlag).Value.String()
+		config.Spec.Install.EtcdShellRunAsGroup = cmd.Flags().Lookup(installer.EtcdShellRunAsGroupFlag).Value.String()
+		config.Spec.Install.EtcdShellFSGroup = cmd.Flags().Lookup(installer.EtcdShellFSGroupFlag).Value.String()
+		config.Spec.Install.EtcdShellRunAsNonRoot, err = cmd.Flags().GetBool(installer.EtcdShellRunAsNonRootFlag)
+		if err != nil {
+			return err
+		}
+		config.Spec.Install.EtcdShellSeccompProfile = cmd.Flags().Lookup(installer.EtcdShellSeccompProfileFlag).Value.String()
 		config.Spec.Install.AdminUsername = cmd.Flags().Lookup(installer.AdminUsernameFlag).Value.String()
 		config.Spec.Install.AdminPassword = cmd.Flags().Lookup(installer.AdminPasswordFlag).Value.String()
 		config.Spec.Install.PortalClientID = cmd.Flags().Lookup(installer.PortalClientIDFlag).Value.String()
@@ -241,6 +306,8 @@ func setUpgradeInstallValues(cmd *cobra.Command, config *apiv1.KubectlStorageOSC
 		config.Spec.Install.PortalAPIURL = cmd.Flags().Lookup(installer.PortalAPIURLFlag).Value.String()
 		config.Spec.Install.PortalTenantID = cmd.Flags().Lookup(installer.PortalTenantIDFlag).Value.String()
 		config.InstallerMeta.StorageOSSecretYaml = ""
+		// backups are read by the install of a resumed upgrade
+		setBackupEncryptionValues(cmd, config)
 		return nil
 	}
 	// config file read without error, set fields in new config object
@@ -252,7 +319,9 @@ func setUpgradeInstallValues(cmd *cobra.Command, config *apiv1.KubectlStorageOSC
 	config.Spec.Install.EnablePortalManager = viper.GetBool(installer.EnablePortalManagerConfig)
 	config.Spec.Install.EnableMetrics = GetBoolIfConfigSet(installer.EnableMetricsConfig)
 	config.Spec.Install.Wait = viper.GetBool(installer.WaitConfig)
+	config.Spec.Channel = viper.GetString(installer.ChannelConfig)
 	config.Spec.Install.StorageOSVersion = viper.GetString(installer.StosVersionConfig)
+	config.Spec.Install.KubernetesVersion = viper.GetString(installer.K8sVersionConfig)
 	config.Spec.Install.StorageOSOperatorYaml = viper.GetString(installer.InstallStosOperatorYamlConfig)
 	config.Spec.Install.StorageOSClusterYaml = viper.GetString(installer.InstallStosClusterYamlConfig)
 	config.Spec.Install.StorageOSPortalConfigYaml = viper.GetString(installer.InstallStosPortalConfigYamlConfig)
@@ -260,8 +329,18 @@ func setUpgradeInstallValues(cmd *cobra.Command, config *apiv1.KubectlStorageOSC
 	config.Spec.Install.ResourceQuotaYaml = viper.GetString(installer.InstallResourceQuotaYamlConfig)
 	config.Spec.Install.EtcdEndpoints = viper.GetString(installer.EtcdEndpointsConfig)
 	config.Spec.Install.SkipEtcdEndpointsValidation = viper.GetBool(installer.SkipEtcdEndpointsValConfig)
+	config.Spec.Install.SkipCompatibilityCheck = viper.GetBool(installer.SkipCompatibilityCheckConfig)
 	config.Spec.Install.EtcdTLSEnabled = viper.GetBool(installer.EtcdTLSEnabledConfig)
 	config.Spec.Install.EtcdSecretName = viper.GetString(installer.EtcdSecretNameConfig)
+	config.Spec.Install.EtcdShellImage = viper.GetString(installer.EtcdShellImageConfig)
+	config.Spec.Install.EtcdShellImagePullSecrets = viper.GetString(installer.EtcdShellImagePullSecretsConfig)
+	config.Spec.Install.EtcdShellTolerations = viper.GetString(installer.EtcdShellTolerationsConfig)
+	config.Spec.Install.EtcdShellNodeSelector = viper.GetString(installer.EtcdShellNodeSelectorConfig)
+	config.Spec.Install.EtcdShellRunAsUser = viper.GetString(installer.EtcdShellRunAsUserConfig)
+	config.Spec.Install.EtcdShellRunAsGroup = viper.GetString(installer.EtcdShellRunAsGroupConfig)
+	config.Spec.Install.EtcdShellFSGroup = viper.GetString(installer.EtcdShellFSGroupConfig)
+	config.Spec.Install.EtcdShellRunAsNonRoot = viper.GetBool(installer.EtcdShellRunAsNonRootConfig)
+	config.Spec.Install.EtcdShellSeccompProfile = viper.GetString(installer.EtcdShellSeccompProfileConfig)
 	config.Spec.Install.StorageOSOperatorNamespace = valueOrDefault(viper.GetString(installer.InstallStosOperatorNSConfig), consts.NewOperatorNamespace)
 	config.Spec.Install.StorageOSClusterNamespace = viper.GetString(installer.StosClusterNSConfig)
 	config.Spec.Install.AdminUsername = viper.GetString(installer.AdminUsernameConfig)
@@ -271,6 +350,7 @@ func setUpgradeInstallValues(cmd *cobra.Command, config *apiv1.KubectlStorageOSC
 	config.Spec.Install.PortalAPIURL = viper.GetString(installer.PortalAPIURLConfig)
 	config.Spec.Install.PortalTenantID = viper.GetString(installer.PortalTenantIDConfig)
 	config.InstallerMeta.StorageOSSecretYaml = ""
+	setBackupEncryptionConfigValues(config)
 	return nil
 }
 
@@ -299,6 +379,14 @@ func setUpgradeUninstallValues(cmd *cobra.Command, config *apiv1.KubectlStorageO
 		if err != nil {
 			return err
 		}
+		config.Spec.QuiesceWorkloads, err = cmd.Flags().GetBool(installer.QuiesceWorkloadsFlag)
+		if err != nil {
+			return err
+		}
+		config.Spec.Reinstall, err = cmd.Flags().GetBool(installer.ReinstallFlag)
+		if err != nil {
+			return err
+		}
 
 		config.Spec.IncludeEtcd = false
 		config.Spec.Uninstall.StorageOSOperatorNamespace = cmd.Flags().Lookup(uninstallStosOperatorNSFlag).Value.String()
@@ -307,6 +395,7 @@ func setUpgradeUninstallValues(cmd *cobra.Command, config *apiv1.KubectlStorageO
 		config.Spec.Uninstall.StorageOSPortalConfigYaml = cmd.Flags().Lookup(uninstallStosPortalConfigYamlFlag).Value.String()
 		config.Spec.Uninstall.StorageOSPortalClientSecretYaml = cmd.Flags().Lookup(uninstallStosPortalClientSecretYamlFlag).Value.String()
 		config.Spec.Uninstall.ResourceQuotaYaml = cmd.Flags().Lookup(uninstallResourceQuotaYamlFlag).Value.String()
+		setBackupEncryptionValues(cmd, config)
 
 		return nil
 	}
@@ -315,12 +404,15 @@ func setUpgradeUninstallValues(cmd *cobra.Command, config *apiv1.KubectlStorageO
 	config.Spec.SkipNamespaceDeletion = viper.GetBool(installer.SkipNamespaceDeletionConfig)
 	config.Spec.IncludeEtcd = false
 	config.Spec.SkipStorageOSCluster = viper.GetBool(installer.SkipStosClusterConfig)
+	config.Spec.QuiesceWorkloads = viper.GetBool(installer.QuiesceWorkloadsConfig)
+	config.Spec.Reinstall = viper.GetBool(installer.ReinstallConfig)
 	config.Spec.Uninstall.StorageOSOperatorNamespace = viper.GetString(installer.UninstallStosOperatorNSConfig)
 	config.Spec.Uninstall.StorageOSOperatorYaml = viper.GetString(installer.UninstallStosOperatorYamlConfig)
 	config.Spec.Uninstall.StorageOSClusterYaml = viper.GetString(installer.UninstallStosClusterYamlConfig)
 	config.Spec.Uninstall.StorageOSPortalConfigYaml = viper.GetString(installer.UninstallStosPortalConfigYamlConfig)
 	config.Spec.Uninstall.StorageOSPortalClientSecretYaml = viper.GetString(installer.UninstallStosPortalClientSecretYamlConfig)
 	config.Spec.Uninstall.ResourceQuotaYaml = viper.GetString(installer.UninstallResourceQuotaYamlConfig)
+	setBackupEncryptionConfigValues(config)
 
 	return nil
 }
@@ -1,22 +1,82 @@
 package cli
 
 import (
+	"fmt"
+
 	"github.com/spf13/cobra"
+	"github.com/storageos/kubectl-storageos/pkg/consts"
+	"github.com/storageos/kubectl-storageos/pkg/installer"
 	"github.com/storageos/kubectl-storageos/pkg/logger"
+	pluginutils "github.com/storageos/kubectl-storageos/pkg/utils"
 	"github.com/storageos/kubectl-storageos/pkg/version"
 )
 
+const (
+	versionCommand = "version"
+
+	changelogFlag   = "changelog"
+	checkUpdateFlag = "check-update"
+
+	errChangelogOutput = `
+	--%s is only supported with the %s output format`
+)
+
 func VersionCmd() *cobra.Command {
+	var err error
+	var changelog bool
+	var output string
+	options := installer.VersionReportOptions{}
+	pluginLogger := logger.NewLogger()
 	cmd := &cobra.Command{
-		Use:          "version",
+		Use:          versionCommand,
 		Args:         cobra.MinimumNArgs(0),
-		Short:        "Show kubectl storageos version",
-		Long:         `Show kubectl storageos version`,
+		Short:        "Show kubectl storageos version and the versions of StorageOS installed in the cluster",
+		Long:         `Show the versions of kubectl storageos, of Kubernetes and of the StorageOS operator, ETCD operator and portal manager installed in the cluster, alongside the latest releases and the commands to upgrade to them. With --check-update, kubectl storageos itself is checked for a newer release. With --changelog, the release notes of every StorageOS operator version after the installed one up to --stos-version, the latest by default, are shown and breaking changes are highlighted.`,
 		SilenceUsage: true,
 		Run: func(cmd *cobra.Command, args []string) {
-			logger.NewLogger().Infof("%s", version.PluginVersion)
+			defer pluginutils.ConvertPanicToError(func(e error) {
+				err = e
+			})
+
+			if err = setReleaseChannel(cmd.Flags().Lookup(installer.ChannelFlag).Value.String()); err != nil {
+				return
+			}
+			if changelog && output != installer.VersionOutputTable {
+				err = fmt.Errorf(errChangelogOutput, changelogFlag, installer.VersionOutputTable)
+				return
+			}
+			if err = installer.PrintVersionReport(options, output, pluginLogger); err != nil || !changelog {
+				return
+			}
+			err = changelogCmd(options.OperatorNamespace, cmd.Flags().Lookup(installer.StosVersionFlag).Value.String(), pluginLogger)
+		},
+		PostRunE: func(cmd *cobra.Command, args []string) error {
+			if err := pluginutils.HandleError(versionCommand, err, false); err != nil {
+				pluginLogger.Error(fmt.Sprintf("%s%s", versionCommand, " has failed"))
+				return err
+			}
+			return nil
 		},
 	}
+	cmd.Flags().StringVarP(&output, installer.OutputFlag, "o", installer.VersionOutputTable, "output format: "+installer.VersionOutputTable+", "+installer.VersionOutputJSON)
+	cmd.Flags().BoolVar(&options.CheckUpdate, checkUpdateFlag, false, "check whether a newer kubectl storageos release exists")
+	cmd.Flags().StringVar(&options.OperatorNamespace, installer.StosOperatorNSFlag, consts.NewOperatorNamespace, "namespace of storageos operator")
+	cmd.Flags().StringVar(&options.EtcdNamespace, installer.EtcdNamespaceFlag, consts.EtcdOperatorNamespace, "namespace of etcd operator")
+	cmd.Flags().BoolVar(&changelog, changelogFlag, false, "show the release notes between the installed storageos operator version and --stos-version")
+	cmd.Flags().String(installer.StosVersionFlag, "", "version of storageos operator to show the release notes up to, defaults to the latest")
+	cmd.Flags().String(installer.ChannelFlag, "", "release channel to look up the latest versions from: stable, prerelease or develop")
 
 	return cmd
 }
+
+func changelogCmd(operatorNamespace, targetVersion string, log *logger.Logger) error {
+	existingVersion, err := version.GetExistingOperatorVersion(operatorNamespace)
+	if err != nil {
+		return err
+	}
+	if targetVersion == "" {
+		targetVersion = version.OperatorLatestSupportedVersion()
+	}
+
+	return installer.PrintChangelog(existingVersion, targetVersion, log)
+}
@@ -0,0 +1,61 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	apiv1 "github.com/storageos/kubectl-storageos/api/v1"
+	"github.com/storageos/kubectl-storageos/pkg/installer"
+	"github.com/storageos/kubectl-storageos/pkg/logger"
+	pluginutils "github.com/storageos/kubectl-storageos/pkg/utils"
+)
+
+const workloadsResume = "resume"
+
+func WorkloadsResumeCmd() *cobra.Command {
+	var err error
+	var traceError bool
+	pluginLogger := logger.NewLogger()
+	cmd := &cobra.Command{
+		Use:          workloadsResume,
+		Args:         cobra.NoArgs,
+		Short:        "Resume the workloads quiesced by upgrade",
+		Long:         `Restore the original replicas of the Deployments and StatefulSets and the suspension of the CronJobs quiesced by upgrade --quiesce-workloads, from the state recorded on disk. Only needed if the upgrade did not complete.`,
+		SilenceUsage: true,
+		Run: func(cmd *cobra.Command, args []string) {
+			defer pluginutils.ConvertPanicToError(func(e error) {
+				err = e
+			})
+
+			config := &apiv1.KubectlStorageOSConfig{}
+			if err = setWorkloadsValues(cmd, config); err != nil {
+				return
+			}
+
+			traceError = config.Spec.StackTrace
+
+			err = workloadsResumeCmd(config, pluginLogger)
+		},
+		PostRunE: func(cmd *cobra.Command, args []string) error {
+			if err := pluginutils.HandleError(workloadsResume, err, traceError); err != nil {
+				pluginLogger.Error(fmt.Sprintf("%s%s", workloadsResume, " has failed"))
+				return err
+			}
+			return nil
+		},
+	}
+	addWorkloadsFlags(cmd)
+
+	return cmd
+}
+
+func workloadsResumeCmd(config *apiv1.KubectlStorageOSConfig, log *logger.Logger) error {
+	log.Verbose = config.Spec.Verbose
+
+	cliInstaller, err := installer.NewWorkloadsInstaller(config, log)
+	if err != nil {
+		return err
+	}
+
+	return cliInstaller.ResumeWorkloads()
+}
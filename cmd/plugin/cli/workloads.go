@@ -0,0 +1,83 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	apiv1 "github.com/storageos/kubectl-storageos/api/v1"
+	"github.com/storageos/kubectl-storageos/pkg/installer"
+	"github.com/storageos/kubectl-storageos/pkg/logger"
+	pluginutils "github.com/storageos/kubectl-storageos/pkg/utils"
+)
+
+const workloads = "workloads"
+
+func WorkloadsCmd() *cobra.Command {
+	var err error
+	var traceError bool
+	var output string
+	pluginLogger := logger.NewLogger()
+	cmd := &cobra.Command{
+		Use:          workloads,
+		Args:         cobra.NoArgs,
+		Short:        "Report the workloads using StorageOS volumes",
+		Long:         `Report every bound PVC provisioned by StorageOS with its persistent volume, the pods mounting it, the Deployments, StatefulSets, DaemonSets, Jobs and CronJobs owning those pods and the nodes they run on. Uninstall and upgrade are blocked while any of these pods exist.`,
+		SilenceUsage: true,
+		Run: func(cmd *cobra.Command, args []string) {
+			defer pluginutils.ConvertPanicToError(func(e error) {
+				err = e
+			})
+
+			config := &apiv1.KubectlStorageOSConfig{}
+			if err = setWorkloadsValues(cmd, config); err != nil {
+				return
+			}
+
+			traceError = config.Spec.StackTrace
+
+			err = workloadsCmd(config, output, pluginLogger)
+		},
+		PostRunE: func(cmd *cobra.Command, args []string) error {
+			if err := pluginutils.HandleError(workloads, err, traceError); err != nil {
+				pluginLogger.Error(fmt.Sprintf("%s%s", workloads, " has failed"))
+				return err
+			}
+			return nil
+		},
+	}
+	addWorkloadsFlags(cmd)
+	cmd.Flags().StringVarP(&output, installer.OutputFlag, "o", installer.WorkloadsOutputTable, "output format: "+installer.WorkloadsOutputTable+", "+installer.WorkloadsOutputJSON)
+
+	cmd.AddCommand(WorkloadsResumeCmd())
+
+	return cmd
+}
+
+func workloadsCmd(config *apiv1.KubectlStorageOSConfig, output string, log *logger.Logger) error {
+	log.Verbose = config.Spec.Verbose
+
+	cliInstaller, err := installer.NewWorkloadsInstaller(config, log)
+	if err != nil {
+		return err
+	}
+
+	return cliInstaller.PrintWorkloadReport(output)
+}
+
+// addWorkloadsFlags adds the flags shared by the workloads commands
+func addWorkloadsFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool(installer.StackTraceFlag, false, "print stack trace of error")
+	cmd.Flags().BoolP(installer.VerboseFlag, "v", false, "verbose logging")
+}
+
+// setWorkloadsValues sets the values of the flags added by addWorkloadsFlags in config
+func setWorkloadsValues(cmd *cobra.Command, config *apiv1.KubectlStorageOSConfig) error {
+	var err error
+	config.Spec.StackTrace, err = cmd.Flags().GetBool(installer.StackTraceFlag)
+	if err != nil {
+		return err
+	}
+	config.Spec.Verbose, err = cmd.Flags().GetBool(installer.VerboseFlag)
+
+	return err
+}
@@ -51,7 +51,7 @@ diff "$root/test-data/resource-quota.yaml" "${TMPDIR}/storageos-dry-run/"*-resou
 rm -rf storageos-dry-run
 
 #  Run install --dry-run command and move output to tmpdir
-kubectl storageos install "${kargs[@]}" --k8s-version=v1.22.0 --enable-portal-manager --portal-api-url=www.test.com --portal-tenant-id=storageos --portal-client-id=storageos --portal-secret=storageos --etcd-endpoints=storageos-etcd.storageos-etcd:2379
+kubectl storageos install "${kargs[@]}" --k8s-version=v1.22.0 --enable-portal-manager --portal-api-url=www.test.com --portal-tenant-id=storageos --portal-client-id=storageos --portal-secret=storageos --skip-portal-credentials-validation --etcd-endpoints=storageos-etcd.storageos-etcd:2379
 # Fetch portal-configmap
 wget -q https://github.com/storageos/kubectl-storageos/releases/download/v1.0.0/configmap-storageos-portal-manager.yaml -P "${TMPDIR}/"
 # Compare portal manifests generated by dry-run with test data
@@ -18,4 +18,7 @@ const (
 	EtcdOperatorNamespace = "storageos-etcd"
 
 	EtcdSecretName = "storageos-etcd-secret"
+
+	PortalManagerName      = "storageos-portal-manager"
+	PortalClientSecretName = "storageos-portal-client"
 )
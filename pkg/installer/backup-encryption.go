@@ -0,0 +1,402 @@
+package installer
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/storageos/kubectl-storageos/pkg/logger"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/hkdf"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	// encryptedFileSuffix is appended to the name of a backup file written encrypted
+	encryptedFileSuffix = ".enc"
+	// backupEncryptionFormatVersion is the version of encryptedBackupFile
+	backupEncryptionFormatVersion = 1
+
+	encryptionPassphrase = "passphrase"
+	encryptionX25519     = "x25519"
+
+	// scrypt parameters recommended for interactive use
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+
+	backupKeySize  = 32
+	backupSaltSize = 16
+	backupKeyInfo  = "kubectl-storageos backup"
+
+	errBackupEncryptionConflict = `
+	Only one of --%s and --%s can be set to encrypt backups`
+
+	errBackupKeyRequired = `
+	%s is encrypted with a %s, set --%s to decrypt it`
+
+	errBackupDecryptionFailed = `
+	Unable to decrypt %s, the %s does not match the one it was encrypted with`
+
+	errInvalidBackupRecipient = `
+	Invalid backup recipient %q, expected the base64 X25519 public key printed by:
+
+	kubectl storageos backup keygen`
+
+	errInvalidBackupIdentity = `
+	Invalid backup identity %s, expected the base64 X25519 private key written by:
+
+	kubectl storageos backup keygen`
+
+	errEmptyBackupPassphrase = `
+	Backup passphrase file %s is empty`
+
+	errBackupKeyExists = `
+	%s already exists, remove it or set another path with --output`
+
+	errBackupNotEncrypted = `
+	Backup %s has no encrypted files`
+
+	backupKeyGeneratedMessage = `Private key written to %s, public key: %s
+
+	Encrypt backups with --%s=%s and decrypt them with --%s=%s.`
+	backupDecryptedMessage = `Decrypted %s to %s.`
+)
+
+// encryptedBackupFile is the content of an encrypted backup file
+type encryptedBackupFile struct {
+	FormatVersion int    `json:"formatVersion"`
+	Encryption    string `json:"encryption"`
+	// Salt of the scrypt key derivation, passphrase only
+	Salt []byte `json:"salt,omitempty"`
+	// EphemeralPublicKey and RecipientPublicKey of the key exchange, x25519 only
+	EphemeralPublicKey []byte `json:"ephemeralPublicKey,omitempty"`
+	RecipientPublicKey []byte `json:"recipientPublicKey,omitempty"`
+	Nonce              []byte `json:"nonce"`
+	Ciphertext         []byte `json:"ciphertext"`
+}
+
+// backupKeys holds the keys used to encrypt and decrypt backup files. Encryption uses the recipient
+// if set, otherwise the passphrase. No key means backup files are written in plaintext.
+type backupKeys struct {
+	passphrase []byte
+	recipient  []byte
+	identity   []byte
+}
+
+// encryption returns the encryption of files written with keys, empty for plaintext
+func (k backupKeys) encryption() string {
+	switch {
+	case k.recipient != nil:
+		return encryptionX25519
+	case k.passphrase != nil:
+		return encryptionPassphrase
+	}
+	return ""
+}
+
+// backupKeys reads the backup keys set in the config of the installer
+func (in *Installer) backupKeys() (backupKeys, error) {
+	keys := backupKeys{}
+	config := in.stosConfig.Spec.Backup
+	if config.PassphraseFile != "" && config.Recipient != "" {
+		return keys, fmt.Errorf(errBackupEncryptionConflict, BackupPassphraseFileFlag, BackupRecipientFlag)
+	}
+
+	if config.PassphraseFile != "" {
+		passphrase, err := os.ReadFile(config.PassphraseFile)
+		if err != nil {
+			return keys, errors.WithStack(err)
+		}
+		keys.passphrase = bytes.TrimRight(passphrase, "\r\n")
+		if len(keys.passphrase) == 0 {
+			return keys, fmt.Errorf(errEmptyBackupPassphrase, config.PassphraseFile)
+		}
+	}
+	if config.Recipient != "" {
+		recipient, err := base64.StdEncoding.DecodeString(config.Recipient)
+		if err != nil || len(recipient) != curve25519.PointSize {
+			return keys, fmt.Errorf(errInvalidBackupRecipient, config.Recipient)
+		}
+		keys.recipient = recipient
+	}
+	if config.IdentityFile != "" {
+		encoded, err := os.ReadFile(config.IdentityFile)
+		if err != nil {
+			return keys, errors.WithStack(err)
+		}
+		identity, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(encoded)))
+		if err != nil || len(identity) != curve25519.ScalarSize {
+			return keys, fmt.Errorf(errInvalidBackupIdentity, config.IdentityFile)
+		}
+		keys.identity = identity
+	}
+
+	return keys, nil
+}
+
+// writeSensitiveBackupFile writes data to path of the on-disk filesystem, encrypted to
+// path+encryptedFileSuffix if backup keys are set
+func (in *Installer) writeSensitiveBackupFile(path string, data []byte) error {
+	keys, err := in.backupKeys()
+	if err != nil {
+		return err
+	}
+	if keys.encryption() == "" {
+		return errors.WithStack(in.onDiskFileSys.WriteFile(path, data))
+	}
+
+	encrypted, err := encryptBackupData(data, keys)
+	if err != nil {
+		return err
+	}
+	if err = os.WriteFile(path+encryptedFileSuffix, encrypted, 0600); err != nil {
+		return errors.WithStack(err)
+	}
+	if in.decryptedBackupFiles == nil {
+		in.decryptedBackupFiles = map[string][]byte{}
+	}
+	in.decryptedBackupFiles[path] = data
+
+	return nil
+}
+
+// backupFileExists returns true if the backup file of path exists, in plaintext or encrypted
+func (in *Installer) backupFileExists(path string) bool {
+	return in.onDiskFileSys.Exists(path) || in.onDiskFileSys.Exists(path+encryptedFileSuffix)
+}
+
+// readBackupFile returns the content of the backup file of path, decrypting it if it was written
+// encrypted
+func (in *Installer) readBackupFile(path string) ([]byte, error) {
+	if data, ok := in.decryptedBackupFiles[path]; ok {
+		return data, nil
+	}
+	if !in.onDiskFileSys.Exists(path + encryptedFileSuffix) {
+		data, err := in.onDiskFileSys.ReadFile(path)
+		return data, errors.WithStack(err)
+	}
+
+	encrypted, err := in.onDiskFileSys.ReadFile(path + encryptedFileSuffix)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	keys, err := in.backupKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	return decryptBackupData(filepath.Base(path), encrypted, keys)
+}
+
+// encryptBackupData encrypts plaintext with XChaCha20-Poly1305. The key is derived from the recipient
+// public key by X25519 key exchange with an ephemeral key, or from the passphrase by scrypt.
+func encryptBackupData(plaintext []byte, keys backupKeys) ([]byte, error) {
+	file := &encryptedBackupFile{
+		FormatVersion: backupEncryptionFormatVersion,
+		Encryption:    keys.encryption(),
+	}
+
+	var key []byte
+	var err error
+	switch file.Encryption {
+	case encryptionX25519:
+		ephemeral := make([]byte, curve25519.ScalarSize)
+		if _, err = rand.Read(ephemeral); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if file.EphemeralPublicKey, err = curve25519.X25519(ephemeral, curve25519.Basepoint); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		file.RecipientPublicKey = keys.recipient
+		if key, err = x25519BackupKey(ephemeral, keys.recipient, file.EphemeralPublicKey, keys.recipient); err != nil {
+			return nil, err
+		}
+	case encryptionPassphrase:
+		file.Salt = make([]byte, backupSaltSize)
+		if _, err = rand.Read(file.Salt); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if key, err = scrypt.Key(keys.passphrase, file.Salt, scryptN, scryptR, scryptP, backupKeySize); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	default:
+		return nil, errors.New("no backup encryption key set")
+	}
+
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	file.Nonce = make([]byte, aead.NonceSize())
+	if _, err = rand.Read(file.Nonce); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	file.Ciphertext = aead.Seal(nil, file.Nonce, plaintext, nil)
+
+	data, err := json.MarshalIndent(file, "", "  ")
+
+	return data, errors.WithStack(err)
+}
+
+// decryptBackupData decrypts data written by encryptBackupData, name is the file name used in errors
+func decryptBackupData(name string, data []byte, keys backupKeys) ([]byte, error) {
+	file := &encryptedBackupFile{}
+	if err := json.Unmarshal(data, file); err != nil {
+		return nil, errors.Wrapf(err, "unable to parse encrypted file %s", name)
+	}
+	if file.FormatVersion > backupEncryptionFormatVersion {
+		return nil, fmt.Errorf("%s was encrypted by a newer version of kubectl-storageos", name)
+	}
+
+	var key []byte
+	var err error
+	var keyDescription string
+	switch file.Encryption {
+	case encryptionX25519:
+		keyDescription = "private key"
+		if keys.identity == nil {
+			return nil, fmt.Errorf(errBackupKeyRequired, name, keyDescription, BackupIdentityFlag)
+		}
+		var publicKey []byte
+		if publicKey, err = curve25519.X25519(keys.identity, curve25519.Basepoint); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if !bytes.Equal(publicKey, file.RecipientPublicKey) {
+			return nil, fmt.Errorf(errBackupDecryptionFailed, name, keyDescription)
+		}
+		if key, err = x25519BackupKey(keys.identity, file.EphemeralPublicKey, file.EphemeralPublicKey, file.RecipientPublicKey); err != nil {
+			return nil, err
+		}
+	case encryptionPassphrase:
+		keyDescription = "passphrase"
+		if keys.passphrase == nil {
+			return nil, fmt.Errorf(errBackupKeyRequired, name, keyDescription, BackupPassphraseFileFlag)
+		}
+		if key, err = scrypt.Key(keys.passphrase, file.Salt, scryptN, scryptR, scryptP, backupKeySize); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	default:
+		return nil, fmt.Errorf("%s is encrypted with unsupported encryption %q", name, file.Encryption)
+	}
+
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	plaintext, err := aead.Open(nil, file.Nonce, file.Ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf(errBackupDecryptionFailed, name, keyDescription)
+	}
+
+	return plaintext, nil
+}
+
+// x25519BackupKey derives the file key from the X25519 shared secret of scalar and point, bound to
+// the public keys of the exchange
+func x25519BackupKey(scalar, point, ephemeralPublicKey, recipientPublicKey []byte) ([]byte, error) {
+	shared, err := curve25519.X25519(scalar, point)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	salt := append(append([]byte{}, ephemeralPublicKey...), recipientPublicKey...)
+	key := make([]byte, backupKeySize)
+	if _, err = io.ReadFull(hkdf.New(sha256.New, shared, salt, []byte(backupKeyInfo)), key); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return key, nil
+}
+
+// generateBackupKey returns a new base64 X25519 private key and its public key
+func generateBackupKey() (string, string, error) {
+	identity := make([]byte, curve25519.ScalarSize)
+	if _, err := rand.Read(identity); err != nil {
+		return "", "", errors.WithStack(err)
+	}
+	recipient, err := curve25519.X25519(identity, curve25519.Basepoint)
+	if err != nil {
+		return "", "", errors.WithStack(err)
+	}
+
+	return base64.StdEncoding.EncodeToString(identity), base64.StdEncoding.EncodeToString(recipient), nil
+}
+
+// GenerateBackupKey writes a new X25519 private key to output and prints its public key, used to
+// encrypt backups with --backup-recipient
+func GenerateBackupKey(output string, log *logger.Logger) error {
+	if _, err := os.Stat(output); err == nil {
+		return fmt.Errorf(errBackupKeyExists, output)
+	}
+	identity, recipient, err := generateBackupKey()
+	if err != nil {
+		return err
+	}
+	if err = os.WriteFile(output, []byte(identity+"\n"), 0600); err != nil {
+		return errors.WithStack(err)
+	}
+	log.Successf(backupKeyGeneratedMessage, output, recipient, BackupRecipientFlag, recipient, BackupIdentityFlag, output)
+
+	return nil
+}
+
+// DecryptBackup decrypts the encrypted files of backup name to the directory output, or prints them
+// to stdout as a single multidoc manifest if output is empty
+func (in *Installer) DecryptBackup(name, output string) error {
+	root, err := in.getBackupRootPath()
+	if err != nil {
+		return err
+	}
+	b, err := findBackup(root, name)
+	if err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(b.path)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	files := make([]string, 0)
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), encryptedFileSuffix) {
+			files = append(files, strings.TrimSuffix(entry.Name(), encryptedFileSuffix))
+		}
+	}
+	if len(files) == 0 {
+		return fmt.Errorf(errBackupNotEncrypted, b.name)
+	}
+
+	if output != "" {
+		if err = os.MkdirAll(output, 0700); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	manifests := make([]string, 0, len(files))
+	for _, file := range files {
+		plaintext, err := in.readBackupFile(filepath.Join(b.path, file))
+		if err != nil {
+			return err
+		}
+		if output == "" {
+			manifests = append(manifests, strings.TrimSpace(string(plaintext)))
+			continue
+		}
+		if err = os.WriteFile(filepath.Join(output, file), plaintext, 0600); err != nil {
+			return errors.WithStack(err)
+		}
+		in.log.Successf(backupDecryptedMessage, file, filepath.Join(output, file))
+	}
+	if output == "" {
+		fmt.Fprintln(in.log.Writer, makeMultiDoc(manifests...))
+	}
+
+	return nil
+}
@@ -0,0 +1,157 @@
+package installer
+
+import (
+	"bytes"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+
+	apiv1 "github.com/storageos/kubectl-storageos/api/v1"
+	"sigs.k8s.io/kustomize/api/filesys"
+)
+
+func TestBackupDataEncryption(t *testing.T) {
+	identity, recipient, err := generateBackupKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherIdentity, _, err := generateBackupKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decode := func(key string) []byte {
+		decoded, err := base64.StdEncoding.DecodeString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return decoded
+	}
+
+	tcases := []struct {
+		name        string
+		encryptKeys backupKeys
+		decryptKeys backupKeys
+		expErr      bool
+	}{
+		{
+			name:        "passphrase",
+			encryptKeys: backupKeys{passphrase: []byte("correct horse")},
+			decryptKeys: backupKeys{passphrase: []byte("correct horse")},
+		},
+		{
+			name:        "wrong passphrase",
+			encryptKeys: backupKeys{passphrase: []byte("correct horse")},
+			decryptKeys: backupKeys{passphrase: []byte("battery staple")},
+			expErr:      true,
+		},
+		{
+			name:        "passphrase not set",
+			encryptKeys: backupKeys{passphrase: []byte("correct horse")},
+			decryptKeys: backupKeys{identity: decode(identity)},
+			expErr:      true,
+		},
+		{
+			name:        "x25519",
+			encryptKeys: backupKeys{recipient: decode(recipient)},
+			decryptKeys: backupKeys{identity: decode(identity)},
+		},
+		{
+			name:        "wrong private key",
+			encryptKeys: backupKeys{recipient: decode(recipient)},
+			decryptKeys: backupKeys{identity: decode(otherIdentity)},
+			expErr:      true,
+		},
+		{
+			name:        "private key not set",
+			encryptKeys: backupKeys{recipient: decode(recipient)},
+			decryptKeys: backupKeys{passphrase: []byte("correct horse")},
+			expErr:      true,
+		},
+	}
+
+	plaintext := []byte("apiVersion: v1\nkind: Secret\n")
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			encrypted, err := encryptBackupData(plaintext, tc.encryptKeys)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if bytes.Contains(encrypted, plaintext) {
+				t.Fatalf("encrypted data contains plaintext")
+			}
+
+			decrypted, err := decryptBackupData("storageos-secrets.yaml", encrypted, tc.decryptKeys)
+			if (err != nil) != tc.expErr {
+				t.Fatalf("expected error %v, got %v", tc.expErr, err)
+			}
+			if !tc.expErr && !bytes.Equal(decrypted, plaintext) {
+				t.Errorf("expected %q, got %q", plaintext, decrypted)
+			}
+		})
+	}
+}
+
+func TestSensitiveBackupFile(t *testing.T) {
+	dir := t.TempDir()
+	passphraseFile := filepath.Join(dir, "passphrase")
+	if err := os.WriteFile(passphraseFile, []byte("correct horse\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	newInstaller := func(backup apiv1.Backup) *Installer {
+		config := &apiv1.KubectlStorageOSConfig{}
+		config.Spec.Backup = backup
+		return &Installer{stosConfig: config, onDiskFileSys: filesys.MakeFsOnDisk()}
+	}
+	data := []byte("apiVersion: v1\nkind: Secret\n")
+
+	// plaintext without keys
+	plainPath := filepath.Join(dir, "plain", stosSecretsFile)
+	if err := os.MkdirAll(filepath.Dir(plainPath), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := newInstaller(apiv1.Backup{}).writeSensitiveBackupFile(plainPath, data); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if written, err := os.ReadFile(plainPath); err != nil || !bytes.Equal(written, data) {
+		t.Errorf("expected plaintext file, got %q, %v", written, err)
+	}
+
+	// encrypted with passphrase, read back by the writer and by a new installer
+	encryptedPath := filepath.Join(dir, "encrypted", stosSecretsFile)
+	if err := os.MkdirAll(filepath.Dir(encryptedPath), 0700); err != nil {
+		t.Fatal(err)
+	}
+	writer := newInstaller(apiv1.Backup{PassphraseFile: passphraseFile})
+	if err := writer.writeSensitiveBackupFile(encryptedPath, data); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := os.Stat(encryptedPath); !os.IsNotExist(err) {
+		t.Errorf("expected no plaintext file, got %v", err)
+	}
+	if !writer.backupFileExists(encryptedPath) {
+		t.Errorf("expected encrypted file to exist")
+	}
+	for name, reader := range map[string]*Installer{
+		"writer":     writer,
+		"passphrase": newInstaller(apiv1.Backup{PassphraseFile: passphraseFile}),
+	} {
+		read, err := reader.readBackupFile(encryptedPath)
+		if err != nil || !bytes.Equal(read, data) {
+			t.Errorf("%s: expected %q, got %q, %v", name, data, read, err)
+		}
+	}
+	if _, err := newInstaller(apiv1.Backup{}).readBackupFile(encryptedPath); err == nil {
+		t.Errorf("expected error reading encrypted file without passphrase")
+	}
+
+	// a passphrase and a recipient cannot both be set
+	_, recipient, err := generateBackupKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	conflict := newInstaller(apiv1.Backup{PassphraseFile: passphraseFile, Recipient: recipient})
+	if err = conflict.writeSensitiveBackupFile(encryptedPath, data); err == nil {
+		t.Errorf("expected error with both passphrase and recipient set")
+	}
+}
@@ -0,0 +1,646 @@
+package installer
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	pluginversion "github.com/storageos/kubectl-storageos/pkg/version"
+	operatorapi "github.com/storageos/operator/api/v1"
+)
+
+const (
+	backupMetadataFile = "metadata.json"
+	// backupFormatVersion is the version of the backup layout, it is incremented whenever files or
+	// metadata change in a way older plugins cannot read or change meaning. Version 2 records the
+	// operator version as the StorageOS version, version 1 recorded the node image tag.
+	backupFormatVersion = 2
+	backupTimeFormat    = "20060102T150405Z"
+	// legacyBackupName is the name of the backup written directly to the backup directory by
+	// plugins prior to versioned backups
+	legacyBackupName = "legacy"
+	// unknownStorageOSVersion is recorded when the version of the backed-up cluster cannot be found
+	unknownStorageOSVersion = "unknown"
+	// backupOperatorVersionFormat is the first format version recording the operator version
+	backupOperatorVersionFormat = 2
+
+	// the subcommands recorded in backup metadata, rollback only restores upgrade backups
+	backupSubcommandUpgrade         = "upgrade"
+	backupSubcommandUninstall       = "uninstall"
+	backupSubcommandUninstallPortal = "uninstall-portal"
+	backupSubcommandRotatePortal    = "portal rotate-credentials"
+
+	backupStatusOK       = "OK"
+	backupStatusModified = "MODIFIED"
+	backupStatusMissing  = "MISSING"
+	backupStatusUnknown  = "UNVERIFIED"
+
+	errBackupNotFound = `
+	Backup %s not found in %s, list backups with:
+
+	kubectl storageos backup list`
+
+	errNoBackups = `
+	No backups found in %s`
+
+	errPruneCriteriaRequired = `
+	At least one of --%s or --%s is required to prune backups`
+
+	errBackupModified = `
+	File(s) %s of backup %s do not match the checksums recorded at backup time`
+
+	noBackupsMessage        = `No backups found in %s.`
+	noBackupsToPruneMessage = `No backups to prune.`
+	backupPrunedMessage     = `Backup %s pruned.`
+	backupPruneDryRun       = `Backup %s would be pruned.`
+	backupExportedMessage   = `Backup %s exported to %s.`
+)
+
+// sensitiveFlagPatterns are the substrings of flag names whose values are redacted from the command
+// recorded in backup metadata
+var sensitiveFlagPatterns = []string{"password", "passphrase", PortalSecretFlag}
+
+// backupMetadata is written to every backup, recording how it was created and the checksum of each
+// file. StorageOSVersion is the version of the StorageOS operator and NodeImage the node container
+// image of the StorageOS cluster, if set. Subcommand is the plugin subcommand which took the backup,
+// empty for backups written before it was recorded.
+type backupMetadata struct {
+	FormatVersion    int               `json:"formatVersion"`
+	Name             string            `json:"name"`
+	ClusterID        string            `json:"clusterID"`
+	StorageOSVersion string            `json:"storageOSVersion"`
+	NodeImage        string            `json:"nodeImage,omitempty"`
+	PluginVersion    string            `json:"pluginVersion"`
+	Command          string            `json:"command"`
+	Subcommand       string            `json:"subcommand,omitempty"`
+	CreatedAt        time.Time         `json:"createdAt"`
+	Checksums        map[string]string `json:"checksums"`
+	// Encryption of the secrets of the backup, empty if they are not encrypted
+	Encryption string `json:"encryption,omitempty"`
+}
+
+// operatorVersion returns the version of the StorageOS operator recorded in m, or an empty string
+// if m is nil or was written before the operator version was recorded
+func (m *backupMetadata) operatorVersion() string {
+	if m == nil || m.FormatVersion < backupOperatorVersionFormat {
+		return ""
+	}
+
+	return m.StorageOSVersion
+}
+
+// backup is a single backup on disk. Metadata is nil for the legacy backup.
+type backup struct {
+	name     string
+	path     string
+	metadata *backupMetadata
+}
+
+// createdAt returns the creation time of the backup, the modification time of the storageos
+// cluster manifest for the legacy backup
+func (b backup) createdAt() time.Time {
+	if b.metadata != nil {
+		return b.metadata.CreatedAt
+	}
+	info, err := os.Stat(filepath.Join(b.path, stosClusterFile))
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime().UTC()
+}
+
+// getBackupRootPath returns the path to the on-disk directory holding the backups of this cluster
+func (in *Installer) getBackupRootPath() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	return filepath.Join(homeDir, kubeDir, stosDir, fmt.Sprintf("%s%v", UninstallPrefix, in.kubeClusterID)), nil
+}
+
+// getBackupPath returns the path to the on-disk directory of the latest backup, where uninstalled
+// manifests are read from.
+func (in *Installer) getBackupPath() (string, error) {
+	root, err := in.getBackupRootPath()
+	if err != nil {
+		return "", err
+	}
+	backups, err := listBackups(root)
+	if err != nil {
+		return "", err
+	}
+	if len(backups) == 0 {
+		return root, nil
+	}
+
+	return backups[len(backups)-1].path, nil
+}
+
+// newBackupPath creates and returns the directory of a new backup named after the current time
+func (in *Installer) newBackupPath() (string, error) {
+	root, err := in.getBackupRootPath()
+	if err != nil {
+		return "", err
+	}
+	name := time.Now().UTC().Format(backupTimeFormat)
+	path := filepath.Join(root, name)
+	for i := 2; ; i++ {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			break
+		}
+		path = filepath.Join(root, fmt.Sprintf("%s-%d", name, i))
+	}
+	if err = os.MkdirAll(path, 0700); err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	return path, nil
+}
+
+// writeBackupMetadata records metadata and the checksum of every file of the backup at path, taken
+// by subcommand
+func (in *Installer) writeBackupMetadata(path string, storageOSCluster *operatorapi.StorageOSCluster, subcommand string) error {
+	checksums, err := backupChecksums(path)
+	if err != nil {
+		return err
+	}
+	keys, err := in.backupKeys()
+	if err != nil {
+		return err
+	}
+	metadata := &backupMetadata{
+		FormatVersion:    backupFormatVersion,
+		Name:             filepath.Base(path),
+		ClusterID:        string(in.kubeClusterID),
+		StorageOSVersion: in.backupOperatorVersion(),
+		NodeImage:        storageOSCluster.Spec.Images.NodeContainer,
+		PluginVersion:    pluginversion.PluginVersion,
+		Command:          redactCommand(os.Args),
+		Subcommand:       subcommand,
+		CreatedAt:        time.Now().UTC(),
+		Checksums:        checksums,
+		Encryption:       keys.encryption(),
+	}
+	data, err := json.MarshalIndent(metadata, "", "  ")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	return errors.WithStack(os.WriteFile(filepath.Join(path, backupMetadataFile), data, 0600))
+}
+
+// backupOperatorVersion returns the version of the StorageOS operator being backed up, looked up in
+// the uninstall operator namespace, or the install one for commands which only set that. "unknown"
+// is returned if it cannot be found, a backup is never prevented by its metadata.
+func (in *Installer) backupOperatorVersion() string {
+	namespace := getStringWithDefault(in.stosConfig.Spec.Uninstall.StorageOSOperatorNamespace, in.stosConfig.Spec.Install.StorageOSOperatorNamespace)
+	version, err := pluginversion.GetExistingOperatorVersion(namespace)
+	if err != nil {
+		return unknownStorageOSVersion
+	}
+
+	return version
+}
+
+// backupChecksums returns the sha256 checksum of every file of the backup at path
+func backupChecksums(path string) (map[string]string, error) {
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	checksums := map[string]string{}
+	for _, entry := range entries {
+		if entry.IsDir() || entry.Name() == backupMetadataFile {
+			continue
+		}
+		checksum, err := fileChecksum(filepath.Join(path, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		checksums[entry.Name()] = checksum
+	}
+
+	return checksums, nil
+}
+
+// fileChecksum returns the hex encoded sha256 checksum of the file at path
+func fileChecksum(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err = io.Copy(hash, file); err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// redactCommand returns args as a single command, replacing the values of sensitive flags
+func redactCommand(args []string) string {
+	redacted := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") || !isSensitiveFlag(arg) {
+			redacted = append(redacted, arg)
+			continue
+		}
+		if name := strings.SplitN(arg, "=", 2); len(name) == 2 {
+			redacted = append(redacted, name[0]+"=<redacted>")
+			continue
+		}
+		redacted = append(redacted, arg)
+		// the value is the next argument unless it is another flag
+		if i+1 < len(args) && !strings.HasPrefix(args[i+1], "-") {
+			redacted = append(redacted, "<redacted>")
+			i++
+		}
+	}
+	if len(redacted) != 0 {
+		redacted[0] = filepath.Base(redacted[0])
+	}
+
+	return strings.Join(redacted, " ")
+}
+
+// isSensitiveFlag returns true if the name of flag, eg. "--admin-password=..." or "--admin-password",
+// matches one of sensitiveFlagPatterns
+func isSensitiveFlag(flag string) bool {
+	name := strings.ToLower(strings.SplitN(strings.TrimLeft(flag, "-"), "=", 2)[0])
+	for _, pattern := range sensitiveFlagPatterns {
+		if strings.Contains(name, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// listBackups returns the backups in root ordered by creation time, oldest first. Manifests written
+// directly to root by plugins prior to versioned backups are returned as the legacy backup.
+func listBackups(root string) ([]backup, error) {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []backup{}, nil
+		}
+		return nil, errors.WithStack(err)
+	}
+
+	backups := make([]backup, 0)
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		path := filepath.Join(root, entry.Name())
+		metadata, err := readBackupMetadata(path)
+		if err != nil {
+			// not a backup
+			continue
+		}
+		backups = append(backups, backup{name: entry.Name(), path: path, metadata: metadata})
+	}
+	if _, err := os.Stat(filepath.Join(root, stosClusterFile)); err == nil {
+		backups = append(backups, backup{name: legacyBackupName, path: root})
+	}
+
+	sort.SliceStable(backups, func(i, j int) bool {
+		return backups[i].createdAt().Before(backups[j].createdAt())
+	})
+
+	return backups, nil
+}
+
+// readBackupMetadata reads the metadata of the backup at path
+func readBackupMetadata(path string) (*backupMetadata, error) {
+	data, err := os.ReadFile(filepath.Join(path, backupMetadataFile))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	metadata := &backupMetadata{}
+	if err = json.Unmarshal(data, metadata); err != nil {
+		return nil, errors.Wrapf(err, "unable to parse %s", filepath.Join(path, backupMetadataFile))
+	}
+
+	return metadata, nil
+}
+
+// verifyBackup returns the status of every file of b, comparing checksums with those recorded in
+// its metadata. Files of the legacy backup cannot be verified.
+func verifyBackup(b backup) (map[string]string, error) {
+	checksums, err := backupChecksums(b.path)
+	if err != nil {
+		return nil, err
+	}
+	statuses := map[string]string{}
+	if b.metadata == nil {
+		for file := range checksums {
+			statuses[file] = backupStatusUnknown
+		}
+		return statuses, nil
+	}
+
+	for file, checksum := range b.metadata.Checksums {
+		switch actual, ok := checksums[file]; {
+		case !ok:
+			statuses[file] = backupStatusMissing
+		case actual != checksum:
+			statuses[file] = backupStatusModified
+		default:
+			statuses[file] = backupStatusOK
+		}
+	}
+	for file := range checksums {
+		if _, ok := statuses[file]; !ok {
+			statuses[file] = backupStatusUnknown
+		}
+	}
+
+	return statuses, nil
+}
+
+// findBackup returns the backup of name in root, "latest" returns the most recent backup
+func findBackup(root, name string) (backup, error) {
+	backups, err := listBackups(root)
+	if err != nil {
+		return backup{}, err
+	}
+	if len(backups) == 0 {
+		return backup{}, fmt.Errorf(errNoBackups, root)
+	}
+	if name == "" || name == "latest" {
+		return backups[len(backups)-1], nil
+	}
+	for _, b := range backups {
+		if b.name == name {
+			return b, nil
+		}
+	}
+
+	return backup{}, fmt.Errorf(errBackupNotFound, name, root)
+}
+
+// backupsToPrune returns the backups to be deleted so that no more than keep backups remain and none
+// is older than olderThan. A zero keep or olderThan is ignored and the latest backup is never pruned,
+// as upgrade reads from it.
+func backupsToPrune(backups []backup, keep int, olderThan time.Duration, now time.Time) []backup {
+	prune := make([]backup, 0)
+	for i, b := range backups {
+		if i == len(backups)-1 {
+			break
+		}
+		tooMany := keep > 0 && i < len(backups)-keep
+		tooOld := olderThan > 0 && now.Sub(b.createdAt()) > olderThan
+		if tooMany || tooOld {
+			prune = append(prune, b)
+		}
+	}
+
+	return prune
+}
+
+// ListBackups prints the backups of this cluster
+func (in *Installer) ListBackups() error {
+	root, err := in.getBackupRootPath()
+	if err != nil {
+		return err
+	}
+	backups, err := listBackups(root)
+	if err != nil {
+		return err
+	}
+	if len(backups) == 0 {
+		in.log.Warnf(noBackupsMessage, root)
+		return nil
+	}
+
+	rows := make([][]string, 0, len(backups))
+	for _, b := range backups {
+		version, command := "-", "-"
+		if b.metadata != nil {
+			version, command = b.metadata.StorageOSVersion, b.metadata.Command
+		}
+		rows = append(rows, []string{b.name, b.createdAt().Format(time.RFC3339), version, command})
+	}
+	in.log.Table([]string{"NAME", "CREATED", "STORAGEOS VERSION", "COMMAND"}, rows)
+
+	return nil
+}
+
+// ShowBackup prints the metadata of backup name and verifies the checksum of each of its files
+func (in *Installer) ShowBackup(name string) error {
+	root, err := in.getBackupRootPath()
+	if err != nil {
+		return err
+	}
+	b, err := findBackup(root, name)
+	if err != nil {
+		return err
+	}
+
+	details := [][]string{
+		{"Name:", b.name},
+		{"Path:", b.path},
+		{"Created:", b.createdAt().Format(time.RFC3339)},
+	}
+	if b.metadata != nil {
+		details = append(details,
+			[]string{"Format version:", fmt.Sprintf("%d", b.metadata.FormatVersion)},
+			[]string{"Cluster ID:", b.metadata.ClusterID},
+			[]string{"StorageOS version:", b.metadata.StorageOSVersion},
+			[]string{"Node image:", getStringWithDefault(b.metadata.NodeImage, "-")},
+			[]string{"Plugin version:", getStringWithDefault(b.metadata.PluginVersion, "-")},
+			[]string{"Command:", b.metadata.Command},
+			[]string{"Subcommand:", getStringWithDefault(b.metadata.Subcommand, "-")},
+			[]string{"Encryption:", getStringWithDefault(b.metadata.Encryption, "none")},
+		)
+	}
+	in.log.Table(nil, details)
+
+	statuses, err := verifyBackup(b)
+	if err != nil {
+		return err
+	}
+	files := make([]string, 0, len(statuses))
+	for file := range statuses {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+
+	rows := make([][]string, 0, len(files))
+	for _, file := range files {
+		checksum := "-"
+		if b.metadata != nil && b.metadata.Checksums[file] != "" {
+			checksum = b.metadata.Checksums[file]
+		}
+		rows = append(rows, []string{file, statuses[file], checksum})
+	}
+	in.log.Table([]string{"FILE", "STATUS", "SHA256"}, rows)
+
+	return backupIntegrityError(b, statuses)
+}
+
+// backupIntegrityError returns an error naming the files of b which are modified or missing
+func backupIntegrityError(b backup, statuses map[string]string) error {
+	modified := make([]string, 0)
+	for file, status := range statuses {
+		if status == backupStatusModified || status == backupStatusMissing {
+			modified = append(modified, file)
+		}
+	}
+	if len(modified) == 0 {
+		return nil
+	}
+	sort.Strings(modified)
+
+	return fmt.Errorf(errBackupModified, strings.Join(modified, ", "), b.name)
+}
+
+// PruneBackups deletes backups so that no more than keep remain and none is older than olderThan.
+// The latest backup is always kept. If dryRun is set, backups to be pruned are only printed.
+func (in *Installer) PruneBackups(keep int, olderThan time.Duration, dryRun bool) error {
+	if keep <= 0 && olderThan <= 0 {
+		return fmt.Errorf(errPruneCriteriaRequired, KeepFlag, OlderThanFlag)
+	}
+	root, err := in.getBackupRootPath()
+	if err != nil {
+		return err
+	}
+	backups, err := listBackups(root)
+	if err != nil {
+		return err
+	}
+
+	prune := backupsToPrune(backups, keep, olderThan, time.Now().UTC())
+	if len(prune) == 0 {
+		in.log.Success(noBackupsToPruneMessage)
+		return nil
+	}
+	for _, b := range prune {
+		if dryRun {
+			in.log.Warnf(backupPruneDryRun, b.name)
+			continue
+		}
+		if err = removeBackup(b); err != nil {
+			return err
+		}
+		in.log.Successf(backupPrunedMessage, b.name)
+	}
+
+	return nil
+}
+
+// removeBackup deletes the files of b. The files of the legacy backup are removed individually as
+// the backup directory also holds the versioned backups.
+func removeBackup(b backup) error {
+	if b.metadata != nil {
+		return errors.WithStack(os.RemoveAll(b.path))
+	}
+	entries, err := os.ReadDir(b.path)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		if err = os.Remove(filepath.Join(b.path, entry.Name())); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	return nil
+}
+
+// ExportBackup writes backup name to a gzipped tarball at output. If output is empty, the tarball is
+// written to the working directory.
+func (in *Installer) ExportBackup(name, output string) error {
+	root, err := in.getBackupRootPath()
+	if err != nil {
+		return err
+	}
+	b, err := findBackup(root, name)
+	if err != nil {
+		return err
+	}
+	if output == "" {
+		output = fmt.Sprintf("storageos-backup-%v-%s.tar.gz", in.kubeClusterID, b.name)
+	}
+
+	file, err := os.OpenFile(output, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer file.Close()
+	if err = writeBackupTarball(b, file); err != nil {
+		return err
+	}
+	if err = file.Close(); err != nil {
+		return errors.WithStack(err)
+	}
+	in.log.Successf(backupExportedMessage, b.name, output)
+
+	return nil
+}
+
+// writeBackupTarball writes the files of b to w as a gzipped tarball, within a directory named after
+// the backup
+func writeBackupTarball(b backup, w io.Writer) error {
+	gzipWriter := gzip.NewWriter(w)
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	entries, err := os.ReadDir(b.path)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		if err = addFileToTarball(tarWriter, filepath.Join(b.path, entry.Name()), filepath.Join(b.name, entry.Name())); err != nil {
+			return err
+		}
+	}
+
+	if err = tarWriter.Close(); err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(gzipWriter.Close())
+}
+
+// addFileToTarball adds the file at path to tarWriter as name
+func addFileToTarball(tarWriter *tar.Writer, path, name string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	header, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	header.Name = name
+	if err = tarWriter.WriteHeader(header); err != nil {
+		return errors.WithStack(err)
+	}
+	_, err = io.Copy(tarWriter, file)
+
+	return errors.WithStack(err)
+}
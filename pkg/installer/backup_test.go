@@ -0,0 +1,228 @@
+package installer
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+// writeTestBackup writes a backup named name with files to root, recording the checksums of files
+func writeTestBackup(t *testing.T, root, name string, createdAt time.Time, files map[string]string) backup {
+	t.Helper()
+	path := filepath.Join(root, name)
+	if err := os.MkdirAll(path, 0700); err != nil {
+		t.Fatal(err)
+	}
+	for file, content := range files {
+		if err := os.WriteFile(filepath.Join(path, file), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	checksums, err := backupChecksums(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	metadata := &backupMetadata{FormatVersion: backupFormatVersion, Name: name, CreatedAt: createdAt, Checksums: checksums}
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(filepath.Join(path, backupMetadataFile), data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return backup{name: name, path: path, metadata: metadata}
+}
+
+func backupNames(backups []backup) []string {
+	names := make([]string, 0, len(backups))
+	for _, b := range backups {
+		names = append(names, b.name)
+	}
+	return names
+}
+
+func TestListBackups(t *testing.T) {
+	root := t.TempDir()
+	now := time.Now().UTC()
+
+	backups, err := listBackups(filepath.Join(root, "missing"))
+	if err != nil || len(backups) != 0 {
+		t.Fatalf("expected no backups for missing directory, got %v, %v", backups, err)
+	}
+
+	writeTestBackup(t, root, "20220102T000000Z", now.Add(-time.Hour), map[string]string{stosClusterFile: "b"})
+	writeTestBackup(t, root, "20220101T000000Z", now.Add(-2*time.Hour), map[string]string{stosClusterFile: "a"})
+	if err = os.MkdirAll(filepath.Join(root, "not-a-backup"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	// legacy backup written directly to root before the versioned backups
+	legacyFile := filepath.Join(root, stosClusterFile)
+	if err = os.WriteFile(legacyFile, []byte("legacy"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Chtimes(legacyFile, now.Add(-3*time.Hour), now.Add(-3*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	backups, err = listBackups(root)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expNames := []string{legacyBackupName, "20220101T000000Z", "20220102T000000Z"}
+	if names := backupNames(backups); !reflect.DeepEqual(names, expNames) {
+		t.Errorf("expected %v, got %v", expNames, names)
+	}
+
+	latest, err := findBackup(root, "latest")
+	if err != nil || latest.name != "20220102T000000Z" {
+		t.Errorf("expected latest backup 20220102T000000Z, got %q, %v", latest.name, err)
+	}
+	if _, err = findBackup(root, "20200101T000000Z"); err == nil {
+		t.Errorf("expected error for unknown backup")
+	}
+}
+
+func TestBackupsToPrune(t *testing.T) {
+	now := time.Now().UTC()
+	backups := make([]backup, 0)
+	for i := 4; i >= 0; i-- {
+		created := now.Add(-time.Duration(i) * 24 * time.Hour)
+		backups = append(backups, backup{name: created.Format(backupTimeFormat), metadata: &backupMetadata{CreatedAt: created}})
+	}
+
+	tcases := []struct {
+		name      string
+		keep      int
+		olderThan time.Duration
+		expPruned int
+	}{
+		{name: "keep 2", keep: 2, expPruned: 3},
+		{name: "keep more than exist", keep: 10, expPruned: 0},
+		{name: "older than 36h", olderThan: 36 * time.Hour, expPruned: 3},
+		{name: "keep or older than", keep: 4, olderThan: 72 * time.Hour, expPruned: 1},
+		{name: "latest is never pruned", olderThan: time.Nanosecond, expPruned: 4},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			pruned := backupsToPrune(backups, tc.keep, tc.olderThan, now)
+			if len(pruned) != tc.expPruned {
+				t.Errorf("expected %d backups pruned, got %v", tc.expPruned, backupNames(pruned))
+			}
+			for _, b := range pruned {
+				if b.name == backups[len(backups)-1].name {
+					t.Errorf("latest backup must not be pruned")
+				}
+			}
+		})
+	}
+}
+
+func TestVerifyBackup(t *testing.T) {
+	root := t.TempDir()
+	b := writeTestBackup(t, root, "20220101T000000Z", time.Now(), map[string]string{
+		stosClusterFile:      "cluster",
+		stosSecretsFile:      "secrets",
+		stosStorageClassFile: "storageclass",
+	})
+	if err := os.WriteFile(filepath.Join(b.path, stosSecretsFile), []byte("changed"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(b.path, stosStorageClassFile)); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(b.path, stosConfigMapsFile), []byte("added"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	statuses, err := verifyBackup(b)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expStatuses := map[string]string{
+		stosClusterFile:      backupStatusOK,
+		stosSecretsFile:      backupStatusModified,
+		stosStorageClassFile: backupStatusMissing,
+		stosConfigMapsFile:   backupStatusUnknown,
+	}
+	if !reflect.DeepEqual(statuses, expStatuses) {
+		t.Errorf("expected %v, got %v", expStatuses, statuses)
+	}
+}
+
+func TestRedactCommand(t *testing.T) {
+	tcases := []struct {
+		name       string
+		args       []string
+		expCommand string
+	}{
+		{
+			name:       "no sensitive flags",
+			args:       []string{"/usr/local/bin/kubectl-storageos", "uninstall", "--skip-namespace-deletion"},
+			expCommand: "kubectl-storageos uninstall --skip-namespace-deletion",
+		},
+		{
+			name:       "flag with equals",
+			args:       []string{"kubectl-storageos", "upgrade", "--admin-password=hunter2", "--portal-secret=s3cret"},
+			expCommand: "kubectl-storageos upgrade --admin-password=<redacted> --portal-secret=<redacted>",
+		},
+		{
+			name:       "flag with separate value",
+			args:       []string{"kubectl-storageos", "upgrade", "--admin-password", "hunter2", "--wait"},
+			expCommand: "kubectl-storageos upgrade --admin-password <redacted> --wait",
+		},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			if command := redactCommand(tc.args); command != tc.expCommand {
+				t.Errorf("expected %q, got %q", tc.expCommand, command)
+			}
+		})
+	}
+}
+
+func TestWriteBackupTarball(t *testing.T) {
+	root := t.TempDir()
+	b := writeTestBackup(t, root, "20220101T000000Z", time.Now(), map[string]string{
+		stosClusterFile: "cluster",
+		stosSecretsFile: "secrets",
+	})
+
+	buf := &bytes.Buffer{}
+	if err := writeBackupTarball(b, buf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	gzipReader, err := gzip.NewReader(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tarReader := tar.NewReader(gzipReader)
+	names := make([]string, 0)
+	for {
+		header, err := tarReader.Next()
+		if err != nil {
+			break
+		}
+		names = append(names, header.Name)
+	}
+	sort.Strings(names)
+	expNames := []string{
+		filepath.Join(b.name, backupMetadataFile),
+		filepath.Join(b.name, stosClusterFile),
+		filepath.Join(b.name, stosSecretsFile),
+	}
+	sort.Strings(expNames)
+	if !reflect.DeepEqual(names, expNames) {
+		t.Errorf("expected %v, got %v", expNames, names)
+	}
+}
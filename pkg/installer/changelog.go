@@ -0,0 +1,88 @@
+package installer
+
+import (
+	"fmt"
+
+	"github.com/storageos/kubectl-storageos/pkg/logger"
+	pluginversion "github.com/storageos/kubectl-storageos/pkg/version"
+)
+
+const (
+	changelogMessage            = `Release notes of StorageOS operator versions after %s up to %s:`
+	noChangelogMessage          = `No release notes found for StorageOS operator versions after %s up to %s.`
+	breakingChangesMessage      = `%s contains breaking changes, please read them before upgrading.`
+	changelogDevelopMessage     = `Release notes are not available for development versions.`
+	changelogUnavailableMessage = `Unable to fetch release notes: %v`
+)
+
+// PrintChangelog prints the release notes of every StorageOS operator release after version from up
+// to and including version to. Sections describing breaking changes are highlighted.
+func PrintChangelog(from, to string, log *logger.Logger) error {
+	if pluginversion.IsDevelop(from) || pluginversion.IsDevelop(to) {
+		log.Warn(changelogDevelopMessage)
+		return nil
+	}
+
+	releases, err := pluginversion.FetchOperatorReleases()
+	if err != nil {
+		return err
+	}
+	between, err := pluginversion.ReleasesBetween(releases, from, to)
+	if err != nil {
+		return err
+	}
+	if len(between) == 0 {
+		log.Warnf(noChangelogMessage, from, to)
+		return nil
+	}
+
+	log.Successf(changelogMessage, from, to)
+	for _, release := range between {
+		writeReleaseNotes(log, pluginversion.ParseReleaseNotes(release))
+	}
+
+	return nil
+}
+
+// PrintUpgradeChangelog prints the release notes of an upgrade from version from to version to. The
+// upgrade does not depend on release notes, so failing to fetch them is only reported.
+func PrintUpgradeChangelog(from, to string, log *logger.Logger) {
+	if err := PrintChangelog(from, to, log); err != nil {
+		log.Warnf(changelogUnavailableMessage, err)
+	}
+}
+
+// writeReleaseNotes writes notes to the writer of log, with the titles and lines of breaking change
+// sections logged as warnings
+func writeReleaseNotes(log *logger.Logger, notes pluginversion.ReleaseNotes) {
+	title := notes.Version
+	if notes.Prerelease {
+		title = fmt.Sprintf("%s (prerelease)", title)
+	}
+	log.Prompt(title)
+	if notes.HasBreakingChanges() {
+		log.Warnf(breakingChangesMessage, notes.Version)
+	}
+
+	for _, section := range notes.Sections {
+		if section.Breaking {
+			log.Warnf("%s", section.Title)
+			for _, line := range section.Lines {
+				log.Warnf("%s", line)
+			}
+			fmt.Fprintln(log.Writer, "")
+			continue
+		}
+		if section.Title != "" {
+			fmt.Fprintln(log.Writer, section.Title)
+		}
+		for _, line := range section.Lines {
+			fmt.Fprintln(log.Writer, line)
+		}
+		fmt.Fprintln(log.Writer, "")
+	}
+	if notes.URL != "" {
+		fmt.Fprintln(log.Writer, notes.URL)
+		fmt.Fprintln(log.Writer, "")
+	}
+}
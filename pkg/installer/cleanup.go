@@ -0,0 +1,307 @@
+package installer
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/storageos/kubectl-storageos/pkg/consts"
+	pluginutils "github.com/storageos/kubectl-storageos/pkg/utils"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	crdKind = "CustomResourceDefinition"
+
+	errCleanupStorageOSClusterExists = `
+	StorageOSCluster %s exists in namespace %s. Cleanup only removes resources left behind once StorageOS
+	has been uninstalled:
+
+	kubectl storageos uninstall`
+
+	errStorageOSOperatorExists = `
+	StorageOS operator deployment(s) %s exist, whether running or not. Cleanup only removes resources left
+	behind once StorageOS has been uninstalled:
+
+	kubectl storageos uninstall`
+
+	errCRDStorageVersion = `
+	CustomResourceDefinition %s has no storage version`
+
+	errDuringCleanup = `
+	An error has occurred deleting %s %s, the remaining StorageOS resources have not been deleted.`
+
+	noOrphansMessage        = `No StorageOS cluster-scoped resources found.`
+	orphansFoundMessage     = `Discovered %d StorageOS cluster-scoped resource(s):`
+	cleanupCancelledMessage = `Cleanup cancelled, no resources have been deleted.`
+	deletingOrphanMessage   = `Deleting %s %s.`
+	orphansDeletedMessage   = `%d StorageOS cluster-scoped resource(s) deleted.`
+
+	skippedOrphanResourceMessage = `Skipped listing %s: %v`
+)
+
+// orphanExcludedKinds are never cleaned up: they hold data, belong to the cluster itself or are
+// removed along with what they refer to
+var orphanExcludedKinds = map[string]bool{
+	"Namespace":        true,
+	"Node":             true,
+	"PersistentVolume": true,
+	"CSINode":          true,
+	"VolumeAttachment": true,
+}
+
+// orphanLabels are the label keys set to storageos on the resources of StorageOS
+var orphanLabels = []string{"app", "app.kubernetes.io/name", "app.kubernetes.io/part-of"}
+
+var orphanNamePattern = regexp.MustCompile(`(?i)storageos`)
+
+// orphanKindOrder is the order in which orphans are deleted. Webhooks are deleted first as their
+// service is gone and they would fail requests, CRDs before the remaining resources.
+var orphanKindOrder = map[string]int{
+	"ValidatingWebhookConfiguration": 0,
+	"MutatingWebhookConfiguration":   0,
+	"APIService":                     1,
+	crdKind:                          2,
+}
+
+// orphan is a cluster-scoped resource of StorageOS left behind
+type orphan struct {
+	resource pluginutils.ClusterResource
+	object   unstructured.Unstructured
+	reason   string
+}
+
+// orphanReason returns why obj of kind is a StorageOS resource, empty if it is not
+func orphanReason(kind string, obj *unstructured.Unstructured) string {
+	if orphanExcludedKinds[kind] {
+		return ""
+	}
+	if kind == "StorageClass" {
+		provisioner, _, _ := unstructured.NestedString(obj.Object, "provisioner")
+		if provisioner == stosSCProvisioner {
+			return "provisioner " + provisioner
+		}
+	}
+	labels := obj.GetLabels()
+	for _, key := range orphanLabels {
+		if labels[key] == "storageos" {
+			return fmt.Sprintf("label %s=%s", key, labels[key])
+		}
+	}
+	if orphanNamePattern.MatchString(obj.GetName()) {
+		return "name"
+	}
+
+	return ""
+}
+
+// sortOrphans sorts orphans in deletion order, by kind and name within the same order
+func sortOrphans(orphans []orphan) {
+	sort.SliceStable(orphans, func(i, j int) bool {
+		kindI, kindJ := orphans[i].resource.Kind, orphans[j].resource.Kind
+		orderI, ok := orphanKindOrder[kindI]
+		if !ok {
+			orderI = len(orphanKindOrder)
+		}
+		orderJ, ok := orphanKindOrder[kindJ]
+		if !ok {
+			orderJ = len(orphanKindOrder)
+		}
+		if orderI != orderJ {
+			return orderI < orderJ
+		}
+		if kindI != kindJ {
+			return kindI < kindJ
+		}
+		return orphans[i].object.GetName() < orphans[j].object.GetName()
+	})
+}
+
+// crdResource returns the resource of the custom resources of crd, at their storage version
+func crdResource(crd *unstructured.Unstructured) (schema.GroupVersionResource, error) {
+	group, _, _ := unstructured.NestedString(crd.Object, "spec", "group")
+	plural, _, _ := unstructured.NestedString(crd.Object, "spec", "names", "plural")
+	versions, _, _ := unstructured.NestedSlice(crd.Object, "spec", "versions")
+	for _, version := range versions {
+		versionMap, ok := version.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if storage, _ := versionMap["storage"].(bool); !storage {
+			continue
+		}
+		name, _ := versionMap["name"].(string)
+		return schema.GroupVersionResource{Group: group, Version: name, Resource: plural}, nil
+	}
+
+	return schema.GroupVersionResource{}, fmt.Errorf(errCRDStorageVersion, crd.GetName())
+}
+
+// findOrphans returns the cluster-scoped resources of StorageOS of the cluster in deletion order
+func (in *Installer) findOrphans() ([]orphan, error) {
+	resources, err := pluginutils.ListClusterResources(in.clientConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	orphans := []orphan{}
+	for _, resource := range resources {
+		if orphanExcludedKinds[resource.Kind] {
+			continue
+		}
+		objects, err := pluginutils.ListObjects(in.clientConfig, resource.Resource, "")
+		if err != nil {
+			// aggregated APIs may be unavailable, their resources are not StorageOS resources
+			in.log.Infof(skippedOrphanResourceMessage, resource.Resource.String(), err)
+			continue
+		}
+		for i := range objects {
+			if reason := orphanReason(resource.Kind, &objects[i]); reason != "" {
+				orphans = append(orphans, orphan{resource: resource, object: objects[i], reason: reason})
+			}
+		}
+	}
+	sortOrphans(orphans)
+
+	return orphans, nil
+}
+
+// Cleanup discovers the cluster-scoped resources of StorageOS left behind by an uninstall, across
+// all API groups, by label, storage class provisioner and name. They are listed and deleted once
+// confirm returns true. Resources stuck on finalizers have their finalizers removed, as have the
+// custom resources of a stuck CRD.
+func (in *Installer) Cleanup(confirm func() (bool, error)) error {
+	if err := in.ensureStorageOSUninstalled(); err != nil {
+		return err
+	}
+
+	orphans, err := in.findOrphans()
+	if err != nil {
+		return err
+	}
+	if len(orphans) == 0 {
+		in.log.Success(noOrphansMessage)
+		return nil
+	}
+
+	in.log.Warnf(orphansFoundMessage, len(orphans))
+	rows := make([][]string, 0, len(orphans))
+	for _, o := range orphans {
+		rows = append(rows, []string{o.resource.Kind, o.object.GetName(), o.reason})
+	}
+	in.log.Table([]string{"KIND", "NAME", "REASON"}, rows)
+
+	confirmed, err := confirm()
+	if err != nil {
+		return err
+	}
+	if !confirmed {
+		in.log.Warn(cleanupCancelledMessage)
+		return nil
+	}
+
+	for _, o := range orphans {
+		if err = in.deleteOrphan(o); err != nil {
+			return errors.Wrap(err, fmt.Sprintf(errDuringCleanup, o.resource.Kind, o.object.GetName()))
+		}
+	}
+	in.log.Successf(orphansDeletedMessage, len(orphans))
+
+	return nil
+}
+
+// ensureStorageOSUninstalled returns an error if a StorageOS cluster or a deployment of the StorageOS
+// operator exists in any namespace, whatever its readiness
+func (in *Installer) ensureStorageOSUninstalled() error {
+	stosClusters, err := pluginutils.ListStorageOSClusters(in.clientConfig)
+	if err != nil {
+		return err
+	}
+	if len(stosClusters) != 0 {
+		return fmt.Errorf(errCleanupStorageOSClusterExists, stosClusters[0].Name, stosClusters[0].Namespace)
+	}
+
+	deployments, err := pluginutils.ListDeployments(in.clientConfig, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	if operators := storageOSOperatorDeployments(deployments.Items); len(operators) != 0 {
+		return fmt.Errorf(errStorageOSOperatorExists, strings.Join(operators, ", "))
+	}
+
+	return nil
+}
+
+// storageOSOperatorDeployments returns the namespaced names of the deployments of the new and old
+// StorageOS operators
+func storageOSOperatorDeployments(deployments []appsv1.Deployment) []string {
+	operators := []string{}
+	for _, dep := range deployments {
+		if dep.Name == consts.NewOperatorName || dep.Name == consts.OldOperatorName {
+			operators = append(operators, dep.Namespace+"/"+dep.Name)
+		}
+	}
+
+	return operators
+}
+
+// deleteOrphan deletes o and waits for its removal. If it is stuck on finalizers, the finalizers of
+// its custom resources, if o is a CRD, and then its own finalizers are removed.
+func (in *Installer) deleteOrphan(o orphan) error {
+	name := o.object.GetName()
+	in.log.Infof(deletingOrphanMessage, o.resource.Kind, name)
+	if err := pluginutils.DeleteObject(in.clientConfig, o.resource.Resource, name, ""); err != nil {
+		return err
+	}
+	doesNotExist := func() error {
+		return pluginutils.ObjectDoesNotExist(in.clientConfig, o.resource.Resource, name, "")
+	}
+	if err := in.waitForCustomResourceDeletion(doesNotExist); err == nil {
+		return nil
+	}
+
+	if o.resource.Kind == crdKind {
+		if err := in.removeCustomResourceFinalizers(&o.object); err != nil {
+			return err
+		}
+		if err := in.waitForCustomResourceDeletion(doesNotExist); err == nil {
+			return nil
+		}
+	}
+
+	in.log.Warnf(removingFinalizersMessage, name)
+	if err := pluginutils.RemoveObjectFinalizers(in.clientConfig, o.resource.Resource, name, ""); err != nil {
+		return err
+	}
+
+	return in.waitForCustomResourceDeletion(doesNotExist)
+}
+
+// removeCustomResourceFinalizers removes the finalizers of every custom resource of crd, which
+// block the deletion of crd
+func (in *Installer) removeCustomResourceFinalizers(crd *unstructured.Unstructured) error {
+	resource, err := crdResource(crd)
+	if err != nil {
+		return err
+	}
+	objects, err := pluginutils.ListObjects(in.clientConfig, resource, "")
+	if err != nil {
+		return err
+	}
+	for _, obj := range objects {
+		if len(obj.GetFinalizers()) == 0 {
+			continue
+		}
+		in.log.Warnf(removingFinalizersMessage, obj.GetName())
+		if err = pluginutils.RemoveObjectFinalizers(in.clientConfig, resource, obj.GetName(), obj.GetNamespace()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
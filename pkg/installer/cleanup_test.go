@@ -0,0 +1,169 @@
+package installer
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/storageos/kubectl-storageos/pkg/consts"
+	pluginutils "github.com/storageos/kubectl-storageos/pkg/utils"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestOrphanReason(t *testing.T) {
+	newObject := func(name string, labels map[string]string, fields map[string]interface{}) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{Object: map[string]interface{}{}}
+		for key, value := range fields {
+			obj.Object[key] = value
+		}
+		obj.SetName(name)
+		obj.SetLabels(labels)
+		return obj
+	}
+
+	tcases := []struct {
+		name      string
+		kind      string
+		object    *unstructured.Unstructured
+		expReason string
+	}{
+		{
+			name:      "storage class by provisioner",
+			kind:      "StorageClass",
+			object:    newObject("fast", nil, map[string]interface{}{"provisioner": stosSCProvisioner}),
+			expReason: "provisioner " + stosSCProvisioner,
+		},
+		{
+			name:   "storage class of another provisioner",
+			kind:   "StorageClass",
+			object: newObject("standard", nil, map[string]interface{}{"provisioner": "kubernetes.io/gce-pd"}),
+		},
+		{
+			name:      "cluster role by label",
+			kind:      "ClusterRole",
+			object:    newObject("csi-provisioner", map[string]string{"app": "storageos"}, nil),
+			expReason: "label app=storageos",
+		},
+		{
+			name:      "crd by name",
+			kind:      "CustomResourceDefinition",
+			object:    newObject("storageosclusters.storageos.com", nil, nil),
+			expReason: "name",
+		},
+		{
+			name:      "csi driver by name",
+			kind:      "CSIDriver",
+			object:    newObject("csi.storageos.com", nil, nil),
+			expReason: "name",
+		},
+		{
+			name:   "namespace excluded",
+			kind:   "Namespace",
+			object: newObject("storageos", map[string]string{"app": "storageos"}, nil),
+		},
+		{
+			name:   "persistent volume excluded",
+			kind:   "PersistentVolume",
+			object: newObject("pvc-storageos", nil, nil),
+		},
+		{
+			name:   "unrelated",
+			kind:   "ClusterRole",
+			object: newObject("cluster-admin", map[string]string{"app": "kubernetes"}, nil),
+		},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			if reason := orphanReason(tc.kind, tc.object); reason != tc.expReason {
+				t.Errorf("expected reason %q, got %q", tc.expReason, reason)
+			}
+		})
+	}
+}
+
+func TestSortOrphans(t *testing.T) {
+	newOrphan := func(kind, name string) orphan {
+		obj := unstructured.Unstructured{Object: map[string]interface{}{}}
+		obj.SetName(name)
+		return orphan{resource: pluginutils.ClusterResource{Kind: kind}, object: obj}
+	}
+	orphans := []orphan{
+		newOrphan("StorageClass", "storageos"),
+		newOrphan("CustomResourceDefinition", "storageosclusters.storageos.com"),
+		newOrphan("ClusterRole", "storageos:operator"),
+		newOrphan("ValidatingWebhookConfiguration", "storageos-validating-webhook"),
+		newOrphan("ClusterRole", "storageos:csi-provisioner"),
+		newOrphan("MutatingWebhookConfiguration", "storageos-mutating-webhook"),
+	}
+	sortOrphans(orphans)
+
+	expOrder := []string{
+		"MutatingWebhookConfiguration/storageos-mutating-webhook",
+		"ValidatingWebhookConfiguration/storageos-validating-webhook",
+		"CustomResourceDefinition/storageosclusters.storageos.com",
+		"ClusterRole/storageos:csi-provisioner",
+		"ClusterRole/storageos:operator",
+		"StorageClass/storageos",
+	}
+	order := []string{}
+	for _, o := range orphans {
+		order = append(order, o.resource.Kind+"/"+o.object.GetName())
+	}
+	if !reflect.DeepEqual(order, expOrder) {
+		t.Errorf("expected order %v, got %v", expOrder, order)
+	}
+}
+
+func TestStorageOSOperatorDeployments(t *testing.T) {
+	newDeployment := func(name, namespace string) appsv1.Deployment {
+		return appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace}}
+	}
+	deployments := []appsv1.Deployment{
+		newDeployment(consts.NewOperatorName, "custom-namespace"),
+		newDeployment("storageos-api-manager", consts.NewOperatorNamespace),
+		newDeployment(consts.OldOperatorName, consts.OldOperatorNamespace),
+		newDeployment("coredns", "kube-system"),
+	}
+
+	expOperators := []string{
+		"custom-namespace/" + consts.NewOperatorName,
+		consts.OldOperatorNamespace + "/" + consts.OldOperatorName,
+	}
+	if operators := storageOSOperatorDeployments(deployments); !reflect.DeepEqual(operators, expOperators) {
+		t.Errorf("expected operators %v, got %v", expOperators, operators)
+	}
+	if operators := storageOSOperatorDeployments(deployments[1:2]); len(operators) != 0 {
+		t.Errorf("expected no operators, got %v", operators)
+	}
+}
+
+func TestCRDResource(t *testing.T) {
+	crd := &unstructured.Unstructured{Object: map[string]interface{}{
+		"metadata": map[string]interface{}{"name": "storageosclusters.storageos.com"},
+		"spec": map[string]interface{}{
+			"group": "storageos.com",
+			"names": map[string]interface{}{"plural": "storageosclusters"},
+			"versions": []interface{}{
+				map[string]interface{}{"name": "v1alpha1", "storage": false},
+				map[string]interface{}{"name": "v1", "storage": true},
+			},
+		},
+	}}
+
+	resource, err := crdResource(crd)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expResource := schema.GroupVersionResource{Group: "storageos.com", Version: "v1", Resource: "storageosclusters"}
+	if resource != expResource {
+		t.Errorf("expected %v, got %v", expResource, resource)
+	}
+
+	unstructured.RemoveNestedField(crd.Object, "spec", "versions")
+	if _, err = crdResource(crd); err == nil {
+		t.Errorf("expected error without storage version")
+	}
+}
@@ -0,0 +1,115 @@
+package installer
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	apiv1 "github.com/storageos/kubectl-storageos/api/v1"
+	"github.com/storageos/kubectl-storageos/pkg/logger"
+	pluginutils "github.com/storageos/kubectl-storageos/pkg/utils"
+	pluginversion "github.com/storageos/kubectl-storageos/pkg/version"
+)
+
+const (
+	errUpgradeIncompatible = `
+	Upgrade from StorageOS %s to %s aborted, no changes have been made:%s`
+
+	notInCompatibilityMatrixMessage = `StorageOS %s is not part of the compatibility matrix of this version of kubectl storageos, only the Kubernetes minimum version is checked.`
+	skippedMinKubeVersionMessage    = `Skipped Kubernetes minimum version check: %v`
+	skippedEtcdOperatorCheckMessage = `Skipped ETCD operator version check: %v`
+	skippedCompatibilityMessage     = `Upgrade from StorageOS %s to %s is not supported, continuing as %s is set:%s`
+)
+
+// CheckUpgradeCompatibility validates, before any change is made, that StorageOS can be upgraded
+// from versionToUninstall to the version of installConfig: the upgrade path, the Kubernetes
+// version of the cluster and the version of the StorageOS ETCD operator, if installed. Every
+// incompatibility is returned in a single error, or logged as a warning if the compatibility check
+// is skipped.
+func CheckUpgradeCompatibility(installConfig *apiv1.KubectlStorageOSConfig, versionToUninstall string, log *logger.Logger) error {
+	versionToInstall := installConfig.Spec.Install.StorageOSVersion
+	if pluginversion.IsDevelop(versionToInstall) {
+		return nil
+	}
+	if _, ok := pluginversion.GetCompatibility(versionToInstall); !ok {
+		log.Warnf(notInCompatibilityMatrixMessage, versionToInstall)
+	}
+
+	checkErrs := []error{}
+	if err := pluginversion.CheckUpgradePath(versionToUninstall, versionToInstall); err != nil {
+		checkErrs = append(checkErrs, err)
+	}
+
+	kubeVersion, err := clusterKubernetesVersion(installConfig)
+	if err != nil {
+		return err
+	}
+	minKubeVersion, err := minKubernetesVersion(versionToInstall)
+	if err != nil {
+		log.Infof(skippedMinKubeVersionMessage, err)
+	}
+	warning, err := pluginversion.CheckKubernetesVersion(kubeVersion, minKubeVersion, versionToInstall)
+	if err != nil {
+		checkErrs = append(checkErrs, err)
+	} else if warning != "" {
+		log.Warnf("%s", warning)
+	}
+
+	// the etcd operator is only checked if it has been installed by kubectl storageos
+	etcdOperatorVersion, err := pluginversion.GetExistingEtcdOperatorVersion("")
+	if err != nil {
+		log.Infof(skippedEtcdOperatorCheckMessage, err)
+	} else if err = pluginversion.CheckEtcdOperatorVersion(etcdOperatorVersion, versionToInstall); err != nil {
+		checkErrs = append(checkErrs, err)
+	}
+
+	if len(checkErrs) == 0 {
+		return nil
+	}
+	messages := make([]string, 0, len(checkErrs))
+	for _, checkErr := range checkErrs {
+		messages = append(messages, checkErr.Error())
+	}
+	if installConfig.Spec.Install.SkipCompatibilityCheck {
+		log.Warnf(skippedCompatibilityMessage, versionToUninstall, versionToInstall, SkipCompatibilityCheckFlag, strings.Join(messages, "\n"))
+		return nil
+	}
+
+	return fmt.Errorf(errUpgradeIncompatible, versionToUninstall, versionToInstall, strings.Join(messages, "\n"))
+}
+
+// clusterKubernetesVersion returns the Kubernetes version of installConfig, or that of the cluster
+// if not set
+func clusterKubernetesVersion(installConfig *apiv1.KubectlStorageOSConfig) (string, error) {
+	if installConfig.Spec.Install.KubernetesVersion != "" {
+		return installConfig.Spec.Install.KubernetesVersion, nil
+	}
+	clientConfig, err := pluginutils.NewClientConfig()
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	kubeVersion, err := pluginutils.GetKubernetesVersion(clientConfig)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	return kubeVersion.String(), nil
+}
+
+// minKubernetesVersion returns the minimum Kubernetes version of the MIN_KUBE_VERSION file of the
+// operator manifests of version, empty for operators without it
+func minKubernetesVersion(version string) (string, error) {
+	lessThanOrEqual, err := pluginversion.VersionIsLessThanOrEqual(version, pluginversion.ClusterOperatorLastVersion())
+	if err != nil {
+		return "", err
+	}
+	if lessThanOrEqual {
+		return "", nil
+	}
+	imageURL, err := pluginversion.OperatorImageUrlByVersion(version)
+	if err != nil {
+		return "", err
+	}
+
+	return fetchImageAndExtractFileFromTarball(imageURL, "MIN_KUBE_VERSION")
+}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 	apiv1 "github.com/storageos/kubectl-storageos/api/v1"
@@ -18,6 +20,13 @@ const (
 
 	Please create a k8s secret in the StorageOS cluster namespace like so:
 
+	kubectl storageos etcd create-secret --stos-cluster-namespace=<storageos-cluster-namespace> \
+		--etcd-ca-cert=path/to/ca.crt \
+		--etcd-client-cert=path/to/tls.crt \
+		--etcd-client-key=path/to/tls.key
+
+	or
+
 	kubectl create secret generic <etcd-secret-name> -n <storageos-cluster-namespace> \
 		--from-file=etcd-client-ca.crt=path/to/ca.crt \
 		--from-file=etcd-client.crt=path/to/tls.crt \
@@ -26,78 +35,36 @@ const (
 
 	If you have uninstalled StorageOS using kubectl-storageos since last creating your secret, check 
 	
-	$HOME/.kube/storageos/uninstall-<cluster-id>/storageos-secrets.yaml for a local backup.
+	$HOME/.kube/storageos/uninstall-<cluster-id>/<timestamp>/storageos-secrets.yaml for a local backup,
+	or run kubectl storageos backup list. Encrypted backups hold storageos-secrets.yaml.enc instead,
+	print its secrets with kubectl storageos backup decrypt <name> and --backup-passphrase-file or
+	--backup-identity.
 	
 
 
 	To skip ETCD endpoints validation during installation, set install flag --%s`
 
-	errFailedToValidateTLSEndpoint = `
-	Unable to validate ETCD endpoint %s 
-
-	Please ensure this endpoint is TLS-enabled.
-
+	errFailedToValidateEndpoints = `
+	Unable to validate %d of %d ETCD endpoint(s):
+%s
 	Please note that, due to a known limitation, if your kubernetes cluster was provisioned using Google Anthos,
 
 	kubectl storageos is unable to perform ETCD endpoint validation.
 
 	To skip ETCD endpoints validation during installation, set install flag --%s`
 
-	errFailedToValidateEndpoint = `
-	Unable to validate ETCD endpoint %s 
-
+	hintNotTLSEnabled = `
 	If ETCD endpoints are TLS-enabled, please set install flag --%s
-
-	Please note that, due to a known limitation, if your kubernetes cluster was provisioned using Google Anthos,
-
-	kubectl storageos is unable to perform ETCD endpoint validation.
-
-	To skip ETCD endpoints validation during installation, set install flag --%s`
+`
+	hintTLSEnabled = `
+	Please ensure the endpoints are TLS-enabled and that secret %s contains a client certificate and CA trusted by the ETCD cluster.
+`
 
 	endpointsValidatedMessage = `ETCD endpoint(s) %s successfully validated.`
 
 	etcdShellPodDeletionFailMessage = `
 	Failed to cleanup etcd shell pod with error %v, 
 	please delete pod manually after installaion is complete.`
-
-	etcdShellPod = `apiVersion: v1
-kind: Pod
-metadata:
-  name: storageos-etcd-shell
-  namespace: storageos
-spec:
-  restartPolicy: OnFailure      
-  containers:
-    - name: storageos-etcd-shell
-      image: gcr.io/etcd-development/etcd:v3.5.0
-      # pod completes and is not restarted after 3m, this is in case
-      # the plugin crashes and is unable to delete this pod after health check
-      command: [ "sleep" ]
-      args: [ "3m" ]
-`
-	etcdShellPodTLS = `apiVersion: v1
-kind: Pod
-metadata:
-  name: storageos-etcd-shell
-  namespace: storageos
-spec:
-  restartPolicy: OnFailure
-  containers:
-    - name: storageos-etcd-shell
-      image: gcr.io/etcd-development/etcd:v3.5.0
-      # pod completes and is not restarted after 3m, this is in case
-      # the plugin crashes and is unable to delete this pod after health check
-      command: [ "sleep" ]
-      args: [ "3m" ]
-      volumeMounts:
-      - mountPath: /run/storageos/pki
-        name: etcd-certs
-        readOnly: true
-  volumes:
-  - name: etcd-certs
-    secret:
-      secretName: storageos-etcd-secret
-`
 )
 
 // handleEndpointsInput adds validated (or not validated) endpoints patch to kustomization file
@@ -125,141 +92,173 @@ func (in *Installer) handleEndpointsInput(configInstall apiv1.KubectlStorageOSCo
 	return in.addPatchesToFSKustomize(filepath.Join(stosDir, clusterDir, kustomizationFile), stosClusterKind, fsClusterName, []pluginutils.KustomizePatch{endpointPatch})
 }
 
-// validateEtcd:
-// - creates the etcd-shell pod (TLS or non-TLS)
-// - deletes the etcd-shell pod (deferred)
-// - prompts the user for endpoints input if required
-// - validates the endpoints using the etcd-shell-pod
+// validateEtcd validates the endpoints using the etcd-shell pod
 func (in *Installer) validateEtcd(configSpec apiv1.KubectlStorageOSConfigSpec) error {
-	var err error
-	etcdShell := etcdShellPod
+	tlsEnabled := configSpec.Install.EtcdTLSEnabled
+	return in.withEtcdShellPod(configSpec, etcdShellPodLifetime, func(etcdShellPodName, etcdShellPodNS string) error {
+		return in.etcdctlHealthCheck(etcdShellPodName, etcdShellPodNS, endpointsSplitter(configSpec.Install.EtcdEndpoints, tlsEnabled), tlsEnabled)
+	})
+}
 
+// withEtcdShellPod:
+// - prepares the etcd secret (TLS only)
+// - creates the etcd-shell pod (TLS or non-TLS) with the given lifetime
+// - deletes the etcd-shell pod (deferred)
+// - ensures the etcd-shell pod is in running state
+// - runs fn with the etcd-shell pod name and namespace
+func (in *Installer) withEtcdShellPod(configSpec apiv1.KubectlStorageOSConfigSpec, lifetime time.Duration, fn func(etcdShellPodName, etcdShellPodNS string) error) error {
 	etcdNS := configSpec.GetETCDValidationNamespace()
-	etcdShell, err = pluginutils.SetFieldInManifest(etcdShell, etcdNS, "namespace", "metadata")
-	if err != nil {
-		return err
-	}
-
 	if configSpec.Install.EtcdTLSEnabled {
-		etcdShell, err = in.tlsValidationPrep(etcdNS, configSpec.Install)
-		if err != nil {
+		if err := in.tlsValidationPrep(etcdNS, configSpec.Install); err != nil {
 			return err
 		}
 	}
 
-	if err = in.kubectlClient.Apply(context.TODO(), "", string(etcdShell), true); err != nil {
+	pod, err := etcdShellPod(etcdNS, configSpec.Install, lifetime)
+	if err != nil {
+		return err
+	}
+	etcdShell, err := podToManifest(pod)
+	if err != nil {
+		return err
+	}
+
+	if err = in.kubectlClient.Apply(context.TODO(), "", etcdShell, true); err != nil {
 		return errors.WithStack(err)
 	}
 
 	defer func() {
-		if err = in.kubectlClient.Delete(context.TODO(), "", string(etcdShell), true); err != nil {
+		if err := in.kubectlClient.Delete(context.TODO(), "", etcdShell, true); err != nil {
 			// do nothing, etcd shell pod runs to completion even in unlikely event that delete fails
 			in.log.Warnf(etcdShellPodDeletionFailMessage, err)
 		}
 	}()
 
-	err = in.validateEndpoints(configSpec.Install.EtcdEndpoints, string(etcdShell), configSpec.Install.EtcdTLSEnabled)
+	if err = pluginutils.WaitFor(func() error {
+		return pluginutils.IsPodRunning(in.clientConfig, pod.Name, pod.Namespace)
+	}, 60, 5); err != nil {
+		return err
+	}
 
-	return err
+	return fn(pod.Name, pod.Namespace)
 }
 
 // tlsValidationPrep:
 // - searches for the etcd-secret
 // - applies app=storageos label to secret
-// - returns the tls equipped etcd-shell pod with storageos cluster namespace and secret name
-func (in *Installer) tlsValidationPrep(namespace string, configInstall apiv1.Install) (string, error) {
+func (in *Installer) tlsValidationPrep(namespace string, configInstall apiv1.Install) error {
 	etcdSecret, err := pluginutils.GetSecret(in.clientConfig, configInstall.EtcdSecretName, namespace)
 	if err != nil {
-		return "", fmt.Errorf(errSecretNotFound, configInstall.EtcdSecretName, namespace, SkipEtcdEndpointsValFlag)
+		return fmt.Errorf(errSecretNotFound, configInstall.EtcdSecretName, namespace, SkipEtcdEndpointsValFlag)
 	}
 
 	// apply app=storageos label to secret, this way it will be backed up locally during uninstall
 	secretLabels := etcdSecret.GetLabels()
+	if secretLabels == nil {
+		secretLabels = map[string]string{}
+	}
 	secretLabels["app"] = "storageos"
 	etcdSecret.SetLabels(secretLabels)
 	etcdSecretManifest, err := secretToManifest(etcdSecret)
 	if err != nil {
-		return "", err
+		return err
 	}
 	if err = in.kubectlClient.Apply(context.TODO(), namespace, string(etcdSecretManifest), true); err != nil {
-		return "", errors.WithStack(err)
+		return errors.WithStack(err)
 	}
 
-	etcdShell := etcdShellPodTLS
-	etcdShell, err = pluginutils.SetFieldInManifest(etcdShell, namespace, "namespace", "metadata")
-	if err != nil {
-		return "", err
-	}
-	etcdShell, err = pluginutils.SetFieldInManifest(etcdShell, configInstall.EtcdSecretName, "secretName", "spec", "volumes", "[name=etcd-certs]", "secret")
-	if err != nil {
-		return "", err
-	}
+	return nil
+}
 
-	return etcdShell, nil
+// endpointResult holds the outcome of the validation of a single etcd endpoint
+type endpointResult struct {
+	endpoint string
+	failure  endpointFailure
+	detail   string
+	duration time.Duration
 }
 
-// validateEndpoints:
-// - retrieves etcd-shell pod name and namespace
-// - ensures the etcd-shell pod is in running state
-// - performs etcdctlHealthCheck
-// - if no error has occurred during health check, the endpoints are validated
-func (in *Installer) validateEndpoints(endpoints, etcdShell string, tlsEnabled bool) error {
-	etcdShellPodName, err := pluginutils.GetFieldInManifest(etcdShell, "metadata", "name")
-	if err != nil {
-		return err
-	}
-	etcdShellPodNS, err := pluginutils.GetFieldInManifest(etcdShell, "metadata", "namespace")
-	if err != nil {
-		return err
+// etcdctlHealthCheck concurrently performs write, read, delete of key/value to each etcd endpoint.
+// The keys are named after the etcd shell pod, so that concurrent validations against the same etcd
+// do not touch each other's keys. The results of all endpoints are printed as a table and an error
+// describing every failed endpoint is returned.
+func (in *Installer) etcdctlHealthCheck(etcdShellPodName, etcdShellPodNS string, endpoints []string, tls bool) error {
+	results := make([]endpointResult, len(endpoints))
+	wg := sync.WaitGroup{}
+	for i, endpoint := range endpoints {
+		wg.Add(1)
+		go func(i int, endpoint string) {
+			defer wg.Done()
+
+			start := time.Now()
+			results[i] = in.validateEndpoint(etcdShellPodName, etcdShellPodNS, endpoint, fmt.Sprintf("kubectl-storageos-validation-%s-%d", etcdShellPodName, i), tls)
+			results[i].duration = time.Since(start).Round(time.Millisecond)
+		}(i, endpoint)
 	}
+	wg.Wait()
 
-	if err = pluginutils.WaitFor(func() error {
-		return pluginutils.IsPodRunning(in.clientConfig, etcdShellPodName, etcdShellPodNS)
-	}, 60, 5); err != nil {
-		return err
+	in.printEndpointResults(results)
+
+	failures := make([]string, 0)
+	for _, result := range results {
+		if result.failure != endpointFailureNone {
+			failures = append(failures, fmt.Sprintf("\t- %s: %s failed, %s", result.endpoint, result.failure, result.detail))
+		}
 	}
-	err = in.etcdctlHealthCheck(etcdShellPodName, etcdShellPodNS, endpointsSplitter(endpoints, tlsEnabled), tlsEnabled)
+	if len(failures) != 0 {
+		hint := fmt.Sprintf(hintNotTLSEnabled, EtcdTLSEnabledFlag)
+		if tls {
+			hint = fmt.Sprintf(hintTLSEnabled, in.stosConfig.Spec.Install.EtcdSecretName)
+		}
+		return fmt.Errorf(errFailedToValidateEndpoints, len(failures), len(endpoints), strings.Join(failures, "\n")+"\n"+hint, SkipEtcdEndpointsValFlag)
+	}
+
+	in.log.Successf(endpointsValidatedMessage, strings.Join(endpoints, ","))
 
-	return err
+	return nil
 }
 
-// etcdctlHealthCheck performs write, read, delete of key/value to etcd endpoints, returning an error
-// if any step fails.
-func (in *Installer) etcdctlHealthCheck(etcdShellPodName, etcdShellPodNS string, endpoints []string, tls bool) error {
-	for _, endpoint := range endpoints {
-		errStr := fmt.Sprintf(errFailedToValidateEndpoint, endpoint, EtcdTLSEnabledFlag, SkipEtcdEndpointsValFlag)
-		if tls {
-			errStr = fmt.Sprintf(errFailedToValidateTLSEndpoint, endpoint, SkipEtcdEndpointsValFlag)
-		}
+// validateEndpoint uses a dummy key/value pair to write to, read from & delete from a single etcd
+// endpoint, stopping at the first failing step. Each etcdctl command is bounded by its dial and
+// command timeouts, an unresponsive endpoint fails with a timeout.
+func (in *Installer) validateEndpoint(etcdShellPodName, etcdShellPodNS, endpoint, key string, tls bool) endpointResult {
+	value := "bar"
+	steps := []struct {
+		stage endpointFailure
+		cmd   []string
+	}{
+		{stage: endpointFailureWrite, cmd: etcdctlPutCmd(endpoint, key, value, tls)},
+		{stage: endpointFailureRead, cmd: etcdctlGetCmd(endpoint, key, tls)},
+		{stage: endpointFailureDelete, cmd: etcdctlDelCmd(endpoint, key, tls)},
+	}
 
-		// use dummy key/value pair 'foo'/'bar' to write to, read from & delete from etcd
-		// in order to validate each endpoint
-		key, value := "foo", "bar"
-		_, stderr, err := pluginutils.ExecToPod(in.clientConfig, etcdctlPutCmd(endpoint, key, value, tls), "", etcdShellPodName, etcdShellPodNS, nil)
-		if err != nil {
-			return fmt.Errorf(fmt.Sprintf("%s%v", errStr, err))
+	for _, step := range steps {
+		stdout, stderr, err := pluginutils.ExecToPod(in.clientConfig, withEtcdctlTimeouts(step.cmd), "", etcdShellPodName, etcdShellPodNS, nil)
+		if err != nil || stderr != "" {
+			output := stderr
+			if output == "" && err != nil {
+				output = err.Error()
+			}
+			failure, detail := classifyEtcdctlError(step.stage, output)
+			return endpointResult{endpoint: endpoint, failure: failure, detail: detail}
 		}
-		if stderr != "" {
-			return errors.WithStack(fmt.Errorf(stderr))
+		if step.stage == endpointFailureRead && !strings.Contains(stdout, value) {
+			return endpointResult{endpoint: endpoint, failure: endpointFailureRead, detail: "value read does not match value written"}
 		}
+	}
 
-		_, stderr, err = pluginutils.ExecToPod(in.clientConfig, etcdctlGetCmd(endpoint, key, tls), "", etcdShellPodName, etcdShellPodNS, nil)
-		if err != nil {
-			return fmt.Errorf(fmt.Sprintf("%s%v", errStr, err))
-		}
-		if stderr != "" {
-			return errors.WithStack(fmt.Errorf(stderr))
-		}
+	return endpointResult{endpoint: endpoint}
+}
 
-		_, stderr, err = pluginutils.ExecToPod(in.clientConfig, etcdctlDelCmd(endpoint, key, tls), "", etcdShellPodName, etcdShellPodNS, nil)
-		if err != nil {
-			return fmt.Errorf(fmt.Sprintf("%s%v", errStr, err))
-		}
-		if stderr != "" {
-			return errors.WithStack(fmt.Errorf(stderr))
+// printEndpointResults prints the validation results of all endpoints as a table
+func (in *Installer) printEndpointResults(results []endpointResult) {
+	rows := make([][]string, 0, len(results))
+	for _, result := range results {
+		status, failure, detail := "OK", "-", "-"
+		if result.failure != endpointFailureNone {
+			status, failure, detail = "FAILED", string(result.failure), result.detail
 		}
+		rows = append(rows, []string{result.endpoint, status, failure, detail, result.duration.String()})
 	}
-	in.log.Successf(endpointsValidatedMessage, strings.Join(endpoints, ","))
-
-	return nil
+	in.log.Table([]string{"ENDPOINT", "STATUS", "FAILURE", "DETAIL", "DURATION"}, rows)
 }
@@ -0,0 +1,291 @@
+package installer
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	etcdoperatorapi "github.com/improbable-eng/etcd-cluster-operator/api/v1alpha1"
+	"github.com/pkg/errors"
+	pluginutils "github.com/storageos/kubectl-storageos/pkg/utils"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	etcdClientPort = 2379
+
+	// etcdMemberWaitSeconds is the time allowed for each member to be added or removed
+	etcdMemberWaitSeconds = 300
+
+	errEtcdClusterNotFound = `
+	No EtcdCluster found in namespace %s.
+
+	Please set --%s to the namespace of the etcd cluster installed with --%s.`
+
+	errInvalidEtcdReplicas = `
+	Invalid number of etcd replicas %q, expected a positive odd number.`
+
+	errEvenEtcdReplicas = `
+	Refusing to scale etcd cluster to %d members.
+
+	An even number of members tolerates no more failures than the odd number below it, while
+	requiring a larger quorum. Please choose %d or %d members.`
+
+	errEtcdScaleLosesFaultTolerance = `
+	Refusing to scale etcd cluster from %d to %d members.
+
+	A cluster of fewer than 3 members cannot tolerate the failure of any member. Please scale
+	to 3 members or more.`
+
+	errEtcdClusterUnhealthy = `
+	ETCD cluster %s is not healthy, refusing to change it as this risks losing quorum.
+%v`
+
+	errEtcdClusterUpdateRejected = `
+	The update of EtcdCluster %s was rejected: %v
+
+	The etcd operator may only permit changes to the replicas and version of an existing cluster.`
+
+	errNoEtcdClusterChanges = `
+	No changes requested, please set at least one of --%s, --%s or --%s.`
+
+	etcdClusterUnchangedMessage      = `ETCD cluster %s already has %d member(s).`
+	etcdClusterScalingMessage        = `Scaling ETCD cluster %s from %d to %d member(s).`
+	etcdClusterUpdatedMessage        = `ETCD cluster %s updated.`
+	etcdPodTemplateAppliesNewMessage = `Pod template changes are applied by the etcd operator to members created after this update, existing members keep their current resources and affinity until they are replaced.`
+)
+
+// ScaleEtcdCluster changes the number of members of the etcd cluster to the value of EtcdReplicas.
+// The cluster must be healthy beforehand and only quorum-safe transitions are allowed. Once the
+// operator has added or removed the members, every member is validated.
+func (in *Installer) ScaleEtcdCluster() error {
+	etcdCluster, err := in.getEtcdCluster()
+	if err != nil {
+		return err
+	}
+
+	desired, err := parseEtcdReplicas(in.stosConfig.Spec.Install.EtcdReplicas)
+	if err != nil {
+		return err
+	}
+	current := int32(len(etcdCluster.Status.Members))
+	if etcdCluster.Spec.Replicas != nil {
+		current = *etcdCluster.Spec.Replicas
+	}
+	if desired == current {
+		in.log.Successf(etcdClusterUnchangedMessage, etcdCluster.Name, current)
+		return nil
+	}
+	if err = validateEtcdScale(current, desired); err != nil {
+		return err
+	}
+
+	if err = in.checkEtcdClusterHealth(etcdCluster); err != nil {
+		return fmt.Errorf(errEtcdClusterUnhealthy, etcdCluster.Name, err)
+	}
+
+	in.log.Warnf(etcdClusterScalingMessage, etcdCluster.Name, current, desired)
+	etcdCluster.Spec.Replicas = &desired
+	if err = pluginutils.UpdateEtcdCluster(in.clientConfig, etcdCluster); err != nil {
+		return errors.WithStack(err)
+	}
+
+	changedMembers := desired - current
+	if changedMembers < 0 {
+		changedMembers = -changedMembers
+	}
+	etcdCluster, err = in.waitForEtcdClusterMembers(etcdCluster.Name, etcdCluster.Namespace, desired, time.Duration(etcdMemberWaitSeconds*changedMembers))
+	if err != nil {
+		return err
+	}
+
+	return in.checkEtcdClusterHealth(etcdCluster)
+}
+
+// UpdateEtcdCluster applies the etcd cpu limit, memory limit and topology key to the pod template
+// of the etcd cluster, once the cluster has been found to be healthy.
+func (in *Installer) UpdateEtcdCluster() error {
+	etcdCluster, err := in.getEtcdCluster()
+	if err != nil {
+		return err
+	}
+
+	configInstall := in.stosConfig.Spec.Install
+	if configInstall.EtcdCPULimit == "" && configInstall.EtcdMemoryLimit == "" && configInstall.EtcdTopologyKey == "" {
+		return fmt.Errorf(errNoEtcdClusterChanges, EtcdCPULimitFlag, EtcdMemoryLimitFlag, EtcdTopologyKeyFlag)
+	}
+	if err = applyEtcdPodTemplateChanges(etcdCluster, configInstall.EtcdCPULimit, configInstall.EtcdMemoryLimit, configInstall.EtcdTopologyKey); err != nil {
+		return err
+	}
+
+	if err = in.checkEtcdClusterHealth(etcdCluster); err != nil {
+		return fmt.Errorf(errEtcdClusterUnhealthy, etcdCluster.Name, err)
+	}
+
+	if err = pluginutils.UpdateEtcdCluster(in.clientConfig, etcdCluster); err != nil {
+		return fmt.Errorf(errEtcdClusterUpdateRejected, etcdCluster.Name, err)
+	}
+	in.log.Successf(etcdClusterUpdatedMessage, etcdCluster.Name)
+	in.log.Warn(etcdPodTemplateAppliesNewMessage)
+
+	return in.checkEtcdClusterHealth(etcdCluster)
+}
+
+// getEtcdCluster returns the etcd cluster in the etcd namespace
+func (in *Installer) getEtcdCluster() (*etcdoperatorapi.EtcdCluster, error) {
+	etcdCluster, err := pluginutils.GetFirstEtcdCluster(in.clientConfig, in.stosConfig.Spec.Install.EtcdNamespace)
+	if err != nil {
+		if kerrors.IsNotFound(err) {
+			return nil, fmt.Errorf(errEtcdClusterNotFound, in.stosConfig.Spec.Install.EtcdNamespace, EtcdNamespaceFlag, IncludeEtcdFlag)
+		}
+		return nil, err
+	}
+
+	return etcdCluster, nil
+}
+
+// waitForEtcdClusterMembers waits until the etcd operator reports the desired number of peers and
+// members, returning the latest etcd cluster object.
+func (in *Installer) waitForEtcdClusterMembers(name, namespace string, desired int32, limitSeconds time.Duration) (*etcdoperatorapi.EtcdCluster, error) {
+	var etcdCluster *etcdoperatorapi.EtcdCluster
+	err := pluginutils.WaitFor(func() error {
+		var err error
+		etcdCluster, err = pluginutils.GetEtcdCluster(in.clientConfig, name, namespace)
+		if err != nil {
+			return err
+		}
+		if etcdCluster.Status.Replicas != desired || int32(len(etcdCluster.Status.Members)) != desired {
+			in.log.Infof("waiting for etcd cluster %s: %d peer(s), %d member(s), want %d", name, etcdCluster.Status.Replicas, len(etcdCluster.Status.Members), desired)
+			return fmt.Errorf("etcd cluster %s has %d peer(s) and %d member(s), want %d", name, etcdCluster.Status.Replicas, len(etcdCluster.Status.Members), desired)
+		}
+		return nil
+	}, limitSeconds, 5)
+
+	return etcdCluster, err
+}
+
+// checkEtcdClusterHealth validates every member of the etcd cluster using the etcd shell pod
+func (in *Installer) checkEtcdClusterHealth(etcdCluster *etcdoperatorapi.EtcdCluster) error {
+	if in.stosConfig.Spec.Install.SkipEtcdEndpointsValidation {
+		return nil
+	}
+
+	configSpec := *in.stosConfig.Spec.DeepCopy()
+	configSpec.SkipStorageOSCluster = false
+	configSpec.Install.EtcdEndpoints = etcdMemberEndpoints(etcdCluster)
+	configSpec.Install.EtcdTLSEnabled = etcdCluster.Spec.TLS != nil && etcdCluster.Spec.TLS.Enabled
+
+	return in.validateEtcd(configSpec)
+}
+
+// etcdMemberEndpoints returns the comma separated client endpoints of every member of the etcd
+// cluster, falling back to the cluster service if no member has been reported yet.
+func etcdMemberEndpoints(etcdCluster *etcdoperatorapi.EtcdCluster) string {
+	if len(etcdCluster.Status.Members) == 0 {
+		return fmt.Sprintf("%s.%s:%d", etcdCluster.Name, etcdCluster.Namespace, etcdClientPort)
+	}
+	endpoints := make([]string, 0, len(etcdCluster.Status.Members))
+	for _, member := range etcdCluster.Status.Members {
+		endpoints = append(endpoints, fmt.Sprintf("%s.%s.%s.svc:%d", member.Name, etcdCluster.Name, etcdCluster.Namespace, etcdClientPort))
+	}
+
+	return strings.Join(endpoints, ",")
+}
+
+// parseEtcdReplicas returns replicas as a positive number
+func parseEtcdReplicas(replicas string) (int32, error) {
+	parsed, err := strconv.ParseInt(replicas, 10, 32)
+	if err != nil || parsed < 1 {
+		return 0, fmt.Errorf(errInvalidEtcdReplicas, replicas)
+	}
+
+	return int32(parsed), nil
+}
+
+// validateEtcdScale returns an error unless scaling from current to desired members is quorum-safe:
+// the desired number of members must be odd and a cluster that tolerates failures must not be
+// scaled to one that does not.
+func validateEtcdScale(current, desired int32) error {
+	if desired < 1 {
+		return fmt.Errorf(errInvalidEtcdReplicas, strconv.Itoa(int(desired)))
+	}
+	if desired%2 == 0 {
+		return fmt.Errorf(errEvenEtcdReplicas, desired, desired-1, desired+1)
+	}
+	if desired < current && desired < 3 {
+		return fmt.Errorf(errEtcdScaleLosesFaultTolerance, current, desired)
+	}
+
+	return nil
+}
+
+// applyEtcdPodTemplateChanges sets the cpu and memory limits (and equal requests so that pods are
+// assigned guaranteed qos) and the preferred anti-affinity topology key of the etcd pod template.
+// Empty values are left unchanged.
+func applyEtcdPodTemplateChanges(etcdCluster *etcdoperatorapi.EtcdCluster, cpuLimit, memoryLimit, topologyKey string) error {
+	if etcdCluster.Spec.PodTemplate == nil {
+		etcdCluster.Spec.PodTemplate = &etcdoperatorapi.EtcdPodTemplateSpec{}
+	}
+	podTemplate := etcdCluster.Spec.PodTemplate
+
+	for name, value := range map[corev1.ResourceName]string{
+		corev1.ResourceCPU:    cpuLimit,
+		corev1.ResourceMemory: memoryLimit,
+	} {
+		if value == "" {
+			continue
+		}
+		quantity, err := resource.ParseQuantity(value)
+		if err != nil {
+			return errors.Wrapf(err, "invalid %s limit %s", name, value)
+		}
+		if podTemplate.Resources == nil {
+			podTemplate.Resources = &corev1.ResourceRequirements{}
+		}
+		if podTemplate.Resources.Limits == nil {
+			podTemplate.Resources.Limits = corev1.ResourceList{}
+		}
+		if podTemplate.Resources.Requests == nil {
+			podTemplate.Resources.Requests = corev1.ResourceList{}
+		}
+		podTemplate.Resources.Limits[name] = quantity
+		podTemplate.Resources.Requests[name] = quantity
+	}
+
+	if topologyKey != "" {
+		if podTemplate.Affinity == nil {
+			podTemplate.Affinity = &corev1.Affinity{}
+		}
+		if podTemplate.Affinity.PodAntiAffinity == nil {
+			podTemplate.Affinity.PodAntiAffinity = &corev1.PodAntiAffinity{}
+		}
+		terms := podTemplate.Affinity.PodAntiAffinity.PreferredDuringSchedulingIgnoredDuringExecution
+		if len(terms) == 0 {
+			terms = []corev1.WeightedPodAffinityTerm{{
+				PodAffinityTerm: corev1.PodAffinityTerm{
+					LabelSelector: etcdPodLabelSelector(etcdCluster.Name),
+				},
+			}}
+		}
+		terms[0].Weight = 100
+		terms[0].PodAffinityTerm.TopologyKey = topologyKey
+		podTemplate.Affinity.PodAntiAffinity.PreferredDuringSchedulingIgnoredDuringExecution = terms
+	}
+
+	return nil
+}
+
+// etcdPodLabelSelector selects the pods of the etcd cluster by the label set by the etcd operator
+func etcdPodLabelSelector(etcdClusterName string) *metav1.LabelSelector {
+	return &metav1.LabelSelector{
+		MatchExpressions: []metav1.LabelSelectorRequirement{{
+			Key:      "etcd.improbable.io/cluster-name",
+			Operator: metav1.LabelSelectorOpIn,
+			Values:   []string{etcdClusterName},
+		}},
+	}
+}
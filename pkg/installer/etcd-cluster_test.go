@@ -0,0 +1,103 @@
+package installer
+
+import (
+	"testing"
+
+	etcdoperatorapi "github.com/improbable-eng/etcd-cluster-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestValidateEtcdScale(t *testing.T) {
+	tcases := []struct {
+		name    string
+		current int32
+		desired int32
+		expErr  bool
+	}{
+		{name: "3 to 5", current: 3, desired: 5},
+		{name: "1 to 3", current: 1, desired: 3},
+		{name: "5 to 3", current: 5, desired: 3},
+		{name: "3 to 2", current: 3, desired: 2, expErr: true},
+		{name: "3 to 4", current: 3, desired: 4, expErr: true},
+		{name: "3 to 1", current: 3, desired: 1, expErr: true},
+		{name: "3 to 0", current: 3, desired: 0, expErr: true},
+	}
+	for _, tc := range tcases {
+		if err := validateEtcdScale(tc.current, tc.desired); (err != nil) != tc.expErr {
+			t.Errorf("%s: expected error %v, got %v", tc.name, tc.expErr, err)
+		}
+	}
+}
+
+func TestParseEtcdReplicas(t *testing.T) {
+	tcases := []struct {
+		replicas    string
+		expReplicas int32
+		expErr      bool
+	}{
+		{replicas: "5", expReplicas: 5},
+		{replicas: "0", expErr: true},
+		{replicas: "-3", expErr: true},
+		{replicas: "three", expErr: true},
+		{replicas: "", expErr: true},
+	}
+	for _, tc := range tcases {
+		replicas, err := parseEtcdReplicas(tc.replicas)
+		if (err != nil) != tc.expErr {
+			t.Errorf("%q: expected error %v, got %v", tc.replicas, tc.expErr, err)
+		}
+		if replicas != tc.expReplicas {
+			t.Errorf("%q: expected %d, got %d", tc.replicas, tc.expReplicas, replicas)
+		}
+	}
+}
+
+func TestEtcdMemberEndpoints(t *testing.T) {
+	etcdCluster := &etcdoperatorapi.EtcdCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "storageos-etcd", Namespace: "storageos-etcd"},
+	}
+	if endpoints := etcdMemberEndpoints(etcdCluster); endpoints != "storageos-etcd.storageos-etcd:2379" {
+		t.Errorf("expected cluster service endpoint, got %s", endpoints)
+	}
+
+	etcdCluster.Status.Members = []etcdoperatorapi.EtcdMember{{Name: "storageos-etcd-0"}, {Name: "storageos-etcd-1"}}
+	exp := "storageos-etcd-0.storageos-etcd.storageos-etcd.svc:2379,storageos-etcd-1.storageos-etcd.storageos-etcd.svc:2379"
+	if endpoints := etcdMemberEndpoints(etcdCluster); endpoints != exp {
+		t.Errorf("expected %s, got %s", exp, endpoints)
+	}
+}
+
+func TestApplyEtcdPodTemplateChanges(t *testing.T) {
+	etcdCluster := &etcdoperatorapi.EtcdCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "storageos-etcd"},
+	}
+	if err := applyEtcdPodTemplateChanges(etcdCluster, "500m", "", "topology.kubernetes.io/zone"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	resources := etcdCluster.Spec.PodTemplate.Resources
+	expCPU := resource.MustParse("500m")
+	if cpu := resources.Limits[corev1.ResourceCPU]; cpu.Cmp(expCPU) != 0 {
+		t.Errorf("expected cpu limit %s, got %s", expCPU.String(), cpu.String())
+	}
+	if cpu := resources.Requests[corev1.ResourceCPU]; cpu.Cmp(expCPU) != 0 {
+		t.Errorf("expected cpu request %s, got %s", expCPU.String(), cpu.String())
+	}
+	if _, ok := resources.Limits[corev1.ResourceMemory]; ok {
+		t.Errorf("expected memory limit to be unset")
+	}
+
+	terms := etcdCluster.Spec.PodTemplate.Affinity.PodAntiAffinity.PreferredDuringSchedulingIgnoredDuringExecution
+	if len(terms) != 1 || terms[0].PodAffinityTerm.TopologyKey != "topology.kubernetes.io/zone" || terms[0].Weight != 100 {
+		t.Errorf("expected a single preferred anti-affinity term for the topology key, got %v", terms)
+	}
+	if terms[0].PodAffinityTerm.LabelSelector.MatchExpressions[0].Values[0] != "storageos-etcd" {
+		t.Errorf("expected anti-affinity to select pods of the etcd cluster, got %v", terms[0].PodAffinityTerm.LabelSelector)
+	}
+
+	if err := applyEtcdPodTemplateChanges(etcdCluster, "", "lots", ""); err == nil {
+		t.Errorf("expected error for invalid memory limit")
+	}
+}
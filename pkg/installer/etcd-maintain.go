@@ -0,0 +1,304 @@
+package installer
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	etcdAlarmNoSpace = "NOSPACE"
+
+	errEtcdMemberNoLeader = `
+	ETCD member %s reports no leader.`
+
+	errEtcdMemberUnhealthyAfterDefrag = `
+	ETCD member %s is not healthy after defragmentation:%v
+
+	Maintenance has been stopped, remaining members have not been defragmented.`
+
+	etcdCompactedMessage         = `ETCD key space compacted to revision %d.`
+	etcdAlreadyCompactedMessage  = `ETCD key space already compacted to revision %d.`
+	etcdDefragmentingMessage     = `Defragmenting ETCD member %s.`
+	etcdMaintainedMessage        = `ETCD maintenance complete, %s reclaimed.`
+	etcdNoSpaceAlarmMessage      = `NOSPACE alarm raised on ETCD member(s) %s, set --%s to clear it once space has been reclaimed.`
+	etcdAlarmsClearedMessage     = `NOSPACE alarm cleared.`
+	etcdOtherAlarmsRaisedMessage = `ETCD alarm(s) %s raised, alarms are not cleared as disarming also clears alarms other than NOSPACE.`
+)
+
+// etcdEndpointStatus is the json output of etcdctl endpoint status for a single endpoint
+type etcdEndpointStatus struct {
+	Endpoint string `json:"Endpoint"`
+	Status   struct {
+		Header struct {
+			MemberID uint64 `json:"member_id"`
+			Revision int64  `json:"revision"`
+		} `json:"header"`
+		Leader      uint64 `json:"leader"`
+		DBSize      int64  `json:"dbSize"`
+		DBSizeInUse int64  `json:"dbSizeInUse"`
+	} `json:"Status"`
+}
+
+// etcdAlarm is a single alarm raised on an etcd member
+type etcdAlarm struct {
+	memberID string
+	alarm    string
+}
+
+// MaintainEtcd compacts the etcd key space to the current revision and defragments members one at a
+// time, followers first and the leader last. Each member must be healthy after its defragmentation
+// before the next one is defragmented. The size reclaimed per member is printed and, if
+// clearAlarms is set, a NOSPACE alarm is cleared.
+func (in *Installer) MaintainEtcd(clearAlarms bool) error {
+	configSpec := *in.stosConfig.Spec.DeepCopy()
+	if configSpec.Install.EtcdEndpoints == "" {
+		etcdCluster, err := in.getEtcdCluster()
+		if err != nil {
+			return err
+		}
+		configSpec.Install.EtcdEndpoints = etcdMemberEndpoints(etcdCluster)
+		configSpec.Install.EtcdTLSEnabled = etcdCluster.Spec.TLS != nil && etcdCluster.Spec.TLS.Enabled
+	}
+	// the shell pod is always required for maintenance, validation may only be skipped between members
+	skipHealthCheck := configSpec.Install.SkipEtcdEndpointsValidation
+	configSpec.Install.SkipEtcdEndpointsValidation = false
+	configSpec.SkipStorageOSCluster = false
+
+	tlsEnabled := configSpec.Install.EtcdTLSEnabled
+	endpoints := endpointsSplitter(configSpec.Install.EtcdEndpoints, tlsEnabled)
+	lifetime := etcdShellPodLifetime + etcdctlDefragTimeout*time.Duration(len(endpoints))
+
+	return in.withEtcdShellPod(configSpec, lifetime, func(etcdShellPodName, etcdShellPodNS string) error {
+		m := &etcdMaintenance{
+			in:              in,
+			endpoints:       endpoints,
+			tls:             tlsEnabled,
+			skipHealthCheck: skipHealthCheck,
+			etcdctl:         &etcdShell{in: in, podName: etcdShellPodName, podNamespace: etcdShellPodNS},
+		}
+		return m.run(clearAlarms)
+	})
+}
+
+// etcdMaintenance holds the state of a single maintenance run
+type etcdMaintenance struct {
+	in              *Installer
+	endpoints       []string
+	tls             bool
+	skipHealthCheck bool
+	etcdctl         etcdctlRunner
+}
+
+func (m *etcdMaintenance) run(clearAlarms bool) error {
+	statuses, err := m.endpointStatuses(m.endpoints)
+	if err != nil {
+		return err
+	}
+
+	if err = m.compact(statuses); err != nil {
+		return err
+	}
+
+	rows := make([][]string, 0, len(statuses))
+	var totalReclaimed int64
+	for _, before := range defragOrder(statuses) {
+		m.in.log.Infof(etcdDefragmentingMessage, before.Endpoint)
+		if _, err = m.exec(etcdctlDefragCmd(before.Endpoint, m.tls), before.Endpoint); err != nil {
+			m.in.log.Table([]string{"MEMBER", "BEFORE", "AFTER", "RECLAIMED"}, rows)
+			return err
+		}
+
+		if !m.skipHealthCheck {
+			if err = m.checkMemberHealth(before.Endpoint); err != nil {
+				m.in.log.Table([]string{"MEMBER", "BEFORE", "AFTER", "RECLAIMED"}, rows)
+				return fmt.Errorf(errEtcdMemberUnhealthyAfterDefrag, before.Endpoint, err)
+			}
+		}
+
+		after, err := m.endpointStatuses([]string{before.Endpoint})
+		if err != nil {
+			m.in.log.Table([]string{"MEMBER", "BEFORE", "AFTER", "RECLAIMED"}, rows)
+			return err
+		}
+		reclaimed := before.Status.DBSize - after[0].Status.DBSize
+		totalReclaimed += reclaimed
+		rows = append(rows, []string{
+			before.Endpoint,
+			formatBytes(before.Status.DBSize),
+			formatBytes(after[0].Status.DBSize),
+			formatBytes(reclaimed),
+		})
+	}
+	m.in.log.Table([]string{"MEMBER", "BEFORE", "AFTER", "RECLAIMED"}, rows)
+
+	if err = m.handleAlarms(clearAlarms); err != nil {
+		return err
+	}
+	m.in.log.Successf(etcdMaintainedMessage, formatBytes(totalReclaimed))
+
+	return nil
+}
+
+// compact compacts the key space to the highest revision reported by the members
+func (m *etcdMaintenance) compact(statuses []etcdEndpointStatus) error {
+	var revision int64
+	for _, status := range statuses {
+		if status.Status.Header.Revision > revision {
+			revision = status.Status.Header.Revision
+		}
+	}
+
+	endpoints := strings.Join(m.endpoints, ",")
+	if _, err := m.exec(etcdctlCompactionCmd(endpoints, revision, m.tls), endpoints); err != nil {
+		if strings.Contains(err.Error(), "required revision has been compacted") {
+			m.in.log.Successf(etcdAlreadyCompactedMessage, revision)
+			return nil
+		}
+		return err
+	}
+	m.in.log.Successf(etcdCompactedMessage, revision)
+
+	return nil
+}
+
+// handleAlarms reports NOSPACE alarms and disarms them if clearAlarms is set. Alarms are only
+// disarmed if NOSPACE is the only alarm raised, as etcdctl disarms every alarm at once.
+func (m *etcdMaintenance) handleAlarms(clearAlarms bool) error {
+	endpoints := strings.Join(m.endpoints, ",")
+	output, err := m.exec(etcdctlAlarmListCmd(endpoints, m.tls), endpoints)
+	if err != nil {
+		return err
+	}
+	alarms := parseEtcdAlarms(output)
+
+	noSpaceMembers := make([]string, 0)
+	otherAlarms := make([]string, 0)
+	for _, alarm := range alarms {
+		if alarm.alarm == etcdAlarmNoSpace {
+			noSpaceMembers = append(noSpaceMembers, alarm.memberID)
+			continue
+		}
+		otherAlarms = append(otherAlarms, fmt.Sprintf("%s (member %s)", alarm.alarm, alarm.memberID))
+	}
+	if len(noSpaceMembers) == 0 {
+		return nil
+	}
+	if !clearAlarms {
+		m.in.log.Warnf(etcdNoSpaceAlarmMessage, strings.Join(noSpaceMembers, ","), ClearAlarmsFlag)
+		return nil
+	}
+	if len(otherAlarms) != 0 {
+		m.in.log.Warnf(etcdOtherAlarmsRaisedMessage, strings.Join(otherAlarms, ", "))
+		return nil
+	}
+
+	if _, err = m.exec(etcdctlAlarmDisarmCmd(endpoints, m.tls), endpoints); err != nil {
+		return err
+	}
+	m.in.log.Success(etcdAlarmsClearedMessage)
+
+	return nil
+}
+
+// checkMemberHealth checks that the member at endpoint has a leader and serves linearizable
+// reads. Writes are not attempted, as they are refused while a NOSPACE alarm is raised, and
+// etcdctl endpoint health reports any raised alarm as unhealthy.
+func (m *etcdMaintenance) checkMemberHealth(endpoint string) error {
+	statuses, err := m.endpointStatuses([]string{endpoint})
+	if err != nil {
+		return err
+	}
+	if statuses[0].Status.Leader == 0 {
+		return fmt.Errorf(errEtcdMemberNoLeader, endpoint)
+	}
+	_, err = m.exec(withEtcdctlTimeouts(etcdctlGetCmd(endpoint, "kubectl-storageos-maintenance", m.tls)), endpoint)
+
+	return err
+}
+
+// endpointStatuses returns the status of each of endpoints
+func (m *etcdMaintenance) endpointStatuses(endpoints []string) ([]etcdEndpointStatus, error) {
+	joined := strings.Join(endpoints, ",")
+	output, err := m.exec(withEtcdctlTimeouts(etcdctlEndpointStatusCmd(joined, m.tls)), joined)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]etcdEndpointStatus, 0)
+	if err = json.Unmarshal([]byte(output), &statuses); err != nil {
+		return nil, errors.Wrapf(err, "unable to parse status of etcd endpoint(s) %s", joined)
+	}
+	if len(statuses) != len(endpoints) {
+		return nil, fmt.Errorf("expected status of %d etcd endpoint(s), got %d", len(endpoints), len(statuses))
+	}
+
+	return statuses, nil
+}
+
+// exec runs the etcdctl command, returning its output
+func (m *etcdMaintenance) exec(cmd []string, endpoints string) (string, error) {
+	return m.etcdctl.runEtcdctl(endpoints, cmd, nil)
+}
+
+// defragOrder returns statuses with followers first and the leader last, so that leadership
+// changes at most once during maintenance
+func defragOrder(statuses []etcdEndpointStatus) []etcdEndpointStatus {
+	ordered := make([]etcdEndpointStatus, len(statuses))
+	copy(ordered, statuses)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		iLeader := ordered[i].Status.Leader == ordered[i].Status.Header.MemberID
+		jLeader := ordered[j].Status.Leader == ordered[j].Status.Header.MemberID
+		return !iLeader && jLeader
+	})
+
+	return ordered
+}
+
+// parseEtcdAlarms parses the output of etcdctl alarm list, eg. "memberID:1234 alarm:NOSPACE"
+func parseEtcdAlarms(output string) []etcdAlarm {
+	alarms := make([]etcdAlarm, 0)
+	for _, line := range strings.Split(output, "\n") {
+		alarm := etcdAlarm{}
+		for _, field := range strings.Fields(line) {
+			kv := strings.SplitN(field, ":", 2)
+			if len(kv) != 2 {
+				continue
+			}
+			switch kv[0] {
+			case "memberID":
+				alarm.memberID = kv[1]
+			case "alarm":
+				alarm.alarm = kv[1]
+			}
+		}
+		if alarm.alarm != "" {
+			alarms = append(alarms, alarm)
+		}
+	}
+
+	return alarms
+}
+
+// formatBytes returns a human readable size using binary units
+func formatBytes(size int64) string {
+	const unit = 1024
+	sign := ""
+	if size < 0 {
+		sign, size = "-", -size
+	}
+	if size < unit {
+		return fmt.Sprintf("%s%d B", sign, size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%s%.1f %ciB", sign, float64(size)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,228 @@
+package installer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/storageos/kubectl-storageos/pkg/logger"
+)
+
+func TestDefragOrder(t *testing.T) {
+	output := `[
+	{"Endpoint":"http://a:2379","Status":{"header":{"member_id":1,"revision":10},"leader":2,"dbSize":2048}},
+	{"Endpoint":"http://b:2379","Status":{"header":{"member_id":2,"revision":10},"leader":2,"dbSize":4096}},
+	{"Endpoint":"http://c:2379","Status":{"header":{"member_id":3,"revision":9},"leader":2,"dbSize":1024}}
+]`
+	statuses := make([]etcdEndpointStatus, 0)
+	if err := json.Unmarshal([]byte(output), &statuses); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ordered := defragOrder(statuses)
+	endpoints := make([]string, 0, len(ordered))
+	for _, status := range ordered {
+		endpoints = append(endpoints, status.Endpoint)
+	}
+	expEndpoints := []string{"http://a:2379", "http://c:2379", "http://b:2379"}
+	if !reflect.DeepEqual(endpoints, expEndpoints) {
+		t.Errorf("expected %v, got %v", expEndpoints, endpoints)
+	}
+	if statuses[1].Endpoint != "http://b:2379" {
+		t.Errorf("expected statuses to be left unchanged")
+	}
+	if ordered[2].Status.DBSize != 4096 {
+		t.Errorf("expected db size 4096, got %d", ordered[2].Status.DBSize)
+	}
+}
+
+func TestParseEtcdAlarms(t *testing.T) {
+	tcases := []struct {
+		name      string
+		output    string
+		expAlarms []etcdAlarm
+	}{
+		{
+			name:      "no alarms",
+			output:    "",
+			expAlarms: []etcdAlarm{},
+		},
+		{
+			name:   "nospace and corrupt",
+			output: "memberID:8211f1d0f64f3269 alarm:NOSPACE\nmemberID:91bc3c398fb3c146 alarm:CORRUPT\n",
+			expAlarms: []etcdAlarm{
+				{memberID: "8211f1d0f64f3269", alarm: "NOSPACE"},
+				{memberID: "91bc3c398fb3c146", alarm: "CORRUPT"},
+			},
+		},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			if alarms := parseEtcdAlarms(tc.output); !reflect.DeepEqual(alarms, tc.expAlarms) {
+				t.Errorf("expected %v, got %v", tc.expAlarms, alarms)
+			}
+		})
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	tcases := []struct {
+		size    int64
+		expSize string
+	}{
+		{size: 0, expSize: "0 B"},
+		{size: 1023, expSize: "1023 B"},
+		{size: 1536, expSize: "1.5 KiB"},
+		{size: 2 * 1024 * 1024 * 1024, expSize: "2.0 GiB"},
+		{size: -2048, expSize: "-2.0 KiB"},
+	}
+
+	for _, tc := range tcases {
+		if size := formatBytes(tc.size); size != tc.expSize {
+			t.Errorf("expected %q for %d, got %q", tc.expSize, tc.size, size)
+		}
+	}
+}
+
+// fakeNoSpaceEtcd answers etcdctl commands like a healthy cluster with a NOSPACE alarm raised,
+// refusing every write and recording the commands run
+type fakeNoSpaceEtcd struct {
+	members  map[string]uint64
+	leader   uint64
+	disarmed bool
+	commands []string
+}
+
+func (f *fakeNoSpaceEtcd) runEtcdctl(endpoints string, cmd []string, stdin io.Reader) (string, error) {
+	subcommand := etcdctlSubcommand(cmd)
+	f.commands = append(f.commands, subcommand)
+	switch {
+	case strings.HasPrefix(subcommand, "put"):
+		return "", errors.New("etcdserver: mvcc: database space exceeded")
+	case subcommand == "endpoint status":
+		statuses := make([]string, 0)
+		for _, endpoint := range strings.Split(endpoints, ",") {
+			statuses = append(statuses, fmt.Sprintf(`{"Endpoint":%q,"Status":{"header":{"member_id":%d,"revision":10},"leader":%d,"dbSize":4096}}`, endpoint, f.members[endpoint], f.leader))
+		}
+		return "[" + strings.Join(statuses, ",") + "]", nil
+	case subcommand == "alarm list":
+		if f.disarmed {
+			return "", nil
+		}
+		return fmt.Sprintf("memberID:%x alarm:NOSPACE\n", f.leader), nil
+	case subcommand == "alarm disarm":
+		f.disarmed = true
+	}
+
+	return "", nil
+}
+
+func TestEtcdMaintenanceNoSpaceAlarm(t *testing.T) {
+	tcases := []struct {
+		name        string
+		clearAlarms bool
+		expDisarmed bool
+	}{
+		{
+			name:        "alarm cleared",
+			clearAlarms: true,
+			expDisarmed: true,
+		},
+		{
+			name:        "alarm reported",
+			clearAlarms: false,
+			expDisarmed: false,
+		},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			etcd := &fakeNoSpaceEtcd{
+				members: map[string]uint64{"http://a:2379": 1, "http://b:2379": 2, "http://c:2379": 3},
+				leader:  2,
+			}
+			log := logger.NewLogger()
+			log.Writer = &strings.Builder{}
+			m := &etcdMaintenance{
+				in:        &Installer{log: log},
+				endpoints: []string{"http://a:2379", "http://b:2379", "http://c:2379"},
+				etcdctl:   etcd,
+			}
+
+			if err := m.run(tc.clearAlarms); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if etcd.disarmed != tc.expDisarmed {
+				t.Errorf("expected alarm disarmed %v, got %v", tc.expDisarmed, etcd.disarmed)
+			}
+			defragged := 0
+			for _, command := range etcd.commands {
+				if command == "defrag" {
+					defragged++
+				}
+				if strings.HasPrefix(command, "put") {
+					t.Errorf("expected no writes while NOSPACE is raised, got %q", command)
+				}
+			}
+			if defragged != len(m.endpoints) {
+				t.Errorf("expected %d members defragmented, got %d", len(m.endpoints), defragged)
+			}
+		})
+	}
+}
+
+// fakeStatusFailingEtcd answers like fakeNoSpaceEtcd, but fails the status of failEndpoint once
+// it has been defragmented
+type fakeStatusFailingEtcd struct {
+	fakeNoSpaceEtcd
+	failEndpoint string
+	defragged    bool
+}
+
+func (f *fakeStatusFailingEtcd) runEtcdctl(endpoints string, cmd []string, stdin io.Reader) (string, error) {
+	switch etcdctlSubcommand(cmd) {
+	case "defrag":
+		f.defragged = f.defragged || endpoints == f.failEndpoint
+	case "endpoint status":
+		if f.defragged && endpoints == f.failEndpoint {
+			return "", errors.New("context deadline exceeded")
+		}
+	}
+
+	return f.fakeNoSpaceEtcd.runEtcdctl(endpoints, cmd, stdin)
+}
+
+func TestEtcdMaintenanceStatusFailure(t *testing.T) {
+	etcd := &fakeStatusFailingEtcd{
+		fakeNoSpaceEtcd: fakeNoSpaceEtcd{
+			members: map[string]uint64{"http://a:2379": 1, "http://b:2379": 2, "http://c:2379": 3},
+			leader:  2,
+		},
+		failEndpoint: "http://c:2379",
+	}
+	output := &strings.Builder{}
+	log := logger.NewLogger()
+	log.Writer = output
+	m := &etcdMaintenance{
+		in:              &Installer{log: log},
+		endpoints:       []string{"http://a:2379", "http://b:2379", "http://c:2379"},
+		skipHealthCheck: true,
+		etcdctl:         etcd,
+	}
+
+	if err := m.run(false); err == nil {
+		t.Fatal("expected an error")
+	}
+	// members are defragmented in the order a, c, b, so only a has a row
+	if !strings.Contains(output.String(), "RECLAIMED") || !strings.Contains(output.String(), "http://a:2379") {
+		t.Errorf("expected the partial status table to be printed, got %q", output.String())
+	}
+	if strings.Contains(output.String(), "http://b:2379") {
+		t.Errorf("expected only members with a status after defragmenting in the table, got %q", output.String())
+	}
+}
@@ -0,0 +1,472 @@
+package installer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	apiv1 "github.com/storageos/kubectl-storageos/api/v1"
+	"github.com/storageos/kubectl-storageos/pkg/consts"
+	pluginutils "github.com/storageos/kubectl-storageos/pkg/utils"
+	operatorapi "github.com/storageos/operator/api/v1"
+)
+
+const (
+	stosNodeDaemonSetName = "storageos-node"
+	etcdMigrationPrefix   = "etcd-migration-"
+
+	// etcdMigrationTxnOps is the number of keys written to the target etcd per transaction, the
+	// default --max-txn-ops of etcd
+	etcdMigrationTxnOps = 128
+	// etcdMigrationTxnBytes is the size of the keys and values written per transaction, below the
+	// default --max-request-bytes of etcd
+	etcdMigrationTxnBytes = 1024 * 1024
+	// etcdLeaseExpiryLimit is the time in seconds keys attached to leases are given to expire once
+	// StorageOS is stopped
+	etcdLeaseExpiryLimit = 120
+
+	errTargetEndpointsRequired = `
+	ETCD endpoints to migrate to are required, set --%s`
+
+	errTargetEndpointsUnchanged = `
+	StorageOS cluster %s already uses ETCD endpoints %s`
+
+	errTargetEtcdNotEmpty = `
+	ETCD endpoints %s already hold %d key(s). ETCD can only be migrated to an empty ETCD cluster.`
+
+	errEtcdLeasedKeysRemain = `
+	%d key(s) of ETCD endpoints %s are still attached to leases after StorageOS has been stopped,
+	leases cannot be migrated.`
+
+	errEtcdMigrationKeyCount = `
+	Expected %d key(s) in ETCD endpoints %s after restore, found %d.`
+
+	errEtcdMigrationAborted = `
+	ETCD migration aborted`
+
+	errEtcdMigrationStepFailed = `
+	ETCD migration failed at step "%s": %v
+
+	The migration has been rolled back, StorageOS uses ETCD endpoints %s.
+	The StorageOS cluster manifest and ETCD snapshot taken before migration can be found in %s.`
+
+	errEtcdMigrationRollbackFailed = `
+	ETCD migration failed at step "%s": %v
+
+	Rolling back step "%s" has also failed: %v
+
+	StorageOS must be recovered manually. The StorageOS cluster manifest and ETCD snapshot taken
+	before migration can be found in %s.`
+
+	etcdMigrationStepMessage     = `ETCD migration: %s.`
+	etcdMigrationRollbackMessage = `Rolling back ETCD migration step "%s".`
+	etcdLeaseExpiryMessage       = `Waiting for keys attached to leases to expire.`
+	etcdMigratedMessage          = `StorageOS cluster %s migrated to ETCD endpoints %s.`
+)
+
+// etcdKeyValues is the json output of etcdctl get
+type etcdKeyValues struct {
+	Kvs []struct {
+		Key   []byte `json:"key"`
+		Value []byte `json:"value"`
+		Lease int64  `json:"lease"`
+	} `json:"kvs"`
+	Count int64 `json:"count"`
+}
+
+// etcdKeyValue is a single key and value to be migrated
+type etcdKeyValue struct {
+	key   string
+	value []byte
+}
+
+// etcdMigrationStep is a single step of an etcd migration. Rollback undoes the step, it must also
+// succeed if the step has only partially completed.
+type etcdMigrationStep struct {
+	description string
+	run         func() error
+	rollback    func() error
+}
+
+// etcdMigration holds the state of a single etcd migration
+type etcdMigration struct {
+	in               *Installer
+	cluster          *operatorapi.StorageOSCluster
+	source           apiv1.KubectlStorageOSConfigSpec
+	target           apiv1.KubectlStorageOSConfigSpec
+	dir              string
+	operatorReplicas int32
+	snapshot         []etcdKeyValue
+}
+
+// MigrateEtcd migrates StorageOS from its current etcd to the etcd endpoints of the install config.
+// StorageOS is stopped, a snapshot of the current etcd is written to disk, its keys are copied into
+// the target etcd, which must be empty, and the StorageOS cluster is switched to the target
+// endpoints and TLS secret. The migration succeeds once the StorageOS node daemonset is ready
+// again, otherwise it is rolled back step by step.
+func (in *Installer) MigrateEtcd() error {
+	configInstall := in.stosConfig.Spec.Install
+	if configInstall.EtcdEndpoints == "" {
+		return fmt.Errorf(errTargetEndpointsRequired, ToEndpointsFlag)
+	}
+
+	cluster, err := pluginutils.GetFirstStorageOSCluster(in.clientConfig)
+	if err != nil {
+		return err
+	}
+	if cluster.Spec.KVBackend.Address == configInstall.EtcdEndpoints {
+		return fmt.Errorf(errTargetEndpointsUnchanged, cluster.Name, configInstall.EtcdEndpoints)
+	}
+
+	if !in.stosConfig.Spec.SkipExistingWorkloadCheck {
+		report, err := in.storageOSWorkloadReport()
+		if err != nil {
+			return fmt.Errorf("failed to get pvcs - %s - %w ", errEtcdMigrationAborted, err)
+		}
+		if err := in.storageOSWorkloadsExist(report); err != nil {
+			return fmt.Errorf("PVC is in use - %s - %w ", errEtcdMigrationAborted, err)
+		}
+	}
+
+	// StorageOS is stopped and started by scaling the operator, it must exist before anything changes
+	operatorNamespace := configInstall.StorageOSOperatorNamespace
+	if err = pluginutils.IsDeploymentReady(in.clientConfig, consts.NewOperatorName, operatorNamespace); err != nil {
+		return errors.Wrap(err, errEtcdMigrationAborted)
+	}
+
+	dir, err := in.getEtcdMigrationPath()
+	if err != nil {
+		return err
+	}
+
+	m := &etcdMigration{
+		in:      in,
+		cluster: cluster.DeepCopy(),
+		source:  etcdMigrationSpec(in.stosConfig.Spec, cluster.Namespace, cluster.Spec.KVBackend.Address, cluster.Spec.TLSEtcdSecretRefName),
+		target:  etcdMigrationSpec(in.stosConfig.Spec, cluster.Namespace, configInstall.EtcdEndpoints, ""),
+		dir:     dir,
+	}
+	if configInstall.EtcdTLSEnabled {
+		m.target.Install.EtcdSecretName = configInstall.EtcdSecretName
+		m.target.Install.EtcdTLSEnabled = true
+	}
+
+	steps := []etcdMigrationStep{
+		{description: "validate target ETCD", run: m.validateTarget},
+		{description: "back up StorageOS cluster", run: m.backupCluster},
+		{description: "stop StorageOS", run: m.stopStorageOS, rollback: m.startStorageOS},
+		{description: "snapshot source ETCD", run: m.snapshotSource},
+		{description: "copy keys into target ETCD", run: m.restoreTarget, rollback: m.clearTarget},
+		{description: "switch StorageOS cluster to target ETCD", run: m.switchCluster, rollback: m.revertCluster},
+		{description: "start StorageOS", run: m.startStorageOS, rollback: m.stopStorageOS},
+	}
+	if err = m.run(steps); err != nil {
+		return err
+	}
+	in.log.Successf(etcdMigratedMessage, cluster.Name, configInstall.EtcdEndpoints)
+
+	return nil
+}
+
+// etcdMigrationSpec returns a copy of configSpec for the etcd shell pod of endpoints, which runs in
+// the StorageOS cluster namespace as the TLS secret is required there. TLS is enabled if secretName
+// is set.
+func etcdMigrationSpec(configSpec apiv1.KubectlStorageOSConfigSpec, namespace, endpoints, secretName string) apiv1.KubectlStorageOSConfigSpec {
+	spec := *configSpec.DeepCopy()
+	spec.SkipStorageOSCluster = false
+	spec.Install.SkipEtcdEndpointsValidation = false
+	spec.Install.StorageOSClusterNamespace = namespace
+	spec.Install.EtcdEndpoints = endpoints
+	spec.Install.EtcdTLSEnabled = secretName != ""
+	spec.Install.EtcdSecretName = secretName
+
+	return spec
+}
+
+// getEtcdMigrationPath returns the directory of the local files of a new etcd migration
+func (in *Installer) getEtcdMigrationPath() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	name := fmt.Sprintf("%s%v-%s", etcdMigrationPrefix, in.kubeClusterID, time.Now().Format("20060102150405"))
+
+	return filepath.Join(homeDir, kubeDir, stosDir, name), nil
+}
+
+// run runs steps in order. If a step fails, it is rolled back along with the completed steps in
+// reverse order.
+func (m *etcdMigration) run(steps []etcdMigrationStep) error {
+	for i, step := range steps {
+		m.in.log.Warnf(etcdMigrationStepMessage, step.description)
+		err := step.run()
+		if err == nil {
+			continue
+		}
+
+		for j := i; j >= 0; j-- {
+			if steps[j].rollback == nil {
+				continue
+			}
+			m.in.log.Warnf(etcdMigrationRollbackMessage, steps[j].description)
+			if rollbackErr := steps[j].rollback(); rollbackErr != nil {
+				return fmt.Errorf(errEtcdMigrationRollbackFailed, step.description, err, steps[j].description, rollbackErr, m.dir)
+			}
+		}
+		return fmt.Errorf(errEtcdMigrationStepFailed, step.description, err, m.cluster.Spec.KVBackend.Address, m.dir)
+	}
+
+	return nil
+}
+
+// validateTarget checks the health of the target endpoints, unless validation is skipped, and that
+// the target etcd holds no keys
+func (m *etcdMigration) validateTarget() error {
+	tlsEnabled := m.target.Install.EtcdTLSEnabled
+	endpoints := endpointsSplitter(m.target.Install.EtcdEndpoints, tlsEnabled)
+
+	return m.in.withEtcdShellPod(m.target, etcdShellPodLifetime, func(podName, podNamespace string) error {
+		if !m.in.stosConfig.Spec.Install.SkipEtcdEndpointsValidation {
+			if err := m.in.etcdctlHealthCheck(podName, podNamespace, endpoints, tlsEnabled); err != nil {
+				return err
+			}
+		}
+
+		joined := strings.Join(endpoints, ",")
+		output, err := m.in.execEtcdctl(podName, podNamespace, joined, withEtcdctlTimeouts(etcdctlCountAllCmd(joined, tlsEnabled)), nil)
+		if err != nil {
+			return err
+		}
+		keyValues, err := parseEtcdKeyValues(output)
+		if err != nil {
+			return err
+		}
+		if keyValues.Count != 0 {
+			return fmt.Errorf(errTargetEtcdNotEmpty, joined, keyValues.Count)
+		}
+
+		return nil
+	})
+}
+
+// backupCluster writes the StorageOS cluster manifest to the migration directory
+func (m *etcdMigration) backupCluster() error {
+	if err := os.MkdirAll(m.dir, 0700); err != nil {
+		return errors.WithStack(err)
+	}
+	manifest, err := storageOSClusterToManifest(m.cluster)
+	if err != nil {
+		return err
+	}
+
+	return errors.WithStack(os.WriteFile(filepath.Join(m.dir, stosClusterFile), manifest, 0600))
+}
+
+// stopStorageOS scales the StorageOS operator down and deletes the StorageOS node daemonset, so
+// that nothing writes to etcd during the migration
+func (m *etcdMigration) stopStorageOS() error {
+	replicas, err := pluginutils.ScaleDeployment(m.in.clientConfig, consts.NewOperatorName, m.in.stosConfig.Spec.Install.StorageOSOperatorNamespace, 0)
+	if err != nil {
+		return err
+	}
+	if replicas > 0 {
+		m.operatorReplicas = replicas
+	}
+	if err = pluginutils.DeleteDaemonSet(m.in.clientConfig, stosNodeDaemonSetName, m.cluster.Namespace); err != nil {
+		return err
+	}
+
+	return pluginutils.WaitFor(func() error {
+		return pluginutils.DaemonSetDoesNotExist(m.in.clientConfig, stosNodeDaemonSetName, m.cluster.Namespace)
+	}, 300, 5)
+}
+
+// startStorageOS scales the StorageOS operator back up and waits for the StorageOS node daemonset
+// it recreates to be ready on every node. The phase of the StorageOS cluster is not relied upon,
+// as it was last written before the operator was scaled down.
+func (m *etcdMigration) startStorageOS() error {
+	replicas := m.operatorReplicas
+	if replicas == 0 {
+		replicas = 1
+	}
+	if _, err := pluginutils.ScaleDeployment(m.in.clientConfig, consts.NewOperatorName, m.in.stosConfig.Spec.Install.StorageOSOperatorNamespace, replicas); err != nil {
+		return err
+	}
+
+	return pluginutils.WaitFor(func() error {
+		return pluginutils.IsDaemonSetRolledOut(m.in.clientConfig, stosNodeDaemonSetName, m.cluster.Namespace)
+	}, 600, 5)
+}
+
+// snapshotSource waits for the keys attached to leases to expire, saves a snapshot of the source
+// etcd to the migration directory and reads every key to be restored into the target etcd
+func (m *etcdMigration) snapshotSource() error {
+	tlsEnabled := m.source.Install.EtcdTLSEnabled
+	endpoints := endpointsSplitter(m.source.Install.EtcdEndpoints, tlsEnabled)
+	joined := strings.Join(endpoints, ",")
+	lifetime := etcdShellPodLifetime + etcdLeaseExpiryLimit*time.Second + etcdctlSnapshotTimeout
+
+	return m.in.withEtcdShellPod(m.source, lifetime, func(podName, podNamespace string) error {
+		var keyValues *etcdKeyValues
+		getKeyValues := func() error {
+			output, err := m.in.execEtcdctl(podName, podNamespace, joined, etcdctlGetAllCmd(joined, tlsEnabled), nil)
+			if err != nil {
+				return err
+			}
+			if keyValues, err = parseEtcdKeyValues(output); err != nil {
+				return err
+			}
+			if _, leased := etcdKeyValuesToMigrate(keyValues); leased != 0 {
+				return fmt.Errorf(errEtcdLeasedKeysRemain, leased, joined)
+			}
+			return nil
+		}
+		if err := getKeyValues(); err != nil {
+			m.in.log.Warn(etcdLeaseExpiryMessage)
+			if err = pluginutils.WaitFor(getKeyValues, etcdLeaseExpiryLimit, 5); err != nil {
+				return err
+			}
+		}
+		m.snapshot, _ = etcdKeyValuesToMigrate(keyValues)
+
+		return m.in.saveEtcdSnapshot(podName, podNamespace, endpoints[0], tlsEnabled, filepath.Join(m.dir, etcdSnapshotFile))
+	})
+}
+
+// restoreTarget writes every key of the snapshot to the target etcd in batched transactions and
+// checks the number of keys written
+func (m *etcdMigration) restoreTarget() error {
+	tlsEnabled := m.target.Install.EtcdTLSEnabled
+	endpoints := strings.Join(endpointsSplitter(m.target.Install.EtcdEndpoints, tlsEnabled), ",")
+	batches := etcdTxnBatches(m.snapshot, etcdMigrationTxnOps, etcdMigrationTxnBytes)
+	lifetime := etcdShellPodLifetime + time.Duration(len(batches))*etcdctlCommandTimeout
+
+	return m.in.withEtcdShellPod(m.target, lifetime, func(podName, podNamespace string) error {
+		for _, batch := range batches {
+			cmd := withEtcdctlTimeouts(etcdctlTxnCmd(endpoints, tlsEnabled))
+			if _, err := m.in.execEtcdctl(podName, podNamespace, endpoints, cmd, strings.NewReader(etcdTxnPuts(batch))); err != nil {
+				return err
+			}
+		}
+
+		output, err := m.in.execEtcdctl(podName, podNamespace, endpoints, withEtcdctlTimeouts(etcdctlCountAllCmd(endpoints, tlsEnabled)), nil)
+		if err != nil {
+			return err
+		}
+		keyValues, err := parseEtcdKeyValues(output)
+		if err != nil {
+			return err
+		}
+		if keyValues.Count != int64(len(m.snapshot)) {
+			return fmt.Errorf(errEtcdMigrationKeyCount, len(m.snapshot), endpoints, keyValues.Count)
+		}
+
+		return nil
+	})
+}
+
+// clearTarget deletes every key from the target etcd, which was empty before the migration
+func (m *etcdMigration) clearTarget() error {
+	tlsEnabled := m.target.Install.EtcdTLSEnabled
+	endpoints := strings.Join(endpointsSplitter(m.target.Install.EtcdEndpoints, tlsEnabled), ",")
+
+	return m.in.withEtcdShellPod(m.target, etcdShellPodLifetime, func(podName, podNamespace string) error {
+		_, err := m.in.execEtcdctl(podName, podNamespace, endpoints, withEtcdctlTimeouts(etcdctlDelAllCmd(endpoints, tlsEnabled)), nil)
+		return err
+	})
+}
+
+// switchCluster sets the kv backend address and etcd TLS secret of the StorageOS cluster to those
+// of the target etcd
+func (m *etcdMigration) switchCluster() error {
+	return m.updateCluster(m.target.Install.EtcdEndpoints, m.target.Install.EtcdSecretName, m.target.Install.StorageOSClusterNamespace)
+}
+
+// revertCluster restores the kv backend address and etcd TLS secret of the StorageOS cluster
+func (m *etcdMigration) revertCluster() error {
+	return m.updateCluster(m.cluster.Spec.KVBackend.Address, m.cluster.Spec.TLSEtcdSecretRefName, m.cluster.Spec.TLSEtcdSecretRefNamespace)
+}
+
+func (m *etcdMigration) updateCluster(address, secretName, secretNamespace string) error {
+	cluster, err := pluginutils.GetFirstStorageOSCluster(m.in.clientConfig)
+	if err != nil {
+		return err
+	}
+	cluster.Spec.KVBackend.Address = address
+	cluster.Spec.TLSEtcdSecretRefName = secretName
+	cluster.Spec.TLSEtcdSecretRefNamespace = ""
+	if secretName != "" {
+		cluster.Spec.TLSEtcdSecretRefNamespace = secretNamespace
+	}
+
+	return errors.WithStack(pluginutils.UpdateStorageOSCluster(m.in.clientConfig, cluster))
+}
+
+// parseEtcdKeyValues parses the json output of etcdctl get
+func parseEtcdKeyValues(output string) (*etcdKeyValues, error) {
+	keyValues := &etcdKeyValues{}
+	if err := json.Unmarshal([]byte(output), keyValues); err != nil {
+		return nil, errors.Wrap(err, "unable to parse etcd keys")
+	}
+
+	return keyValues, nil
+}
+
+// etcdKeyValuesToMigrate returns the keys and values to migrate and the number of keys skipped as
+// they are attached to a lease
+func etcdKeyValuesToMigrate(keyValues *etcdKeyValues) ([]etcdKeyValue, int) {
+	migrate := make([]etcdKeyValue, 0, len(keyValues.Kvs))
+	var leased int
+	for _, kv := range keyValues.Kvs {
+		if kv.Lease != 0 {
+			leased++
+			continue
+		}
+		migrate = append(migrate, etcdKeyValue{key: string(kv.Key), value: kv.Value})
+	}
+
+	return migrate, leased
+}
+
+// etcdTxnBatches splits keyValues into batches of at most maxOps keys and, unless a single key and
+// value is larger, maxBytes of keys and values
+func etcdTxnBatches(keyValues []etcdKeyValue, maxOps, maxBytes int) [][]etcdKeyValue {
+	batches := make([][]etcdKeyValue, 0)
+	batch := make([]etcdKeyValue, 0)
+	size := 0
+	for _, kv := range keyValues {
+		kvSize := len(kv.key) + len(kv.value)
+		if len(batch) != 0 && (len(batch) == maxOps || size+kvSize > maxBytes) {
+			batches = append(batches, batch)
+			batch, size = make([]etcdKeyValue, 0), 0
+		}
+		batch = append(batch, kv)
+		size += kvSize
+	}
+	if len(batch) != 0 {
+		batches = append(batches, batch)
+	}
+
+	return batches
+}
+
+// etcdTxnPuts returns the etcdctl txn input putting every key and value without conditions. Keys
+// and values are quoted, so that any byte can be written, and follow "--", so that neither is read
+// as a flag.
+func etcdTxnPuts(keyValues []etcdKeyValue) string {
+	var txn strings.Builder
+	// no compares
+	txn.WriteString("\n")
+	for _, kv := range keyValues {
+		fmt.Fprintf(&txn, "put -- %s %s\n", strconv.Quote(kv.key), strconv.Quote(string(kv.value)))
+	}
+	// no failure operations
+	txn.WriteString("\n\n")
+
+	return txn.String()
+}
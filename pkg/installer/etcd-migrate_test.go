@@ -0,0 +1,245 @@
+package installer
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	apiv1 "github.com/storageos/kubectl-storageos/api/v1"
+	"github.com/storageos/kubectl-storageos/pkg/logger"
+	operatorapi "github.com/storageos/operator/api/v1"
+)
+
+func TestEtcdKeyValuesToMigrate(t *testing.T) {
+	// keys and values are base64 encoded by etcdctl, "c3RvcmFnZW9zL2E=" is "storageos/a"
+	output := `{
+	"header":{"revision":12},
+	"kvs":[
+		{"key":"c3RvcmFnZW9zL2E=","create_revision":2,"mod_revision":2,"version":1,"value":"AAEC"},
+		{"key":"c3RvcmFnZW9zL2I=","create_revision":3,"mod_revision":3,"version":1,"value":"YmFy","lease":7587862072905402631},
+		{"key":"c3RvcmFnZW9zL2M=","create_revision":4,"mod_revision":4,"version":1}
+	],
+	"count":3
+}`
+	keyValues, err := parseEtcdKeyValues(output)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if keyValues.Count != 3 {
+		t.Errorf("expected count 3, got %d", keyValues.Count)
+	}
+
+	migrate, leased := etcdKeyValuesToMigrate(keyValues)
+	expMigrate := []etcdKeyValue{
+		{key: "storageos/a", value: []byte{0, 1, 2}},
+		{key: "storageos/c", value: nil},
+	}
+	if !reflect.DeepEqual(migrate, expMigrate) {
+		t.Errorf("expected %v, got %v", expMigrate, migrate)
+	}
+	if leased != 1 {
+		t.Errorf("expected 1 leased key, got %d", leased)
+	}
+
+	if _, err = parseEtcdKeyValues("Error: context deadline exceeded"); err == nil {
+		t.Errorf("expected error for invalid output")
+	}
+}
+
+func TestEtcdMigrationSpec(t *testing.T) {
+	configSpec := apiv1.KubectlStorageOSConfigSpec{
+		SkipStorageOSCluster: true,
+		Install: apiv1.Install{
+			SkipEtcdEndpointsValidation: true,
+			StorageOSClusterNamespace:   "storageos",
+			EtcdEndpoints:               "old:2379",
+			EtcdShellImage:              "registry.example.com/etcd:v3.5.0",
+		},
+	}
+
+	spec := etcdMigrationSpec(configSpec, "kube-system", "new:2379", "etcd-secret")
+	if spec.GetETCDValidationNamespace() != "kube-system" {
+		t.Errorf("expected etcd shell pod in kube-system, got %q", spec.GetETCDValidationNamespace())
+	}
+	if spec.Install.EtcdEndpoints != "new:2379" || !spec.Install.EtcdTLSEnabled || spec.Install.EtcdSecretName != "etcd-secret" {
+		t.Errorf("unexpected etcd settings %+v", spec.Install)
+	}
+	if spec.Install.EtcdShellImage != configSpec.Install.EtcdShellImage {
+		t.Errorf("expected etcd shell settings to be kept")
+	}
+	if configSpec.Install.EtcdEndpoints != "old:2379" {
+		t.Errorf("expected config spec to be left unchanged")
+	}
+
+	if spec = etcdMigrationSpec(configSpec, "storageos", "new:2379", ""); spec.Install.EtcdTLSEnabled {
+		t.Errorf("expected tls to be disabled without secret")
+	}
+}
+
+func TestEtcdMigrationRun(t *testing.T) {
+	tcases := []struct {
+		name        string
+		failStep    string
+		failRestore string
+		expCalls    []string
+		expErr      string
+	}{
+		{
+			name:     "success",
+			expCalls: []string{"run stop", "run copy", "run switch", "run start"},
+		},
+		{
+			name:     "failed step is rolled back with completed steps",
+			failStep: "switch",
+			expCalls: []string{"run stop", "run copy", "run switch", "rollback switch", "rollback copy", "rollback stop"},
+			expErr:   `failed at step "switch"`,
+		},
+		{
+			name:        "failed rollback stops rollback",
+			failStep:    "start",
+			failRestore: "copy",
+			expCalls:    []string{"run stop", "run copy", "run switch", "run start", "rollback start", "rollback switch", "rollback copy"},
+			expErr:      `Rolling back step "copy" has also failed`,
+		},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			calls := make([]string, 0)
+			step := func(name string, withRollback bool) etcdMigrationStep {
+				s := etcdMigrationStep{
+					description: name,
+					run: func() error {
+						calls = append(calls, "run "+name)
+						if name == tc.failStep {
+							return errors.New("step failed")
+						}
+						return nil
+					},
+				}
+				if withRollback {
+					s.rollback = func() error {
+						calls = append(calls, "rollback "+name)
+						if name == tc.failRestore {
+							return errors.New("rollback failed")
+						}
+						return nil
+					}
+				}
+				return s
+			}
+
+			m := &etcdMigration{
+				in:      &Installer{log: logger.NewLogger()},
+				cluster: &operatorapi.StorageOSCluster{},
+			}
+			err := m.run([]etcdMigrationStep{
+				step("stop", true),
+				step("copy", true),
+				step("switch", true),
+				step("start", true),
+			})
+			if !reflect.DeepEqual(calls, tc.expCalls) {
+				t.Errorf("expected calls %v, got %v", tc.expCalls, calls)
+			}
+			if tc.expErr == "" && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if tc.expErr != "" && (err == nil || !strings.Contains(err.Error(), tc.expErr)) {
+				t.Errorf("expected error containing %q, got %v", tc.expErr, err)
+			}
+		})
+	}
+}
+
+func TestEtcdTxnBatches(t *testing.T) {
+	keyValues := []etcdKeyValue{
+		{key: "a", value: []byte("123")},
+		{key: "b", value: []byte("123")},
+		{key: "c", value: []byte("123456789")},
+		{key: "d", value: []byte("1")},
+		{key: "e", value: []byte("1")},
+	}
+	tcases := []struct {
+		name       string
+		maxOps     int
+		maxBytes   int
+		expBatches [][]string
+	}{
+		{
+			name:       "single batch",
+			maxOps:     128,
+			maxBytes:   1024,
+			expBatches: [][]string{{"a", "b", "c", "d", "e"}},
+		},
+		{
+			name:       "limited by ops",
+			maxOps:     2,
+			maxBytes:   1024,
+			expBatches: [][]string{{"a", "b"}, {"c", "d"}, {"e"}},
+		},
+		{
+			name:       "limited by bytes, oversized key alone",
+			maxOps:     128,
+			maxBytes:   8,
+			expBatches: [][]string{{"a", "b"}, {"c"}, {"d", "e"}},
+		},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			batches := make([][]string, 0)
+			for _, batch := range etcdTxnBatches(keyValues, tc.maxOps, tc.maxBytes) {
+				keys := make([]string, 0, len(batch))
+				for _, kv := range batch {
+					keys = append(keys, kv.key)
+				}
+				batches = append(batches, keys)
+			}
+			if !reflect.DeepEqual(batches, tc.expBatches) {
+				t.Errorf("expected %v, got %v", tc.expBatches, batches)
+			}
+		})
+	}
+
+	if batches := etcdTxnBatches(nil, 128, 1024); len(batches) != 0 {
+		t.Errorf("expected no batches, got %v", batches)
+	}
+}
+
+func TestEtcdTxnPuts(t *testing.T) {
+	keyValues := []etcdKeyValue{
+		{key: "storageos/a", value: []byte{0, 1, 2, 0xff}},
+		{key: "--storageos/b", value: []byte("-1 \"quoted\"\nline")},
+		{key: "storageos/c", value: nil},
+	}
+	txn := etcdTxnPuts(keyValues)
+
+	// an empty line ends the compares, the success operations and the failure operations
+	lines := strings.Split(txn, "\n")
+	if len(lines) != len(keyValues)+4 || lines[0] != "" || strings.Join(lines[len(lines)-3:], "") != "" {
+		t.Fatalf("expected empty compares and failure operations, got %q", txn)
+	}
+
+	// arguments are split and unquoted the same way as etcdctl txn does
+	argRegexp := regexp.MustCompile(`"(?:[^"\\]|\\.)*"|'[^']*'|[^'"\s]\S*[^'"\s]?`)
+	for i, op := range lines[1 : len(keyValues)+1] {
+		args := argRegexp.FindAllString(op, -1)
+		if len(args) != 4 || args[0] != "put" || args[1] != "--" {
+			t.Fatalf("expected put -- <key> <value>, got %q", op)
+		}
+		var key, value string
+		if _, err := fmt.Sscanf(args[2], "%q", &key); err != nil {
+			t.Fatalf("unable to unquote key %s: %v", args[2], err)
+		}
+		if _, err := fmt.Sscanf(args[3], "%q", &value); err != nil {
+			t.Fatalf("unable to unquote value %s: %v", args[3], err)
+		}
+		if key != keyValues[i].key || value != string(keyValues[i].value) {
+			t.Errorf("expected %q=%q, got %q=%q", keyValues[i].key, keyValues[i].value, key, value)
+		}
+	}
+}
@@ -0,0 +1,281 @@
+package installer
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+	pluginutils "github.com/storageos/kubectl-storageos/pkg/utils"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// keys of the etcd secret, these match the file names expected at /run/storageos/pki
+	etcdSecretCAKey   = "etcd-client-ca.crt"
+	etcdSecretCertKey = "etcd-client.crt"
+	etcdSecretKeyKey  = "etcd-client.key"
+
+	// the CA key is never stored in the secret, it is only written to disk for signing server certificates
+	etcdCAKeyFile = "etcd-ca.key"
+
+	etcdCACommonName     = "storageos-etcd-ca"
+	etcdClientCommonName = "storageos-etcd-client"
+
+	errEtcdSecretExists = `
+	Secret %s already exists in namespace %s.
+
+	Please delete the existing secret before creating a new one:
+
+	kubectl delete secret %s -n %s`
+
+	errEtcdCertFilesNotSet = `
+	Either set --%s to generate a self-signed CA and client certificate,
+	or set all of --%s, --%s and --%s to package existing files.`
+
+	etcdCertsWrittenMessage  = `Generated CA, client certificate and keys written to %s. The etcd server certificate must be signed by, and client authentication must trust, %s.`
+	etcdSecretCreatedMessage = `Secret %s created in namespace %s.`
+)
+
+// EtcdSecretOptions holds the options of the etcd create-secret command.
+type EtcdSecretOptions struct {
+	Name       string
+	Namespace  string
+	CACertPath string
+	CertPath   string
+	KeyPath    string
+	Generate   bool
+	Validity   time.Duration
+	OutputDir  string
+}
+
+// etcdCerts holds the PEM encoded CA, client certificate and client key of an etcd secret.
+type etcdCerts struct {
+	ca    []byte
+	cert  []byte
+	key   []byte
+	caKey []byte
+}
+
+// CreateEtcdSecret either reads existing certificate files or generates a self-signed CA and client
+// certificate, validates them and creates the etcd secret with app=storageos label so that it is
+// backed up locally during uninstall.
+func (in *Installer) CreateEtcdSecret(opts EtcdSecretOptions) error {
+	var certs *etcdCerts
+	var err error
+	switch {
+	case opts.Generate:
+		certs, err = generateEtcdCerts(opts.Validity, time.Now())
+		if err != nil {
+			return err
+		}
+		if opts.OutputDir != "" {
+			if err = writeEtcdCertsToDisk(certs, opts.OutputDir); err != nil {
+				return err
+			}
+			in.log.Warnf(etcdCertsWrittenMessage, opts.OutputDir, filepath.Join(opts.OutputDir, etcdSecretCAKey))
+		}
+	case opts.CACertPath != "" && opts.CertPath != "" && opts.KeyPath != "":
+		certs, err = readEtcdCertsFromDisk(opts.CACertPath, opts.CertPath, opts.KeyPath)
+		if err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf(errEtcdCertFilesNotSet, GenerateCertsFlag, EtcdCACertFlag, EtcdClientCertFlag, EtcdClientKeyFlag)
+	}
+
+	if err = validateEtcdCerts(certs, time.Now()); err != nil {
+		return err
+	}
+
+	if err = pluginutils.EnsureNamespace(in.clientConfig, opts.Namespace); err != nil {
+		return err
+	}
+
+	if err = pluginutils.CreateSecret(in.clientConfig, etcdSecret(opts.Name, opts.Namespace, certs), opts.Namespace); err != nil {
+		if kerrors.IsAlreadyExists(err) {
+			return fmt.Errorf(errEtcdSecretExists, opts.Name, opts.Namespace, opts.Name, opts.Namespace)
+		}
+		return errors.WithStack(err)
+	}
+	in.log.Successf(etcdSecretCreatedMessage, opts.Name, opts.Namespace)
+
+	return nil
+}
+
+// etcdSecret returns the etcd secret object, labelled app=storageos
+func etcdSecret(name, namespace string, certs *etcdCerts) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels: map[string]string{
+				"app": "storageos",
+			},
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: map[string][]byte{
+			etcdSecretCAKey:   certs.ca,
+			etcdSecretCertKey: certs.cert,
+			etcdSecretKeyKey:  certs.key,
+		},
+	}
+}
+
+// readEtcdCertsFromDisk reads the CA, client certificate and client key at the given paths
+func readEtcdCertsFromDisk(caPath, certPath, keyPath string) (*etcdCerts, error) {
+	certs := &etcdCerts{}
+	var err error
+	if certs.ca, err = os.ReadFile(caPath); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if certs.cert, err = os.ReadFile(certPath); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if certs.key, err = os.ReadFile(keyPath); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return certs, nil
+}
+
+// writeEtcdCertsToDisk writes the CA, client certificate and client key to dir, using the same
+// file names as the keys of the etcd secret. The CA key is also written so that the etcd server
+// certificates can be signed by the same CA.
+func writeEtcdCertsToDisk(certs *etcdCerts, dir string) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return errors.WithStack(err)
+	}
+	for name, data := range map[string][]byte{
+		etcdSecretCAKey:   certs.ca,
+		etcdSecretCertKey: certs.cert,
+		etcdSecretKeyKey:  certs.key,
+		etcdCAKeyFile:     certs.caKey,
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0600); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	return nil
+}
+
+// generateEtcdCerts generates a self-signed CA and a client certificate signed by that CA, both
+// valid from now for the duration of validity.
+func generateEtcdCerts(validity time.Duration, now time.Time) (*etcdCerts, error) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	caSerial, err := newSerialNumber()
+	if err != nil {
+		return nil, err
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          caSerial,
+		Subject:               pkix.Name{CommonName: etcdCACommonName, Organization: []string{"StorageOS"}},
+		NotBefore:             now.Add(-time.Minute),
+		NotAfter:              now.Add(validity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	caCert, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	clientKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	clientSerial, err := newSerialNumber()
+	if err != nil {
+		return nil, err
+	}
+	clientTemplate := &x509.Certificate{
+		SerialNumber: clientSerial,
+		Subject:      pkix.Name{CommonName: etcdClientCommonName, Organization: []string{"StorageOS"}},
+		NotBefore:    now.Add(-time.Minute),
+		NotAfter:     now.Add(validity),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	clientDER, err := x509.CreateCertificate(rand.Reader, clientTemplate, caCert, &clientKey.PublicKey, caKey)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	clientKeyDER, err := x509.MarshalECPrivateKey(clientKey)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	caKeyDER, err := x509.MarshalECPrivateKey(caKey)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return &etcdCerts{
+		ca:    pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}),
+		cert:  pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: clientDER}),
+		key:   pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: clientKeyDER}),
+		caKey: pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: caKeyDER}),
+	}, nil
+}
+
+// validateEtcdCerts returns an error if the client key does not match the client certificate, if
+// the client certificate is not signed by the CA or if any certificate is not valid at time now.
+func validateEtcdCerts(certs *etcdCerts, now time.Time) error {
+	keyPair, err := tls.X509KeyPair(certs.cert, certs.key)
+	if err != nil {
+		return errors.Wrap(err, "client certificate and key do not match")
+	}
+	clientCert, err := x509.ParseCertificate(keyPair.Certificate[0])
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	caPool := x509.NewCertPool()
+	if !caPool.AppendCertsFromPEM(certs.ca) {
+		return fmt.Errorf("no valid PEM encoded certificate found in CA")
+	}
+
+	if now.After(clientCert.NotAfter) {
+		return fmt.Errorf("client certificate %s expired at %s", clientCert.Subject.CommonName, clientCert.NotAfter.Format(time.RFC3339))
+	}
+	if now.Before(clientCert.NotBefore) {
+		return fmt.Errorf("client certificate %s is not valid before %s", clientCert.Subject.CommonName, clientCert.NotBefore.Format(time.RFC3339))
+	}
+
+	if _, err = clientCert.Verify(x509.VerifyOptions{
+		Roots:       caPool,
+		CurrentTime: now,
+		KeyUsages:   []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}); err != nil {
+		return errors.Wrap(err, "client certificate is not valid for the given CA")
+	}
+
+	return nil
+}
+
+func newSerialNumber() (*big.Int, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return serial, nil
+}
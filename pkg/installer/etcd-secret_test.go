@@ -0,0 +1,93 @@
+package installer
+
+import (
+	"testing"
+	"time"
+)
+
+func TestValidateEtcdCerts(t *testing.T) {
+	now := time.Now()
+
+	validCerts, err := generateEtcdCerts(time.Hour, now)
+	if err != nil {
+		t.Fatalf("failed to generate certs: %v", err)
+	}
+	otherCerts, err := generateEtcdCerts(time.Hour, now)
+	if err != nil {
+		t.Fatalf("failed to generate certs: %v", err)
+	}
+
+	tcases := []struct {
+		name      string
+		certs     *etcdCerts
+		now       time.Time
+		expectErr bool
+	}{
+		{
+			name:      "valid chain",
+			certs:     validCerts,
+			now:       now,
+			expectErr: false,
+		},
+		{
+			name:      "expired",
+			certs:     validCerts,
+			now:       now.Add(2 * time.Hour),
+			expectErr: true,
+		},
+		{
+			name:      "not yet valid",
+			certs:     validCerts,
+			now:       now.Add(-time.Hour),
+			expectErr: true,
+		},
+		{
+			name:      "wrong CA",
+			certs:     &etcdCerts{ca: otherCerts.ca, cert: validCerts.cert, key: validCerts.key},
+			now:       now,
+			expectErr: true,
+		},
+		{
+			name:      "mismatched key",
+			certs:     &etcdCerts{ca: validCerts.ca, cert: validCerts.cert, key: otherCerts.key},
+			now:       now,
+			expectErr: true,
+		},
+		{
+			name:      "invalid CA",
+			certs:     &etcdCerts{ca: []byte("not a certificate"), cert: validCerts.cert, key: validCerts.key},
+			now:       now,
+			expectErr: true,
+		},
+	}
+	for _, tc := range tcases {
+		err := validateEtcdCerts(tc.certs, tc.now)
+		if tc.expectErr && err == nil {
+			t.Errorf("%s: expected error, got nil", tc.name)
+		}
+		if !tc.expectErr && err != nil {
+			t.Errorf("%s: expected no error, got %v", tc.name, err)
+		}
+	}
+}
+
+func TestEtcdSecret(t *testing.T) {
+	certs := &etcdCerts{ca: []byte("ca"), cert: []byte("cert"), key: []byte("key"), caKey: []byte("ca-key")}
+	secret := etcdSecret("storageos-etcd-secret", "storageos", certs)
+
+	if secret.Labels["app"] != "storageos" {
+		t.Errorf("expected app=storageos label, got %v", secret.Labels)
+	}
+	for key, expected := range map[string]string{
+		etcdSecretCAKey:   "ca",
+		etcdSecretCertKey: "cert",
+		etcdSecretKeyKey:  "key",
+	} {
+		if string(secret.Data[key]) != expected {
+			t.Errorf("expected %s for key %s, got %s", expected, key, secret.Data[key])
+		}
+	}
+	if len(secret.Data) != 3 {
+		t.Errorf("expected CA key to be excluded from secret, got keys %v", secret.Data)
+	}
+}
@@ -0,0 +1,294 @@
+package installer
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	gyaml "github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+	apiv1 "github.com/storageos/kubectl-storageos/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/rand"
+)
+
+const (
+	etcdShellPodPrefix      = "storageos-etcd-shell"
+	etcdShellContainerName  = "storageos-etcd-shell"
+	defaultEtcdShellImage   = "gcr.io/etcd-development/etcd:v3.5.0"
+	etcdShellCertsVolume    = "etcd-certs"
+	etcdShellCertsMountPath = "/run/storageos/pki"
+
+	// etcdShellPodLifetime is the lifetime of the etcd shell pod used for endpoint validation
+	etcdShellPodLifetime = 3 * time.Minute
+
+	errInvalidNodeSelector = `
+	Invalid etcd shell node selector %q, expected a comma separated list of key=value pairs.`
+
+	errInvalidToleration = `
+	Invalid etcd shell toleration %q, expected a comma separated list of key[=value][:effect]
+	where effect is one of NoSchedule, PreferNoSchedule or NoExecute.`
+
+	errInvalidRunAsUser = `
+	Invalid etcd shell user %q, expected a non-root numeric user ID.`
+
+	errInvalidRunAsGroup = `
+	Invalid etcd shell group %q, expected a numeric group ID.`
+
+	errInvalidFSGroup = `
+	Invalid etcd shell fs group %q, expected a numeric group ID.`
+
+	errInvalidSeccompProfile = `
+	Invalid etcd shell seccomp profile %q, expected RuntimeDefault, Unconfined or Localhost/<profile>.`
+)
+
+// etcdShellPod returns the pod used to run etcdctl against the etcd endpoints. The pod is given a
+// unique name so that concurrent runs cannot collide and completes after lifetime. Image, pull
+// secrets, tolerations, node selector and security context are taken from configInstall, if set.
+func etcdShellPod(namespace string, configInstall apiv1.Install, lifetime time.Duration) (*corev1.Pod, error) {
+	image := getStringWithDefault(configInstall.EtcdShellImage, defaultEtcdShellImage)
+	pod, err := shellPod(namespace, etcdShellPodPrefix, etcdShellContainerName, image, configInstall, lifetime)
+	if err != nil {
+		return nil, err
+	}
+
+	if configInstall.EtcdTLSEnabled {
+		pod.Spec.Volumes = []corev1.Volume{
+			{
+				Name: etcdShellCertsVolume,
+				VolumeSource: corev1.VolumeSource{
+					Secret: &corev1.SecretVolumeSource{
+						SecretName: configInstall.EtcdSecretName,
+					},
+				},
+			},
+		}
+		pod.Spec.Containers[0].VolumeMounts = []corev1.VolumeMount{
+			{
+				Name:      etcdShellCertsVolume,
+				MountPath: etcdShellCertsMountPath,
+				ReadOnly:  true,
+			},
+		}
+	}
+
+	return pod, nil
+}
+
+// shellPod returns a pod named after prefix in namespace, running image in a container which sleeps
+// for lifetime. The pull secrets, tolerations, node selector and security context of the etcd shell
+// are taken from configInstall, so that every shell pod of the plugin is scheduled and secured alike.
+func shellPod(namespace, prefix, containerName, image string, configInstall apiv1.Install, lifetime time.Duration) (*corev1.Pod, error) {
+	nodeSelector, err := parseNodeSelector(configInstall.EtcdShellNodeSelector)
+	if err != nil {
+		return nil, err
+	}
+	tolerations, err := parseTolerations(configInstall.EtcdShellTolerations)
+	if err != nil {
+		return nil, err
+	}
+	podSecurityContext, containerSecurityContext, err := etcdShellSecurityContexts(configInstall)
+	if err != nil {
+		return nil, err
+	}
+
+	return &corev1.Pod{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "Pod",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-%s", prefix, rand.String(5)),
+			Namespace: namespace,
+			Labels: map[string]string{
+				"app.kubernetes.io/name":       prefix,
+				"app.kubernetes.io/managed-by": "kubectl-storageos",
+			},
+		},
+		Spec: corev1.PodSpec{
+			// pod completes and is not restarted after its lifetime, this is in case
+			// the plugin crashes and is unable to delete this pod after use
+			RestartPolicy:    corev1.RestartPolicyOnFailure,
+			NodeSelector:     nodeSelector,
+			Tolerations:      tolerations,
+			ImagePullSecrets: parseImagePullSecrets(configInstall.EtcdShellImagePullSecrets),
+			SecurityContext:  podSecurityContext,
+			Containers: []corev1.Container{
+				{
+					Name:            containerName,
+					Image:           image,
+					Command:         []string{"sleep"},
+					Args:            []string{fmt.Sprintf("%d", int(lifetime.Seconds()))},
+					SecurityContext: containerSecurityContext,
+				},
+			},
+		},
+	}, nil
+}
+
+// etcdShellSecurityContexts returns the pod and container security contexts of configInstall. If
+// none of their fields is set, none are returned and the defaults of the cluster apply. Otherwise
+// the container cannot escalate privileges and drops all capabilities, and a user ID runs the pod
+// as non-root, in the group of the same ID and with the RuntimeDefault seccomp profile unless they
+// are set, as required by the restricted pod security standard.
+func etcdShellSecurityContexts(configInstall apiv1.Install) (*corev1.PodSecurityContext, *corev1.SecurityContext, error) {
+	if configInstall.EtcdShellRunAsUser == "" && configInstall.EtcdShellRunAsGroup == "" && configInstall.EtcdShellFSGroup == "" &&
+		!configInstall.EtcdShellRunAsNonRoot && configInstall.EtcdShellSeccompProfile == "" {
+		return nil, nil, nil
+	}
+
+	podSecurityContext := &corev1.PodSecurityContext{}
+	if configInstall.EtcdShellRunAsUser != "" {
+		uid, err := strconv.ParseInt(configInstall.EtcdShellRunAsUser, 10, 64)
+		if err != nil || uid <= 0 {
+			return nil, nil, fmt.Errorf(errInvalidRunAsUser, configInstall.EtcdShellRunAsUser)
+		}
+		runAsNonRoot := true
+		podSecurityContext.RunAsUser = &uid
+		podSecurityContext.RunAsGroup = &uid
+		podSecurityContext.RunAsNonRoot = &runAsNonRoot
+		podSecurityContext.SeccompProfile = &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault}
+	}
+	if configInstall.EtcdShellRunAsGroup != "" {
+		gid, err := strconv.ParseInt(configInstall.EtcdShellRunAsGroup, 10, 64)
+		if err != nil || gid < 0 {
+			return nil, nil, fmt.Errorf(errInvalidRunAsGroup, configInstall.EtcdShellRunAsGroup)
+		}
+		podSecurityContext.RunAsGroup = &gid
+	}
+	if configInstall.EtcdShellFSGroup != "" {
+		fsGroup, err := strconv.ParseInt(configInstall.EtcdShellFSGroup, 10, 64)
+		if err != nil || fsGroup < 0 {
+			return nil, nil, fmt.Errorf(errInvalidFSGroup, configInstall.EtcdShellFSGroup)
+		}
+		podSecurityContext.FSGroup = &fsGroup
+	}
+	if configInstall.EtcdShellRunAsNonRoot {
+		runAsNonRoot := true
+		podSecurityContext.RunAsNonRoot = &runAsNonRoot
+	}
+	if configInstall.EtcdShellSeccompProfile != "" {
+		seccompProfile, err := parseSeccompProfile(configInstall.EtcdShellSeccompProfile)
+		if err != nil {
+			return nil, nil, err
+		}
+		podSecurityContext.SeccompProfile = seccompProfile
+	}
+
+	allowPrivilegeEscalation := false
+	containerSecurityContext := &corev1.SecurityContext{
+		AllowPrivilegeEscalation: &allowPrivilegeEscalation,
+		Capabilities: &corev1.Capabilities{
+			Drop: []corev1.Capability{"ALL"},
+		},
+	}
+
+	return podSecurityContext, containerSecurityContext, nil
+}
+
+// parseSeccompProfile parses a seccomp profile type, followed by the profile after a slash for the
+// Localhost type
+func parseSeccompProfile(profile string) (*corev1.SeccompProfile, error) {
+	localhostPrefix := string(corev1.SeccompProfileTypeLocalhost) + "/"
+	switch {
+	case profile == string(corev1.SeccompProfileTypeRuntimeDefault) || profile == string(corev1.SeccompProfileTypeUnconfined):
+		return &corev1.SeccompProfile{Type: corev1.SeccompProfileType(profile)}, nil
+	case strings.HasPrefix(profile, localhostPrefix) && len(profile) > len(localhostPrefix):
+		localhostProfile := strings.TrimPrefix(profile, localhostPrefix)
+		return &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeLocalhost, LocalhostProfile: &localhostProfile}, nil
+	}
+
+	return nil, fmt.Errorf(errInvalidSeccompProfile, profile)
+}
+
+// parseNodeSelector parses a comma separated list of key=value pairs
+func parseNodeSelector(nodeSelector string) (map[string]string, error) {
+	if nodeSelector == "" {
+		return nil, nil
+	}
+	selector := map[string]string{}
+	for _, pair := range strings.Split(nodeSelector, ",") {
+		kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf(errInvalidNodeSelector, nodeSelector)
+		}
+		selector[kv[0]] = kv[1]
+	}
+
+	return selector, nil
+}
+
+// parseTolerations parses a comma separated list of tolerations in the format key[=value][:effect].
+// A toleration without value uses the Exists operator, a toleration without effect tolerates all
+// effects and the key "*" tolerates every taint.
+func parseTolerations(tolerations string) ([]corev1.Toleration, error) {
+	if tolerations == "" {
+		return nil, nil
+	}
+	parsed := make([]corev1.Toleration, 0)
+	for _, entry := range strings.Split(tolerations, ",") {
+		entry = strings.TrimSpace(entry)
+		toleration := corev1.Toleration{}
+
+		keyValue := entry
+		if i := strings.LastIndex(entry, ":"); i != -1 {
+			keyValue = entry[:i]
+			toleration.Effect = corev1.TaintEffect(entry[i+1:])
+			switch toleration.Effect {
+			case corev1.TaintEffectNoSchedule, corev1.TaintEffectPreferNoSchedule, corev1.TaintEffectNoExecute:
+			default:
+				return nil, fmt.Errorf(errInvalidToleration, entry)
+			}
+		}
+
+		kv := strings.SplitN(keyValue, "=", 2)
+		switch {
+		case kv[0] == "":
+			return nil, fmt.Errorf(errInvalidToleration, entry)
+		case kv[0] == "*" && len(kv) == 1:
+			toleration.Operator = corev1.TolerationOpExists
+		case len(kv) == 1:
+			toleration.Key = kv[0]
+			toleration.Operator = corev1.TolerationOpExists
+		default:
+			toleration.Key = kv[0]
+			toleration.Value = kv[1]
+			toleration.Operator = corev1.TolerationOpEqual
+		}
+		parsed = append(parsed, toleration)
+	}
+
+	return parsed, nil
+}
+
+// parseImagePullSecrets parses a comma separated list of secret names
+func parseImagePullSecrets(secrets string) []corev1.LocalObjectReference {
+	if secrets == "" {
+		return nil
+	}
+	references := make([]corev1.LocalObjectReference, 0)
+	for _, name := range strings.Split(secrets, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			references = append(references, corev1.LocalObjectReference{Name: name})
+		}
+	}
+
+	return references
+}
+
+// podToManifest returns a manifest for pod
+func podToManifest(pod *corev1.Pod) (string, error) {
+	data, err := json.Marshal(pod)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	data, err = gyaml.JSONToYAML(data)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	return string(data), nil
+}
@@ -0,0 +1,243 @@
+package installer
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	apiv1 "github.com/storageos/kubectl-storageos/api/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestParseTolerations(t *testing.T) {
+	tcases := []struct {
+		name           string
+		tolerations    string
+		expTolerations []corev1.Toleration
+		expErr         bool
+	}{
+		{
+			name:        "empty",
+			tolerations: "",
+		},
+		{
+			name:        "key value effect",
+			tolerations: "dedicated=storage:NoSchedule",
+			expTolerations: []corev1.Toleration{
+				{Key: "dedicated", Operator: corev1.TolerationOpEqual, Value: "storage", Effect: corev1.TaintEffectNoSchedule},
+			},
+		},
+		{
+			name:        "key only and key effect",
+			tolerations: "node-role.kubernetes.io/master, node.kubernetes.io/unreachable:NoExecute",
+			expTolerations: []corev1.Toleration{
+				{Key: "node-role.kubernetes.io/master", Operator: corev1.TolerationOpExists},
+				{Key: "node.kubernetes.io/unreachable", Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoExecute},
+			},
+		},
+		{
+			name:        "tolerate everything",
+			tolerations: "*",
+			expTolerations: []corev1.Toleration{
+				{Operator: corev1.TolerationOpExists},
+			},
+		},
+		{
+			name:        "invalid effect",
+			tolerations: "dedicated=storage:Never",
+			expErr:      true,
+		},
+		{
+			name:        "missing key",
+			tolerations: "=storage",
+			expErr:      true,
+		},
+	}
+	for _, tc := range tcases {
+		tolerations, err := parseTolerations(tc.tolerations)
+		if (err != nil) != tc.expErr {
+			t.Errorf("%s: expected error %v, got %v", tc.name, tc.expErr, err)
+		}
+		if !reflect.DeepEqual(tolerations, tc.expTolerations) {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.expTolerations, tolerations)
+		}
+	}
+}
+
+func TestParseNodeSelector(t *testing.T) {
+	tcases := []struct {
+		name            string
+		nodeSelector    string
+		expNodeSelector map[string]string
+		expErr          bool
+	}{
+		{
+			name:         "empty",
+			nodeSelector: "",
+		},
+		{
+			name:            "multiple",
+			nodeSelector:    "kubernetes.io/os=linux, storage=",
+			expNodeSelector: map[string]string{"kubernetes.io/os": "linux", "storage": ""},
+		},
+		{
+			name:         "missing value",
+			nodeSelector: "kubernetes.io/os",
+			expErr:       true,
+		},
+	}
+	for _, tc := range tcases {
+		nodeSelector, err := parseNodeSelector(tc.nodeSelector)
+		if (err != nil) != tc.expErr {
+			t.Errorf("%s: expected error %v, got %v", tc.name, tc.expErr, err)
+		}
+		if !reflect.DeepEqual(nodeSelector, tc.expNodeSelector) {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.expNodeSelector, nodeSelector)
+		}
+	}
+}
+
+func TestEtcdShellPod(t *testing.T) {
+	configInstall := apiv1.Install{
+		EtcdTLSEnabled:            true,
+		EtcdSecretName:            "my-etcd-secret",
+		EtcdShellImage:            "registry.local/etcd:v3.5.4",
+		EtcdShellImagePullSecrets: "regcred,,other",
+		EtcdShellRunAsUser:        "1000",
+	}
+
+	first, err := etcdShellPod("storageos", configInstall, etcdShellPodLifetime)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	second, err := etcdShellPod("storageos", configInstall, etcdShellPodLifetime)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if first.Name == second.Name || !strings.HasPrefix(first.Name, etcdShellPodPrefix+"-") {
+		t.Errorf("expected unique names with prefix %s, got %s and %s", etcdShellPodPrefix, first.Name, second.Name)
+	}
+
+	container := first.Spec.Containers[0]
+	if container.Image != configInstall.EtcdShellImage {
+		t.Errorf("expected image %s, got %s", configInstall.EtcdShellImage, container.Image)
+	}
+	expPullSecrets := []corev1.LocalObjectReference{{Name: "regcred"}, {Name: "other"}}
+	if !reflect.DeepEqual(first.Spec.ImagePullSecrets, expPullSecrets) {
+		t.Errorf("expected pull secrets %v, got %v", expPullSecrets, first.Spec.ImagePullSecrets)
+	}
+	if container.Args[0] != "180" {
+		t.Errorf("expected pod to sleep for 180 seconds, got %v", container.Args)
+	}
+	if first.Spec.Volumes[0].Secret.SecretName != configInstall.EtcdSecretName {
+		t.Errorf("expected secret %s, got %s", configInstall.EtcdSecretName, first.Spec.Volumes[0].Secret.SecretName)
+	}
+	if *first.Spec.SecurityContext.RunAsUser != 1000 || !*first.Spec.SecurityContext.RunAsNonRoot {
+		t.Errorf("expected pod to run as non-root user 1000, got %v", first.Spec.SecurityContext)
+	}
+	if *container.SecurityContext.AllowPrivilegeEscalation {
+		t.Errorf("expected privilege escalation to be disallowed")
+	}
+
+	configInstall = apiv1.Install{}
+	pod, err := etcdShellPod("storageos", configInstall, etcdShellPodLifetime)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if pod.Spec.Containers[0].Image != defaultEtcdShellImage || pod.Spec.SecurityContext != nil || pod.Spec.Volumes != nil {
+		t.Errorf("expected default non-TLS pod, got %v", pod.Spec)
+	}
+
+	if _, err = etcdShellPod("storageos", apiv1.Install{EtcdShellRunAsUser: "0"}, etcdShellPodLifetime); err == nil {
+		t.Errorf("expected error for root user")
+	}
+}
+
+func TestEtcdShellSecurityContexts(t *testing.T) {
+	int64Ptr := func(i int64) *int64 { return &i }
+	boolPtr := func(b bool) *bool { return &b }
+	localhostProfile := "profiles/etcd.json"
+
+	tcases := []struct {
+		name          string
+		configInstall apiv1.Install
+		expContext    *corev1.PodSecurityContext
+		expErr        bool
+	}{
+		{
+			name: "cluster defaults",
+		},
+		{
+			name:          "restricted user",
+			configInstall: apiv1.Install{EtcdShellRunAsUser: "1000"},
+			expContext: &corev1.PodSecurityContext{
+				RunAsUser:      int64Ptr(1000),
+				RunAsGroup:     int64Ptr(1000),
+				RunAsNonRoot:   boolPtr(true),
+				SeccompProfile: &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault},
+			},
+		},
+		{
+			name: "user with group, fs group and localhost seccomp profile",
+			configInstall: apiv1.Install{
+				EtcdShellRunAsUser:      "1000",
+				EtcdShellRunAsGroup:     "3000",
+				EtcdShellFSGroup:        "2000",
+				EtcdShellSeccompProfile: "Localhost/" + localhostProfile,
+			},
+			expContext: &corev1.PodSecurityContext{
+				RunAsUser:      int64Ptr(1000),
+				RunAsGroup:     int64Ptr(3000),
+				FSGroup:        int64Ptr(2000),
+				RunAsNonRoot:   boolPtr(true),
+				SeccompProfile: &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeLocalhost, LocalhostProfile: &localhostProfile},
+			},
+		},
+		{
+			name:          "non-root with user assigned by the cluster",
+			configInstall: apiv1.Install{EtcdShellRunAsNonRoot: true, EtcdShellSeccompProfile: "RuntimeDefault"},
+			expContext: &corev1.PodSecurityContext{
+				RunAsNonRoot:   boolPtr(true),
+				SeccompProfile: &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault},
+			},
+		},
+		{
+			name:          "invalid group",
+			configInstall: apiv1.Install{EtcdShellRunAsGroup: "wheel"},
+			expErr:        true,
+		},
+		{
+			name:          "invalid fs group",
+			configInstall: apiv1.Install{EtcdShellFSGroup: "-1"},
+			expErr:        true,
+		},
+		{
+			name:          "localhost seccomp profile without profile",
+			configInstall: apiv1.Install{EtcdShellSeccompProfile: "Localhost/"},
+			expErr:        true,
+		},
+		{
+			name:          "unknown seccomp profile",
+			configInstall: apiv1.Install{EtcdShellSeccompProfile: "docker/default"},
+			expErr:        true,
+		},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			podContext, containerContext, err := etcdShellSecurityContexts(tc.configInstall)
+			if (err != nil) != tc.expErr {
+				t.Fatalf("expected error %v, got %v", tc.expErr, err)
+			}
+			if !reflect.DeepEqual(podContext, tc.expContext) {
+				t.Errorf("expected pod security context %+v, got %+v", tc.expContext, podContext)
+			}
+			if tc.expContext != nil && (containerContext == nil || *containerContext.AllowPrivilegeEscalation) {
+				t.Errorf("expected privilege escalation to be disallowed, got %+v", containerContext)
+			}
+			if tc.expContext == nil && containerContext != nil {
+				t.Errorf("expected no container security context, got %+v", containerContext)
+			}
+		})
+	}
+}
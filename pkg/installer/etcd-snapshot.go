@@ -0,0 +1,60 @@
+package installer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/pkg/errors"
+	pluginutils "github.com/storageos/kubectl-storageos/pkg/utils"
+)
+
+const (
+	etcdSnapshotFile      = "etcd-snapshot.db"
+	etcdShellSnapshotPath = "/tmp/etcd-snapshot.db"
+
+	errEtcdSnapshotCopyFailed = `
+	Unable to copy ETCD snapshot %s from pod %s; %s: %v %s`
+
+	etcdSnapshotMessage = `ETCD snapshot at revision %d of %d key(s) written to %s, it can be restored with "etcdutl snapshot restore".`
+)
+
+// etcdSnapshotStatus is the json output of etcdctl snapshot status
+type etcdSnapshotStatus struct {
+	Hash      uint32 `json:"hash"`
+	Revision  int64  `json:"revision"`
+	TotalKey  int    `json:"totalKey"`
+	TotalSize int64  `json:"totalSize"`
+}
+
+// saveEtcdSnapshot saves a snapshot of the etcd member at endpoint in the etcd shell pod, checks
+// its integrity and copies it to path. The etcd shell image must provide cat.
+func (in *Installer) saveEtcdSnapshot(podName, podNamespace, endpoint string, tls bool, path string) error {
+	if _, err := in.execEtcdctl(podName, podNamespace, endpoint, etcdctlSnapshotSaveCmd(endpoint, etcdShellSnapshotPath, tls), nil); err != nil {
+		return err
+	}
+	output, err := in.execEtcdctl(podName, podNamespace, endpoint, etcdctlSnapshotStatusCmd(etcdShellSnapshotPath), nil)
+	if err != nil {
+		return err
+	}
+	status := &etcdSnapshotStatus{}
+	if err = json.Unmarshal([]byte(output), status); err != nil {
+		return errors.Wrap(err, "unable to parse etcd snapshot status")
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer file.Close()
+	stderr, err := pluginutils.StreamFromPod(in.clientConfig, []string{"cat", etcdShellSnapshotPath}, "", podName, podNamespace, nil, file)
+	if err != nil {
+		return fmt.Errorf(errEtcdSnapshotCopyFailed, etcdShellSnapshotPath, podNamespace, podName, err, stderr)
+	}
+	if err = file.Close(); err != nil {
+		return errors.WithStack(err)
+	}
+	in.log.Successf(etcdSnapshotMessage, status.Revision, status.TotalKey, path)
+
+	return nil
+}
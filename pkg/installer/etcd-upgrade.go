@@ -0,0 +1,408 @@
+package installer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/coreos/go-semver/semver"
+	gyaml "github.com/ghodss/yaml"
+	etcdoperatorapi "github.com/improbable-eng/etcd-cluster-operator/api/v1alpha1"
+	"github.com/pkg/errors"
+	"github.com/storageos/kubectl-storageos/pkg/consts"
+	pluginutils "github.com/storageos/kubectl-storageos/pkg/utils"
+	pluginversion "github.com/storageos/kubectl-storageos/pkg/version"
+	appsv1 "k8s.io/api/apps/v1"
+)
+
+const (
+	etcdUpgradePrefix = "etcd-upgrade-"
+
+	// etcdSupportedMajorVersion is the only etcd major version supported by the etcd operator
+	etcdSupportedMajorVersion = 3
+
+	errEtcdOperatorDowngrade = `
+	Refusing to downgrade the etcd operator from %s to %s.`
+
+	errEtcdDowngrade = `
+	Refusing to downgrade etcd from %s to %s, etcd does not support downgrades.`
+
+	errEtcdMinorVersionsSkipped = `
+	Refusing to upgrade etcd from %s to %s, etcd is upgraded one minor version at a time.
+	Upgrade to the latest patch release of %s first, in that order.`
+
+	errInvalidEtcdVersion = `
+	Invalid etcd version %q, expected a 3.x.y version without "v" prefix.`
+
+	errEtcdOperatorDeploymentNotFound = `
+	No deployment %s found in the etcd operator manifest.`
+
+	errEtcdMembersNotUpgraded = `
+	ETCD cluster %s has %d of %d member(s) running %s.`
+
+	errEtcdUpgradeFailed = `
+	ETCD upgrade failed: %v
+
+	The EtcdCluster manifest and ETCD snapshot taken before the upgrade can be found in %s.`
+
+	etcdUpToDateMessage          = `ETCD operator %s and etcd cluster %s version %s are up to date.`
+	etcdOperatorUpgradingMessage = `Upgrading the etcd operator from %s to %s.`
+	etcdOperatorUpgradedMessage  = `ETCD operator upgraded to %s.`
+	etcdUpgradingMessage         = `Upgrading etcd cluster %s from %s to %s, one member at a time.`
+	etcdMemberUpgradingMessage   = `Waiting for the etcd operator to replace member %d of %d of etcd cluster %s with one running %s.`
+	etcdMemberUpgradedMessage    = `%d of %d etcd member(s) running %s.`
+	etcdUpgradedMessage          = `ETCD cluster %s upgraded to %s.`
+)
+
+// etcdOperatorCarriedArgs are the prefixes of the etcd operator arguments set at install time, which
+// are carried over to the upgraded operator
+var etcdOperatorCarriedArgs = []string{
+	"--leader-election-cm-namespace=",
+	"--proxy-url=",
+	"--etcd-repository=",
+}
+
+// etcdUpgradePlan describes the changes made by an etcd upgrade
+type etcdUpgradePlan struct {
+	operatorFrom string
+	operatorTo   string
+	etcdFrom     string
+	etcdTo       string
+}
+
+// upgradeOperator returns true if the etcd operator is to be upgraded
+func (p etcdUpgradePlan) upgradeOperator() bool {
+	return p.operatorFrom != p.operatorTo
+}
+
+// upgradeEtcd returns true if the etcd cluster version is to be upgraded
+func (p etcdUpgradePlan) upgradeEtcd() bool {
+	return p.etcdFrom != p.etcdTo
+}
+
+// planEtcdUpgrade returns the upgrade from the current etcd operator and etcd versions to the target
+// versions. An empty target keeps the current version, downgrades and etcd upgrades skipping a minor
+// version are refused.
+func planEtcdUpgrade(operatorFrom, operatorTo, etcdFrom, etcdTo string) (etcdUpgradePlan, error) {
+	plan := etcdUpgradePlan{operatorFrom: operatorFrom, operatorTo: operatorFrom, etcdFrom: etcdFrom, etcdTo: etcdFrom}
+
+	// develop is only replaced by develop
+	if operatorTo != "" && operatorTo != operatorFrom && !pluginversion.IsDevelop(operatorFrom) {
+		if !pluginversion.IsDevelop(operatorTo) {
+			lessThan, err := pluginversion.VersionIsLessThan(operatorTo, operatorFrom)
+			if err != nil {
+				return plan, err
+			}
+			if lessThan {
+				return plan, fmt.Errorf(errEtcdOperatorDowngrade, operatorFrom, operatorTo)
+			}
+		}
+		plan.operatorTo = operatorTo
+	}
+
+	if etcdTo != "" && etcdTo != etcdFrom {
+		to, err := semver.NewVersion(etcdTo)
+		if err != nil || to.Major != etcdSupportedMajorVersion {
+			return plan, fmt.Errorf(errInvalidEtcdVersion, etcdTo)
+		}
+		from, err := semver.NewVersion(etcdFrom)
+		if err != nil {
+			return plan, errors.WithStack(err)
+		}
+		if to.LessThan(*from) {
+			return plan, fmt.Errorf(errEtcdDowngrade, etcdFrom, etcdTo)
+		}
+		if skipped := etcdIntermediateMinorVersions(*from, *to); len(skipped) != 0 {
+			return plan, fmt.Errorf(errEtcdMinorVersionsSkipped, etcdFrom, etcdTo, strings.Join(skipped, ", "))
+		}
+		plan.etcdTo = etcdTo
+	}
+
+	return plan, nil
+}
+
+// etcdIntermediateMinorVersions returns the minor versions between from and to of the same major
+// version, which an etcd upgrade must go through
+func etcdIntermediateMinorVersions(from, to semver.Version) []string {
+	versions := []string{}
+	if from.Major != to.Major {
+		return versions
+	}
+	for minor := from.Minor + 1; minor < to.Minor; minor++ {
+		versions = append(versions, fmt.Sprintf("%d.%d", to.Major, minor))
+	}
+
+	return versions
+}
+
+// etcdOperatorArgPatches returns the patches carrying the install time arguments of the current
+// etcd operator over to the arguments of the new etcd operator
+func etcdOperatorArgPatches(current, desired []string) []pluginutils.KustomizePatch {
+	patches := []pluginutils.KustomizePatch{}
+	for _, prefix := range etcdOperatorCarriedArgs {
+		currentArg := ""
+		for _, arg := range current {
+			if strings.HasPrefix(arg, prefix) {
+				currentArg = arg
+				break
+			}
+		}
+		if currentArg == "" {
+			continue
+		}
+
+		index := -1
+		for i, arg := range desired {
+			if strings.HasPrefix(arg, prefix) {
+				index = i
+				break
+			}
+		}
+		switch {
+		case index == -1:
+			patches = append(patches, pluginutils.KustomizePatch{Op: "add", Path: "/spec/template/spec/containers/0/args/-", Value: currentArg})
+		case desired[index] != currentArg:
+			patches = append(patches, pluginutils.KustomizePatch{Op: "replace", Path: fmt.Sprintf("/spec/template/spec/containers/0/args/%d", index), Value: currentArg})
+		}
+	}
+
+	return patches
+}
+
+// countUpgradedEtcdPeers returns the number of peers reporting etcd version
+func countUpgradedEtcdPeers(peers []etcdoperatorapi.EtcdPeer, version string) int32 {
+	var upgraded int32
+	for _, peer := range peers {
+		if peer.DeletionTimestamp.IsZero() && peer.Spec.Version == version && peer.Status.ServerVersion == version {
+			upgraded++
+		}
+	}
+
+	return upgraded
+}
+
+// UpgradeEtcd upgrades the etcd operator to EtcdOperatorVersion, or the manifest of EtcdOperatorYaml,
+// and the version of the etcd cluster to EtcdVersionTag. The cluster must be healthy beforehand and
+// a snapshot of etcd is written to disk before anything changes. The etcd operator replaces the
+// members one at a time, the health of the cluster is checked after each of them.
+func (in *Installer) UpgradeEtcd() error {
+	configInstall := in.stosConfig.Spec.Install
+	etcdCluster, err := in.getEtcdCluster()
+	if err != nil {
+		return err
+	}
+	operatorVersion, err := pluginversion.GetExistingEtcdOperatorVersion(configInstall.EtcdNamespace)
+	if err != nil {
+		return err
+	}
+
+	plan, err := planEtcdUpgrade(operatorVersion, configInstall.EtcdOperatorVersion, etcdCluster.Spec.Version, configInstall.EtcdVersionTag)
+	if err != nil {
+		return err
+	}
+	reapplyOperator := configInstall.EtcdOperatorYaml != ""
+	if !plan.upgradeOperator() && !plan.upgradeEtcd() && !reapplyOperator {
+		in.log.Successf(etcdUpToDateMessage, operatorVersion, etcdCluster.Name, etcdCluster.Spec.Version)
+		return nil
+	}
+
+	if err = in.checkEtcdClusterHealth(etcdCluster); err != nil {
+		return fmt.Errorf(errEtcdClusterUnhealthy, etcdCluster.Name, err)
+	}
+
+	dir, err := in.getEtcdUpgradePath()
+	if err != nil {
+		return err
+	}
+	if err = in.snapshotEtcdCluster(etcdCluster, dir); err != nil {
+		return err
+	}
+
+	if plan.upgradeOperator() || reapplyOperator {
+		in.log.Warnf(etcdOperatorUpgradingMessage, plan.operatorFrom, plan.operatorTo)
+		if err = in.upgradeEtcdOperator(plan.operatorTo); err != nil {
+			return fmt.Errorf(errEtcdUpgradeFailed, err, dir)
+		}
+		if err = in.checkEtcdClusterHealth(etcdCluster); err != nil {
+			return fmt.Errorf(errEtcdUpgradeFailed, fmt.Errorf(errEtcdClusterUnhealthy, etcdCluster.Name, err), dir)
+		}
+		in.log.Successf(etcdOperatorUpgradedMessage, plan.operatorTo)
+	}
+
+	if plan.upgradeEtcd() {
+		in.log.Warnf(etcdUpgradingMessage, etcdCluster.Name, plan.etcdFrom, plan.etcdTo)
+		if err = in.upgradeEtcdMembers(etcdCluster.Name, etcdCluster.Namespace, plan.etcdTo); err != nil {
+			return fmt.Errorf(errEtcdUpgradeFailed, err, dir)
+		}
+		in.log.Successf(etcdUpgradedMessage, etcdCluster.Name, plan.etcdTo)
+	}
+
+	return nil
+}
+
+// getEtcdUpgradePath returns the directory of the local files of a new etcd upgrade
+func (in *Installer) getEtcdUpgradePath() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	name := fmt.Sprintf("%s%v-%s", etcdUpgradePrefix, in.kubeClusterID, time.Now().Format("20060102150405"))
+
+	return filepath.Join(homeDir, kubeDir, stosDir, name), nil
+}
+
+// snapshotEtcdCluster writes the etcd cluster manifest and an etcdctl snapshot of the etcd cluster,
+// which can be restored with etcdutl, to dir
+func (in *Installer) snapshotEtcdCluster(etcdCluster *etcdoperatorapi.EtcdCluster, dir string) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return errors.WithStack(err)
+	}
+	manifest, err := etcdClusterToManifest(etcdCluster)
+	if err != nil {
+		return err
+	}
+	if err = os.WriteFile(filepath.Join(dir, etcdClusterFile), manifest, 0600); err != nil {
+		return errors.WithStack(err)
+	}
+
+	tlsEnabled := etcdCluster.Spec.TLS != nil && etcdCluster.Spec.TLS.Enabled
+	secretName := ""
+	if tlsEnabled {
+		secretName = in.stosConfig.Spec.Install.EtcdSecretName
+	}
+	spec := etcdMigrationSpec(in.stosConfig.Spec, in.stosConfig.Spec.Install.StorageOSClusterNamespace, etcdMemberEndpoints(etcdCluster), secretName)
+	// a snapshot is saved from a single member
+	endpoint := endpointsSplitter(spec.Install.EtcdEndpoints, tlsEnabled)[0]
+
+	return in.withEtcdShellPod(spec, etcdShellPodLifetime, func(podName, podNamespace string) error {
+		return in.saveEtcdSnapshot(podName, podNamespace, endpoint, tlsEnabled, filepath.Join(dir, etcdSnapshotFile))
+	})
+}
+
+// upgradeEtcdOperator applies the etcd operator manifest of version, carrying over the arguments
+// set at install time, and waits for the etcd operator to be ready
+func (in *Installer) upgradeEtcdOperator(version string) error {
+	configInstall := in.stosConfig.Spec.Install
+	current, err := pluginutils.GetDeployment(in.clientConfig, consts.EtcdOperatorName, configInstall.EtcdNamespace)
+	if err != nil {
+		return err
+	}
+
+	config := in.stosConfig.DeepCopy()
+	config.Spec.IncludeEtcd = true
+	if configInstall.EtcdOperatorYaml == "" {
+		pluginversion.SetEtcdOperatorLatestSupportedVersion(version)
+	}
+	in.installerOptions = &installerOptions{etcdOperator: true}
+	if in.fileSys, err = in.installerOptions.buildInstallerFileSys(config, in.clientConfig); err != nil {
+		return err
+	}
+
+	operatorPath := filepath.Join(etcdDir, operatorDir, etcdOperatorFile)
+	kustomizationPath := filepath.Join(etcdDir, operatorDir, kustomizationFile)
+	deployments, err := in.getAllManifestsOfKindFromFsMultiDoc(operatorPath, "Deployment")
+	if err != nil {
+		return err
+	}
+	var desired *appsv1.Deployment
+	for _, manifest := range deployments {
+		deployment := &appsv1.Deployment{}
+		if err = gyaml.Unmarshal([]byte(manifest), deployment); err != nil {
+			return errors.WithStack(err)
+		}
+		if deployment.Name == consts.EtcdOperatorName {
+			desired = deployment
+			break
+		}
+	}
+	if desired == nil || len(desired.Spec.Template.Spec.Containers) == 0 {
+		return fmt.Errorf(errEtcdOperatorDeploymentNotFound, consts.EtcdOperatorName)
+	}
+
+	if configInstall.EtcdNamespace != consts.EtcdOperatorNamespace {
+		if err = in.setFieldInFsManifest(kustomizationPath, configInstall.EtcdNamespace, "namespace", ""); err != nil {
+			return err
+		}
+	}
+	if len(current.Spec.Template.Spec.Containers) != 0 {
+		patches := etcdOperatorArgPatches(current.Spec.Template.Spec.Containers[0].Args, desired.Spec.Template.Spec.Containers[0].Args)
+		if len(patches) != 0 {
+			if err = in.addPatchesToFSKustomize(kustomizationPath, "Deployment", consts.EtcdOperatorName, patches); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err = in.kustomizeAndApply(filepath.Join(etcdDir, operatorDir), etcdOperatorFile); err != nil {
+		return err
+	}
+
+	return in.operatorDeploymentsAreReady(operatorPath)
+}
+
+// upgradeEtcdMembers sets the version of the etcd cluster and waits for the etcd operator to replace
+// every member with one of the new version, checking the health of the cluster after each member
+func (in *Installer) upgradeEtcdMembers(name, namespace, version string) error {
+	etcdCluster, err := pluginutils.GetEtcdCluster(in.clientConfig, name, namespace)
+	if err != nil {
+		return err
+	}
+	etcdCluster.Spec.Version = version
+	if err = pluginutils.UpdateEtcdCluster(in.clientConfig, etcdCluster); err != nil {
+		return fmt.Errorf(errEtcdClusterUpdateRejected, etcdCluster.Name, err)
+	}
+
+	replicas := int32(len(etcdCluster.Status.Members))
+	if etcdCluster.Spec.Replicas != nil {
+		replicas = *etcdCluster.Spec.Replicas
+	}
+
+	var upgraded int32
+	for upgraded < replicas {
+		previous := upgraded
+		in.log.Warnf(etcdMemberUpgradingMessage, previous+1, replicas, name, version)
+		if err = pluginutils.WaitFor(func() error {
+			peers, err := pluginutils.ListEtcdPeers(in.clientConfig, name, namespace)
+			if err != nil {
+				return err
+			}
+			upgraded = countUpgradedEtcdPeers(peers, version)
+			if upgraded <= previous {
+				return fmt.Errorf(errEtcdMembersNotUpgraded, name, upgraded, replicas, version)
+			}
+			return nil
+		}, etcdMemberWaitSeconds, 5); err != nil {
+			return err
+		}
+
+		if etcdCluster, err = in.waitForEtcdClusterMembers(name, namespace, replicas, etcdMemberWaitSeconds); err != nil {
+			return err
+		}
+		if err = in.checkEtcdClusterHealth(etcdCluster); err != nil {
+			return fmt.Errorf(errEtcdClusterUnhealthy, name, err)
+		}
+		in.log.Successf(etcdMemberUpgradedMessage, upgraded, replicas, version)
+	}
+
+	return nil
+}
+
+// etcdClusterToManifest returns a manifest for etcdCluster
+func etcdClusterToManifest(etcdCluster *etcdoperatorapi.EtcdCluster) ([]byte, error) {
+	newEtcdCluster := &etcdoperatorapi.EtcdCluster{}
+	newEtcdCluster.APIVersion = etcdoperatorapi.GroupVersion.String()
+	newEtcdCluster.Kind = etcdClusterKind
+	newEtcdCluster.SetName(etcdCluster.GetName())
+	newEtcdCluster.SetNamespace(etcdCluster.GetNamespace())
+	newEtcdCluster.SetLabels(etcdCluster.GetLabels())
+	newEtcdCluster.SetAnnotations(etcdCluster.GetAnnotations())
+	newEtcdCluster.Spec = etcdCluster.Spec
+
+	data, err := gyaml.Marshal(newEtcdCluster)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return data, nil
+}
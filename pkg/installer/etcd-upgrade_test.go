@@ -0,0 +1,186 @@
+package installer
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/coreos/go-semver/semver"
+	etcdoperatorapi "github.com/improbable-eng/etcd-cluster-operator/api/v1alpha1"
+	pluginutils "github.com/storageos/kubectl-storageos/pkg/utils"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestPlanEtcdUpgrade(t *testing.T) {
+	tcases := []struct {
+		name         string
+		operatorFrom string
+		operatorTo   string
+		etcdFrom     string
+		etcdTo       string
+		expOperator  bool
+		expEtcd      bool
+		expErr       bool
+	}{
+		{
+			name:         "operator and etcd",
+			operatorFrom: "v0.3.0",
+			operatorTo:   "v0.3.1",
+			etcdFrom:     "3.5.3",
+			etcdTo:       "3.5.6",
+			expOperator:  true,
+			expEtcd:      true,
+		},
+		{
+			name:         "up to date",
+			operatorFrom: "v0.3.1",
+			operatorTo:   "v0.3.1",
+			etcdFrom:     "3.5.6",
+		},
+		{
+			name:         "operator downgrade",
+			operatorFrom: "v0.3.1",
+			operatorTo:   "v0.3.0",
+			etcdFrom:     "3.5.6",
+			expErr:       true,
+		},
+		{
+			name:         "etcd downgrade",
+			operatorFrom: "v0.3.1",
+			etcdFrom:     "3.5.6",
+			etcdTo:       "3.4.16",
+			expErr:       true,
+		},
+		{
+			name:         "next etcd minor version",
+			operatorFrom: "v0.3.1",
+			etcdFrom:     "3.4.16",
+			etcdTo:       "3.5.6",
+			expEtcd:      true,
+		},
+		{
+			name:         "etcd minor version skipped",
+			operatorFrom: "v0.3.1",
+			etcdFrom:     "3.3.27",
+			etcdTo:       "3.5.6",
+			expErr:       true,
+		},
+		{
+			name:         "unsupported etcd major version",
+			operatorFrom: "v0.3.1",
+			etcdFrom:     "3.5.6",
+			etcdTo:       "4.0.0",
+			expErr:       true,
+		},
+		{
+			name:         "develop kept",
+			operatorFrom: "develop",
+			operatorTo:   "v0.3.1",
+			etcdFrom:     "3.5.6",
+		},
+		{
+			name:         "to develop",
+			operatorFrom: "v0.3.1",
+			operatorTo:   "develop",
+			etcdFrom:     "3.5.6",
+			expOperator:  true,
+		},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			plan, err := planEtcdUpgrade(tc.operatorFrom, tc.operatorTo, tc.etcdFrom, tc.etcdTo)
+			if (err != nil) != tc.expErr {
+				t.Fatalf("expected error %v, got %v", tc.expErr, err)
+			}
+			if tc.expErr {
+				return
+			}
+			if plan.upgradeOperator() != tc.expOperator {
+				t.Errorf("expected operator upgrade %v, got %v", tc.expOperator, plan.upgradeOperator())
+			}
+			if plan.upgradeEtcd() != tc.expEtcd {
+				t.Errorf("expected etcd upgrade %v, got %v", tc.expEtcd, plan.upgradeEtcd())
+			}
+		})
+	}
+}
+
+func TestEtcdIntermediateMinorVersions(t *testing.T) {
+	tcases := []struct {
+		from        string
+		to          string
+		expVersions []string
+	}{
+		{from: "3.5.3", to: "3.5.6", expVersions: []string{}},
+		{from: "3.4.16", to: "3.5.6", expVersions: []string{}},
+		{from: "3.3.27", to: "3.5.6", expVersions: []string{"3.4"}},
+		{from: "3.2.32", to: "3.5.6", expVersions: []string{"3.3", "3.4"}},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.from+"-"+tc.to, func(t *testing.T) {
+			versions := etcdIntermediateMinorVersions(*semver.New(tc.from), *semver.New(tc.to))
+			if !reflect.DeepEqual(versions, tc.expVersions) {
+				t.Errorf("expected intermediate versions %v, got %v", tc.expVersions, versions)
+			}
+		})
+	}
+}
+
+func TestEtcdOperatorArgPatches(t *testing.T) {
+	desired := []string{"--enable-leader-election", "--proxy-url=storageos-proxy.storageos-etcd.svc", "--leader-election-cm-namespace=storageos"}
+
+	tcases := []struct {
+		name       string
+		current    []string
+		expPatches []pluginutils.KustomizePatch
+	}{
+		{
+			name:       "defaults",
+			current:    desired,
+			expPatches: []pluginutils.KustomizePatch{},
+		},
+		{
+			name:    "custom namespaces and repository",
+			current: []string{"--enable-leader-election", "--proxy-url=storageos-proxy.etcd.svc", "--leader-election-cm-namespace=kube-system", "--etcd-repository=registry.local/etcd"},
+			expPatches: []pluginutils.KustomizePatch{
+				{Op: "replace", Path: "/spec/template/spec/containers/0/args/2", Value: "--leader-election-cm-namespace=kube-system"},
+				{Op: "replace", Path: "/spec/template/spec/containers/0/args/1", Value: "--proxy-url=storageos-proxy.etcd.svc"},
+				{Op: "add", Path: "/spec/template/spec/containers/0/args/-", Value: "--etcd-repository=registry.local/etcd"},
+			},
+		},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			patches := etcdOperatorArgPatches(tc.current, desired)
+			if !reflect.DeepEqual(patches, tc.expPatches) {
+				t.Errorf("expected patches %v, got %v", tc.expPatches, patches)
+			}
+		})
+	}
+}
+
+func TestCountUpgradedEtcdPeers(t *testing.T) {
+	peer := func(specVersion, serverVersion string, deleting bool) etcdoperatorapi.EtcdPeer {
+		p := etcdoperatorapi.EtcdPeer{
+			Spec:   etcdoperatorapi.EtcdPeerSpec{Version: specVersion},
+			Status: etcdoperatorapi.EtcdPeerStatus{ServerVersion: serverVersion},
+		}
+		if deleting {
+			now := metav1.Now()
+			p.DeletionTimestamp = &now
+		}
+		return p
+	}
+
+	peers := []etcdoperatorapi.EtcdPeer{
+		peer("3.5.6", "3.5.6", false),
+		peer("3.5.6", "", false),
+		peer("3.5.3", "3.5.3", false),
+		peer("3.5.6", "3.5.6", true),
+	}
+	if upgraded := countUpgradedEtcdPeers(peers, "3.5.6"); upgraded != 1 {
+		t.Errorf("expected 1 upgraded peer, got %d", upgraded)
+	}
+}
@@ -2,7 +2,11 @@ package installer
 
 import (
 	"fmt"
+	"io"
 	"strings"
+	"time"
+
+	pluginutils "github.com/storageos/kubectl-storageos/pkg/utils"
 )
 
 const (
@@ -11,8 +15,97 @@ const (
 	certPath    = "/run/storageos/pki/etcd-client.crt"
 	keyPath     = "/run/storageos/pki/etcd-client.key"
 	caCertPath  = "/run/storageos/pki/etcd-client-ca.crt"
+
+	etcdctlDialTimeout     = 5 * time.Second
+	etcdctlCommandTimeout  = 10 * time.Second
+	etcdctlDefragTimeout   = 5 * time.Minute
+	etcdctlSnapshotTimeout = 5 * time.Minute
+
+	errEtcdctlCommandFailed = `
+	Command "etcdctl %s" failed against ETCD endpoint(s) %s: %s failed, %s`
+)
+
+// endpointFailure describes the reason an etcd endpoint failed validation
+type endpointFailure string
+
+const (
+	endpointFailureNone    endpointFailure = ""
+	endpointFailureDNS     endpointFailure = "DNS resolution"
+	endpointFailureTCP     endpointFailure = "TCP connect"
+	endpointFailureTLS     endpointFailure = "TLS handshake"
+	endpointFailureAuth    endpointFailure = "authentication"
+	endpointFailureTimeout endpointFailure = "timeout"
+	endpointFailureWrite   endpointFailure = "write"
+	endpointFailureRead    endpointFailure = "read"
+	endpointFailureDelete  endpointFailure = "delete"
 )
 
+// etcdctlErrorPattern maps a substring of etcdctl output to a failure and a human readable detail
+type etcdctlErrorPattern struct {
+	substring string
+	failure   endpointFailure
+	detail    string
+}
+
+// etcdctlErrorPatterns are matched in order against the lower case output of a failed etcdctl
+// command, the most specific patterns must therefore come first.
+var etcdctlErrorPatterns = []etcdctlErrorPattern{
+	{"certificate signed by unknown authority", endpointFailureTLS, "server certificate is not signed by the CA in the etcd secret (wrong CA)"},
+	{"certificate has expired or is not yet valid", endpointFailureTLS, "certificate has expired or is not yet valid"},
+	{"is valid for", endpointFailureTLS, "server certificate SANs do not match the endpoint address"},
+	{"doesn't contain any ip sans", endpointFailureTLS, "server certificate SANs do not match the endpoint address"},
+	{"not valid for any names", endpointFailureTLS, "server certificate SANs do not match the endpoint address"},
+	{"bad certificate", endpointFailureTLS, "client certificate was rejected by the server"},
+	{"first record does not look like a tls handshake", endpointFailureTLS, "endpoint is not TLS-enabled"},
+	{"error reading server preface", endpointFailureTLS, "endpoint may require TLS"},
+	{"x509:", endpointFailureTLS, "certificate verification failed"},
+	{"tls:", endpointFailureTLS, "TLS handshake failed"},
+	{"no such host", endpointFailureDNS, "host name could not be resolved"},
+	{"server misbehaving", endpointFailureDNS, "DNS server failed to resolve host name"},
+	{"lookup ", endpointFailureDNS, "host name could not be resolved"},
+	{"authentication failed", endpointFailureAuth, "invalid user name or password"},
+	{"user name is empty", endpointFailureAuth, "etcd authentication is enabled but no user was given"},
+	{"user name not found", endpointFailureAuth, "user does not exist"},
+	{"invalid auth token", endpointFailureAuth, "authentication token was rejected"},
+	{"permission denied", endpointFailureAuth, "user is not permitted to access the key space"},
+	{"connection refused", endpointFailureTCP, "connection refused"},
+	{"no route to host", endpointFailureTCP, "no route to host"},
+	{"network is unreachable", endpointFailureTCP, "network is unreachable"},
+	{"connection reset", endpointFailureTCP, "connection reset by peer"},
+	{"i/o timeout", endpointFailureTCP, "connection timed out"},
+	{"context deadline exceeded", endpointFailureTimeout, "no response within the command timeout"},
+}
+
+// classifyEtcdctlError returns the failure and detail of a failed etcdctl command from its output.
+// If no known pattern is found, the failure is that of the stage the command was run in and the
+// detail is the last line of output.
+func classifyEtcdctlError(stage endpointFailure, output string) (endpointFailure, string) {
+	lowerOutput := strings.ToLower(output)
+	for _, pattern := range etcdctlErrorPatterns {
+		if strings.Contains(lowerOutput, pattern.substring) {
+			return pattern.failure, pattern.detail
+		}
+	}
+
+	return stage, lastLine(output)
+}
+
+// lastLine returns the last non-empty line of s
+func lastLine(s string) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
+
+// withEtcdctlTimeouts adds dial and command timeouts to an etcdctl command so that an unresponsive
+// endpoint cannot block validation.
+func withEtcdctlTimeouts(cmd []string) []string {
+	return append([]string{
+		cmd[0],
+		fmt.Sprintf("--dial-timeout=%s", etcdctlDialTimeout),
+		fmt.Sprintf("--command-timeout=%s", etcdctlCommandTimeout),
+	}, cmd[1:]...)
+}
+
 // etcdctlMemberList returns a slice of strings representing the etcdctl command for members list to
 // be interpreted by the pod exec:
 // {`/bin/bash`, `-c`, `etcdctl --endpoints "http://<endpoints>" member list`}
@@ -146,3 +239,140 @@ func endpointsSplitter(endpoints string, tls bool) []string {
 
 	return httpEndpointsSlice
 }
+
+// etcdctlCmd returns a slice of strings representing an etcdctl command with args against
+// endpoints, using the client certificates of the etcd secret if tls is set:
+// {`etcdctl`, `--endpoints`, `<endpoints>`, `<args>`}
+func etcdctlCmd(endpoints string, tls bool, args ...string) []string {
+	cmd := []string{
+		"etcdctl",
+		"--endpoints",
+		endpoints,
+	}
+	if tls {
+		cmd = append(cmd,
+			"--key",
+			keyPath,
+			"--cert",
+			certPath,
+			"--cacert",
+			caCertPath,
+		)
+	}
+
+	return append(cmd, args...)
+}
+
+// etcdctlEndpointStatusCmd returns the etcdctl command for the status of endpoints in json format
+func etcdctlEndpointStatusCmd(endpoints string, tls bool) []string {
+	return etcdctlCmd(endpoints, tls, "endpoint", "status", "--write-out=json")
+}
+
+// etcdctlCompactionCmd returns the etcdctl command to compact the key space to revision and wait
+// for the compaction to be applied to the backend database
+func etcdctlCompactionCmd(endpoints string, revision int64, tls bool) []string {
+	return etcdctlCmd(endpoints, tls, "compaction", "--physical", fmt.Sprintf("%d", revision))
+}
+
+// etcdctlDefragCmd returns the etcdctl command to defragment the member at endpoint. Defragmenting
+// a large database may take longer than the default command timeout.
+func etcdctlDefragCmd(endpoint string, tls bool) []string {
+	return etcdctlCmd(endpoint, tls, fmt.Sprintf("--command-timeout=%s", etcdctlDefragTimeout), "defrag")
+}
+
+// etcdctlAlarmListCmd returns the etcdctl command to list alarms
+func etcdctlAlarmListCmd(endpoints string, tls bool) []string {
+	return etcdctlCmd(endpoints, tls, "alarm", "list")
+}
+
+// etcdctlAlarmDisarmCmd returns the etcdctl command to disarm all alarms
+func etcdctlAlarmDisarmCmd(endpoints string, tls bool) []string {
+	return etcdctlCmd(endpoints, tls, "alarm", "disarm")
+}
+
+// etcdctlGetAllCmd returns the etcdctl command to get every key and value in json format
+func etcdctlGetAllCmd(endpoints string, tls bool) []string {
+	return etcdctlCmd(endpoints, tls, "get", "", "--prefix", "--write-out=json")
+}
+
+// etcdctlCountAllCmd returns the etcdctl command to count every key in json format
+func etcdctlCountAllCmd(endpoints string, tls bool) []string {
+	return etcdctlCmd(endpoints, tls, "get", "", "--prefix", "--count-only", "--write-out=json")
+}
+
+// etcdctlTxnCmd returns the etcdctl command to run the transaction read from stdin
+func etcdctlTxnCmd(endpoints string, tls bool) []string {
+	return etcdctlCmd(endpoints, tls, "txn")
+}
+
+// etcdctlSnapshotSaveCmd returns the etcdctl command to save a snapshot of the member at endpoint
+// to path in the etcd shell pod. Saving a large database may take longer than the default command
+// timeout.
+func etcdctlSnapshotSaveCmd(endpoint, path string, tls bool) []string {
+	return etcdctlCmd(endpoint, tls, fmt.Sprintf("--command-timeout=%s", etcdctlSnapshotTimeout), "snapshot", "save", path)
+}
+
+// etcdctlSnapshotStatusCmd returns the etcdctl command for the status of the snapshot at path in
+// json format
+func etcdctlSnapshotStatusCmd(path string) []string {
+	return []string{"etcdctl", "snapshot", "status", path, "--write-out=json"}
+}
+
+// etcdctlDelAllCmd returns the etcdctl command to delete every key
+func etcdctlDelAllCmd(endpoints string, tls bool) []string {
+	return etcdctlCmd(endpoints, tls, "del", "", "--prefix")
+}
+
+// execEtcdctl runs the etcdctl command in the etcd shell pod, returning its output or an error
+// describing why the command failed against endpoints
+func (in *Installer) execEtcdctl(podName, podNamespace, endpoints string, cmd []string, stdin io.Reader) (string, error) {
+	stdout, stderr, err := pluginutils.ExecToPod(in.clientConfig, cmd, "", podName, podNamespace, stdin)
+	if err != nil {
+		output := stderr
+		if output == "" {
+			output = err.Error()
+		}
+		subcommand := etcdctlSubcommand(cmd)
+		failure, detail := classifyEtcdctlError(endpointFailure(subcommand), output)
+		return "", fmt.Errorf(errEtcdctlCommandFailed, subcommand, endpoints, failure, detail)
+	}
+
+	return stdout, nil
+}
+
+// etcdctlRunner runs etcdctl commands against endpoints, returning their output. It is
+// implemented by etcdShell and by the fake etcd clusters in tests.
+type etcdctlRunner interface {
+	runEtcdctl(endpoints string, cmd []string, stdin io.Reader) (string, error)
+}
+
+// etcdShell runs etcdctl commands in an etcd shell pod
+type etcdShell struct {
+	in           *Installer
+	podName      string
+	podNamespace string
+}
+
+func (s *etcdShell) runEtcdctl(endpoints string, cmd []string, stdin io.Reader) (string, error) {
+	return s.in.execEtcdctl(s.podName, s.podNamespace, endpoints, cmd, stdin)
+}
+
+// etcdctlSubcommand returns the etcdctl command without its flags, certificates and empty
+// arguments, eg. "endpoint status" or "put <key>"
+func etcdctlSubcommand(cmd []string) string {
+	subcommand := make([]string, 0)
+	for i := 1; i < len(cmd); i++ {
+		switch {
+		case cmd[i] == "--endpoints" || cmd[i] == "--key" || cmd[i] == "--cert" || cmd[i] == "--cacert":
+			i++
+		case cmd[i] == "--":
+			// arguments after "--" are never flags
+			return strings.Join(append(subcommand, cmd[i+1:]...), " ")
+		case strings.HasPrefix(cmd[i], "--") || cmd[i] == "":
+		default:
+			subcommand = append(subcommand, cmd[i])
+		}
+	}
+
+	return strings.Join(subcommand, " ")
+}
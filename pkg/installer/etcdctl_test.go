@@ -408,3 +408,154 @@ func TestEtcdctlDelCmd(t *testing.T) {
 		}
 	}
 }
+
+func TestWithEtcdctlTimeouts(t *testing.T) {
+	cmd := withEtcdctlTimeouts(etcdctlGetCmd("http://1.2.3.4:2379", "foo", false))
+	expCmd := []string{
+		"etcdctl",
+		"--dial-timeout=5s",
+		"--command-timeout=10s",
+		"--endpoints",
+		"http://1.2.3.4:2379",
+		"get",
+		"foo",
+	}
+	if !reflect.DeepEqual(cmd, expCmd) {
+		t.Errorf("expected %v, got %v", expCmd, cmd)
+	}
+}
+
+func TestClassifyEtcdctlError(t *testing.T) {
+	tcases := []struct {
+		name       string
+		stage      endpointFailure
+		output     string
+		expFailure endpointFailure
+		expDetail  string
+	}{
+		{
+			name:       "dns",
+			stage:      endpointFailureWrite,
+			output:     `{"level":"warn","msg":"retrying of unary invoker failed","error":"rpc error: code = Unavailable desc = connection error: desc = \"transport: Error while dialing dial tcp: lookup etcd.invalid on 10.96.0.10:53: no such host\""}`,
+			expFailure: endpointFailureDNS,
+			expDetail:  "host name could not be resolved",
+		},
+		{
+			name:       "dns timeout",
+			stage:      endpointFailureWrite,
+			output:     "dial tcp: lookup etcd.storageos on 10.96.0.10:53: read udp 10.0.0.1:5353->10.96.0.10:53: i/o timeout",
+			expFailure: endpointFailureDNS,
+			expDetail:  "host name could not be resolved",
+		},
+		{
+			name:       "tcp",
+			stage:      endpointFailureWrite,
+			output:     "transport: Error while dialing dial tcp 1.2.3.4:2379: connect: connection refused",
+			expFailure: endpointFailureTCP,
+			expDetail:  "connection refused",
+		},
+		{
+			name:       "wrong ca",
+			stage:      endpointFailureWrite,
+			output:     "transport: authentication handshake failed: x509: certificate signed by unknown authority",
+			expFailure: endpointFailureTLS,
+			expDetail:  "server certificate is not signed by the CA in the etcd secret (wrong CA)",
+		},
+		{
+			name:       "expired",
+			stage:      endpointFailureWrite,
+			output:     "x509: certificate has expired or is not yet valid: current time 2022-01-01T00:00:00Z is after 2021-01-01T00:00:00Z",
+			expFailure: endpointFailureTLS,
+			expDetail:  "certificate has expired or is not yet valid",
+		},
+		{
+			name:       "san mismatch",
+			stage:      endpointFailureWrite,
+			output:     "x509: certificate is valid for etcd.storageos, not etcd.default",
+			expFailure: endpointFailureTLS,
+			expDetail:  "server certificate SANs do not match the endpoint address",
+		},
+		{
+			name:       "auth",
+			stage:      endpointFailureWrite,
+			output:     "Error: etcdserver: user name is empty",
+			expFailure: endpointFailureAuth,
+			expDetail:  "etcd authentication is enabled but no user was given",
+		},
+		{
+			name:       "deadline",
+			stage:      endpointFailureRead,
+			output:     "Error: context deadline exceeded",
+			expFailure: endpointFailureTimeout,
+			expDetail:  "no response within the command timeout",
+		},
+		{
+			name:       "unknown",
+			stage:      endpointFailureDelete,
+			output:     "some warning\nError: etcdserver: mvcc: required revision has been compacted\n",
+			expFailure: endpointFailureDelete,
+			expDetail:  "Error: etcdserver: mvcc: required revision has been compacted",
+		},
+	}
+	for _, tc := range tcases {
+		failure, detail := classifyEtcdctlError(tc.stage, tc.output)
+		if failure != tc.expFailure {
+			t.Errorf("%s: expected failure %q, got %q", tc.name, tc.expFailure, failure)
+		}
+		if detail != tc.expDetail {
+			t.Errorf("%s: expected detail %q, got %q", tc.name, tc.expDetail, detail)
+		}
+	}
+}
+
+func TestEtcdctlSubcommand(t *testing.T) {
+	tcases := []struct {
+		name          string
+		cmd           []string
+		expSubcommand string
+	}{
+		{
+			name:          "endpoint status",
+			cmd:           etcdctlEndpointStatusCmd("http://1.2.3.4:2379", false),
+			expSubcommand: "endpoint status",
+		},
+		{
+			name:          "tls compaction",
+			cmd:           etcdctlCompactionCmd("https://1.2.3.4:2379", 42, true),
+			expSubcommand: "compaction 42",
+		},
+		{
+			name:          "defrag with timeouts",
+			cmd:           withEtcdctlTimeouts(etcdctlDefragCmd("http://1.2.3.4:2379", false)),
+			expSubcommand: "defrag",
+		},
+		{
+			name:          "get all",
+			cmd:           etcdctlGetAllCmd("http://1.2.3.4:2379", false),
+			expSubcommand: "get",
+		},
+		{
+			name:          "tls txn",
+			cmd:           withEtcdctlTimeouts(etcdctlTxnCmd("https://1.2.3.4:2379", true)),
+			expSubcommand: "txn",
+		},
+		{
+			name:          "tls snapshot save",
+			cmd:           etcdctlSnapshotSaveCmd("https://1.2.3.4:2379", etcdShellSnapshotPath, true),
+			expSubcommand: "snapshot save " + etcdShellSnapshotPath,
+		},
+		{
+			name:          "alarm disarm",
+			cmd:           etcdctlAlarmDisarmCmd("https://1.2.3.4:2379", true),
+			expSubcommand: "alarm disarm",
+		},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			if subcommand := etcdctlSubcommand(tc.cmd); subcommand != tc.expSubcommand {
+				t.Errorf("expected %q, got %q", tc.expSubcommand, subcommand)
+			}
+		})
+	}
+}
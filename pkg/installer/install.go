@@ -50,23 +50,7 @@ func (in *Installer) Install(upgrade bool) error {
 	wg.Wait()
 
 	if in.stosConfig.Spec.Install.Wait {
-		once := sync.Once{}
-		errChan <- pluginutils.WaitFor(func() error {
-			cluster, err := pluginutils.GetFirstStorageOSCluster(in.clientConfig)
-			if err != nil {
-				return err
-			}
-
-			once.Do(func() {
-				in.log.Warnf("Waiting for StorageOS cluster '%s' to enter running phase.", cluster.Name)
-			})
-
-			if cluster.Status.Phase != "Running" {
-				return fmt.Errorf("cluster %s not ready", cluster.Name)
-			}
-
-			return nil
-		}, 300, 5)
+		errChan <- in.waitForStorageOSClusterRunning()
 	}
 
 	go close(errChan)
@@ -74,6 +58,27 @@ func (in *Installer) Install(upgrade bool) error {
 	return collectErrors(errChan)
 }
 
+// waitForStorageOSClusterRunning returns no error once the storageos cluster has entered running phase
+func (in *Installer) waitForStorageOSClusterRunning() error {
+	once := sync.Once{}
+	return pluginutils.WaitFor(func() error {
+		cluster, err := pluginutils.GetFirstStorageOSCluster(in.clientConfig)
+		if err != nil {
+			return err
+		}
+
+		once.Do(func() {
+			in.log.Warnf("Waiting for StorageOS cluster '%s' to enter running phase.", cluster.Name)
+		})
+
+		if cluster.Status.Phase != "Running" {
+			return fmt.Errorf("cluster %s not ready", cluster.Name)
+		}
+
+		return nil
+	}, 300, 5)
+}
+
 func (in *Installer) installLocalPathStorageClass() error {
 	return in.kustomizeAndApply(filepath.Join(localPathProvisionerDir, storageclassDir), localPathProvisionerFile)
 }
@@ -32,6 +32,11 @@ const (
 	VerboseFlag                     = "verbose"
 	SkipNamespaceDeletionFlag       = "skip-namespace-deletion"
 	SkipExistingWorkloadCheckFlag   = "skip-existing-workload-check"
+	QuiesceWorkloadsFlag            = "quiesce-workloads"
+	YesFlag                         = "yes"
+	ResumeFlag                      = "resume"
+	ReinstallFlag                   = "reinstall"
+	RetainVolumesFlag               = "retain-volumes"
 	StosVersionFlag                 = "stos-version"
 	EtcdOperatorVersionFlag         = "etcd-operator-version"
 	K8sVersionFlag                  = "k8s-version"
@@ -72,12 +77,44 @@ const (
 	EtcdReplicasFlag                = "etcd-replicas"
 	EnableMetricsFlag               = "enable-metrics"
 	TestClusterFlag                 = "test-cluster"
+	EtcdCACertFlag                  = "etcd-ca-cert"
+	EtcdClientCertFlag              = "etcd-client-cert"
+	EtcdClientKeyFlag               = "etcd-client-key"
+	GenerateCertsFlag               = "generate"
+	CertValidityFlag                = "cert-validity"
+	CertOutputDirFlag               = "cert-output-dir"
+	EtcdShellImageFlag              = "etcd-shell-image"
+	EtcdShellImagePullSecretsFlag   = "etcd-shell-image-pull-secrets"
+	EtcdShellTolerationsFlag        = "etcd-shell-tolerations"
+	EtcdShellNodeSelectorFlag       = "etcd-shell-node-selector"
+	EtcdShellRunAsUserFlag          = "etcd-shell-run-as-user"
+	EtcdShellRunAsGroupFlag         = "etcd-shell-run-as-group"
+	EtcdShellFSGroupFlag            = "etcd-shell-fs-group"
+	EtcdShellRunAsNonRootFlag       = "etcd-shell-run-as-non-root"
+	EtcdShellSeccompProfileFlag     = "etcd-shell-seccomp-profile"
+	ReplicasFlag                    = "replicas"
+	ClearAlarmsFlag                 = "clear-alarms"
+	ToEndpointsFlag                 = "to-endpoints"
+	KeepFlag                        = "keep"
+	OlderThanFlag                   = "older-than"
+	OutputFlag                      = "output"
+	BackupPassphraseFileFlag        = "backup-passphrase-file"
+	BackupRecipientFlag             = "backup-recipient"
+	BackupIdentityFlag              = "backup-identity"
+	ChannelFlag                     = "channel"
+	PortalShellImageFlag            = "portal-shell-image"
+	SkipPortalCredentialsValFlag    = "skip-portal-credentials-validation"
+	AllowInsecurePortalURLFlag      = "allow-insecure-portal-url"
+	SkipCompatibilityCheckFlag      = "skip-compatibility-check"
 
 	// config file fields - contain path delimiters for plugin interpretation of config manifest
 	StackTraceConfig                          = "spec.stackTrace"
 	VerboseConfig                             = "spec.verbose"
 	SkipNamespaceDeletionConfig               = "spec.skipNamespaceDeletion"
 	SkipExistingWorkloadCheckConfig           = "spec.skipExistingWorkloadCheck"
+	QuiesceWorkloadsConfig                    = "spec.quiesceWorkloads"
+	ReinstallConfig                           = "spec.reinstall"
+	RetainVolumesConfig                       = "spec.uninstall.retainVolumes"
 	SkipStosClusterConfig                     = "spec.skipStorageOSCluster"
 	IncludeEtcdConfig                         = "spec.includeEtcd"
 	WaitConfig                                = "spec.install.wait"
@@ -127,6 +164,22 @@ const (
 	EtcdReplicasConfig                        = "spec.install.etcdReplicas"
 	EnableMetricsConfig                       = "spec.install.enableMetrics"
 	TestClusterConfig                         = "spec.install.enableTestClusterTaint"
+	EtcdShellImageConfig                      = "spec.install.etcdShellImage"
+	EtcdShellImagePullSecretsConfig           = "spec.install.etcdShellImagePullSecrets"
+	EtcdShellTolerationsConfig                = "spec.install.etcdShellTolerations"
+	EtcdShellNodeSelectorConfig               = "spec.install.etcdShellNodeSelector"
+	EtcdShellRunAsUserConfig                  = "spec.install.etcdShellRunAsUser"
+	EtcdShellRunAsGroupConfig                 = "spec.install.etcdShellRunAsGroup"
+	EtcdShellFSGroupConfig                    = "spec.install.etcdShellFSGroup"
+	EtcdShellRunAsNonRootConfig               = "spec.install.etcdShellRunAsNonRoot"
+	EtcdShellSeccompProfileConfig             = "spec.install.etcdShellSeccompProfile"
+	BackupPassphraseFileConfig                = "spec.backup.passphraseFile"
+	BackupRecipientConfig                     = "spec.backup.recipient"
+	BackupIdentityConfig                      = "spec.backup.identityFile"
+	ChannelConfig                             = "spec.channel"
+	SkipPortalCredentialsValConfig            = "spec.install.skipPortalCredentialsValidation"
+	AllowInsecurePortalURLConfig              = "spec.install.allowInsecurePortalURL"
+	SkipCompatibilityCheckConfig              = "spec.install.skipCompatibilityCheck"
 
 	// dir and file names for in memory fs
 	etcdDir                  = "etcd"
@@ -147,6 +200,8 @@ const (
 	csiSecretsFile           = "storageos-csi-secrets.yaml"
 	stosStorageClassFile     = "storageos-storageclass.yaml"
 	stosConfigMapsFile       = "storageos-configmaps.yaml"
+	stosPVsFile              = "storageos-persistentvolumes.yaml"
+	stosPVCsFile             = "storageos-persistentvolumeclaims.yaml"
 	etcdOperatorFile         = "etcd-operator.yaml"
 	etcdClusterFile          = "etcd-cluster.yaml"
 	kustomizationFile        = "kustomization.yaml"
@@ -207,6 +262,9 @@ type Installer struct {
 	dryRunFileCounter int
 	storageOSCluster  *operatorapi.StorageOSCluster
 	log               *logger.Logger
+	// decryptedBackupFiles holds the plaintext of the backup files encrypted by this installer by
+	// path, so that upgrade reads them back without the decryption key
+	decryptedBackupFiles map[string][]byte
 }
 
 // NewInstaller returns an Installer used for install command
@@ -374,6 +432,74 @@ func NewDryRunInstaller(config *apiv1.KubectlStorageOSConfig, log *logger.Logger
 	return installer, nil
 }
 
+// NewEtcdInstaller returns a lightweight Installer used by the etcd subcommands. No manifests are
+// fetched as these commands operate directly on existing cluster objects.
+func NewEtcdInstaller(config *apiv1.KubectlStorageOSConfig, log *logger.Logger) (*Installer, error) {
+	return newLightweightInstaller(config, log)
+}
+
+// NewBackupInstaller returns a lightweight Installer used by the backup subcommands, which operate
+// on the local backups of the current cluster.
+func NewBackupInstaller(config *apiv1.KubectlStorageOSConfig, log *logger.Logger) (*Installer, error) {
+	return newLightweightInstaller(config, log)
+}
+
+// NewWorkloadsInstaller returns a lightweight Installer used by the workloads command, which reports
+// the workloads using StorageOS volumes.
+func NewWorkloadsInstaller(config *apiv1.KubectlStorageOSConfig, log *logger.Logger) (*Installer, error) {
+	return newLightweightInstaller(config, log)
+}
+
+// NewCleanupInstaller returns a lightweight Installer used by the cleanup command, which removes the
+// cluster-scoped resources left behind by an uninstall.
+func NewCleanupInstaller(config *apiv1.KubectlStorageOSConfig, log *logger.Logger) (*Installer, error) {
+	return newLightweightInstaller(config, log)
+}
+
+// NewPortalStatusInstaller returns a lightweight Installer used by the portal status command, which
+// checks the portal manager of the StorageOS cluster.
+func NewPortalStatusInstaller(config *apiv1.KubectlStorageOSConfig, log *logger.Logger) (*Installer, error) {
+	in, err := newLightweightInstaller(config, log)
+	if err != nil {
+		return in, err
+	}
+
+	in.storageOSCluster, err = pluginutils.GetFirstStorageOSCluster(in.clientConfig)
+	if err != nil {
+		return in, errors.WithStack(err)
+	}
+
+	return in, nil
+}
+
+// newLightweightInstaller returns an Installer without manifests, identifying the current cluster
+func newLightweightInstaller(config *apiv1.KubectlStorageOSConfig, log *logger.Logger) (*Installer, error) {
+	installer := &Installer{}
+
+	clientConfig, err := pluginutils.NewClientConfig()
+	if err != nil {
+		return installer, errors.WithStack(err)
+	}
+
+	kubesystemNS, err := pluginutils.GetNamespace(clientConfig, "kube-system")
+	if err != nil {
+		return installer, errors.WithStack(err)
+	}
+
+	installer = &Installer{
+		kubectlClient:    kubectlNew(log),
+		clientConfig:     clientConfig,
+		kubeClusterID:    kubesystemNS.GetUID(),
+		stosConfig:       config,
+		fileSys:          filesys.MakeFsInMemory(),
+		onDiskFileSys:    filesys.MakeFsOnDisk(),
+		installerOptions: &installerOptions{},
+		log:              log,
+	}
+
+	return installer, nil
+}
+
 // NewUninstaller returns an Installer used for uninstall command
 func NewUninstaller(config *apiv1.KubectlStorageOSConfig, log *logger.Logger) (*Installer, error) {
 	uninstaller := &Installer{}
@@ -576,51 +702,58 @@ func (in *Installer) omitAndReturnKindFromFSMultiDoc(path, kind string) ([]strin
 	return objsOfKind, nil
 }
 
-// writeBackupFileSystem writes manifests of uninstalled secrets, configmaps, storageoscluster and storageclass to disk
-func (in *Installer) writeBackupFileSystem(storageOSCluster *operatorapi.StorageOSCluster) error {
-	backupPath, err := in.getBackupPath()
+// writeBackupFileSystem writes manifests of uninstalled secrets, configmaps, storageoscluster and storageclass to
+// a new backup on disk, along with the metadata of the backup taken by subcommand. The path of the
+// backup is returned.
+func (in *Installer) writeBackupFileSystem(storageOSCluster *operatorapi.StorageOSCluster, subcommand string) (string, error) {
+	backupPath, err := in.newBackupPath()
 	if err != nil {
-		return err
-	}
-	if err = in.onDiskFileSys.MkdirAll(backupPath); err != nil {
-		return errors.WithStack(err)
+		return "", err
 	}
 
 	storageOSClusterManifest, err := storageOSClusterToManifest(storageOSCluster)
 	if err != nil {
-		return err
+		return "", err
 	}
 	if err = in.onDiskFileSys.WriteFile(filepath.Join(backupPath, stosClusterFile), storageOSClusterManifest); err != nil {
-		return errors.WithStack(err)
+		return "", errors.WithStack(err)
 	}
 
 	secretList, err := pluginutils.ListSecrets(in.clientConfig, metav1.ListOptions{LabelSelector: stosAppLabel})
 	if err != nil {
-		return err
+		return "", err
 	}
 	stosSecretList, csiSecretList := separateSecrets(secretList)
 	if err = in.writeSecretsToDisk(stosSecretList, filepath.Join(backupPath, stosSecretsFile)); err != nil {
-		return errors.WithStack(err)
+		return "", errors.WithStack(err)
 	}
 	if err = in.writeSecretsToDisk(csiSecretList, filepath.Join(backupPath, csiSecretsFile)); err != nil {
-		return errors.WithStack(err)
+		return "", errors.WithStack(err)
 	}
 
 	storageClassList, err := in.listStorageOSStorageClasses()
 	if err != nil {
-		return err
+		return "", err
 	}
 	if err := in.writeStorageClassesToDisk(storageClassList, filepath.Join(backupPath, stosStorageClassFile)); err != nil {
-		return errors.WithStack(err)
+		return "", errors.WithStack(err)
 	}
 
 	configMapList, err := pluginutils.ListConfigMaps(in.clientConfig, metav1.ListOptions{LabelSelector: stosAppLabel})
 	if err != nil {
-		return err
+		return "", err
+	}
+	if err = in.writeConfigMapsToDisk(configMapList, filepath.Join(backupPath, stosConfigMapsFile)); err != nil {
+		return "", errors.WithStack(err)
 	}
-	err = in.writeConfigMapsToDisk(configMapList, filepath.Join(backupPath, stosConfigMapsFile))
 
-	return errors.WithStack(err)
+	if in.stosConfig.Spec.Uninstall.RetainVolumes {
+		if err = in.writeVolumesToDisk(backupPath); err != nil {
+			return "", err
+		}
+	}
+
+	return backupPath, in.writeBackupMetadata(backupPath, storageOSCluster, subcommand)
 }
 
 func (in *Installer) listStorageOSStorageClasses() (*kstoragev1.StorageClassList, error) {
@@ -639,7 +772,8 @@ func (in *Installer) listStorageOSStorageClasses() (*kstoragev1.StorageClassList
 	return stosStorageClassList, nil
 }
 
-// writeSecretsToDisk writes multidoc manifest of SecretList.Items to path of on-disk filesystem
+// writeSecretsToDisk writes multidoc manifest of SecretList.Items to path of on-disk filesystem,
+// encrypted if backup keys are set
 func (in *Installer) writeSecretsToDisk(secretList *corev1.SecretList, path string) error {
 	if len(secretList.Items) == 0 {
 		return nil
@@ -648,9 +782,8 @@ func (in *Installer) writeSecretsToDisk(secretList *corev1.SecretList, path stri
 	if err != nil {
 		return err
 	}
-	err = in.onDiskFileSys.WriteFile(path, secretsMultiDoc)
 
-	return errors.WithStack(err)
+	return in.writeSensitiveBackupFile(path, secretsMultiDoc)
 }
 
 // writeConfigMapsToDisk writes multidoc manifest of ConfigMapList.Items to path of on-disk filesystem
@@ -680,12 +813,3 @@ func (in *Installer) writeStorageClassesToDisk(storageClassList *kstoragev1.Stor
 
 	return errors.WithStack(err)
 }
-
-// getBackupPath returns the path to the on-disk directory where uninstalled manifests are stored.
-func (in *Installer) getBackupPath() (string, error) {
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		return "", errors.WithStack(err)
-	}
-	return filepath.Join(homeDir, kubeDir, stosDir, fmt.Sprintf("%s%v", UninstallPrefix, in.kubeClusterID)), nil
-}
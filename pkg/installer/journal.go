@@ -0,0 +1,294 @@
+package installer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+	apiv1 "github.com/storageos/kubectl-storageos/api/v1"
+	"github.com/storageos/kubectl-storageos/pkg/logger"
+	pluginutils "github.com/storageos/kubectl-storageos/pkg/utils"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	upgradeJournalFile = "upgrade-journal.json"
+
+	// the in-cluster journal is kept in kube-system, which is never removed by uninstall
+	upgradeJournalConfigMap = "storageos-upgrade-journal"
+	upgradeJournalNamespace = "kube-system"
+	upgradeJournalKey       = "journal.json"
+
+	// steps of an upgrade, in order
+	upgradeStepBackupTaken             = "BackupTaken"
+	upgradeStepStorageClassesProtected = "StorageClassesProtected"
+	upgradeStepUninstallDone           = "UninstallDone"
+	upgradeStepInstallStarted          = "InstallStarted"
+
+	errNoUpgradeToResume = `
+	No interrupted upgrade found, neither in %s nor in configmap %s/%s.`
+
+	errUpgradeInterrupted = `
+	An upgrade from StorageOS %s to %s has been interrupted after step %s, StorageOS is not installed.
+	Continue it with:
+	kubectl storageos upgrade --` + ResumeFlag
+
+	errResumeVersionMismatch = `
+	The interrupted upgrade installs StorageOS %s, not %s. Re-run without --` + StosVersionFlag + `.`
+
+	discardedUpgradeJournalMessage = `Discarded the journal of an interrupted upgrade from StorageOS %s to %s, which had not uninstalled StorageOS.`
+	resumingUpgradeMessage         = `Resuming upgrade from StorageOS %s to %s after step %s.`
+	resumingQuiescedUpgradeMessage = `The interrupted upgrade quiesced workloads, they are resumed once StorageOS is running.`
+	resumingDeletedClusterMessage  = `The interrupted upgrade deleted the StorageOS cluster, completing the uninstall.`
+	upgradeStepMessage             = `Upgrade step %s completed.`
+)
+
+// upgradeJournal records the completed steps of an upgrade, with the values of the uninstalled
+// StorageOS cluster needed to install the new version and the name of the backup taken of it, so
+// that the upgrade can be resumed
+type upgradeJournal struct {
+	FromVersion               string        `json:"fromVersion"`
+	ToVersion                 string        `json:"toVersion"`
+	EtcdEndpoints             string        `json:"etcdEndpoints,omitempty"`
+	StorageOSClusterNamespace string        `json:"storageOSClusterNamespace,omitempty"`
+	BackupName                string        `json:"backupName,omitempty"`
+	QuiesceWorkloads          bool          `json:"quiesceWorkloads,omitempty"`
+	Steps                     []upgradeStep `json:"steps"`
+}
+
+// upgradeStep is a completed step of an upgrade
+type upgradeStep struct {
+	Name        string    `json:"name"`
+	CompletedAt time.Time `json:"completedAt"`
+}
+
+// completed returns true if step is part of the completed steps of j
+func (j *upgradeJournal) completed(step string) bool {
+	for _, s := range j.Steps {
+		if s.Name == step {
+			return true
+		}
+	}
+	return false
+}
+
+// lastStep returns the last completed step of j, empty if none
+func (j *upgradeJournal) lastStep() string {
+	if len(j.Steps) == 0 {
+		return ""
+	}
+	return j.Steps[len(j.Steps)-1].Name
+}
+
+// uninstallStarted returns true if the upgrade of j may have started to uninstall StorageOS, every
+// step before it being completed and the values of the StorageOS cluster recorded
+func (j *upgradeJournal) uninstallStarted() bool {
+	return j.completed(upgradeStepBackupTaken) && j.completed(upgradeStepStorageClassesProtected) &&
+		j.EtcdEndpoints != "" && j.StorageOSClusterNamespace != ""
+}
+
+// latestJournal returns whichever of the local and in-cluster journals has the most completed
+// steps, either may be nil
+func latestJournal(local, inCluster *upgradeJournal) *upgradeJournal {
+	if local == nil {
+		return inCluster
+	}
+	if inCluster == nil || len(local.Steps) > len(inCluster.Steps) {
+		return local
+	}
+	return inCluster
+}
+
+// getUpgradeJournalPath returns the path of the local upgrade journal of the current cluster
+func (in *Installer) getUpgradeJournalPath() (string, error) {
+	root, err := in.getBackupRootPath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(root, upgradeJournalFile), nil
+}
+
+// getUpgradeBackupPath returns the path of the backup taken by the upgrade of j, that of the latest
+// backup for journals written before the backup name was recorded
+func (in *Installer) getUpgradeBackupPath(j *upgradeJournal) (string, error) {
+	if j.BackupName == "" {
+		return in.getBackupPath()
+	}
+	root, err := in.getBackupRootPath()
+	if err != nil {
+		return "", err
+	}
+	b, err := findBackup(root, j.BackupName)
+	if err != nil {
+		return "", err
+	}
+
+	return b.path, nil
+}
+
+// readUpgradeJournal returns the latest of the local and in-cluster journals, nil if neither exists
+func (in *Installer) readUpgradeJournal() (*upgradeJournal, error) {
+	path, err := in.getUpgradeJournalPath()
+	if err != nil {
+		return nil, err
+	}
+	var local *upgradeJournal
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, errors.WithStack(err)
+	}
+	if err == nil {
+		local = &upgradeJournal{}
+		if err = json.Unmarshal(data, local); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+
+	var inCluster *upgradeJournal
+	configMap, err := pluginutils.GetConfigMap(in.clientConfig, upgradeJournalConfigMap, upgradeJournalNamespace)
+	if err != nil && !kerrors.IsNotFound(err) {
+		return nil, err
+	}
+	if err == nil {
+		inCluster = &upgradeJournal{}
+		if err = json.Unmarshal([]byte(configMap.Data[upgradeJournalKey]), inCluster); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+
+	return latestJournal(local, inCluster), nil
+}
+
+// writeUpgradeJournal writes j both locally and in-cluster
+func (in *Installer) writeUpgradeJournal(j *upgradeJournal) error {
+	data, err := json.MarshalIndent(j, "", "    ")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	path, err := in.getUpgradeJournalPath()
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return errors.WithStack(err)
+	}
+	if err = os.WriteFile(path, data, 0600); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return pluginutils.CreateOrUpdateConfigMap(in.clientConfig, &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      upgradeJournalConfigMap,
+			Namespace: upgradeJournalNamespace,
+			Labels:    map[string]string{"app.kubernetes.io/name": "storageos"},
+		},
+		Data: map[string]string{upgradeJournalKey: string(data)},
+	})
+}
+
+// completeUpgradeStep adds step to the completed steps of j and writes it
+func (in *Installer) completeUpgradeStep(j *upgradeJournal, step string) error {
+	j.Steps = append(j.Steps, upgradeStep{Name: step, CompletedAt: time.Now().UTC()})
+	if err := in.writeUpgradeJournal(j); err != nil {
+		return err
+	}
+	in.log.Infof(upgradeStepMessage, step)
+
+	return nil
+}
+
+// deleteUpgradeJournal removes the local and in-cluster journals once an upgrade has completed
+func (in *Installer) deleteUpgradeJournal() error {
+	path, err := in.getUpgradeJournalPath()
+	if err != nil {
+		return err
+	}
+	if err = os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return errors.WithStack(err)
+	}
+
+	return pluginutils.DeleteConfigMap(in.clientConfig, upgradeJournalConfigMap, upgradeJournalNamespace)
+}
+
+// startUpgradeJournal returns the journal of a new upgrade from fromVersion to toVersion, recording
+// whether it quiesces workloads so that a resumed upgrade resumes them. The journal
+// of a previous upgrade which had not uninstalled StorageOS is discarded, as StorageOS is still
+// installed, otherwise that upgrade must be resumed first.
+func (in *Installer) startUpgradeJournal(fromVersion, toVersion string, quiesce bool) (*upgradeJournal, error) {
+	previous, err := in.readUpgradeJournal()
+	if err != nil {
+		return nil, err
+	}
+	if previous != nil {
+		if previous.completed(upgradeStepUninstallDone) {
+			return nil, fmt.Errorf(errUpgradeInterrupted, previous.FromVersion, previous.ToVersion, previous.lastStep())
+		}
+		in.log.Warnf(discardedUpgradeJournalMessage, previous.FromVersion, previous.ToVersion)
+	}
+
+	j := &upgradeJournal{FromVersion: fromVersion, ToVersion: toVersion, QuiesceWorkloads: quiesce, Steps: []upgradeStep{}}
+	if err = in.writeUpgradeJournal(j); err != nil {
+		return nil, err
+	}
+
+	return j, nil
+}
+
+// interruptedUpgradeJournal returns the journal of the interrupted upgrade to resume
+func (in *Installer) interruptedUpgradeJournal() (*upgradeJournal, error) {
+	j, err := in.readUpgradeJournal()
+	if err != nil {
+		return nil, err
+	}
+	if j == nil {
+		path, err := in.getUpgradeJournalPath()
+		if err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf(errNoUpgradeToResume, path, upgradeJournalNamespace, upgradeJournalConfigMap)
+	}
+
+	return j, nil
+}
+
+// UpgradeToResume returns the versions StorageOS is upgraded from and to by the interrupted upgrade
+// to resume. toVersion must match that of the interrupted upgrade, unless empty.
+func UpgradeToResume(config *apiv1.KubectlStorageOSConfig, toVersion string, log *logger.Logger) (string, string, error) {
+	in, err := newLightweightInstaller(config, log)
+	if err != nil {
+		return "", "", err
+	}
+	j, err := in.interruptedUpgradeJournal()
+	if err != nil {
+		return "", "", err
+	}
+	if toVersion != "" && toVersion != j.ToVersion {
+		return "", "", fmt.Errorf(errResumeVersionMismatch, j.ToVersion, toVersion)
+	}
+
+	return j.FromVersion, j.ToVersion, nil
+}
+
+// CheckNoInterruptedUpgrade returns an error if an interrupted upgrade has uninstalled StorageOS, so
+// that it is resumed rather than a new upgrade started
+func CheckNoInterruptedUpgrade(config *apiv1.KubectlStorageOSConfig, log *logger.Logger) error {
+	in, err := newLightweightInstaller(config, log)
+	if err != nil {
+		return err
+	}
+	j, err := in.readUpgradeJournal()
+	if err != nil {
+		return err
+	}
+	if j != nil && j.completed(upgradeStepUninstallDone) {
+		return fmt.Errorf(errUpgradeInterrupted, j.FromVersion, j.ToVersion, j.lastStep())
+	}
+
+	return nil
+}
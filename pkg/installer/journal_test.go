@@ -0,0 +1,172 @@
+package installer
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestUpgradeJournalSteps(t *testing.T) {
+	j := &upgradeJournal{Steps: []upgradeStep{}}
+	if j.lastStep() != "" || j.completed(upgradeStepBackupTaken) {
+		t.Fatalf("expected no completed step, got %+v", j.Steps)
+	}
+
+	j.Steps = append(j.Steps, upgradeStep{Name: upgradeStepBackupTaken}, upgradeStep{Name: upgradeStepStorageClassesProtected})
+	if !j.completed(upgradeStepBackupTaken) || !j.completed(upgradeStepStorageClassesProtected) {
+		t.Errorf("expected backup and storage class steps to be completed, got %+v", j.Steps)
+	}
+	if j.completed(upgradeStepUninstallDone) {
+		t.Errorf("expected uninstall step not to be completed, got %+v", j.Steps)
+	}
+	if last := j.lastStep(); last != upgradeStepStorageClassesProtected {
+		t.Errorf("expected last step %s, got %s", upgradeStepStorageClassesProtected, last)
+	}
+}
+
+func TestUpgradeJournalQuiesceWorkloads(t *testing.T) {
+	for _, quiesce := range []bool{true, false} {
+		data, err := json.Marshal(&upgradeJournal{FromVersion: "v2.5.0", ToVersion: "v2.6.0", QuiesceWorkloads: quiesce, Steps: []upgradeStep{}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		j := &upgradeJournal{}
+		if err = json.Unmarshal(data, j); err != nil {
+			t.Fatal(err)
+		}
+		if j.QuiesceWorkloads != quiesce {
+			t.Errorf("expected quiesceWorkloads %t to be recorded, got %t from %s", quiesce, j.QuiesceWorkloads, data)
+		}
+	}
+}
+
+func TestLatestJournal(t *testing.T) {
+	newJournal := func(steps ...string) *upgradeJournal {
+		j := &upgradeJournal{Steps: []upgradeStep{}}
+		for _, step := range steps {
+			j.Steps = append(j.Steps, upgradeStep{Name: step})
+		}
+		return j
+	}
+	behind := newJournal(upgradeStepBackupTaken)
+	ahead := newJournal(upgradeStepBackupTaken, upgradeStepStorageClassesProtected, upgradeStepUninstallDone)
+
+	tcases := []struct {
+		name      string
+		local     *upgradeJournal
+		inCluster *upgradeJournal
+		expected  *upgradeJournal
+	}{
+		{name: "none"},
+		{name: "local only", local: behind, expected: behind},
+		{name: "in-cluster only", inCluster: behind, expected: behind},
+		{name: "local ahead", local: ahead, inCluster: behind, expected: ahead},
+		{name: "in-cluster ahead", local: behind, inCluster: ahead, expected: ahead},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			if j := latestJournal(tc.local, tc.inCluster); j != tc.expected {
+				t.Errorf("expected journal %+v, got %+v", tc.expected, j)
+			}
+		})
+	}
+}
+
+func TestUpgradeJournalUninstallStarted(t *testing.T) {
+	protected := []upgradeStep{{Name: upgradeStepBackupTaken}, {Name: upgradeStepStorageClassesProtected}}
+	tcases := []struct {
+		name     string
+		journal  *upgradeJournal
+		expected bool
+	}{
+		{
+			name:    "no step",
+			journal: &upgradeJournal{EtcdEndpoints: "http://etcd:2379", StorageOSClusterNamespace: "storageos", Steps: []upgradeStep{}},
+		},
+		{
+			name:    "backup taken",
+			journal: &upgradeJournal{EtcdEndpoints: "http://etcd:2379", StorageOSClusterNamespace: "storageos", Steps: protected[:1]},
+		},
+		{
+			name:    "cluster values not recorded",
+			journal: &upgradeJournal{Steps: protected},
+		},
+		{
+			name:     "storage classes protected",
+			journal:  &upgradeJournal{EtcdEndpoints: "http://etcd:2379", StorageOSClusterNamespace: "storageos", Steps: protected},
+			expected: true,
+		},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			if started := tc.journal.uninstallStarted(); started != tc.expected {
+				t.Errorf("expected uninstall started %t, got %t", tc.expected, started)
+			}
+		})
+	}
+}
+
+func TestStorageOSClusterGone(t *testing.T) {
+	resource := schema.GroupResource{Group: "storageos.com", Resource: "storageosclusters"}
+	tcases := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{name: "not found", err: fmt.Errorf("listing: %w", kerrors.NewNotFound(resource, "")), expected: true},
+		{name: "crd removed", err: errors.WithStack(&meta.NoKindMatchError{GroupKind: schema.GroupKind{Group: "storageos.com", Kind: "StorageOSCluster"}}), expected: true},
+		{name: "forbidden", err: kerrors.NewForbidden(resource, "", errors.New("denied"))},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			if gone := storageOSClusterGone(tc.err); gone != tc.expected {
+				t.Errorf("expected gone %t for %v, got %t", tc.expected, tc.err, gone)
+			}
+		})
+	}
+}
+
+func TestGetUpgradeBackupPath(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	in := &Installer{kubeClusterID: "cluster"}
+	root, err := in.getBackupRootPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().UTC()
+	upgrade := writeTestBackup(t, root, "20220101T000000Z", now.Add(-time.Hour), map[string]string{stosClusterFile: "upgrade"})
+	latest := writeTestBackup(t, root, "20220101T010000Z", now, map[string]string{stosClusterFile: "uninstall-portal"})
+
+	tcases := []struct {
+		name     string
+		journal  *upgradeJournal
+		expected string
+		expErr   bool
+	}{
+		{name: "recorded backup", journal: &upgradeJournal{BackupName: upgrade.name}, expected: upgrade.path},
+		{name: "backup not recorded", journal: &upgradeJournal{}, expected: latest.path},
+		{name: "recorded backup removed", journal: &upgradeJournal{BackupName: "20210101T000000Z"}, expErr: true},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			path, err := in.getUpgradeBackupPath(tc.journal)
+			if tc.expErr != (err != nil) {
+				t.Fatalf("expected error %t, got %v", tc.expErr, err)
+			}
+			if path != tc.expected {
+				t.Errorf("expected backup %s, got %s", tc.expected, path)
+			}
+		})
+	}
+}
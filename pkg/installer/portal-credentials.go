@@ -0,0 +1,150 @@
+package installer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	apiv1 "github.com/storageos/kubectl-storageos/api/v1"
+	"github.com/storageos/kubectl-storageos/pkg/logger"
+)
+
+const (
+	// portalTokenBodyLimit is the size of the token response read to explain a rejection
+	portalTokenBodyLimit = 4096
+
+	errInvalidPortalURL = `
+	Invalid portal URL: %v
+
+	Please check --%s.`
+
+	errInsecurePortalURL = `
+	The portal URL %s uses plaintext http, which would send the portal secret unencrypted.
+	Please use an https URL, or set --%s to allow it.`
+
+	errPortalCredentialsRejected = `
+	The portal at %s did not issue a token for the portal credentials: %s%s`
+
+	errPortalUnreachable = `
+	The portal at %s could not be reached: %v
+
+	Please check --%s, or set --%s if the portal is only reachable from inside the cluster.`
+
+	portalCredentialsUnverifiedMessage = `The portal credentials could not be validated against %s, they are kept as set: %v`
+	portalCredentialsValidatedMessage  = `Portal credentials validated against %s.`
+)
+
+// portalHTTPClient sends the token requests validating portal credentials
+var portalHTTPClient = &http.Client{Timeout: portalRequestTimeout * time.Second}
+
+// portalTokenError is the error response of the portal token endpoint
+type portalTokenError struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+	Message          string `json:"message"`
+}
+
+// ValidatePortalCredentials requests a token from the portal at portalURL with the client
+// credentials and tenant ID. An error is returned if portalURL is invalid, uses plaintext http
+// without allowInsecure, cannot be resolved or connected to, or if the portal refuses the token
+// request, see portalTokenVerdict. A warning is returned instead if the request times out or the
+// portal fails it with a 5xx status.
+func ValidatePortalCredentials(portalURL, clientID, secret, tenantID string, allowInsecure bool) (string, error) {
+	if err := validatePortalURL(portalURL); err != nil {
+		return "", fmt.Errorf(errInvalidPortalURL, err, PortalAPIURLFlag)
+	}
+	if parsed, _ := url.Parse(portalURL); parsed.Scheme == "http" && !allowInsecure {
+		return "", fmt.Errorf(errInsecurePortalURL, portalURL, AllowInsecurePortalURLFlag)
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	form.Set("client_id", clientID)
+	form.Set("client_secret", secret)
+	form.Set("tenant_id", tenantID)
+
+	resp, err := portalHTTPClient.PostForm(strings.TrimSuffix(portalURL, "/")+portalTokenPath, form)
+	if err != nil {
+		if urlErr, ok := err.(*url.Error); ok && urlErr.Timeout() {
+			return fmt.Sprintf(portalCredentialsUnverifiedMessage, portalURL, err), nil
+		}
+		return "", fmt.Errorf(errPortalUnreachable, portalURL, err, PortalAPIURLFlag, SkipPortalCredentialsValFlag)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return "", nil
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, portalTokenBodyLimit))
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	verdict, rejected := portalTokenVerdict(resp.StatusCode, body)
+	if !rejected {
+		return fmt.Sprintf(portalCredentialsUnverifiedMessage, portalURL, verdict), nil
+	}
+
+	return "", fmt.Errorf(errPortalCredentialsRejected, portalURL, verdict, portalTokenErrorDetail(body))
+}
+
+// CheckPortalCredentials validates the portal credentials of configInstall with
+// ValidatePortalCredentials, logging whether they have been validated or why they could not be
+func CheckPortalCredentials(configInstall apiv1.Install, log *logger.Logger) error {
+	warning, err := ValidatePortalCredentials(configInstall.PortalAPIURL, configInstall.PortalClientID, configInstall.PortalSecret, configInstall.PortalTenantID, configInstall.AllowInsecurePortalURL)
+	if err != nil {
+		return err
+	}
+	if warning != "" {
+		log.Warnf("%s", warning)
+		return nil
+	}
+	log.Successf(portalCredentialsValidatedMessage, configInstall.PortalAPIURL)
+
+	return nil
+}
+
+// portalTokenErrorNamesCredentials returns whether the error response body of the token endpoint
+// blames the client or the tenant, as the invalid_client error of OAuth2 does
+func portalTokenErrorNamesCredentials(body []byte) bool {
+	tokenError := portalTokenError{}
+	if err := json.Unmarshal(body, &tokenError); err != nil {
+		return false
+	}
+
+	for _, reason := range []string{tokenError.Error, tokenError.ErrorDescription, tokenError.Message} {
+		reason = strings.ToLower(reason)
+		if strings.Contains(reason, "client") || strings.Contains(reason, "tenant") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// portalTokenErrorDetail returns the reason given in the error response body of the token
+// endpoint, prefixed for appending to a verdict, or an empty string if none is given
+func portalTokenErrorDetail(body []byte) string {
+	tokenError := portalTokenError{}
+	if err := json.Unmarshal(body, &tokenError); err != nil {
+		return ""
+	}
+
+	reasons := []string{}
+	for _, reason := range []string{tokenError.Error, tokenError.ErrorDescription, tokenError.Message} {
+		if reason = strings.TrimSpace(reason); reason != "" {
+			reasons = append(reasons, reason)
+		}
+	}
+	if len(reasons) == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf(" (%s)", strings.Join(reasons, ": "))
+}
@@ -0,0 +1,234 @@
+package installer
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	apiv1 "github.com/storageos/kubectl-storageos/api/v1"
+	"github.com/storageos/kubectl-storageos/pkg/logger"
+)
+
+func TestValidatePortalCredentials(t *testing.T) {
+	portal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/unavailable"+portalTokenPath {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if r.URL.Path == "/slow"+portalTokenPath {
+			time.Sleep(200 * time.Millisecond)
+			return
+		}
+		if r.URL.Path != "/api"+portalTokenPath {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "client_credentials" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if r.PostForm.Get("client_id") == "unknown" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"invalid_client","error_description":"client not found"}`))
+			return
+		}
+		if r.PostForm.Get("tenant_id") == "malformed" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"invalid_request"}`))
+			return
+		}
+		if r.PostForm.Get("tenant_id") != "tenant" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"error":"invalid_tenant","error_description":"unknown tenant"}`))
+			return
+		}
+		if r.PostForm.Get("client_id") != "client" || r.PostForm.Get("client_secret") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":"invalid_client"}`))
+			return
+		}
+		w.Write([]byte(`{"access_token":"token","token_type":"Bearer"}`))
+	}))
+	defer portal.Close()
+
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	tcases := []struct {
+		name          string
+		url           string
+		clientID      string
+		secret        string
+		tenantID      string
+		allowInsecure bool
+		timeout       time.Duration
+		expErrMsg     string
+		expWarning    string
+	}{
+		{
+			name:          "accepted",
+			url:           portal.URL + "/api/",
+			clientID:      "client",
+			secret:        "secret",
+			tenantID:      "tenant",
+			allowInsecure: true,
+		},
+		{
+			name:          "wrong secret",
+			url:           portal.URL + "/api",
+			clientID:      "client",
+			secret:        "typo",
+			tenantID:      "tenant",
+			allowInsecure: true,
+			expErrMsg:     "credentials rejected with HTTP 401, check the client ID, secret and tenant ID (invalid_client)",
+		},
+		{
+			name:          "wrong tenant",
+			url:           portal.URL + "/api",
+			clientID:      "client",
+			secret:        "secret",
+			tenantID:      "typo",
+			allowInsecure: true,
+			expErrMsg:     "credentials rejected with HTTP 403, check the client ID, secret and tenant ID (invalid_tenant: unknown tenant)",
+		},
+		{
+			name:          "unknown client",
+			url:           portal.URL + "/api",
+			clientID:      "unknown",
+			secret:        "secret",
+			tenantID:      "tenant",
+			allowInsecure: true,
+			expErrMsg:     "credentials rejected with HTTP 400, check the client ID, secret and tenant ID (invalid_client: client not found)",
+		},
+		{
+			name:          "bad request",
+			url:           portal.URL + "/api",
+			clientID:      "client",
+			secret:        "secret",
+			tenantID:      "malformed",
+			allowInsecure: true,
+			expErrMsg:     "token request refused with HTTP 400 (invalid_request)",
+		},
+		{
+			name:          "wrong path",
+			url:           portal.URL + "/typo",
+			clientID:      "client",
+			secret:        "secret",
+			tenantID:      "tenant",
+			allowInsecure: true,
+			expErrMsg:     "token endpoint not found with HTTP 404, check the portal URL",
+		},
+		{
+			name:          "server error",
+			url:           portal.URL + "/unavailable",
+			clientID:      "client",
+			secret:        "secret",
+			tenantID:      "tenant",
+			allowInsecure: true,
+			expWarning:    "token request failed with HTTP 500",
+		},
+		{
+			name:      "invalid url",
+			url:       "portal.example.com",
+			expErrMsg: `URL "portal.example.com" is not a valid http or https URL`,
+		},
+		{
+			name:      "plaintext http",
+			url:       portal.URL + "/api",
+			clientID:  "client",
+			secret:    "secret",
+			tenantID:  "tenant",
+			expErrMsg: "uses plaintext http",
+		},
+		{
+			name:          "timeout",
+			url:           portal.URL + "/slow",
+			clientID:      "client",
+			secret:        "secret",
+			tenantID:      "tenant",
+			allowInsecure: true,
+			timeout:       50 * time.Millisecond,
+			expWarning:    "could not be validated against " + portal.URL + "/slow",
+		},
+		{
+			name:          "connection refused",
+			url:           closed.URL,
+			allowInsecure: true,
+			expErrMsg:     "could not be reached",
+		},
+		{
+			name:      "unknown host",
+			url:       "https://portal.invalid",
+			expErrMsg: "could not be reached",
+		},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.timeout != 0 {
+				defaultClient := portalHTTPClient
+				portalHTTPClient = &http.Client{Timeout: tc.timeout}
+				defer func() { portalHTTPClient = defaultClient }()
+			}
+			warning, err := ValidatePortalCredentials(tc.url, tc.clientID, tc.secret, tc.tenantID, tc.allowInsecure)
+			if tc.expErrMsg == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if (tc.expWarning == "") != (warning == "") || !strings.Contains(warning, tc.expWarning) {
+					t.Errorf("expected warning containing %q, got %q", tc.expWarning, warning)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.expErrMsg) {
+				t.Errorf("expected error containing %q, got %v", tc.expErrMsg, err)
+			}
+		})
+	}
+}
+
+func TestCheckPortalCredentials(t *testing.T) {
+	portal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api"+portalTokenPath {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"access_token":"token","token_type":"Bearer"}`))
+	}))
+	defer portal.Close()
+
+	tcases := []struct {
+		name   string
+		url    string
+		expLog string
+	}{
+		{
+			name:   "validated",
+			url:    portal.URL + "/api",
+			expLog: fmt.Sprintf(portalCredentialsValidatedMessage, portal.URL+"/api"),
+		},
+		{
+			name:   "unverified",
+			url:    portal.URL + "/unavailable",
+			expLog: "could not be validated against " + portal.URL + "/unavailable",
+		},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			out := &strings.Builder{}
+			log := logger.NewLogger()
+			log.Writer = out
+			configInstall := apiv1.Install{PortalAPIURL: tc.url, PortalClientID: "client", PortalSecret: "secret", PortalTenantID: "tenant", AllowInsecurePortalURL: true}
+			if err := CheckPortalCredentials(configInstall, log); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !strings.Contains(out.String(), tc.expLog) {
+				t.Errorf("expected log containing %q, got %q", tc.expLog, out.String())
+			}
+		})
+	}
+}
@@ -93,7 +93,7 @@ func buildStringForKustomize(clientID, password, portalURL, tenantID string) str
 
 // UninstallPortalManager writes backup-filestem and uninstalls portal manager components.
 func (in *Installer) UninstallPortalManager() error {
-	if err := in.writeBackupFileSystem(in.storageOSCluster); err != nil {
+	if _, err := in.writeBackupFileSystem(in.storageOSCluster, backupSubcommandUninstallPortal); err != nil {
 		return err
 	}
 
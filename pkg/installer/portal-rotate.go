@@ -0,0 +1,96 @@
+package installer
+
+import (
+	"fmt"
+
+	apiv1 "github.com/storageos/kubectl-storageos/api/v1"
+	"github.com/storageos/kubectl-storageos/pkg/consts"
+	pluginutils "github.com/storageos/kubectl-storageos/pkg/utils"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+const (
+	// portalManagerRolloutTimeout is how long portal manager has to roll out with the new
+	// credentials before the rotation fails
+	portalManagerRolloutTimeout  = 120
+	portalManagerRolloutInterval = 5
+
+	errPortalClientSecretNotFound = `
+	Portal client secret %s not found in namespace %s, please install portal manager with install-portal.`
+
+	errPortalRotationAborted = `%v
+
+	Portal credentials have not been rotated.`
+
+	errPortalRotationUnconfirmed = `
+	Portal credentials have been rotated, but portal manager could not confirm them:
+%v
+	The previous portal client secret is kept in the backup at %s.`
+
+	portalSecretBackedUpMessage = `Previous portal client secret backed up to %s.`
+)
+
+// RotatePortalCredentials regenerates the portal client secret with the credentials of the config,
+// defaulting the client ID, tenant ID and portal URL to those of the existing secret. The existing
+// secret is written to a new backup first, then portal manager is restarted and its connectivity
+// to the portal with the new credentials is checked.
+func (in *Installer) RotatePortalCredentials() error {
+	namespace := in.storageOSCluster.Namespace
+	secret, err := pluginutils.GetSecret(in.clientConfig, consts.PortalClientSecretName, namespace)
+	if err != nil {
+		if kerrors.IsNotFound(err) {
+			return fmt.Errorf(errPortalClientSecretNotFound, consts.PortalClientSecretName, namespace)
+		}
+		return err
+	}
+
+	configInstall := &in.stosConfig.Spec.Install
+	defaultPortalCredentials(configInstall, secret)
+	if err := FlagsAreSet(map[string]string{
+		PortalClientIDFlag: configInstall.PortalClientID,
+		PortalSecretFlag:   configInstall.PortalSecret,
+		PortalTenantIDFlag: configInstall.PortalTenantID,
+		PortalAPIURLFlag:   configInstall.PortalAPIURL,
+	}); err != nil {
+		return err
+	}
+	if !configInstall.SkipPortalCredentialsValidation {
+		if err := CheckPortalCredentials(*configInstall, in.log); err != nil {
+			return fmt.Errorf(errPortalRotationAborted, err)
+		}
+	}
+
+	backupPath, err := in.writeBackupFileSystem(in.storageOSCluster, backupSubcommandRotatePortal)
+	if err != nil {
+		return err
+	}
+	in.log.Successf(portalSecretBackedUpMessage, backupPath)
+
+	if err := in.installPortalManagerClient(namespace); err != nil {
+		return err
+	}
+
+	if err := pluginutils.RestartDeployment(in.clientConfig, consts.PortalManagerName, namespace); err != nil {
+		return err
+	}
+	if err := pluginutils.WaitFor(func() error {
+		return pluginutils.IsDeploymentRolledOut(in.clientConfig, consts.PortalManagerName, namespace)
+	}, portalManagerRolloutTimeout, portalManagerRolloutInterval); err != nil {
+		return err
+	}
+
+	if err := in.printPortalChecks(in.portalConnectivityChecks(namespace)); err != nil {
+		return fmt.Errorf(errPortalRotationUnconfirmed, err, backupPath)
+	}
+
+	return nil
+}
+
+// defaultPortalCredentials sets the client ID, tenant ID and portal URL of configInstall that are
+// not set to the values of the existing portal client secret
+func defaultPortalCredentials(configInstall *apiv1.Install, secret *corev1.Secret) {
+	configInstall.PortalClientID = getStringWithDefault(configInstall.PortalClientID, string(secret.Data[portalClientIDKey]))
+	configInstall.PortalTenantID = getStringWithDefault(configInstall.PortalTenantID, string(secret.Data[portalTenantIDKey]))
+	configInstall.PortalAPIURL = getStringWithDefault(configInstall.PortalAPIURL, string(secret.Data[portalURLKey]))
+}
@@ -0,0 +1,65 @@
+package installer
+
+import (
+	"testing"
+
+	apiv1 "github.com/storageos/kubectl-storageos/api/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestDefaultPortalCredentials(t *testing.T) {
+	secret := &corev1.Secret{
+		Data: map[string][]byte{
+			portalClientIDKey: []byte("old-client"),
+			portalPasswordKey: []byte("old-secret"),
+			portalURLKey:      []byte("https://portal.example.com"),
+			portalTenantIDKey: []byte("old-tenant"),
+		},
+	}
+
+	tests := map[string]struct {
+		input    apiv1.Install
+		expected apiv1.Install
+	}{
+		"secret only": {
+			input: apiv1.Install{PortalSecret: "new-secret"},
+			expected: apiv1.Install{
+				PortalClientID: "old-client",
+				PortalSecret:   "new-secret",
+				PortalAPIURL:   "https://portal.example.com",
+				PortalTenantID: "old-tenant",
+			},
+		},
+		"all set": {
+			input: apiv1.Install{
+				PortalClientID: "new-client",
+				PortalSecret:   "new-secret",
+				PortalAPIURL:   "https://new.example.com",
+				PortalTenantID: "new-tenant",
+			},
+			expected: apiv1.Install{
+				PortalClientID: "new-client",
+				PortalSecret:   "new-secret",
+				PortalAPIURL:   "https://new.example.com",
+				PortalTenantID: "new-tenant",
+			},
+		},
+		"secret not defaulted": {
+			input: apiv1.Install{PortalClientID: "new-client"},
+			expected: apiv1.Install{
+				PortalClientID: "new-client",
+				PortalAPIURL:   "https://portal.example.com",
+				PortalTenantID: "old-tenant",
+			},
+		},
+	}
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			defaultPortalCredentials(&tc.input, secret)
+			if tc.input != tc.expected {
+				t.Errorf("expected %+v, got %+v", tc.expected, tc.input)
+			}
+		})
+	}
+}
@@ -0,0 +1,441 @@
+package installer
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	gyaml "github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+	apiv1 "github.com/storageos/kubectl-storageos/api/v1"
+	"github.com/storageos/kubectl-storageos/pkg/consts"
+	pluginutils "github.com/storageos/kubectl-storageos/pkg/utils"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	portalShellPodPrefix     = "storageos-portal-shell"
+	portalShellContainerName = "storageos-portal-shell"
+	defaultPortalShellImage  = "curlimages/curl:7.85.0"
+
+	// portalShellPodLifetime is the lifetime of the portal shell pod used for connectivity checks
+	portalShellPodLifetime = 2 * time.Minute
+	// portalRequestTimeout is the timeout in seconds of each request to the portal
+	portalRequestTimeout = 10
+
+	portalManagerLabel = "app.kubernetes.io/component=portal-manager"
+	portalConfigKey    = "portal_config.yaml"
+
+	// portalTokenPath is the path of the OAuth2 client credentials token endpoint, relative to the
+	// portal API URL. It has to match the token request of portal manager, whose source is not part
+	// of this repository.
+	portalTokenPath = "/oauth/token"
+	// curlTimeoutError is the error curl prints when --max-time is exceeded
+	curlTimeoutError = "curl: (28)"
+
+	portalClientIDKey = "CLIENT_ID"
+	portalPasswordKey = "PASSWORD"
+	portalURLKey      = "URL"
+	portalTenantIDKey = "TENANT_ID"
+
+	portalCheckOK      = "OK"
+	portalCheckWarning = "WARNING"
+	portalCheckFailed  = "FAILED"
+	portalCheckSkipped = "SKIPPED"
+
+	// portalLogErrorLimit is the length the last error logged by portal manager is truncated to
+	portalLogErrorLimit = 160
+
+	errPortalChecksFailed = `
+	Portal manager failed %d of %d checks:
+%s`
+
+	portalHealthyMessage = `Portal manager is healthy.`
+
+	portalShellPodDeletionFailMessage = `
+	Failed to cleanup portal shell pod with error %v, please delete pod manually.`
+)
+
+var portalLogErrorRegexp = regexp.MustCompile(`(?i)("level"\s*:\s*"error"|\berror\b|\bE\d{4} )`)
+
+// portalCheck is the verdict of a single portal manager status check
+type portalCheck struct {
+	name   string
+	result string
+	detail string
+}
+
+// PortalStatus checks the portal client secret, the portal manager configmap, pod and logs, and
+// that the portal is reachable and accepts the credentials from inside the cluster. A verdict is
+// printed for each check and an error listing the failed checks is returned.
+func (in *Installer) PortalStatus() error {
+	namespace := in.storageOSCluster.Namespace
+	checks := []portalCheck{clusterPortalCheck(in.storageOSCluster.Spec.EnablePortalManager)}
+
+	secretCheck := portalCheck{name: "client secret", result: portalCheckFailed}
+	secret, err := pluginutils.GetSecret(in.clientConfig, consts.PortalClientSecretName, namespace)
+	if err != nil {
+		secretCheck.detail = err.Error()
+	} else {
+		secretCheck = clientSecretPortalCheck(secret)
+	}
+	checks = append(checks, secretCheck)
+
+	configMap, err := pluginutils.GetConfigMap(in.clientConfig, consts.PortalManagerName, namespace)
+	if err != nil {
+		checks = append(checks, portalCheck{name: "configmap", result: portalCheckFailed, detail: err.Error()})
+	} else {
+		checks = append(checks, configMapPortalCheck(configMap))
+	}
+
+	checks = append(checks, in.portalManagerPodChecks(namespace)...)
+
+	if secretCheck.result == portalCheckFailed {
+		checks = append(checks,
+			portalCheck{name: "reachability", result: portalCheckSkipped, detail: "client secret is invalid"},
+			portalCheck{name: "authentication", result: portalCheckSkipped, detail: "client secret is invalid"},
+		)
+	} else {
+		checks = append(checks, in.portalConnectivityChecks(namespace)...)
+	}
+
+	return in.printPortalChecks(checks)
+}
+
+// clusterPortalCheck checks that portal manager is enabled in the StorageOS cluster
+func clusterPortalCheck(enabled bool) portalCheck {
+	if !enabled {
+		return portalCheck{name: "storageos cluster", result: portalCheckFailed, detail: "portal manager is disabled, enable it with: kubectl storageos enable-portal"}
+	}
+	return portalCheck{name: "storageos cluster", result: portalCheckOK, detail: "portal manager is enabled"}
+}
+
+// clientSecretPortalCheck checks that the portal client secret holds credentials and a valid URL
+func clientSecretPortalCheck(secret *corev1.Secret) portalCheck {
+	check := portalCheck{name: "client secret", result: portalCheckFailed}
+
+	missing := []string{}
+	for _, key := range []string{portalClientIDKey, portalPasswordKey, portalURLKey, portalTenantIDKey} {
+		if strings.TrimSpace(string(secret.Data[key])) == "" {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) != 0 {
+		check.detail = fmt.Sprintf("%s missing or empty", strings.Join(missing, ", "))
+		return check
+	}
+
+	portalURL := string(secret.Data[portalURLKey])
+	if err := validatePortalURL(portalURL); err != nil {
+		check.detail = err.Error()
+		return check
+	}
+
+	check.result = portalCheckOK
+	check.detail = fmt.Sprintf("client ID %s, tenant ID %s, URL %s", secret.Data[portalClientIDKey], secret.Data[portalTenantIDKey], portalURL)
+	return check
+}
+
+// validatePortalURL returns an error if portalURL is not an absolute http or https URL
+func validatePortalURL(portalURL string) error {
+	parsed, err := url.Parse(portalURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("URL %q is not a valid http or https URL", portalURL)
+	}
+	return nil
+}
+
+// configMapPortalCheck checks that the portal manager configmap holds a valid portal config
+func configMapPortalCheck(configMap *corev1.ConfigMap) portalCheck {
+	check := portalCheck{name: "configmap", result: portalCheckFailed}
+
+	raw, ok := configMap.Data[portalConfigKey]
+	if !ok {
+		check.detail = fmt.Sprintf("%s is missing", portalConfigKey)
+		return check
+	}
+	portalConfig := map[string]interface{}{}
+	if err := gyaml.Unmarshal([]byte(raw), &portalConfig); err != nil {
+		check.detail = fmt.Sprintf("%s is invalid: %v", portalConfigKey, err)
+		return check
+	}
+
+	check.result = portalCheckOK
+	check.detail = fmt.Sprintf("%s is valid", portalConfigKey)
+	return check
+}
+
+// portalManagerPodChecks checks the state and the logs of the portal manager pod
+func (in *Installer) portalManagerPodChecks(namespace string) []portalCheck {
+	pods, err := pluginutils.ListPods(in.clientConfig, namespace, portalManagerLabel)
+	if err != nil {
+		return []portalCheck{
+			{name: "pod", result: portalCheckFailed, detail: err.Error()},
+			{name: "logs", result: portalCheckSkipped, detail: "pod not found"},
+		}
+	}
+	if len(pods.Items) == 0 {
+		return []portalCheck{
+			{name: "pod", result: portalCheckFailed, detail: fmt.Sprintf("no pod labelled %s in namespace %s", portalManagerLabel, namespace)},
+			{name: "logs", result: portalCheckSkipped, detail: "pod not found"},
+		}
+	}
+
+	pod := &pods.Items[0]
+	logs, err := pluginutils.FetchPodLogs(in.clientConfig, pod.Name, pod.Namespace)
+	logCheck := portalCheck{name: "logs", result: portalCheckWarning, detail: fmt.Sprintf("unable to read logs: %v", err)}
+	if err == nil {
+		logCheck = logsPortalCheck(logs)
+	}
+
+	return []portalCheck{podPortalCheck(pod), logCheck}
+}
+
+// podPortalCheck checks that the portal manager pod is running and ready without restarts
+func podPortalCheck(pod *corev1.Pod) portalCheck {
+	check := portalCheck{name: "pod", result: portalCheckOK}
+
+	restarts := int32(0)
+	ready := pod.Status.Phase == corev1.PodRunning
+	reason := string(pod.Status.Phase)
+	for _, status := range pod.Status.ContainerStatuses {
+		restarts += status.RestartCount
+		if !status.Ready {
+			ready = false
+		}
+		if status.State.Waiting != nil && status.State.Waiting.Reason != "" {
+			reason = status.State.Waiting.Reason
+		}
+	}
+
+	switch {
+	case !ready:
+		check.result = portalCheckFailed
+		check.detail = fmt.Sprintf("pod %s is not ready: %s", pod.Name, reason)
+	case restarts > 0:
+		check.result = portalCheckWarning
+		check.detail = fmt.Sprintf("pod %s is ready, restarted %d times", pod.Name, restarts)
+	default:
+		check.detail = fmt.Sprintf("pod %s is ready", pod.Name)
+	}
+	return check
+}
+
+// logsPortalCheck checks the portal manager logs for errors, the last error logged is reported
+func logsPortalCheck(logs string) portalCheck {
+	errorCount := 0
+	lastError := ""
+	for _, line := range strings.Split(logs, "\n") {
+		if portalLogErrorRegexp.MatchString(line) {
+			errorCount++
+			lastError = strings.TrimSpace(line)
+		}
+	}
+
+	if errorCount == 0 {
+		return portalCheck{name: "logs", result: portalCheckOK, detail: "no errors logged"}
+	}
+	if len(lastError) > portalLogErrorLimit {
+		lastError = lastError[:portalLogErrorLimit] + "..."
+	}
+	return portalCheck{name: "logs", result: portalCheckWarning, detail: fmt.Sprintf("%d errors logged, last: %s", errorCount, lastError)}
+}
+
+// portalConnectivityChecks checks from a pod in namespace that the portal is reachable and accepts
+// the credentials of the portal client secret
+func (in *Installer) portalConnectivityChecks(namespace string) []portalCheck {
+	checks := []portalCheck{}
+	err := in.withPortalShellPod(namespace, func(podName, podNamespace string) error {
+		stdout, stderr, err := pluginutils.ExecToPod(in.clientConfig, portalReachabilityCmd(), "", podName, podNamespace, nil)
+		checks = append(checks, reachabilityPortalCheck(stdout, stderr, err))
+
+		stdout, stderr, err = pluginutils.ExecToPod(in.clientConfig, portalTokenCmd(), "", podName, podNamespace, nil)
+		checks = append(checks, authenticationPortalCheck(stdout, stderr, err))
+		return nil
+	})
+	if err != nil {
+		detail := fmt.Sprintf("unable to run portal shell pod: %v", err)
+		return []portalCheck{
+			{name: "reachability", result: portalCheckFailed, detail: detail},
+			{name: "authentication", result: portalCheckSkipped, detail: detail},
+		}
+	}
+
+	return checks
+}
+
+// portalReachabilityCmd returns the command printing the HTTP status code of the portal URL
+func portalReachabilityCmd() []string {
+	return []string{"sh", "-c", fmt.Sprintf(`curl -sS -o /dev/null -w '%%{http_code}' --max-time %d "$%s"`, portalRequestTimeout, portalURLKey)}
+}
+
+// portalTokenCmd returns the command requesting a token from the portal with the client
+// credentials and printing the response body followed by its HTTP status code on the last line
+func portalTokenCmd() []string {
+	return []string{"sh", "-c", fmt.Sprintf(`curl -sS -w '\n%%{http_code}' --max-time %d -X POST `+
+		`--data-urlencode grant_type=client_credentials `+
+		`--data-urlencode "client_id=$%s" --data-urlencode "client_secret=$%s" --data-urlencode "tenant_id=$%s" `+
+		`"${%s%%/}%s"`,
+		portalRequestTimeout, portalClientIDKey, portalPasswordKey, portalTenantIDKey, portalURLKey, portalTokenPath)}
+}
+
+// parseHTTPStatus returns the HTTP status code printed by curl on the last line of stdout and the
+// response body printed before it, or an error describing why no response was received
+func parseHTTPStatus(stdout, stderr string, err error) (int, string, error) {
+	body, status := "", strings.TrimSpace(stdout)
+	if i := strings.LastIndex(status, "\n"); i >= 0 {
+		body, status = status[:i], status[i+1:]
+	}
+	code, parseErr := strconv.Atoi(strings.TrimSpace(status))
+	if parseErr == nil && code != 0 {
+		return code, body, nil
+	}
+	if detail := strings.TrimSpace(stderr); detail != "" {
+		return 0, "", errors.New(detail)
+	}
+	if err != nil {
+		return 0, "", err
+	}
+	return 0, "", errors.New("no response received")
+}
+
+// reachabilityPortalCheck checks that the portal URL answered
+func reachabilityPortalCheck(stdout, stderr string, err error) portalCheck {
+	check := portalCheck{name: "reachability", result: portalCheckOK}
+
+	code, _, err := parseHTTPStatus(stdout, stderr, err)
+	switch {
+	case err != nil:
+		check.result = portalCheckFailed
+		check.detail = fmt.Sprintf("portal unreachable from inside the cluster: %v", err)
+	case code >= 500:
+		check.result = portalCheckWarning
+		check.detail = fmt.Sprintf("portal answered with HTTP %d", code)
+	default:
+		check.detail = fmt.Sprintf("portal answered with HTTP %d", code)
+	}
+	return check
+}
+
+// authenticationPortalCheck checks that the portal issued a token for the client credentials, it
+// only warns if the token request timed out or failed with a 5xx status
+func authenticationPortalCheck(stdout, stderr string, err error) portalCheck {
+	check := portalCheck{name: "authentication", result: portalCheckFailed}
+
+	code, body, err := parseHTTPStatus(stdout, stderr, err)
+	if err != nil {
+		if strings.Contains(err.Error(), curlTimeoutError) {
+			check.result = portalCheckWarning
+		}
+		check.detail = fmt.Sprintf("no response to token request: %v", err)
+		return check
+	}
+	if code < 200 || code >= 300 {
+		verdict, rejected := portalTokenVerdict(code, []byte(body))
+		if !rejected {
+			check.result = portalCheckWarning
+		}
+		check.detail = verdict + portalTokenErrorDetail([]byte(body))
+		return check
+	}
+
+	check.result = portalCheckOK
+	check.detail = fmt.Sprintf("credentials accepted with HTTP %d", code)
+	return check
+}
+
+// portalTokenVerdict explains why the portal did not issue a token with HTTP status code and error
+// response body, and whether the token request is refused. Only 5xx failures of the portal leave the
+// credentials unverified, any other answer refuses them, a 404 meaning the portal URL is wrong.
+func portalTokenVerdict(code int, body []byte) (string, bool) {
+	switch {
+	case code == http.StatusUnauthorized || code == http.StatusForbidden:
+		return fmt.Sprintf("credentials rejected with HTTP %d, check the client ID, secret and tenant ID", code), true
+	case code == http.StatusBadRequest && portalTokenErrorNamesCredentials(body):
+		return fmt.Sprintf("credentials rejected with HTTP %d, check the client ID, secret and tenant ID", code), true
+	case code == http.StatusNotFound:
+		return fmt.Sprintf("token endpoint not found with HTTP %d, check the portal URL", code), true
+	case code >= 500:
+		return fmt.Sprintf("token request failed with HTTP %d", code), false
+	default:
+		return fmt.Sprintf("token request refused with HTTP %d", code), true
+	}
+}
+
+// withPortalShellPod creates a pod in namespace with the portal client secret in its environment,
+// waits for it to run, runs fn with its name and namespace and deletes it
+func (in *Installer) withPortalShellPod(namespace string, fn func(podName, podNamespace string) error) error {
+	pod, err := portalShellPod(namespace, in.stosConfig.Spec.Install, portalShellPodLifetime)
+	if err != nil {
+		return err
+	}
+	portalShell, err := podToManifest(pod)
+	if err != nil {
+		return err
+	}
+
+	if err = in.kubectlClient.Apply(context.TODO(), "", portalShell, true); err != nil {
+		return errors.WithStack(err)
+	}
+
+	defer func() {
+		if err := in.kubectlClient.Delete(context.TODO(), "", portalShell, true); err != nil {
+			// do nothing, portal shell pod runs to completion even in unlikely event that delete fails
+			in.log.Warnf(portalShellPodDeletionFailMessage, err)
+		}
+	}()
+
+	if err = pluginutils.WaitFor(func() error {
+		return pluginutils.IsPodRunning(in.clientConfig, pod.Name, pod.Namespace)
+	}, 60, 5); err != nil {
+		return err
+	}
+
+	return fn(pod.Name, pod.Namespace)
+}
+
+// portalShellPod returns the pod used to send requests to the portal from inside the cluster,
+// scheduled and secured like the etcd shell pod by configInstall. The fields of the portal client
+// secret are set as environment variables and the pod completes after lifetime.
+func portalShellPod(namespace string, configInstall apiv1.Install, lifetime time.Duration) (*corev1.Pod, error) {
+	image := getStringWithDefault(configInstall.PortalShellImage, defaultPortalShellImage)
+	pod, err := shellPod(namespace, portalShellPodPrefix, portalShellContainerName, image, configInstall, lifetime)
+	if err != nil {
+		return nil, err
+	}
+	pod.Spec.Containers[0].EnvFrom = []corev1.EnvFromSource{
+		{
+			SecretRef: &corev1.SecretEnvSource{
+				LocalObjectReference: corev1.LocalObjectReference{Name: consts.PortalClientSecretName},
+			},
+		},
+	}
+
+	return pod, nil
+}
+
+// printPortalChecks prints the verdict of every check as a table and returns an error listing the
+// failed checks
+func (in *Installer) printPortalChecks(checks []portalCheck) error {
+	rows := make([][]string, 0, len(checks))
+	failures := []string{}
+	for _, check := range checks {
+		rows = append(rows, []string{check.name, check.result, tableValue(check.detail)})
+		if check.result == portalCheckFailed {
+			failures = append(failures, fmt.Sprintf("\t- %s: %s", check.name, check.detail))
+		}
+	}
+	in.log.Table([]string{"CHECK", "RESULT", "DETAIL"}, rows)
+
+	if len(failures) != 0 {
+		return fmt.Errorf(errPortalChecksFailed, len(failures), len(checks), strings.Join(failures, "\n"))
+	}
+	in.log.Success(portalHealthyMessage)
+
+	return nil
+}
@@ -0,0 +1,234 @@
+package installer
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	apiv1 "github.com/storageos/kubectl-storageos/api/v1"
+	"github.com/storageos/kubectl-storageos/pkg/consts"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestClientSecretPortalCheck(t *testing.T) {
+	tcases := []struct {
+		name      string
+		data      map[string]string
+		expResult string
+		expDetail string
+	}{
+		{
+			name:      "valid",
+			data:      map[string]string{"CLIENT_ID": "client", "PASSWORD": "secret", "URL": "https://portal.example.com/api", "TENANT_ID": "tenant"},
+			expResult: portalCheckOK,
+			expDetail: "client ID client, tenant ID tenant, URL https://portal.example.com/api",
+		},
+		{
+			name:      "missing fields",
+			data:      map[string]string{"CLIENT_ID": "client", "PASSWORD": "secret", "URL": " "},
+			expResult: portalCheckFailed,
+			expDetail: "URL, TENANT_ID missing or empty",
+		},
+		{
+			name:      "invalid url",
+			data:      map[string]string{"CLIENT_ID": "client", "PASSWORD": "secret", "URL": "portal.example.com", "TENANT_ID": "tenant"},
+			expResult: portalCheckFailed,
+			expDetail: `URL "portal.example.com" is not a valid http or https URL`,
+		},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			secret := &corev1.Secret{Data: map[string][]byte{}}
+			for key, value := range tc.data {
+				secret.Data[key] = []byte(value)
+			}
+			check := clientSecretPortalCheck(secret)
+			if check.result != tc.expResult || check.detail != tc.expDetail {
+				t.Errorf("expected %s %q, got %s %q", tc.expResult, tc.expDetail, check.result, check.detail)
+			}
+		})
+	}
+}
+
+func TestPodPortalCheck(t *testing.T) {
+	tcases := []struct {
+		name      string
+		phase     corev1.PodPhase
+		status    corev1.ContainerStatus
+		expResult string
+	}{
+		{
+			name:      "ready",
+			phase:     corev1.PodRunning,
+			status:    corev1.ContainerStatus{Ready: true},
+			expResult: portalCheckOK,
+		},
+		{
+			name:      "restarted",
+			phase:     corev1.PodRunning,
+			status:    corev1.ContainerStatus{Ready: true, RestartCount: 2},
+			expResult: portalCheckWarning,
+		},
+		{
+			name:      "crash loop",
+			phase:     corev1.PodRunning,
+			status:    corev1.ContainerStatus{State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}}},
+			expResult: portalCheckFailed,
+		},
+		{
+			name:      "pending",
+			phase:     corev1.PodPending,
+			expResult: portalCheckFailed,
+		},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			pod := &corev1.Pod{Status: corev1.PodStatus{Phase: tc.phase, ContainerStatuses: []corev1.ContainerStatus{tc.status}}}
+			if check := podPortalCheck(pod); check.result != tc.expResult {
+				t.Errorf("expected %s, got %s: %s", tc.expResult, check.result, check.detail)
+			}
+		})
+	}
+}
+
+func TestLogsPortalCheck(t *testing.T) {
+	if check := logsPortalCheck("{\"level\":\"info\",\"msg\":\"connected\"}\n"); check.result != portalCheckOK {
+		t.Errorf("expected %s, got %s: %s", portalCheckOK, check.result, check.detail)
+	}
+
+	logs := "{\"level\":\"error\",\"msg\":\"token refresh failed\"}\n{\"level\":\"info\",\"msg\":\"retrying\"}\n{\"level\":\"error\",\"msg\":\"401 unauthorized\"}\n"
+	check := logsPortalCheck(logs)
+	if check.result != portalCheckWarning || !strings.HasPrefix(check.detail, "2 errors logged") || !strings.Contains(check.detail, "401 unauthorized") {
+		t.Errorf("unexpected check %+v", check)
+	}
+}
+
+func TestConnectivityPortalChecks(t *testing.T) {
+	tcases := []struct {
+		name       string
+		stdout     string
+		stderr     string
+		err        error
+		expReach   string
+		expAuth    string
+		authDetail string
+	}{
+		{
+			name:     "accepted",
+			stdout:   "200",
+			expReach: portalCheckOK,
+			expAuth:  portalCheckOK,
+		},
+		{
+			name:       "rejected",
+			stdout:     "401",
+			expReach:   portalCheckOK,
+			expAuth:    portalCheckFailed,
+			authDetail: "credentials rejected",
+		},
+		{
+			name:       "unknown client",
+			stdout:     "{\"error\":\"invalid_client\"}\n400",
+			expReach:   portalCheckOK,
+			expAuth:    portalCheckFailed,
+			authDetail: "credentials rejected with HTTP 400, check the client ID, secret and tenant ID (invalid_client)",
+		},
+		{
+			name:       "not found",
+			stdout:     "404",
+			expReach:   portalCheckOK,
+			expAuth:    portalCheckFailed,
+			authDetail: "check the portal URL",
+		},
+		{
+			name:       "server error",
+			stdout:     "503",
+			expReach:   portalCheckWarning,
+			expAuth:    portalCheckWarning,
+			authDetail: "token request failed with HTTP 503",
+		},
+		{
+			name:       "unreachable",
+			stdout:     "000",
+			stderr:     "curl: (6) Could not resolve host: portal.example.com",
+			err:        errors.New("command terminated with exit code 6"),
+			expReach:   portalCheckFailed,
+			expAuth:    portalCheckFailed,
+			authDetail: "Could not resolve host",
+		},
+		{
+			name:       "timeout",
+			stdout:     "000",
+			stderr:     "curl: (28) Operation timed out after 10001 milliseconds with 0 bytes received",
+			err:        errors.New("command terminated with exit code 28"),
+			expReach:   portalCheckFailed,
+			expAuth:    portalCheckWarning,
+			authDetail: "Operation timed out",
+		},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			if check := reachabilityPortalCheck(tc.stdout, tc.stderr, tc.err); check.result != tc.expReach {
+				t.Errorf("expected reachability %s, got %s: %s", tc.expReach, check.result, check.detail)
+			}
+			check := authenticationPortalCheck(tc.stdout, tc.stderr, tc.err)
+			if check.result != tc.expAuth || !strings.Contains(check.detail, tc.authDetail) {
+				t.Errorf("expected authentication %s containing %q, got %s: %s", tc.expAuth, tc.authDetail, check.result, check.detail)
+			}
+		})
+	}
+}
+
+func TestPortalShellPod(t *testing.T) {
+	configInstall := apiv1.Install{
+		EtcdShellImage:            "registry.local/etcd:v3.5.4",
+		EtcdShellImagePullSecrets: "regcred",
+		EtcdShellTolerations:      "dedicated=infra:NoSchedule",
+		EtcdShellNodeSelector:     "role=infra",
+		EtcdShellRunAsUser:        "1000",
+		PortalShellImage:          "registry.local/curl:7.85.0",
+	}
+
+	pod, err := portalShellPod("storageos", configInstall, portalShellPodLifetime)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(pod.Name, portalShellPodPrefix+"-") {
+		t.Errorf("expected name with prefix %s, got %s", portalShellPodPrefix, pod.Name)
+	}
+	container := pod.Spec.Containers[0]
+	if container.Image != configInstall.PortalShellImage {
+		t.Errorf("expected image %s, got %s", configInstall.PortalShellImage, container.Image)
+	}
+	if container.EnvFrom[0].SecretRef.Name != consts.PortalClientSecretName {
+		t.Errorf("expected environment from secret %s, got %v", consts.PortalClientSecretName, container.EnvFrom)
+	}
+	if !reflect.DeepEqual(pod.Spec.ImagePullSecrets, []corev1.LocalObjectReference{{Name: "regcred"}}) {
+		t.Errorf("expected the etcd shell pull secrets, got %v", pod.Spec.ImagePullSecrets)
+	}
+	if len(pod.Spec.Tolerations) != 1 || pod.Spec.Tolerations[0].Key != "dedicated" {
+		t.Errorf("expected the etcd shell tolerations, got %v", pod.Spec.Tolerations)
+	}
+	if pod.Spec.NodeSelector["role"] != "infra" {
+		t.Errorf("expected the etcd shell node selector, got %v", pod.Spec.NodeSelector)
+	}
+	if pod.Spec.SecurityContext == nil || *pod.Spec.SecurityContext.RunAsUser != 1000 || container.SecurityContext == nil {
+		t.Errorf("expected the etcd shell security context, got %v and %v", pod.Spec.SecurityContext, container.SecurityContext)
+	}
+
+	pod, err = portalShellPod("storageos", apiv1.Install{}, portalShellPodLifetime)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if pod.Spec.Containers[0].Image != defaultPortalShellImage {
+		t.Errorf("expected default image %s, got %s", defaultPortalShellImage, pod.Spec.Containers[0].Image)
+	}
+
+	if _, err = portalShellPod("storageos", apiv1.Install{EtcdShellTolerations: "key:Never"}, portalShellPodLifetime); err == nil {
+		t.Errorf("expected error for invalid toleration")
+	}
+}
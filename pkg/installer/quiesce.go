@@ -0,0 +1,299 @@
+package installer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	pluginutils "github.com/storageos/kubectl-storageos/pkg/utils"
+)
+
+const (
+	quiesceStateFile = "quiesced-workloads.json"
+
+	deploymentKind  = "Deployment"
+	statefulSetKind = "StatefulSet"
+	cronJobKind     = "CronJob"
+
+	errWorkloadsNotQuiescable = `
+	Workloads %s use StorageOS volumes and cannot be quiesced, only Deployments, StatefulSets and CronJobs
+	are scaled down or suspended. Stop these workloads or re-run without --` + QuiesceWorkloadsFlag + `.`
+
+	errWorkloadsNotStopped = `
+	Pods using StorageOS volumes are still running after quiescing workloads: %v`
+
+	errQuiesceFailed = `
+	Quiescing workloads has failed: %v
+
+	Workloads have been resumed.`
+
+	errResumeFailed = `
+	Quiescing workloads has failed: %v
+
+	Resuming workloads has also failed: %v
+
+	The original state of the workloads can be found in %s, resume them with:
+	kubectl storageos workloads resume`
+
+	quiescingWorkloadMessage = `Quiescing %s %s/%s.`
+	resumingWorkloadMessage  = `Resuming %s %s/%s.`
+	quiescedWorkloadsMessage = `%d workload(s) quiesced, their original state has been written to %s.`
+	noQuiescedStateMessage   = `No quiesced workloads found.`
+	workloadsResumedMessage  = `%d workload(s) resumed.`
+
+	quiescedUpgradeFailedMessage = `Workloads quiesced for upgrade have not been resumed. Once StorageOS is running, resume them with:
+	kubectl storageos workloads resume`
+)
+
+// quiescedWorkload is a workload scaled down or suspended, with the state it is resumed to
+type quiescedWorkload struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Replicas  int32  `json:"replicas,omitempty"`
+	Suspended bool   `json:"suspended,omitempty"`
+}
+
+func (w quiescedWorkload) key() string {
+	return w.Kind + "/" + w.Namespace + "/" + w.Name
+}
+
+// quiesceState is written to disk before any workload is quiesced, so that workloads can be
+// resumed if the plugin exits before resuming them
+type quiesceState struct {
+	Workloads []quiescedWorkload `json:"workloads"`
+}
+
+// quiescableWorkloads returns the workloads of report to be quiesced, which are not already part of
+// state, and the workloads of report which cannot be quiesced
+func quiescableWorkloads(report *workloadReport, state *quiesceState) ([]quiescedWorkload, []string) {
+	known := map[string]bool{}
+	for _, workload := range state.Workloads {
+		known[workload.key()] = true
+	}
+
+	workloads := []quiescedWorkload{}
+	notQuiescable := []string{}
+	for _, volume := range report.Volumes {
+		for _, kindName := range volume.Workloads {
+			parts := strings.SplitN(kindName, "/", 2)
+			workload := quiescedWorkload{Kind: parts[0], Namespace: volume.Namespace, Name: parts[len(parts)-1]}
+			switch workload.Kind {
+			case deploymentKind, statefulSetKind, cronJobKind:
+			default:
+				notQuiescable = append(notQuiescable, volume.Namespace+"/"+kindName)
+				continue
+			}
+			if known[workload.key()] {
+				continue
+			}
+			known[workload.key()] = true
+			workloads = append(workloads, workload)
+		}
+	}
+	sort.Strings(notQuiescable)
+
+	return workloads, notQuiescable
+}
+
+// getQuiesceStatePath returns the path of the quiesce state file of the current cluster
+func (in *Installer) getQuiesceStatePath() (string, error) {
+	root, err := in.getBackupRootPath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(root, quiesceStateFile), nil
+}
+
+// readQuiesceState reads the quiesce state at path, returning an empty state if none exists
+func readQuiesceState(path string) (*quiesceState, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &quiesceState{Workloads: []quiescedWorkload{}}, nil
+		}
+		return nil, errors.WithStack(err)
+	}
+	state := &quiesceState{}
+	if err = json.Unmarshal(data, state); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return state, nil
+}
+
+// writeQuiesceState writes state to path
+func writeQuiesceState(path string, state *quiesceState) error {
+	data, err := json.MarshalIndent(state, "", "    ")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(os.WriteFile(path, data, 0600))
+}
+
+// QuiesceWorkloads scales the Deployments and StatefulSets using StorageOS volumes to zero and
+// suspends the CronJobs using them, then waits for their pods to stop. The original replicas and
+// suspension of the workloads are written to disk first. Workloads already part of a previous,
+// unresumed quiesce keep their recorded state. If quiescing fails, workloads are resumed.
+func (in *Installer) QuiesceWorkloads() error {
+	path, err := in.getQuiesceStatePath()
+	if err != nil {
+		return err
+	}
+	state, err := readQuiesceState(path)
+	if err != nil {
+		return err
+	}
+	report, err := in.storageOSWorkloadReport()
+	if err != nil {
+		return err
+	}
+	workloads, notQuiescable := quiescableWorkloads(report, state)
+	if len(notQuiescable) != 0 {
+		in.log.Warn(workloadReportMessage)
+		if err = in.printWorkloadReport(report, WorkloadsOutputTable); err != nil {
+			return err
+		}
+		return fmt.Errorf(errWorkloadsNotQuiescable, strings.Join(notQuiescable, ", "))
+	}
+
+	// record the state of every workload before any of them is changed
+	for i := range workloads {
+		if err = in.recordWorkloadState(&workloads[i]); err != nil {
+			return err
+		}
+	}
+	state.Workloads = append(state.Workloads, workloads...)
+	if err = writeQuiesceState(path, state); err != nil {
+		return err
+	}
+
+	if err = in.quiesceWorkloads(state); err != nil {
+		if resumeErr := in.resumeWorkloads(state); resumeErr != nil {
+			return fmt.Errorf(errResumeFailed, err, resumeErr, path)
+		}
+		if removeErr := os.Remove(path); removeErr != nil {
+			return errors.WithStack(removeErr)
+		}
+		return fmt.Errorf(errQuiesceFailed, err)
+	}
+	in.log.Successf(quiescedWorkloadsMessage, len(state.Workloads), path)
+
+	return nil
+}
+
+// recordWorkloadState sets the current replicas or suspension of workload
+func (in *Installer) recordWorkloadState(workload *quiescedWorkload) error {
+	var err error
+	switch workload.Kind {
+	case deploymentKind:
+		workload.Replicas, err = pluginutils.GetDeploymentReplicas(in.clientConfig, workload.Name, workload.Namespace)
+	case statefulSetKind:
+		workload.Replicas, err = pluginutils.GetStatefulSetReplicas(in.clientConfig, workload.Name, workload.Namespace)
+	case cronJobKind:
+		workload.Suspended, err = pluginutils.CronJobIsSuspended(in.clientConfig, workload.Name, workload.Namespace)
+	}
+	return err
+}
+
+// quiesceWorkloads scales down or suspends the workloads of state and waits for the pods using
+// StorageOS volumes to stop
+func (in *Installer) quiesceWorkloads(state *quiesceState) error {
+	for _, workload := range state.Workloads {
+		in.log.Infof(quiescingWorkloadMessage, workload.Kind, workload.Namespace, workload.Name)
+		var err error
+		switch workload.Kind {
+		case deploymentKind:
+			_, err = pluginutils.ScaleDeployment(in.clientConfig, workload.Name, workload.Namespace, 0)
+		case statefulSetKind:
+			_, err = pluginutils.ScaleStatefulSet(in.clientConfig, workload.Name, workload.Namespace, 0)
+		case cronJobKind:
+			_, err = pluginutils.SuspendCronJob(in.clientConfig, workload.Name, workload.Namespace, true)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	var report *workloadReport
+	err := pluginutils.WaitFor(func() error {
+		var err error
+		report, err = in.storageOSWorkloadReport()
+		if err != nil {
+			return err
+		}
+		if report.podCount() != 0 {
+			return fmt.Errorf("%d pod(s) using storageos volumes", report.podCount())
+		}
+		return nil
+	}, 300, 5)
+	if err != nil {
+		if report != nil && report.podCount() != 0 {
+			in.log.Warn(workloadReportMessage)
+			if printErr := in.printWorkloadReport(report, WorkloadsOutputTable); printErr != nil {
+				return printErr
+			}
+		}
+		return fmt.Errorf(errWorkloadsNotStopped, err)
+	}
+
+	return nil
+}
+
+// ResumeWorkloads restores the workloads quiesced by QuiesceWorkloads to their original replicas
+// and suspension, then removes the quiesce state from disk
+func (in *Installer) ResumeWorkloads() error {
+	path, err := in.getQuiesceStatePath()
+	if err != nil {
+		return err
+	}
+	state, err := readQuiesceState(path)
+	if err != nil {
+		return err
+	}
+	if len(state.Workloads) == 0 {
+		in.log.Success(noQuiescedStateMessage)
+		return nil
+	}
+	if err = in.resumeWorkloads(state); err != nil {
+		return err
+	}
+	if err = os.Remove(path); err != nil {
+		return errors.WithStack(err)
+	}
+	in.log.Successf(workloadsResumedMessage, len(state.Workloads))
+
+	return nil
+}
+
+// resumeWorkloads restores the workloads of state, carrying on past failures so that as many
+// workloads as possible are resumed
+func (in *Installer) resumeWorkloads(state *quiesceState) error {
+	errs := []string{}
+	for _, workload := range state.Workloads {
+		in.log.Infof(resumingWorkloadMessage, workload.Kind, workload.Namespace, workload.Name)
+		var err error
+		switch workload.Kind {
+		case deploymentKind:
+			_, err = pluginutils.ScaleDeployment(in.clientConfig, workload.Name, workload.Namespace, workload.Replicas)
+		case statefulSetKind:
+			_, err = pluginutils.ScaleStatefulSet(in.clientConfig, workload.Name, workload.Namespace, workload.Replicas)
+		case cronJobKind:
+			_, err = pluginutils.SuspendCronJob(in.clientConfig, workload.Name, workload.Namespace, workload.Suspended)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s %s/%s: %v", workload.Kind, workload.Namespace, workload.Name, err))
+		}
+	}
+	if len(errs) != 0 {
+		return errors.New(strings.Join(errs, "\n"))
+	}
+
+	return nil
+}
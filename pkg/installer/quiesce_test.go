@@ -0,0 +1,85 @@
+package installer
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestQuiescableWorkloads(t *testing.T) {
+	report := &workloadReport{Volumes: []volumeReport{
+		{Namespace: "batch", PVC: "reports", Workloads: []string{"CronJob/reports", "Pod/debug"}},
+		{Namespace: "default", PVC: "db", Workloads: []string{"StatefulSet/db"}},
+		{Namespace: "default", PVC: "logs", Workloads: []string{"DaemonSet/fluentd", "Deployment/web"}},
+		{Namespace: "default", PVC: "web", Workloads: []string{"Deployment/web"}},
+		{Namespace: "default", PVC: "idle", Workloads: []string{}},
+	}}
+
+	tcases := []struct {
+		name             string
+		state            *quiesceState
+		expWorkloads     []quiescedWorkload
+		expNotQuiescable []string
+	}{
+		{
+			name:  "no previous state",
+			state: &quiesceState{},
+			expWorkloads: []quiescedWorkload{
+				{Kind: cronJobKind, Namespace: "batch", Name: "reports"},
+				{Kind: statefulSetKind, Namespace: "default", Name: "db"},
+				{Kind: deploymentKind, Namespace: "default", Name: "web"},
+			},
+			expNotQuiescable: []string{"batch/Pod/debug", "default/DaemonSet/fluentd"},
+		},
+		{
+			name: "previous state kept",
+			state: &quiesceState{Workloads: []quiescedWorkload{
+				{Kind: deploymentKind, Namespace: "default", Name: "web", Replicas: 3},
+			}},
+			expWorkloads: []quiescedWorkload{
+				{Kind: cronJobKind, Namespace: "batch", Name: "reports"},
+				{Kind: statefulSetKind, Namespace: "default", Name: "db"},
+			},
+			expNotQuiescable: []string{"batch/Pod/debug", "default/DaemonSet/fluentd"},
+		},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			workloads, notQuiescable := quiescableWorkloads(report, tc.state)
+			if !reflect.DeepEqual(workloads, tc.expWorkloads) {
+				t.Errorf("expected workloads %+v, got %+v", tc.expWorkloads, workloads)
+			}
+			if !reflect.DeepEqual(notQuiescable, tc.expNotQuiescable) {
+				t.Errorf("expected not quiescable %v, got %v", tc.expNotQuiescable, notQuiescable)
+			}
+		})
+	}
+}
+
+func TestQuiesceState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cluster", quiesceStateFile)
+
+	state, err := readQuiesceState(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(state.Workloads) != 0 {
+		t.Errorf("expected empty state, got %+v", state)
+	}
+
+	state.Workloads = []quiescedWorkload{
+		{Kind: deploymentKind, Namespace: "default", Name: "web", Replicas: 3},
+		{Kind: cronJobKind, Namespace: "batch", Name: "reports", Suspended: true},
+	}
+	if err = writeQuiesceState(path, state); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	read, err := readQuiesceState(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(read, state) {
+		t.Errorf("expected %+v, got %+v", state, read)
+	}
+}
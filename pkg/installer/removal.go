@@ -0,0 +1,203 @@
+package installer
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/storageos/kubectl-storageos/pkg/consts"
+	pluginutils "github.com/storageos/kubectl-storageos/pkg/utils"
+	operatorapi "github.com/storageos/operator/api/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	// removalTimeout is how long the resources of an uninstalled StorageOS cluster have to be
+	// removed before the upgrade fails
+	removalTimeout  = 180
+	removalInterval = 2
+
+	errStorageOSRemovalStuck = `
+	StorageOS resources have not been removed within %d seconds, the new version has not been installed:
+	%s
+	Check the finalizers of the resources listed above and remove them once their controllers are gone.`
+
+	waitingForRemovalMessage = `Waiting for removal of %d StorageOS resource(s): %s`
+	storageOSRemovedMessage  = `StorageOS resources removed.`
+)
+
+var (
+	crdResourceGVR               = schema.GroupVersionResource{Group: "apiextensions.k8s.io", Version: "v1", Resource: "customresourcedefinitions"}
+	mutatingWebhookResourceGVR   = schema.GroupVersionResource{Group: "admissionregistration.k8s.io", Version: "v1", Resource: "mutatingwebhookconfigurations"}
+	validatingWebhookResourceGVR = schema.GroupVersionResource{Group: "admissionregistration.k8s.io", Version: "v1", Resource: "validatingwebhookconfigurations"}
+	deploymentResourceGVR        = schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
+	stosClusterResourceGVR       = operatorapi.GroupVersion.WithResource("storageosclusters")
+)
+
+// removalTarget is a resource of StorageOS to be removed by uninstall
+type removalTarget struct {
+	kind      string
+	resource  schema.GroupVersionResource
+	name      string
+	namespace string
+}
+
+// String returns kind/name of t, prefixed by its namespace if namespaced
+func (t removalTarget) String() string {
+	if t.namespace == "" {
+		return t.kind + "/" + t.name
+	}
+	return t.namespace + "/" + t.kind + "/" + t.name
+}
+
+// isStorageOSCRD returns true if crd defines a resource of a storageos.com API group
+func isStorageOSCRD(crd *unstructured.Unstructured) bool {
+	group, _, _ := unstructured.NestedString(crd.Object, "spec", "group")
+	return group == operatorapi.GroupVersion.Group || strings.HasSuffix(group, "."+operatorapi.GroupVersion.Group)
+}
+
+// storageOSRemovalTargets returns the StorageOSClusters, operator deployments, webhooks and CRDs of
+// StorageOS which still exist
+func (in *Installer) storageOSRemovalTargets() ([]removalTarget, error) {
+	targets := []removalTarget{}
+	listTargets := func(kind string, resource schema.GroupVersionResource, match func(*unstructured.Unstructured) bool) error {
+		objects, err := pluginutils.ListObjects(in.clientConfig, resource, "")
+		if err != nil {
+			// the resource is no longer served, storageosclusters once their CRD is removed
+			if kerrors.IsNotFound(err) {
+				return nil
+			}
+			return err
+		}
+		for i := range objects {
+			if match(&objects[i]) {
+				targets = append(targets, removalTarget{kind: kind, resource: resource, name: objects[i].GetName(), namespace: objects[i].GetNamespace()})
+			}
+		}
+		return nil
+	}
+	all := func(*unstructured.Unstructured) bool { return true }
+	isWebhook := func(kind string) func(*unstructured.Unstructured) bool {
+		return func(obj *unstructured.Unstructured) bool { return orphanReason(kind, obj) != "" }
+	}
+
+	if err := listTargets("StorageOSCluster", stosClusterResourceGVR, all); err != nil {
+		return nil, err
+	}
+	operatorNamespace := in.stosConfig.Spec.Uninstall.StorageOSOperatorNamespace
+	for _, name := range []string{consts.NewOperatorName, consts.OldOperatorName} {
+		if _, err := pluginutils.GetObject(in.clientConfig, deploymentResourceGVR, name, operatorNamespace); err != nil {
+			if kerrors.IsNotFound(err) {
+				continue
+			}
+			return nil, err
+		}
+		targets = append(targets, removalTarget{kind: "Deployment", resource: deploymentResourceGVR, name: name, namespace: operatorNamespace})
+	}
+	if err := listTargets("MutatingWebhookConfiguration", mutatingWebhookResourceGVR, isWebhook("MutatingWebhookConfiguration")); err != nil {
+		return nil, err
+	}
+	if err := listTargets("ValidatingWebhookConfiguration", validatingWebhookResourceGVR, isWebhook("ValidatingWebhookConfiguration")); err != nil {
+		return nil, err
+	}
+	if err := listTargets(crdKind, crdResourceGVR, isStorageOSCRD); err != nil {
+		return nil, err
+	}
+
+	return targets, nil
+}
+
+// remainingTargets returns the objects of targets which still exist
+func (in *Installer) remainingTargets(targets []removalTarget) ([]removalTarget, map[string]*unstructured.Unstructured, error) {
+	remaining := []removalTarget{}
+	objects := map[string]*unstructured.Unstructured{}
+	for _, target := range targets {
+		obj, err := pluginutils.GetObject(in.clientConfig, target.resource, target.name, target.namespace)
+		if err != nil {
+			if kerrors.IsNotFound(err) {
+				continue
+			}
+			return nil, nil, err
+		}
+		remaining = append(remaining, target)
+		objects[target.String()] = obj
+	}
+
+	return remaining, objects, nil
+}
+
+// waitForStorageOSRemoval waits for the StorageOS resources left by uninstall to be removed,
+// reporting progress as they go. If some are stuck, an error lists them with their finalizers.
+func (in *Installer) waitForStorageOSRemoval() error {
+	targets, err := in.storageOSRemovalTargets()
+	if err != nil {
+		return err
+	}
+
+	reported := -1
+	var remaining []removalTarget
+	var objects map[string]*unstructured.Unstructured
+	err = pluginutils.WaitFor(func() error {
+		var listErr error
+		remaining, objects, listErr = in.remainingTargets(targets)
+		if listErr != nil {
+			return listErr
+		}
+		if len(remaining) == 0 {
+			return nil
+		}
+		if len(remaining) != reported {
+			reported = len(remaining)
+			in.log.Warnf(waitingForRemovalMessage, len(remaining), joinTargets(remaining))
+		}
+		return fmt.Errorf(waitingForRemovalMessage, len(remaining), joinTargets(remaining))
+	}, removalTimeout, removalInterval)
+	if err != nil {
+		if len(remaining) == 0 {
+			return err
+		}
+		return fmt.Errorf(errStorageOSRemovalStuck, removalTimeout, removalStuckDetails(remaining, objects, time.Now()))
+	}
+	in.log.Success(storageOSRemovedMessage)
+
+	return nil
+}
+
+// joinTargets returns targets as a comma separated list
+func joinTargets(targets []removalTarget) string {
+	names := make([]string, 0, len(targets))
+	for _, target := range targets {
+		names = append(names, target.String())
+	}
+	return strings.Join(names, ", ")
+}
+
+// removalStuckDetails returns a line per target of remaining with its finalizers and how long it
+// has been deleted for, at now
+func removalStuckDetails(remaining []removalTarget, objects map[string]*unstructured.Unstructured, now time.Time) string {
+	lines := make([]string, 0, len(remaining))
+	for _, target := range remaining {
+		line := target.String()
+		obj, ok := objects[target.String()]
+		if !ok {
+			lines = append(lines, line)
+			continue
+		}
+		if deletion := obj.GetDeletionTimestamp(); deletion != nil {
+			line += fmt.Sprintf(": deleting for %s", now.Sub(deletion.Time).Round(time.Second))
+		} else {
+			line += ": not deleted"
+		}
+		if finalizers := obj.GetFinalizers(); len(finalizers) != 0 {
+			sorted := append([]string{}, finalizers...)
+			sort.Strings(sorted)
+			line += fmt.Sprintf(", finalizers [%s]", strings.Join(sorted, ", "))
+		}
+		lines = append(lines, line)
+	}
+
+	return strings.Join(lines, "\n\t")
+}
@@ -0,0 +1,58 @@
+package installer
+
+import (
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestIsStorageOSCRD(t *testing.T) {
+	tcases := []struct {
+		name   string
+		group  string
+		expCRD bool
+	}{
+		{name: "storageos group", group: "storageos.com", expCRD: true},
+		{name: "storageos subgroup", group: "api.storageos.com", expCRD: true},
+		{name: "etcd group", group: "etcd.improbable.io"},
+		{name: "lookalike group", group: "notstorageos.com"},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			crd := &unstructured.Unstructured{Object: map[string]interface{}{
+				"spec": map[string]interface{}{"group": tc.group},
+			}}
+			if isCRD := isStorageOSCRD(crd); isCRD != tc.expCRD {
+				t.Errorf("expected %t, got %t", tc.expCRD, isCRD)
+			}
+		})
+	}
+}
+
+func TestRemovalStuckDetails(t *testing.T) {
+	now := time.Date(2022, 1, 1, 12, 0, 0, 0, time.UTC)
+	deleting := &unstructured.Unstructured{Object: map[string]interface{}{}}
+	deletion := metav1.NewTime(now.Add(-90 * time.Second))
+	deleting.SetDeletionTimestamp(&deletion)
+	deleting.SetFinalizers([]string{"storageos.com/finalizer", "kubernetes"})
+	notDeleted := &unstructured.Unstructured{Object: map[string]interface{}{}}
+
+	cluster := removalTarget{kind: "StorageOSCluster", name: "storageos-cluster", namespace: "storageos"}
+	crd := removalTarget{kind: crdKind, name: "storageosclusters.storageos.com"}
+	webhook := removalTarget{kind: "MutatingWebhookConfiguration", name: "storageos-mutating-webhook"}
+	details := removalStuckDetails(
+		[]removalTarget{cluster, crd, webhook},
+		map[string]*unstructured.Unstructured{cluster.String(): deleting, crd.String(): notDeleted},
+		now,
+	)
+
+	expDetails := "storageos/StorageOSCluster/storageos-cluster: deleting for 1m30s, finalizers [kubernetes, storageos.com/finalizer]\n\t" +
+		"CustomResourceDefinition/storageosclusters.storageos.com: not deleted\n\t" +
+		"MutatingWebhookConfiguration/storageos-mutating-webhook"
+	if details != expDetails {
+		t.Errorf("expected details:\n%s\ngot:\n%s", expDetails, details)
+	}
+}
@@ -0,0 +1,273 @@
+package installer
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	gyaml "github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+	apiv1 "github.com/storageos/kubectl-storageos/api/v1"
+	"github.com/storageos/kubectl-storageos/pkg/logger"
+	pluginutils "github.com/storageos/kubectl-storageos/pkg/utils"
+	pluginversion "github.com/storageos/kubectl-storageos/pkg/version"
+	operatorapi "github.com/storageos/operator/api/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	kyaml "sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+const (
+	errStorageOSClusterExists = `
+	StorageOS cluster %s already exists in namespace %s, restore requires StorageOS to be uninstalled:
+
+	kubectl storageos uninstall`
+
+	errRestoreVersionUnknown = `
+	The StorageOS version of backup %s is unknown, set the version to install with --%s`
+
+	errRestoreVersionUnsupported = `
+	StorageOS %s cannot be restored, versions up to %s are installed by the deprecated cluster-operator.
+	Set a newer version to install with --%s`
+
+	restoringBackupMessage = `Restoring backup %s with StorageOS %s.`
+	restoredFileMessage    = `Restored %d object(s) of %s.`
+)
+
+// restoreFiles are the files of a backup applied before the storageos cluster, in order. Secrets are
+// restored ahead of the storageos cluster which references them.
+var restoreFiles = []string{stosConfigMapsFile, stosSecretsFile, csiSecretsFile, stosStorageClassFile}
+
+// restorePlan is a verified backup with the storageos version and cluster it restores
+type restorePlan struct {
+	backup          backup
+	version         string
+	cluster         *operatorapi.StorageOSCluster
+	clusterManifest string
+}
+
+// Restore reinstalls StorageOS from backup name. The operator version recorded in the backup is
+// installed, then the backed-up configmaps, secrets, storage classes and storageos cluster are
+// re-applied as they were when the backup was taken.
+func Restore(config *apiv1.KubectlStorageOSConfig, name string, log *logger.Logger) error {
+	backupInstaller, err := NewBackupInstaller(config, log)
+	if err != nil {
+		return err
+	}
+	root, err := backupInstaller.getBackupRootPath()
+	if err != nil {
+		return err
+	}
+	b, err := findBackup(root, name)
+	if err != nil {
+		return err
+	}
+	plan, err := backupInstaller.prepareRestore(b, config.Spec.Install.StorageOSVersion)
+	if err != nil {
+		return err
+	}
+	if err = backupInstaller.storageOSClusterMustNotExist(); err != nil {
+		return err
+	}
+
+	return installRestorePlan(config, plan, log)
+}
+
+// storageOSClusterMustNotExist returns an error if a storageos cluster exists
+func (in *Installer) storageOSClusterMustNotExist() error {
+	existingCluster, err := pluginutils.GetFirstStorageOSCluster(in.clientConfig)
+	if err == nil {
+		return fmt.Errorf(errStorageOSClusterExists, existingCluster.Name, existingCluster.Namespace)
+	}
+	if !kerrors.IsNotFound(err) {
+		return err
+	}
+
+	return nil
+}
+
+// prepareRestore verifies the integrity of b and decrypts its encrypted files, failing before
+// anything is installed, and returns the plan restoring it with version override if set
+func (in *Installer) prepareRestore(b backup, override string) (*restorePlan, error) {
+	statuses, err := verifyBackup(b)
+	if err != nil {
+		return nil, err
+	}
+	if err = backupIntegrityError(b, statuses); err != nil {
+		return nil, err
+	}
+
+	version, err := restoreVersion(b, override)
+	if err != nil {
+		return nil, err
+	}
+	// decrypt encrypted files before anything is installed, failing early if the key is not set
+	for _, file := range restoreFiles {
+		path := filepath.Join(b.path, file)
+		if !in.backupFileExists(path) {
+			continue
+		}
+		if _, err = in.readBackupFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	clusterManifest, err := in.onDiskFileSys.ReadFile(filepath.Join(b.path, stosClusterFile))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	cluster := &operatorapi.StorageOSCluster{}
+	if err = gyaml.Unmarshal(clusterManifest, cluster); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return &restorePlan{backup: b, version: version, cluster: cluster, clusterManifest: string(clusterManifest)}, nil
+}
+
+// installRestorePlan installs the storageos operator of plan and restores its backup
+func installRestorePlan(config *apiv1.KubectlStorageOSConfig, plan *restorePlan, log *logger.Logger) error {
+	log.Warnf(restoringBackupMessage, plan.backup.name, plan.version)
+
+	// the operator is installed from the manifests of the backed-up version, the storageos cluster
+	// is applied from the backup rather than the release manifests
+	pluginversion.SetOperatorLatestSupportedVersion(plan.version)
+	config.Spec.SkipStorageOSCluster = true
+	config.Spec.Install.StorageOSClusterNamespace = plan.cluster.Namespace
+
+	installer, err := NewInstaller(config, log)
+	if err != nil {
+		return err
+	}
+	if err = installer.installStorageOS(); err != nil {
+		return err
+	}
+
+	return installer.restoreBackup(plan.backup, plan.cluster, plan.clusterManifest)
+}
+
+// restoreVersion returns the storageos version to install for b, override if set
+func restoreVersion(b backup, override string) (string, error) {
+	version := getStringWithDefault(override, b.metadata.operatorVersion())
+	if version == "" || version == unknownStorageOSVersion {
+		return "", fmt.Errorf(errRestoreVersionUnknown, b.name, StosVersionFlag)
+	}
+	if pluginversion.IsDevelop(version) {
+		return version, nil
+	}
+
+	oldVersion, err := pluginversion.VersionIsLessThanOrEqual(version, pluginversion.ClusterOperatorLastVersion())
+	if err != nil {
+		return "", err
+	}
+	if oldVersion {
+		return "", fmt.Errorf(errRestoreVersionUnsupported, version, pluginversion.ClusterOperatorLastVersion(), StosVersionFlag)
+	}
+
+	return version, nil
+}
+
+// restoreBackup applies the files of b in the order of restoreFiles, validates the etcd endpoints of
+// cluster, applies clusterManifest and finally re-binds the persistent volumes of b, if any
+func (in *Installer) restoreBackup(b backup, cluster *operatorapi.StorageOSCluster, clusterManifest string) error {
+	for _, file := range restoreFiles {
+		if err := in.applyRestoreManifest(filepath.Join(b.path, file)); err != nil {
+			return err
+		}
+	}
+
+	if !in.stosConfig.Spec.Install.SkipEtcdEndpointsValidation {
+		validationSpec := etcdMigrationSpec(in.stosConfig.Spec, cluster.Namespace, cluster.Spec.KVBackend.Address, cluster.Spec.TLSEtcdSecretRefName)
+		if err := in.validateEtcd(validationSpec); err != nil {
+			return err
+		}
+	}
+
+	if err := in.applyRestoreManifest(filepath.Join(b.path, stosClusterFile)); err != nil {
+		return err
+	}
+	if err := in.restoreVolumes(b); err != nil {
+		return err
+	}
+	if !in.stosConfig.Spec.Install.Wait {
+		return nil
+	}
+
+	return in.waitForStorageOSClusterRunning()
+}
+
+// applyRestoreManifest applies every object of the multidoc manifest at path, creating their
+// namespaces if needed. Encrypted files are decrypted. A file missing from the backup had no
+// objects to back up and is skipped.
+func (in *Installer) applyRestoreManifest(path string) error {
+	if !in.backupFileExists(path) {
+		return nil
+	}
+	multidoc, err := in.readBackupFile(path)
+	if err != nil {
+		return err
+	}
+
+	manifests := splitMultiDoc(string(multidoc))
+	for _, manifest := range manifests {
+		manifest, err := restoreManifest(manifest)
+		if err != nil {
+			return err
+		}
+		namespace, err := pluginutils.GetFieldInManifest(manifest, "metadata", "namespace")
+		if err != nil {
+			return err
+		}
+		if namespace != "" {
+			if err = pluginutils.EnsureNamespace(in.clientConfig, namespace); err != nil {
+				return err
+			}
+		}
+		if err = in.kubectlClient.Apply(context.TODO(), "", manifest, true); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	in.log.Infof(restoredFileMessage, len(manifests), filepath.Base(path))
+
+	return nil
+}
+
+// restoreManifest returns manifest without the fields preventing it from being created again: the
+// resource version of the backed-up object and the finalizer added to it by upgrade
+func restoreManifest(manifest string) (string, error) {
+	obj, err := kyaml.Parse(manifest)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	metadata, err := obj.Pipe(kyaml.Lookup("metadata"))
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	if metadata == nil {
+		return obj.MustString(), nil
+	}
+	if _, err = metadata.Pipe(kyaml.Clear("resourceVersion")); err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	finalizers, err := metadata.Pipe(kyaml.Lookup("finalizers"))
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	if finalizers == nil {
+		return obj.MustString(), nil
+	}
+	kept := make([]*kyaml.Node, 0)
+	for _, finalizer := range finalizers.YNode().Content {
+		if finalizer.Value != stosFinalizer {
+			kept = append(kept, finalizer)
+		}
+	}
+	if len(kept) != 0 {
+		finalizers.YNode().Content = kept
+		return obj.MustString(), nil
+	}
+	if _, err = metadata.Pipe(kyaml.Clear("finalizers")); err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	return obj.MustString(), nil
+}
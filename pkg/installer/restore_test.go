@@ -0,0 +1,164 @@
+package installer
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRestoreVersion(t *testing.T) {
+	tcases := []struct {
+		name       string
+		backup     backup
+		override   string
+		expVersion string
+		expErr     bool
+	}{
+		{
+			name:       "version of metadata",
+			backup:     backup{name: "b", metadata: &backupMetadata{FormatVersion: backupFormatVersion, StorageOSVersion: "v2.6.0"}},
+			expVersion: "v2.6.0",
+		},
+		{
+			name:       "operator version differs from node image",
+			backup:     backup{name: "b", metadata: &backupMetadata{FormatVersion: backupFormatVersion, StorageOSVersion: "v2.6.0", NodeImage: "storageos/node:v2.6.1"}},
+			expVersion: "v2.6.0",
+		},
+		{
+			name:   "node image tag of format version 1",
+			backup: backup{name: "b", metadata: &backupMetadata{FormatVersion: 1, StorageOSVersion: "v2.6.1"}},
+			expErr: true,
+		},
+		{
+			name:       "node image tag of format version 1 with override",
+			backup:     backup{name: "b", metadata: &backupMetadata{FormatVersion: 1, StorageOSVersion: "v2.6.1"}},
+			override:   "v2.6.0",
+			expVersion: "v2.6.0",
+		},
+		{
+			name:       "override",
+			backup:     backup{name: "b", metadata: &backupMetadata{FormatVersion: backupFormatVersion, StorageOSVersion: "v2.6.0"}},
+			override:   "v2.7.0",
+			expVersion: "v2.7.0",
+		},
+		{
+			name:       "develop",
+			backup:     backup{name: "b", metadata: &backupMetadata{FormatVersion: backupFormatVersion, StorageOSVersion: "develop"}},
+			expVersion: "develop",
+		},
+		{
+			name:   "unknown",
+			backup: backup{name: "b", metadata: &backupMetadata{FormatVersion: backupFormatVersion, StorageOSVersion: unknownStorageOSVersion}},
+			expErr: true,
+		},
+		{
+			name:   "legacy",
+			backup: backup{name: legacyBackupName},
+			expErr: true,
+		},
+		{
+			name:       "legacy with override",
+			backup:     backup{name: legacyBackupName},
+			override:   "v2.6.0",
+			expVersion: "v2.6.0",
+		},
+		{
+			name:   "cluster-operator version",
+			backup: backup{name: "b", metadata: &backupMetadata{FormatVersion: backupFormatVersion, StorageOSVersion: "v2.4.4"}},
+			expErr: true,
+		},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			version, err := restoreVersion(tc.backup, tc.override)
+			if (err != nil) != tc.expErr {
+				t.Fatalf("expected error %v, got %v", tc.expErr, err)
+			}
+			if version != tc.expVersion {
+				t.Errorf("expected version %q, got %q", tc.expVersion, version)
+			}
+		})
+	}
+}
+
+func TestRestoreManifest(t *testing.T) {
+	tcases := []struct {
+		name        string
+		manifest    string
+		expManifest string
+	}{
+		{
+			name: "resource version and storageos finalizer removed",
+			manifest: `apiVersion: v1
+kind: Secret
+metadata:
+  name: storageos-api
+  namespace: storageos
+  resourceVersion: "1234"
+  finalizers:
+  - storageos.com/finalizer
+data:
+  username: c3RvcmFnZW9z
+`,
+			expManifest: `apiVersion: v1
+kind: Secret
+metadata:
+  name: storageos-api
+  namespace: storageos
+data:
+  username: c3RvcmFnZW9z
+`,
+		},
+		{
+			name: "other finalizers kept",
+			manifest: `apiVersion: storage.k8s.io/v1
+kind: StorageClass
+metadata:
+  name: storageos
+  finalizers:
+  - storageos.com/finalizer
+  - example.com/finalizer
+provisioner: csi.storageos.com
+`,
+			expManifest: `apiVersion: storage.k8s.io/v1
+kind: StorageClass
+metadata:
+  name: storageos
+  finalizers:
+    - example.com/finalizer
+provisioner: csi.storageos.com
+`,
+		},
+		{
+			name: "unchanged",
+			manifest: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: storageos-node
+  namespace: storageos
+data:
+  LOG_LEVEL: info
+`,
+			expManifest: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: storageos-node
+  namespace: storageos
+data:
+  LOG_LEVEL: info
+`,
+		},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			manifest, err := restoreManifest(tc.manifest)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if strings.TrimSpace(manifest) != strings.TrimSpace(tc.expManifest) {
+				t.Errorf("expected\n%s\ngot\n%s", tc.expManifest, manifest)
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package installer
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	apiv1 "github.com/storageos/kubectl-storageos/api/v1"
+	"github.com/storageos/kubectl-storageos/pkg/logger"
+	pluginversion "github.com/storageos/kubectl-storageos/pkg/version"
+)
+
+const (
+	errNoRollbackBackup = `
+	No backup taken by an upgrade from a StorageOS version other than %s found in %s.
+	List the backups with:
+	kubectl storageos backup list
+	and roll back to one of them with:
+	kubectl storageos rollback <backup-name>`
+
+	errRollbackSameVersion = `
+	Backup %s holds StorageOS %s, which is already installed.`
+
+	rollingBackMessage          = `Rolling back StorageOS %s to %s with backup %s taken on %s.`
+	rollbackCancelledMessage    = `Rollback cancelled, no changes have been made.`
+	rollbackNotInstalledMessage = `StorageOS is not installed, restoring StorageOS %s with backup %s taken on %s.`
+	rolledBackMessage           = `StorageOS rolled back to %s successfully.`
+)
+
+// isUpgradeBackup returns true if b has been taken by the upgrade subcommand. Backups written before
+// the subcommand was recorded are not, they can still be rolled back to by name.
+func isUpgradeBackup(b backup) bool {
+	return b.metadata != nil && b.metadata.Subcommand == backupSubcommandUpgrade
+}
+
+// latestUpgradeBackup returns the latest of backups, sorted oldest first, taken by an upgrade of a
+// StorageOS operator version other than currentVersion, false if there is none
+func latestUpgradeBackup(backups []backup, currentVersion string) (backup, bool) {
+	for i := len(backups) - 1; i >= 0; i-- {
+		b := backups[i]
+		if !isUpgradeBackup(b) {
+			continue
+		}
+		version := b.metadata.operatorVersion()
+		if version == "" || version == unknownStorageOSVersion || version == currentVersion {
+			continue
+		}
+		return b, true
+	}
+
+	return backup{}, false
+}
+
+// Rollback reinstalls the StorageOS version installed before the last upgrade, from backup name if
+// set or otherwise from the latest backup taken by an upgrade of a version other than
+// currentVersion. The backup is verified before anything is changed. The installed StorageOS
+// currentVersion, if any, is uninstalled once confirm returns true, then the operator version,
+// configmaps, secrets, storage classes and storageos cluster of the backup are restored. The
+// journal of an interrupted upgrade is removed, as that upgrade is abandoned.
+func Rollback(config *apiv1.KubectlStorageOSConfig, name, currentVersion string, confirm func() (bool, error), log *logger.Logger) error {
+	backupInstaller, err := NewBackupInstaller(config, log)
+	if err != nil {
+		return err
+	}
+	root, err := backupInstaller.getBackupRootPath()
+	if err != nil {
+		return err
+	}
+
+	var b backup
+	if name != "" {
+		if b, err = findBackup(root, name); err != nil {
+			return err
+		}
+	} else {
+		backups, err := listBackups(root)
+		if err != nil {
+			return err
+		}
+		var ok bool
+		if b, ok = latestUpgradeBackup(backups, currentVersion); !ok {
+			return fmt.Errorf(errNoRollbackBackup, currentVersion, filepath.Clean(root))
+		}
+	}
+
+	plan, err := backupInstaller.prepareRestore(b, config.Spec.Install.StorageOSVersion)
+	if err != nil {
+		return err
+	}
+	takenOn := b.createdAt().Format(time.RFC3339)
+
+	if currentVersion == "" {
+		// StorageOS has been uninstalled, by an interrupted upgrade for instance
+		if err = backupInstaller.storageOSClusterMustNotExist(); err != nil {
+			return err
+		}
+		log.Warnf(rollbackNotInstalledMessage, plan.version, b.name, takenOn)
+	} else {
+		isCurrent, err := pluginversion.VersionIsEqualTo(plan.version, currentVersion)
+		if err != nil {
+			return err
+		}
+		if isCurrent {
+			return fmt.Errorf(errRollbackSameVersion, b.name, plan.version)
+		}
+		log.Warnf(rollingBackMessage, currentVersion, plan.version, b.name, takenOn)
+	}
+
+	confirmed, err := confirm()
+	if err != nil {
+		return err
+	}
+	if !confirmed {
+		log.Warn(rollbackCancelledMessage)
+		return nil
+	}
+
+	if currentVersion != "" {
+		// uninstall takes a backup of the current version before removing it
+		uninstaller, err := NewUninstaller(config, log)
+		if err != nil {
+			return err
+		}
+		if err = uninstaller.Uninstall(false, currentVersion); err != nil {
+			return err
+		}
+		if err = uninstaller.waitForStorageOSRemoval(); err != nil {
+			return err
+		}
+	}
+
+	if err = installRestorePlan(config, plan, log); err != nil {
+		return err
+	}
+
+	if err = backupInstaller.deleteUpgradeJournal(); err != nil {
+		return err
+	}
+	log.Successf(rolledBackMessage, plan.version)
+
+	return nil
+}
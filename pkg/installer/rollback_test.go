@@ -0,0 +1,120 @@
+package installer
+
+import (
+	"testing"
+)
+
+func TestIsUpgradeBackup(t *testing.T) {
+	tcases := []struct {
+		name       string
+		command    string
+		subcommand string
+		expUp      bool
+	}{
+		{
+			name:       "upgrade",
+			command:    "kubectl-storageos upgrade --stos-version v2.8.0",
+			subcommand: backupSubcommandUpgrade,
+			expUp:      true,
+		},
+		{
+			name:       "upgrade after flags with values",
+			command:    "kubectl-storageos --config-path /x upgrade",
+			subcommand: backupSubcommandUpgrade,
+			expUp:      true,
+		},
+		{
+			name:       "uninstall",
+			command:    "kubectl-storageos uninstall --skip-namespace-deletion",
+			subcommand: backupSubcommandUninstall,
+		},
+		{
+			name:    "subcommand not recorded",
+			command: "kubectl-storageos upgrade",
+		},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			b := backup{name: "b", metadata: &backupMetadata{Command: tc.command, Subcommand: tc.subcommand}}
+			if up := isUpgradeBackup(b); up != tc.expUp {
+				t.Errorf("expected %v, got %v", tc.expUp, up)
+			}
+		})
+	}
+
+	if isUpgradeBackup(backup{name: "legacy"}) {
+		t.Error("expected legacy backup not to be an upgrade backup")
+	}
+}
+
+func TestLatestUpgradeBackup(t *testing.T) {
+	upgradeBackup := func(name, version string) backup {
+		return backup{name: name, metadata: &backupMetadata{
+			FormatVersion:    backupFormatVersion,
+			StorageOSVersion: version,
+			NodeImage:        "storageos/node:v2.9.0",
+			Subcommand:       backupSubcommandUpgrade,
+		}}
+	}
+
+	tcases := []struct {
+		name           string
+		backups        []backup
+		currentVersion string
+		expName        string
+		expFound       bool
+	}{
+		{
+			name:           "latest upgrade backup",
+			backups:        []backup{upgradeBackup("a", "v2.5.0"), upgradeBackup("b", "v2.6.0")},
+			currentVersion: "v2.7.0",
+			expName:        "b",
+			expFound:       true,
+		},
+		{
+			name: "uninstall backups skipped",
+			backups: []backup{
+				upgradeBackup("a", "v2.6.0"),
+				{name: "b", metadata: &backupMetadata{FormatVersion: backupFormatVersion, StorageOSVersion: "v2.6.0", Subcommand: backupSubcommandUninstall}},
+			},
+			currentVersion: "v2.7.0",
+			expName:        "a",
+			expFound:       true,
+		},
+		{
+			name:           "current version skipped",
+			backups:        []backup{upgradeBackup("a", "v2.6.0"), upgradeBackup("b", "v2.7.0")},
+			currentVersion: "v2.7.0",
+			expName:        "a",
+			expFound:       true,
+		},
+		{
+			name: "node image tag of older backups skipped",
+			backups: []backup{
+				upgradeBackup("a", "v2.6.0"),
+				{name: "b", metadata: &backupMetadata{FormatVersion: 1, StorageOSVersion: "v2.5.0", Subcommand: backupSubcommandUpgrade}},
+			},
+			currentVersion: "v2.7.0",
+			expName:        "a",
+			expFound:       true,
+		},
+		{
+			name:           "unknown version skipped",
+			backups:        []backup{upgradeBackup("a", unknownStorageOSVersion), {name: "legacy"}},
+			currentVersion: "v2.7.0",
+		},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			b, found := latestUpgradeBackup(tc.backups, tc.currentVersion)
+			if found != tc.expFound {
+				t.Fatalf("expected found %v, got %v", tc.expFound, found)
+			}
+			if b.name != tc.expName {
+				t.Errorf("expected backup %q, got %q", tc.expName, b.name)
+			}
+		})
+	}
+}
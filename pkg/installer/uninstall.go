@@ -34,15 +34,17 @@ const (
 	StorageOS components exist in protected namespace %s, you must enable --skip-namespace-deletion to uninstall successfully.`
 
 	errPVCsExist = `
-	Discovered bound PVC [%s/%s] provisioned by StorageOS storageclass provisioner [` + stosSCProvisioner + `].
+	Discovered %d bound PVC(s) provisioned by StorageOS storageclass provisioner [` + stosSCProvisioner + `], listed above.
 	No PVCs should be bound to StorageOS volumes before uninstalling ETCD.
 	Re-run with --skip-existing-workload-check to ignore.`
 
 	errWorkloadsExist = `
-	Discovered workload [%s/%s] using PVC provisioned by StorageOS storageclass provisioner [` + stosSCProvisioner + `].
+	Discovered %d pod(s) using %d PVC(s) provisioned by StorageOS storageclass provisioner [` + stosSCProvisioner + `], listed above.
 	All workloads that rely on StorageOS volumes should be stopped before uninstalling StorageOS.
 	Re-run with --skip-existing-workload-check to ignore.`
 
+	workloadReportMessage = `StorageOS volumes in use:`
+
 	removingFinalizersMessage = `Attempting to remove any existing finalizers from object [%s] to allow object deletion.`
 
 	errDuringStosUninstall = `
@@ -62,14 +64,14 @@ var protectedNamespaces = map[string]bool{
 // Uninstall performs storageos and etcd uninstallation for kubectl-storageos. Bool 'upgrade'
 // indicates whether or not this uninstallation is part of an upgrade.
 func (in *Installer) Uninstall(upgrade bool, currentVersion string) error {
-	stosPVCs := &corev1.PersistentVolumeClaimList{}
+	report := &workloadReport{}
 	var err error
 	if !in.stosConfig.Spec.SkipExistingWorkloadCheck {
-		stosPVCs, err = in.storageOSPVCs()
+		report, err = in.storageOSWorkloadReport()
 		if err != nil {
 			return fmt.Errorf("failed to get pvcs - %s - %w ", errStosUninstallAborted, err)
 		}
-		if err := in.storageOSWorkloadsExist(stosPVCs); err != nil {
+		if err := in.storageOSWorkloadsExist(report); err != nil {
 			return fmt.Errorf("PVC is in use - %s - %w ", errStosUninstallAborted, err)
 		}
 	}
@@ -89,8 +91,12 @@ func (in *Installer) Uninstall(upgrade bool, currentVersion string) error {
 	}
 
 	if in.stosConfig.Spec.IncludeEtcd {
-		if !in.stosConfig.Spec.SkipExistingWorkloadCheck && len(stosPVCs.Items) > 0 {
-			return errors.Wrap(fmt.Errorf(errPVCsExist, stosPVCs.Items[0].Namespace, stosPVCs.Items[0].Name), errEtcdUninstallAborted)
+		if !in.stosConfig.Spec.SkipExistingWorkloadCheck && len(report.Volumes) > 0 {
+			in.log.Warn(workloadReportMessage)
+			if err := in.printWorkloadReport(report, WorkloadsOutputTable); err != nil {
+				return err
+			}
+			return errors.Wrap(fmt.Errorf(errPVCsExist, len(report.Volumes)), errEtcdUninstallAborted)
 		}
 
 		wg.Add(1)
@@ -217,9 +223,14 @@ func (in *Installer) uninstallStorageOSCluster(upgrade bool) error {
 
 	// if this is not an upgrade, write manifests to disk before deletion
 	if !upgrade {
-		if err = in.writeBackupFileSystem(in.storageOSCluster); err != nil {
+		if _, err = in.writeBackupFileSystem(in.storageOSCluster, backupSubcommandUninstall); err != nil {
 			return errors.WithStack(err)
 		}
+		if in.stosConfig.Spec.Uninstall.RetainVolumes {
+			if err = in.retainStorageOSVolumes(); err != nil {
+				return err
+			}
+		}
 	}
 
 	if !in.stosConfig.Spec.SkipNamespaceDeletion {
@@ -377,21 +388,19 @@ func (in *Installer) storageOSPVCs() (*corev1.PersistentVolumeClaimList, error)
 	return stosPVCs, nil
 }
 
-// storageOSWorkloadsExist return error if a pod is discovered using a storageos pvc.
-func (in *Installer) storageOSWorkloadsExist(stosPVCs *corev1.PersistentVolumeClaimList) error {
-	pods, err := pluginutils.ListPods(in.clientConfig, "", "")
-	if err != nil {
-		return err
+// storageOSWorkloadsExist returns error if a pod is discovered using a storageos pvc, after
+// printing report.
+func (in *Installer) storageOSWorkloadsExist(report *workloadReport) error {
+	pods := report.podCount()
+	if pods == 0 {
+		return nil
 	}
-	for _, pod := range pods.Items {
-		for _, stosPVC := range stosPVCs.Items {
-			if pluginutils.PodHasPVC(&pod, stosPVC.Name) {
-				return fmt.Errorf(errWorkloadsExist, pod.Namespace, pod.Name)
-			}
-		}
+	in.log.Warn(workloadReportMessage)
+	if err := in.printWorkloadReport(report, WorkloadsOutputTable); err != nil {
+		return err
 	}
 
-	return nil
+	return fmt.Errorf(errWorkloadsExist, pods, report.volumesInUse())
 }
 
 // kustomizeAndDelete performs the following in the order described:
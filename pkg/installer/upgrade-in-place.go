@@ -0,0 +1,436 @@
+package installer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	gyaml "github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+	apiv1 "github.com/storageos/kubectl-storageos/api/v1"
+	"github.com/storageos/kubectl-storageos/pkg/logger"
+	pluginutils "github.com/storageos/kubectl-storageos/pkg/utils"
+	pluginversion "github.com/storageos/kubectl-storageos/pkg/version"
+	operatorapi "github.com/storageos/operator/api/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/kustomize/api/krusty"
+)
+
+const (
+	// fieldManager owns the fields of the operator resources applied by an in-place upgrade
+	fieldManager = "kubectl-storageos"
+
+	errNoCRDStorageVersion = `
+	CRD %s has no storage version.`
+
+	inPlaceUpgradeMessage   = `Upgrading StorageOS operator in place from %s to %s.`
+	reinstallUpgradeMessage = `StorageOS will be uninstalled and reinstalled, as %s.`
+	quiesceNotNeededMessage = `Workloads are not quiesced, StorageOS volumes remain available during an in-place upgrade.`
+	crdMigratedMessage      = `Migrated %d object(s) of CRD %s to storage version %s.`
+	staleResourcesMessage   = `Removing operator resources which are not part of StorageOS %s: %s`
+	clusterRolloutMessage   = `StorageOS cluster %s is rolled out by the new operator.`
+	keptImagesMessage       = `Kept the images set in StorageOS cluster %s, which are not defaults of the previous operator: %s`
+)
+
+// relatedImageFields maps the related image environment variables of the operator, which set its
+// default images, to the fields of the images of the storageos cluster overriding them
+var relatedImageFields = map[string]string{
+	"RELATED_IMAGE_STORAGEOS_NODE":              "nodeContainer",
+	"RELATED_IMAGE_STORAGEOS_INIT":              "initContainer",
+	"RELATED_IMAGE_CSIV1_NODE_DRIVER_REGISTRAR": "csiNodeDriverRegistrarContainer",
+	"RELATED_IMAGE_CSIV1_EXTERNAL_PROVISIONER":  "csiExternalProvisionerContainer",
+	"RELATED_IMAGE_CSIV1_EXTERNAL_ATTACHER_V3":  "csiExternalAttacherContainer",
+	"RELATED_IMAGE_CSIV1_EXTERNAL_RESIZER":      "csiExternalResizerContainer",
+	"RELATED_IMAGE_CSIV1_LIVENESS_PROBE":        "csiLivenessProbeContainer",
+	"RELATED_IMAGE_KUBE_SCHEDULER":              "kubeSchedulerContainer",
+	"RELATED_IMAGE_API_MANAGER":                 "apiManagerContainer",
+	"RELATED_IMAGE_NODE_MANAGER":                "nodeManagerContainer",
+	"RELATED_IMAGE_UPGRADE_GUARD":               "upgradeGuardContainer",
+	"RELATED_IMAGE_PORTAL_MANAGER":              "portalManagerContainer",
+	"RELATED_IMAGE_METRICS_EXPORTER":            "metricsExporterContainer",
+}
+
+// inPlaceUpgradeBlockers returns the reasons why StorageOS cannot be upgraded in place from
+// versionToUninstall to the version of installConfig, empty if it can. The existing
+// storageOSCluster is kept by an in-place upgrade, so settings which only apply to a new cluster
+// require a reinstall.
+func inPlaceUpgradeBlockers(uninstallConfig, installConfig *apiv1.KubectlStorageOSConfig, storageOSCluster *operatorapi.StorageOSCluster, versionToUninstall string) ([]string, error) {
+	reasons := []string{}
+	if uninstallConfig.Spec.Reinstall {
+		reasons = append(reasons, "a reinstall has been requested")
+	}
+
+	versionToInstall := installConfig.Spec.Install.StorageOSVersion
+	supported, err := pluginversion.InPlaceUpgradeSupported(versionToUninstall, versionToInstall)
+	if err != nil {
+		return nil, err
+	}
+	if !supported {
+		reasons = append(reasons, fmt.Sprintf("the operator of %s cannot be upgraded in place to %s", versionToUninstall, versionToInstall))
+	}
+
+	install := installConfig.Spec.Install
+	if install.StorageOSOperatorNamespace != uninstallConfig.Spec.Uninstall.StorageOSOperatorNamespace {
+		reasons = append(reasons, "the operator namespace changes")
+	}
+	clusterNamespace := storageOSCluster.Spec.Namespace
+	if clusterNamespace == "" {
+		clusterNamespace = storageOSCluster.Namespace
+	}
+	if install.StorageOSClusterNamespace != "" && install.StorageOSClusterNamespace != clusterNamespace {
+		reasons = append(reasons, "the storageos cluster namespace changes")
+	}
+	if install.StorageOSClusterYaml != "" {
+		reasons = append(reasons, "a storageos cluster manifest is set")
+	}
+	if install.EtcdEndpoints != "" && install.EtcdEndpoints != storageOSCluster.Spec.KVBackend.Address {
+		reasons = append(reasons, "the etcd endpoints change")
+	}
+	if install.EtcdTLSEnabled {
+		reasons = append(reasons, "etcd TLS is set")
+	}
+	if install.AdminUsername != "" || install.AdminPassword != "" {
+		reasons = append(reasons, "the admin credentials change")
+	}
+	if install.EnablePortalManager {
+		reasons = append(reasons, "portal manager is enabled")
+	}
+	if install.EnableMetrics != nil {
+		reasons = append(reasons, "metrics exporter is set")
+	}
+
+	return reasons, nil
+}
+
+// upgradeInPlace upgrades the StorageOS operator from versionToUninstall to the version of
+// installer by applying the new operator manifests over the old ones with server-side apply. CRDs
+// are migrated to their new storage version and the operator resources dropped by the new version
+// are removed. The storageos cluster is kept and rolled out by the new operator.
+func upgradeInPlace(uninstallConfig *apiv1.KubectlStorageOSConfig, installer *Installer, versionToUninstall string, log *logger.Logger) error {
+	installConfig := installer.stosConfig
+	uninstaller, err := NewUninstaller(uninstallConfig, log)
+	if err != nil {
+		return err
+	}
+	// write storageoscluster, secret and storageclass manifests to disk, for rollback
+	if _, err = uninstaller.writeBackupFileSystem(uninstaller.storageOSCluster, backupSubcommandUpgrade); err != nil {
+		return errors.WithStack(err)
+	}
+
+	log.Warnf(inPlaceUpgradeMessage, versionToUninstall, installConfig.Spec.Install.StorageOSVersion)
+	if uninstallConfig.Spec.QuiesceWorkloads {
+		log.Warn(quiesceNotNeededMessage)
+	}
+
+	oldManifest, err := uninstaller.kustomizeOperator(uninstallConfig.Spec.Uninstall.StorageOSOperatorNamespace)
+	if err != nil {
+		return err
+	}
+	newManifest, err := installer.kustomizeOperator(installConfig.Spec.Install.StorageOSOperatorNamespace)
+	if err != nil {
+		return err
+	}
+
+	if err = pluginutils.ServerSideApply(installer.clientConfig, newManifest, fieldManager); err != nil {
+		return err
+	}
+	if err = installer.migrateCRDs(newManifest); err != nil {
+		return err
+	}
+	if err = installer.operatorDeploymentsAreReady(filepath.Join(stosDir, operatorDir, stosOperatorFile)); err != nil {
+		return err
+	}
+	if err = installer.operatorServicesAreReady(filepath.Join(stosDir, operatorDir, stosOperatorFile)); err != nil {
+		return err
+	}
+
+	stale, err := staleOperatorManifests(oldManifest, newManifest)
+	if err != nil {
+		return err
+	}
+	if len(stale) != 0 {
+		names := make([]string, 0, len(stale))
+		for _, manifest := range stale {
+			names = append(names, manifestID(manifest))
+		}
+		log.Warnf(staleResourcesMessage, installConfig.Spec.Install.StorageOSVersion, strings.Join(names, ", "))
+		if err = installer.kubectlClient.Delete(context.TODO(), "", makeMultiDoc(stale...), true); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	if installConfig.Spec.SkipStorageOSCluster {
+		return nil
+	}
+	if err = installer.rollOutStorageOSCluster(uninstaller.storageOSCluster, oldManifest); err != nil {
+		return err
+	}
+	if installConfig.Spec.Install.Wait {
+		return installer.waitForStorageOSClusterRunning()
+	}
+
+	return nil
+}
+
+// kustomizeOperator builds the operator manifests of the in-memory fs in namespace, writes them
+// back to the fs and returns them
+func (in *Installer) kustomizeOperator(namespace string) (string, error) {
+	if err := in.setFieldInFsManifest(filepath.Join(stosDir, operatorDir, kustomizationFile), namespace, "namespace", ""); err != nil {
+		return "", err
+	}
+	kustomizer := krusty.MakeKustomizer(krusty.MakeDefaultOptions())
+	resMap, err := kustomizer.Run(in.fileSys, filepath.Join(stosDir, operatorDir))
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	resYaml, err := resMap.AsYaml()
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	if err = in.fileSys.WriteFile(filepath.Join(stosDir, operatorDir, stosOperatorFile), resYaml); err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	return string(resYaml), nil
+}
+
+// migrateCRDs migrates the objects of every CRD of manifest to the storage version of the CRD
+func (in *Installer) migrateCRDs(manifest string) error {
+	for _, doc := range splitMultiDoc(manifest) {
+		kind, err := pluginutils.GetFieldInManifest(doc, "kind")
+		if err != nil {
+			return err
+		}
+		if kind != crdKind {
+			continue
+		}
+		name, err := pluginutils.GetFieldInManifest(doc, "metadata", "name")
+		if err != nil {
+			return err
+		}
+		if err = in.migrateCRDStorage(name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// migrateCRDStorage rewrites the objects of CRD name stored at versions other than its storage
+// version, then drops those versions from the stored versions of the CRD, so that they can be
+// removed by later versions of the operator
+func (in *Installer) migrateCRDStorage(name string) error {
+	crd, err := pluginutils.GetObject(in.clientConfig, crdResourceGVR, name, "")
+	if err != nil {
+		return err
+	}
+	storageVersion, stale, err := crdStorageVersion(crd)
+	if err != nil {
+		return err
+	}
+	if len(stale) == 0 {
+		return nil
+	}
+
+	group, _, _ := unstructured.NestedString(crd.Object, "spec", "group")
+	plural, _, _ := unstructured.NestedString(crd.Object, "spec", "names", "plural")
+	resource := schema.GroupVersionResource{Group: group, Version: storageVersion, Resource: plural}
+	objects, err := pluginutils.ListObjects(in.clientConfig, resource, "")
+	if err != nil {
+		return err
+	}
+	for _, obj := range objects {
+		objName, objNamespace := obj.GetName(), obj.GetNamespace()
+		// an update without changes stores the object at the storage version
+		err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			latest, err := pluginutils.GetObject(in.clientConfig, resource, objName, objNamespace)
+			if err != nil {
+				return err
+			}
+			return pluginutils.UpdateObject(in.clientConfig, resource, latest)
+		})
+		if err != nil && !kerrors.IsNotFound(err) {
+			return err
+		}
+	}
+
+	patch, err := json.Marshal(map[string]interface{}{
+		"status": map[string]interface{}{"storedVersions": []string{storageVersion}},
+	})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if err = pluginutils.PatchObject(in.clientConfig, crdResourceGVR, name, "", types.MergePatchType, patch, "status"); err != nil {
+		return err
+	}
+	in.log.Infof(crdMigratedMessage, len(objects), name, storageVersion)
+
+	return nil
+}
+
+// crdStorageVersion returns the storage version of crd and its other stored versions
+func crdStorageVersion(crd *unstructured.Unstructured) (string, []string, error) {
+	versions, _, err := unstructured.NestedSlice(crd.Object, "spec", "versions")
+	if err != nil {
+		return "", nil, errors.WithStack(err)
+	}
+	storageVersion := ""
+	for _, version := range versions {
+		v, ok := version.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if storage, _ := v["storage"].(bool); storage {
+			storageVersion, _ = v["name"].(string)
+		}
+	}
+	if storageVersion == "" {
+		return "", nil, fmt.Errorf(errNoCRDStorageVersion, crd.GetName())
+	}
+
+	storedVersions, _, err := unstructured.NestedStringSlice(crd.Object, "status", "storedVersions")
+	if err != nil {
+		return "", nil, errors.WithStack(err)
+	}
+	stale := []string{}
+	for _, stored := range storedVersions {
+		if stored != storageVersion {
+			stale = append(stale, stored)
+		}
+	}
+
+	return storageVersion, stale, nil
+}
+
+// staleOperatorManifests returns the manifests of oldManifest which are not part of newManifest.
+// Namespaces and CRDs are never stale, as their removal would delete the storageos cluster.
+func staleOperatorManifests(oldManifest, newManifest string) ([]string, error) {
+	current := map[string]bool{}
+	for _, doc := range splitMultiDoc(newManifest) {
+		if strings.TrimSpace(doc) == "" {
+			continue
+		}
+		current[manifestID(doc)] = true
+	}
+
+	stale := []string{}
+	for _, doc := range splitMultiDoc(oldManifest) {
+		if strings.TrimSpace(doc) == "" {
+			continue
+		}
+		kind, err := pluginutils.GetFieldInManifest(doc, "kind")
+		if err != nil {
+			return nil, err
+		}
+		if kind == "Namespace" || kind == crdKind {
+			continue
+		}
+		if !current[manifestID(doc)] {
+			stale = append(stale, doc)
+		}
+	}
+
+	return stale, nil
+}
+
+// manifestID returns the kind, namespace and name of manifest, as namespace/kind/name if namespaced
+func manifestID(manifest string) string {
+	kind, _ := pluginutils.GetFieldInManifest(manifest, "kind")
+	name, _ := pluginutils.GetFieldInManifest(manifest, "metadata", "name")
+	namespace, _ := pluginutils.GetFieldInManifest(manifest, "metadata", "namespace")
+
+	return removalTarget{kind: kind, name: name, namespace: namespace}.String()
+}
+
+// rollOutStorageOSCluster removes the images of storageOSCluster which are defaults of the old
+// operator of oldManifest, so that the new operator rolls the cluster out with its own images.
+// Images set to anything else are user overrides and kept.
+func (in *Installer) rollOutStorageOSCluster(storageOSCluster *operatorapi.StorageOSCluster, oldManifest string) error {
+	obj, err := pluginutils.GetObject(in.clientConfig, stosClusterResourceGVR, storageOSCluster.Name, storageOSCluster.Namespace)
+	if err != nil {
+		return err
+	}
+	images, found, _ := unstructured.NestedStringMap(obj.Object, "spec", "images")
+	if found {
+		defaults, err := operatorDefaultImages(oldManifest)
+		if err != nil {
+			return err
+		}
+		patch, kept, err := defaultImagesPatch(images, defaults)
+		if err != nil {
+			return err
+		}
+		if patch != nil {
+			if err = pluginutils.PatchObject(in.clientConfig, stosClusterResourceGVR, storageOSCluster.Name, storageOSCluster.Namespace, types.MergePatchType, patch); err != nil {
+				return err
+			}
+		}
+		if len(kept) != 0 {
+			in.log.Warnf(keptImagesMessage, storageOSCluster.Name, strings.Join(kept, ", "))
+		}
+	}
+	in.log.Warnf(clusterRolloutMessage, storageOSCluster.Name)
+
+	return nil
+}
+
+// operatorDefaultImages returns the default images of the operator of manifest, set by the related
+// image environment variables of its configmaps, by the storageos cluster image field they fill
+func operatorDefaultImages(manifest string) (map[string]string, error) {
+	defaults := map[string]string{}
+	for _, doc := range splitMultiDoc(manifest) {
+		kind, err := pluginutils.GetFieldInManifest(doc, "kind")
+		if err != nil {
+			return nil, err
+		}
+		if kind != "ConfigMap" {
+			continue
+		}
+		configMap := &corev1.ConfigMap{}
+		if err = gyaml.Unmarshal([]byte(doc), configMap); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		for envVar, image := range configMap.Data {
+			if field, ok := relatedImageFields[envVar]; ok {
+				defaults[field] = image
+			}
+		}
+	}
+
+	return defaults, nil
+}
+
+// defaultImagesPatch returns the merge patch removing the images of a storageos cluster which match
+// defaults, nil if none does, and the sorted fields of the images kept
+func defaultImagesPatch(images, defaults map[string]string) ([]byte, []string, error) {
+	removed := map[string]interface{}{}
+	kept := []string{}
+	for field, image := range images {
+		if image == "" {
+			continue
+		}
+		if defaults[field] == image {
+			removed[field] = nil
+			continue
+		}
+		kept = append(kept, field)
+	}
+	sort.Strings(kept)
+	if len(removed) == 0 {
+		return nil, kept, nil
+	}
+	patch, err := json.Marshal(map[string]interface{}{"spec": map[string]interface{}{"images": removed}})
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+
+	return patch, kept, nil
+}
@@ -0,0 +1,222 @@
+package installer
+
+import (
+	"reflect"
+	"testing"
+
+	apiv1 "github.com/storageos/kubectl-storageos/api/v1"
+	operatorapi "github.com/storageos/operator/api/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestInPlaceUpgradeBlockers(t *testing.T) {
+	cluster := &operatorapi.StorageOSCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "storageos", Namespace: "storageos"},
+		Spec:       operatorapi.StorageOSClusterSpec{KVBackend: operatorapi.StorageOSClusterKVBackend{Address: "etcd:2379"}},
+	}
+	enableMetrics := true
+
+	tcases := []struct {
+		name       string
+		reinstall  bool
+		fromVer    string
+		install    apiv1.Install
+		expReasons int
+	}{
+		{
+			name:    "in place",
+			fromVer: "v2.6.0",
+			install: apiv1.Install{StorageOSVersion: "v2.7.0", StorageOSOperatorNamespace: "storageos", StorageOSClusterNamespace: "storageos", EtcdEndpoints: "etcd:2379"},
+		},
+		{
+			name:       "reinstall requested",
+			reinstall:  true,
+			fromVer:    "v2.6.0",
+			install:    apiv1.Install{StorageOSVersion: "v2.7.0", StorageOSOperatorNamespace: "storageos"},
+			expReasons: 1,
+		},
+		{
+			name:       "crossing cluster operator last version",
+			fromVer:    "v2.4.4",
+			install:    apiv1.Install{StorageOSVersion: "v2.5.0", StorageOSOperatorNamespace: "storageos"},
+			expReasons: 1,
+		},
+		{
+			name:    "new cluster settings",
+			fromVer: "v2.6.0",
+			install: apiv1.Install{
+				StorageOSVersion:           "v2.7.0",
+				StorageOSOperatorNamespace: "storageos-operator",
+				StorageOSClusterNamespace:  "other",
+				EtcdEndpoints:              "other:2379",
+				AdminPassword:              "password",
+				EnableMetrics:              &enableMetrics,
+			},
+			expReasons: 5,
+		},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			uninstallConfig := &apiv1.KubectlStorageOSConfig{Spec: apiv1.KubectlStorageOSConfigSpec{
+				Reinstall: tc.reinstall,
+				Uninstall: apiv1.Uninstall{StorageOSOperatorNamespace: "storageos"},
+			}}
+			installConfig := &apiv1.KubectlStorageOSConfig{Spec: apiv1.KubectlStorageOSConfigSpec{Install: tc.install}}
+
+			reasons, err := inPlaceUpgradeBlockers(uninstallConfig, installConfig, cluster, tc.fromVer)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(reasons) != tc.expReasons {
+				t.Errorf("expected %d reasons, got %v", tc.expReasons, reasons)
+			}
+		})
+	}
+}
+
+func TestCRDStorageVersion(t *testing.T) {
+	tcases := []struct {
+		name       string
+		crd        map[string]interface{}
+		expVersion string
+		expStale   []string
+		expErr     bool
+	}{
+		{
+			name: "migrated",
+			crd: map[string]interface{}{
+				"spec":   map[string]interface{}{"versions": []interface{}{map[string]interface{}{"name": "v1", "storage": true}}},
+				"status": map[string]interface{}{"storedVersions": []interface{}{"v1"}},
+			},
+			expVersion: "v1",
+			expStale:   []string{},
+		},
+		{
+			name: "stored at older version",
+			crd: map[string]interface{}{
+				"spec": map[string]interface{}{"versions": []interface{}{
+					map[string]interface{}{"name": "v1alpha1", "storage": false},
+					map[string]interface{}{"name": "v1", "storage": true},
+				}},
+				"status": map[string]interface{}{"storedVersions": []interface{}{"v1alpha1", "v1"}},
+			},
+			expVersion: "v1",
+			expStale:   []string{"v1alpha1"},
+		},
+		{
+			name: "no storage version",
+			crd: map[string]interface{}{
+				"spec": map[string]interface{}{"versions": []interface{}{map[string]interface{}{"name": "v1"}}},
+			},
+			expErr: true,
+		},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			version, stale, err := crdStorageVersion(&unstructured.Unstructured{Object: tc.crd})
+			if (err != nil) != tc.expErr {
+				t.Fatalf("expected error %v, got %v", tc.expErr, err)
+			}
+			if version != tc.expVersion {
+				t.Errorf("expected storage version %q, got %q", tc.expVersion, version)
+			}
+			if !tc.expErr && !reflect.DeepEqual(stale, tc.expStale) {
+				t.Errorf("expected stale versions %v, got %v", tc.expStale, stale)
+			}
+		})
+	}
+}
+
+func TestStaleOperatorManifests(t *testing.T) {
+	oldManifest := makeMultiDoc(
+		"apiVersion: v1\nkind: Namespace\nmetadata:\n  name: storageos-old\n",
+		"apiVersion: apiextensions.k8s.io/v1\nkind: CustomResourceDefinition\nmetadata:\n  name: old.storageos.com\n",
+		"apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: storageos-operator\n  namespace: storageos\n",
+		"apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: storageos-scheduler\n  namespace: storageos\n",
+		"apiVersion: rbac.authorization.k8s.io/v1\nkind: ClusterRole\nmetadata:\n  name: storageos:old\n",
+	)
+	newManifest := makeMultiDoc(
+		"apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: storageos-operator\n  namespace: storageos\n",
+	)
+
+	stale, err := staleOperatorManifests(oldManifest, newManifest)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ids := []string{}
+	for _, manifest := range stale {
+		ids = append(ids, manifestID(manifest))
+	}
+	expected := []string{"storageos/Deployment/storageos-scheduler", "ClusterRole/storageos:old"}
+	if !reflect.DeepEqual(ids, expected) {
+		t.Errorf("expected stale manifests %v, got %v", expected, ids)
+	}
+}
+
+func TestOperatorDefaultImages(t *testing.T) {
+	manifest := makeMultiDoc(
+		"apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: storageos-related-images\n  namespace: storageos\ndata:\n  RELATED_IMAGE_STORAGEOS_NODE: storageos/node:v2.7.0\n  RELATED_IMAGE_API_MANAGER: storageos/api-manager:v1.2.8\n  UNRELATED: value\n",
+		"apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: storageos-operator\n  namespace: storageos\n",
+	)
+
+	defaults, err := operatorDefaultImages(manifest)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := map[string]string{
+		"nodeContainer":       "storageos/node:v2.7.0",
+		"apiManagerContainer": "storageos/api-manager:v1.2.8",
+	}
+	if !reflect.DeepEqual(defaults, expected) {
+		t.Errorf("expected default images %v, got %v", expected, defaults)
+	}
+}
+
+func TestDefaultImagesPatch(t *testing.T) {
+	defaults := map[string]string{
+		"nodeContainer":       "storageos/node:v2.7.0",
+		"apiManagerContainer": "storageos/api-manager:v1.2.8",
+	}
+	tcases := []struct {
+		name     string
+		images   map[string]string
+		expPatch string
+		expKept  []string
+	}{
+		{
+			name:     "defaults removed",
+			images:   map[string]string{"nodeContainer": "storageos/node:v2.7.0", "apiManagerContainer": "storageos/api-manager:v1.2.8"},
+			expPatch: `{"spec":{"images":{"apiManagerContainer":null,"nodeContainer":null}}}`,
+			expKept:  []string{},
+		},
+		{
+			name:     "overrides kept",
+			images:   map[string]string{"nodeContainer": "registry.example.com/node:v2.7.0", "apiManagerContainer": "storageos/api-manager:v1.2.8", "initContainer": "registry.example.com/init:v2.1.2"},
+			expPatch: `{"spec":{"images":{"apiManagerContainer":null}}}`,
+			expKept:  []string{"initContainer", "nodeContainer"},
+		},
+		{
+			name:    "only overrides",
+			images:  map[string]string{"nodeContainer": "registry.example.com/node:v2.7.0"},
+			expKept: []string{"nodeContainer"},
+		},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			patch, kept, err := defaultImagesPatch(tc.images, defaults)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(patch) != tc.expPatch {
+				t.Errorf("expected patch %s, got %s", tc.expPatch, patch)
+			}
+			if !reflect.DeepEqual(kept, tc.expKept) {
+				t.Errorf("expected kept images %v, got %v", tc.expKept, kept)
+			}
+		})
+	}
+}
@@ -3,13 +3,15 @@ package installer
 import (
 	"context"
 	"path/filepath"
-	"time"
+	"strings"
 
 	"github.com/pkg/errors"
 	apiv1 "github.com/storageos/kubectl-storageos/api/v1"
 	"github.com/storageos/kubectl-storageos/pkg/logger"
 	pluginutils "github.com/storageos/kubectl-storageos/pkg/utils"
 	pluginversion "github.com/storageos/kubectl-storageos/pkg/version"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 )
 
 const (
@@ -26,111 +28,264 @@ const (
 	`
 )
 
-func Upgrade(uninstallConfig *apiv1.KubectlStorageOSConfig, installConfig *apiv1.KubectlStorageOSConfig, versionToUninstall string, log *logger.Logger) error {
+// Upgrade upgrades StorageOS from versionToUninstall to the version of installConfig, in place if
+// the operator allows it. Otherwise versionToUninstall is uninstalled and the new version installed,
+// each completed step being recorded in a journal, both locally and in-cluster, so that an
+// interrupted upgrade can be continued from its last completed step with resume.
+func Upgrade(uninstallConfig *apiv1.KubectlStorageOSConfig, installConfig *apiv1.KubectlStorageOSConfig, versionToUninstall string, resume bool, log *logger.Logger) (err error) {
 	// create new installer with in-mem fs of operator and cluster to be installed
 	// use installer to validate etcd-endpoints before going any further
 	installer, err := NewInstaller(installConfig, log)
 	if err != nil {
 		return err
 	}
-	storageOSCluster, err := pluginutils.GetFirstStorageOSCluster(installer.clientConfig)
+
+	// an interrupted upgrade has uninstalled StorageOS, it can only be reinstalled
+	if !resume {
+		storageOSCluster, err := pluginutils.GetFirstStorageOSCluster(installer.clientConfig)
+		if err != nil {
+			return err
+		}
+		reasons, err := inPlaceUpgradeBlockers(uninstallConfig, installConfig, storageOSCluster, versionToUninstall)
+		if err != nil {
+			return err
+		}
+		if len(reasons) == 0 {
+			return upgradeInPlace(uninstallConfig, installer, versionToUninstall, log)
+		}
+		log.Warnf(reinstallUpgradeMessage, strings.Join(reasons, ", "))
+	}
+
+	var journal *upgradeJournal
+	if resume {
+		journal, err = installer.interruptedUpgradeJournal()
+		if err == nil {
+			log.Warnf(resumingUpgradeMessage, journal.FromVersion, journal.ToVersion, journal.lastStep())
+		}
+	} else {
+		journal, err = installer.startUpgradeJournal(versionToUninstall, installConfig.Spec.Install.StorageOSVersion, uninstallConfig.Spec.QuiesceWorkloads)
+	}
 	if err != nil {
 		return err
 	}
+	// workloads quiesced by an interrupted upgrade are resumed, even without --quiesce-workloads
+	if resume && journal.QuiesceWorkloads && !uninstallConfig.Spec.QuiesceWorkloads {
+		log.Warn(resumingQuiescedUpgradeMessage)
+	}
+	journal.QuiesceWorkloads = journal.QuiesceWorkloads || uninstallConfig.Spec.QuiesceWorkloads
+	uninstalled := journal.completed(upgradeStepUninstallDone)
+
+	// record the etcd endpoints and namespace of the existing cluster, which is gone once uninstalled
+	if !uninstalled {
+		storageOSCluster, err := pluginutils.GetFirstStorageOSCluster(installer.clientConfig)
+		switch {
+		case err == nil:
+			journal.EtcdEndpoints = storageOSCluster.Spec.KVBackend.Address
+			// First, check spec.namespace which defines the namespace for storageos installation by storageos/cluster-operator,
+			// (this field is deprecated in storageos/operator). Otherwise, use metadata.Namespace for storageos installation
+			// (default behaviour for storageos/operator).
+			if storageOSCluster.Spec.Namespace != "" {
+				journal.StorageOSClusterNamespace = storageOSCluster.Spec.Namespace
+			} else {
+				journal.StorageOSClusterNamespace = storageOSCluster.Namespace
+			}
+			if err = installer.writeUpgradeJournal(journal); err != nil {
+				return err
+			}
+		case storageOSClusterGone(err) && journal.uninstallStarted():
+			// the interrupted upgrade deleted the cluster before recording the uninstall as done
+			if err = completeInterruptedUninstall(uninstallConfig, installer, journal, log); err != nil {
+				return err
+			}
+			uninstalled = true
+		default:
+			return err
+		}
+	}
 
 	// if etcdEndpoints was not passed via config, use that of existing cluster
 	if installConfig.Spec.Install.EtcdEndpoints == "" {
-		installConfig.Spec.Install.EtcdEndpoints = storageOSCluster.Spec.KVBackend.Address
+		installConfig.Spec.Install.EtcdEndpoints = journal.EtcdEndpoints
 	}
 
 	// if storageOSClusterNamespace was not passed via config, use that of existing cluster
 	if installConfig.Spec.Install.StorageOSClusterNamespace == "" {
-		// First, check spec.namespace which defines the namespace for storageos installation by storageos/cluster-operator,
-		// (this field is deprecated in storageos/operator). Otherwise, use metadata.Namespace for storageos installation
-		// (default behaviour for storageos/operator).
-		if storageOSCluster.Spec.Namespace != "" {
-			installConfig.Spec.Install.StorageOSClusterNamespace = storageOSCluster.Spec.Namespace
-		} else {
-			installConfig.Spec.Install.StorageOSClusterNamespace = storageOSCluster.Namespace
-		}
+		installConfig.Spec.Install.StorageOSClusterNamespace = journal.StorageOSClusterNamespace
 	}
 
 	if err = installer.handleEndpointsInput(installConfig.Spec); err != nil {
 		return err
 	}
 
-	// create uninstaller with in-mem fs of operator and cluster to be uninstalled
-	uninstaller, err := NewUninstaller(uninstallConfig, log)
-	if err != nil {
-		return err
+	quiesce := journal.QuiesceWorkloads
+	if quiesce {
+		defer func() {
+			if err != nil {
+				log.Warn(quiescedUpgradeFailedMessage)
+			}
+		}()
 	}
 
-	if err = uninstaller.prepareForUpgrade(installConfig, versionToUninstall, installer); err != nil {
-		return err
+	if uninstalled {
+		// the existing cluster is gone, its manifests and secrets are read from the backup taken by
+		// the interrupted upgrade
+		backupPath, err := installer.getUpgradeBackupPath(journal)
+		if err != nil {
+			return err
+		}
+		if err = installer.copyUpgradeData(installConfig, installer, backupPath); err != nil {
+			return err
+		}
+	} else {
+		// create uninstaller with in-mem fs of operator and cluster to be uninstalled
+		uninstaller, err := NewUninstaller(uninstallConfig, log)
+		if err != nil {
+			return err
+		}
+
+		if err = uninstaller.prepareForUpgrade(installConfig, versionToUninstall, installer, journal); err != nil {
+			return err
+		}
+
+		// scale down workloads using storageos volumes so that the uninstall workload check passes
+		if quiesce {
+			if err = uninstaller.QuiesceWorkloads(); err != nil {
+				return err
+			}
+		}
+
+		// uninstall existing storageos operator and cluster
+		if err = uninstaller.Uninstall(true, versionToUninstall); err != nil {
+			return err
+		}
+		if err = installer.completeUpgradeStep(journal, upgradeStepUninstallDone); err != nil {
+			return err
+		}
 	}
 
-	// uninstall existing storageos operator and cluster
-	if err = uninstaller.Uninstall(true, versionToUninstall); err != nil {
+	if !journal.completed(upgradeStepInstallStarted) {
+		remover, err := newLightweightInstaller(uninstallConfig, log)
+		if err != nil {
+			return err
+		}
+		// wait for CRDs, webhooks, operator and cluster to be removed before reinstalling them
+		if err = remover.waitForStorageOSRemoval(); err != nil {
+			return err
+		}
+		if err = installer.completeUpgradeStep(journal, upgradeStepInstallStarted); err != nil {
+			return err
+		}
+	}
+
+	// install new storageos operator and cluster
+	if err = installer.Install(true); err != nil {
 		return err
 	}
 
-	// sleep to allow CRDs to be removed
-	// TODO: Add specific check instead of sleep
-	time.Sleep(30 * time.Second)
+	if quiesce {
+		// workloads are resumed once the new storageos cluster is running
+		if !installConfig.Spec.Install.Wait && !installConfig.Spec.SkipStorageOSCluster {
+			if err = installer.waitForStorageOSClusterRunning(); err != nil {
+				return err
+			}
+		}
+		if err = installer.ResumeWorkloads(); err != nil {
+			return err
+		}
+	}
 
-	// install new storageos operator and cluster
-	err = installer.Install(true)
+	err = installer.deleteUpgradeJournal()
 
 	return err
 }
 
-// prepareForUpgrade performs necessary steps before upgrade commences
-func (in *Installer) prepareForUpgrade(installConfig *apiv1.KubectlStorageOSConfig, versionToUninstall string, installer *Installer) error {
-	// write storageoscluster, secret and storageclass manifests to disk
-	if err := in.writeBackupFileSystem(in.storageOSCluster); err != nil {
-		return errors.WithStack(err)
+// completeInterruptedUninstall deletes the operator and CRDs an upgrade interrupted during uninstall
+// may have left once the storageos cluster was deleted, then records the uninstall of journal as
+// done. The values of the deleted cluster are read from journal.
+func completeInterruptedUninstall(uninstallConfig *apiv1.KubectlStorageOSConfig, installer *Installer, journal *upgradeJournal, log *logger.Logger) error {
+	log.Warn(resumingDeletedClusterMessage)
+	uninstaller, err := NewUninstaller(uninstallConfig, log)
+	if err != nil {
+		return err
 	}
-
-	// apply the storageclass manifest written to disk (now with finalizer to prevent deletion by operator)
-	if err := in.applyBackupManifestWithFinalizer(stosStorageClassFile); err != nil {
+	if err = uninstaller.uninstallStorageOSOperator(); err != nil {
 		return err
 	}
 
-	// if the version being uninstalled during upgrade is that of the 'old' operator (pre v2.5) existing
-	// CSI secrets are applied with finalizer to prevent deletion by operator
-	oldVersion, err := pluginversion.VersionIsLessThanOrEqual(versionToUninstall, pluginversion.ClusterOperatorLastVersion())
+	return installer.completeUpgradeStep(journal, upgradeStepUninstallDone)
+}
+
+// storageOSClusterGone returns true if err reports that no storageos cluster exists, or that
+// storageosclusters are no longer served once their CRD is removed
+func storageOSClusterGone(err error) bool {
+	return kerrors.IsNotFound(err) || meta.IsNoMatchError(errors.Cause(err))
+}
+
+// prepareForUpgrade performs necessary steps before upgrade commences, skipping those already
+// completed by an interrupted upgrade of journal
+func (in *Installer) prepareForUpgrade(installConfig *apiv1.KubectlStorageOSConfig, versionToUninstall string, installer *Installer, journal *upgradeJournal) error {
+	if !journal.completed(upgradeStepBackupTaken) {
+		// write storageoscluster, secret and storageclass manifests to disk, recording the backup
+		// so that a resumed upgrade reads this one rather than the latest
+		backupPath, err := in.writeBackupFileSystem(in.storageOSCluster, backupSubcommandUpgrade)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		journal.BackupName = filepath.Base(backupPath)
+		if err := in.completeUpgradeStep(journal, upgradeStepBackupTaken); err != nil {
+			return err
+		}
+	}
+	backupPath, err := in.getUpgradeBackupPath(journal)
 	if err != nil {
 		return err
 	}
-	if !pluginversion.IsDevelop(versionToUninstall) && oldVersion {
-		if err = in.applyBackupManifestWithFinalizer(csiSecretsFile); err != nil {
+
+	if !journal.completed(upgradeStepStorageClassesProtected) {
+		// apply the storageclass manifest written to disk (now with finalizer to prevent deletion by operator)
+		if err := in.applyBackupManifestWithFinalizer(backupPath, stosStorageClassFile); err != nil {
+			return err
+		}
+
+		// if the version being uninstalled during upgrade is that of the 'old' operator (pre v2.5) existing
+		// CSI secrets are applied with finalizer to prevent deletion by operator
+		oldVersion, err := pluginversion.VersionIsLessThanOrEqual(versionToUninstall, pluginversion.ClusterOperatorLastVersion())
+		if err != nil {
+			return err
+		}
+		if !pluginversion.IsDevelop(versionToUninstall) && oldVersion {
+			if err = in.applyBackupManifestWithFinalizer(backupPath, csiSecretsFile); err != nil {
+				return err
+			}
+		}
+		if err = in.completeUpgradeStep(journal, upgradeStepStorageClassesProtected); err != nil {
 			return err
 		}
 	}
 
+	return in.copyUpgradeData(installConfig, installer, backupPath)
+}
+
+// copyUpgradeData copies the storageos cluster and secret data of the backup at backupPath to
+// installer and installConfig
+func (in *Installer) copyUpgradeData(installConfig *apiv1.KubectlStorageOSConfig, installer *Installer, backupPath string) error {
 	// if no storageos-cluster.yaml has been passed to the cli, use the backed-up storageos cluster.
 	if installConfig.Spec.Install.StorageOSClusterYaml == "" {
-		if err := in.copyStorageOSClusterToMemory(installer); err != nil {
+		if err := in.copyStorageOSClusterToMemory(installer, backupPath); err != nil {
 			return err
 		}
 	}
 	// discover uninstalled secret username and password for upgrade. Here we use (1) the (un)installer
 	// as it contains the on-disk FS of the uninstalled secrets and (2) the installConfig so we can
 	// set secret username and password in the secret manifest to be installed later
-	err = in.copyStorageOSSecretData(installConfig)
-
-	return err
+	return in.copyStorageOSSecretData(installConfig, backupPath)
 }
 
-// copyStorageOSClusterToMemory takes the (uninstalled) on-disk storageos-cluster manifest and combines it with
-// the installer's in-memory storageos-api secret to create a multi-doc storageos-cluster.yaml. This manifest
-// is written to the installer's in-memory fs for installation. Thus maintaining the original cluster's specs.
-func (in *Installer) copyStorageOSClusterToMemory(installer *Installer) error {
-	backupPath, err := in.getBackupPath()
-	if err != nil {
-		return err
-	}
-
+// copyStorageOSClusterToMemory takes the (uninstalled) storageos-cluster manifest of the backup at backupPath
+// and combines it with the installer's in-memory storageos-api secret to create a multi-doc storageos-cluster.yaml.
+// This manifest is written to the installer's in-memory fs for installation. Thus maintaining the original
+// cluster's specs.
+func (in *Installer) copyStorageOSClusterToMemory(installer *Installer, backupPath string) error {
 	onDiskStosClusterManifest, err := in.onDiskFileSys.ReadFile(filepath.Join(backupPath, stosClusterFile))
 	if err != nil {
 		return errors.WithStack(err)
@@ -259,16 +414,12 @@ func (in *Installer) copyStorageOSPortalClientData(installConfig *apiv1.KubectlS
 	return nil
 }
 
-// copyStorageOSSecretData
-func (in *Installer) copyStorageOSSecretData(installConfig *apiv1.KubectlStorageOSConfig) error {
-	backupPath, err := in.getBackupPath()
+// copyStorageOSSecretData copies the storageos secret data of the backup at backupPath to installConfig
+func (in *Installer) copyStorageOSSecretData(installConfig *apiv1.KubectlStorageOSConfig, backupPath string) error {
+	stosSecrets, err := in.readBackupFile(filepath.Join(backupPath, stosSecretsFile))
 	if err != nil {
 		return err
 	}
-	stosSecrets, err := in.onDiskFileSys.ReadFile(filepath.Join(backupPath, stosSecretsFile))
-	if err != nil {
-		return errors.WithStack(err)
-	}
 
 	if err := in.copyStorageOSAPIData(installConfig, string(stosSecrets)); err != nil {
 		return err
@@ -299,18 +450,13 @@ func (in *Installer) copyStorageOSSecretData(installConfig *apiv1.KubectlStorage
 	return in.copyStorageOSPortalClientData(installConfig, string(stosSecrets))
 }
 
-// applyBackupManifest applies file from the (un)installer's on-disk filesystem with finalizer
-func (in *Installer) applyBackupManifestWithFinalizer(file string) error {
-	backupPath, err := in.getBackupPath()
+// applyBackupManifestWithFinalizer applies file of the backup at backupPath with finalizer
+func (in *Installer) applyBackupManifestWithFinalizer(backupPath, file string) error {
+	multidoc, err := in.readBackupFile(filepath.Join(backupPath, file))
 	if err != nil {
 		return err
 	}
 
-	multidoc, err := in.onDiskFileSys.ReadFile(filepath.Join(backupPath, file))
-	if err != nil {
-		return errors.WithStack(err)
-	}
-
 	manifests := splitMultiDoc(string(multidoc))
 	for _, manifest := range manifests {
 		// if a finalizer already exists for this object, continue.
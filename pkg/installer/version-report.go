@@ -0,0 +1,264 @@
+package installer
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/storageos/kubectl-storageos/pkg/logger"
+	pluginutils "github.com/storageos/kubectl-storageos/pkg/utils"
+	pluginversion "github.com/storageos/kubectl-storageos/pkg/version"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// VersionOutputTable prints the version report as a table
+	VersionOutputTable = "table"
+	// VersionOutputJSON prints the version report as JSON
+	VersionOutputJSON = "json"
+
+	pluginComponent        = "kubectl-storageos"
+	operatorComponent      = "storageos-operator"
+	etcdOperatorComponent  = "etcd-operator"
+	portalManagerComponent = "portal-manager"
+
+	errUnknownVersionOutput = `
+	Unknown output format %s, must be one of ` + VersionOutputTable + `, ` + VersionOutputJSON
+
+	kubernetesVersionMessage   = `Kubernetes %s, distribution %s.`
+	operatorUpgradeHint        = `StorageOS %s is available, upgrade with: kubectl storageos upgrade --stos-version=%s`
+	operatorNotInstalledHint   = `StorageOS is not installed, install it with: kubectl storageos install`
+	etcdOperatorUpgradeHint    = `ETCD operator %s is available, upgrade with: kubectl storageos upgrade etcd --etcd-operator-version=%s`
+	pluginUpgradeHint          = `kubectl storageos %s is available at %s`
+	clusterUnreachableWarning  = `Unable to reach the Kubernetes cluster: %v`
+	installedVersionWarning    = `Unable to detect the installed %s version: %v`
+	latestVersionWarning       = `Unable to fetch the latest %s release: %v`
+	notInstalledVersion        = "not installed"
+	unknownVersion             = "unknown"
+	portalManagerDisabledValue = "disabled"
+)
+
+// VersionReportOptions sets where the installed components are looked up and whether to check for
+// a newer kubectl storageos release
+type VersionReportOptions struct {
+	OperatorNamespace string
+	EtcdNamespace     string
+	CheckUpdate       bool
+}
+
+// versionReport holds the versions of kubectl storageos, of the components installed in the
+// cluster and of their latest releases
+type versionReport struct {
+	Components []componentVersion `json:"components"`
+	Kubernetes *kubernetesVersion `json:"kubernetes,omitempty"`
+	Hints      []string           `json:"hints,omitempty"`
+	Warnings   []string           `json:"warnings,omitempty"`
+}
+
+// componentVersion is the installed and latest version of a component, the installed version is
+// empty if the component is not installed and the latest is empty if it has not been looked up
+type componentVersion struct {
+	Name      string `json:"name"`
+	Installed string `json:"installed,omitempty"`
+	Latest    string `json:"latest,omitempty"`
+	LatestURL string `json:"latestURL,omitempty"`
+}
+
+// kubernetesVersion is the version and distribution of the Kubernetes cluster
+type kubernetesVersion struct {
+	Version      string `json:"version"`
+	Distribution string `json:"distribution"`
+}
+
+// component returns the component of name, nil if it is not in the report
+func (r *versionReport) component(name string) *componentVersion {
+	for i := range r.Components {
+		if r.Components[i].Name == name {
+			return &r.Components[i]
+		}
+	}
+	return nil
+}
+
+// warnf adds a warning to the report
+func (r *versionReport) warnf(format string, args ...interface{}) {
+	r.Warnings = append(r.Warnings, fmt.Sprintf(format, args...))
+}
+
+// PrintVersionReport prints the versions of kubectl storageos, Kubernetes and the StorageOS
+// components installed in the cluster alongside their latest releases, and hints at the upgrades
+// available. Lookups that fail are reported as warnings.
+func PrintVersionReport(options VersionReportOptions, output string, log *logger.Logger) error {
+	if output != VersionOutputTable && output != VersionOutputJSON {
+		return fmt.Errorf(errUnknownVersionOutput, output)
+	}
+
+	report := buildVersionReport(options)
+	report.Hints = upgradeHints(report)
+
+	return printVersionReport(report, output, log)
+}
+
+// buildVersionReport looks up the installed and latest versions of every component
+func buildVersionReport(options VersionReportOptions) *versionReport {
+	report := &versionReport{Components: []componentVersion{
+		{Name: pluginComponent, Installed: getStringWithDefault(pluginversion.PluginVersion, unknownVersion)},
+		{Name: operatorComponent},
+		{Name: etcdOperatorComponent},
+		{Name: portalManagerComponent},
+	}}
+
+	if options.CheckUpdate {
+		setLatestRelease(report, pluginComponent, pluginversion.LatestPluginRelease)
+	}
+	setLatestRelease(report, operatorComponent, pluginversion.LatestOperatorRelease)
+	setLatestRelease(report, etcdOperatorComponent, pluginversion.LatestEtcdOperatorRelease)
+
+	clientConfig, err := pluginutils.NewClientConfig()
+	if err == nil {
+		report.Kubernetes, err = getKubernetesVersion(clientConfig)
+	}
+	if err != nil {
+		report.warnf(clusterUnreachableWarning, err)
+		for _, name := range []string{operatorComponent, etcdOperatorComponent, portalManagerComponent} {
+			report.component(name).Installed = unknownVersion
+		}
+		return report
+	}
+
+	setInstalledVersion(report, operatorComponent, func() (string, error) {
+		return pluginversion.GetExistingOperatorVersion(options.OperatorNamespace)
+	})
+	setInstalledVersion(report, etcdOperatorComponent, func() (string, error) {
+		return pluginversion.GetExistingEtcdOperatorVersion(options.EtcdNamespace)
+	})
+	setInstalledVersion(report, portalManagerComponent, func() (string, error) {
+		cluster, err := pluginutils.GetFirstStorageOSCluster(clientConfig)
+		if err != nil {
+			return "", err
+		}
+		if !cluster.Spec.EnablePortalManager {
+			return portalManagerDisabledValue, nil
+		}
+		return pluginversion.GetExistingPortalManagerVersion(cluster.Namespace)
+	})
+
+	return report
+}
+
+// getKubernetesVersion returns the version and distribution of the Kubernetes cluster
+func getKubernetesVersion(clientConfig *rest.Config) (*kubernetesVersion, error) {
+	info, err := pluginutils.GetKubernetesVersion(clientConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return &kubernetesVersion{
+		Version:      info.GitVersion,
+		Distribution: pluginutils.DetermineDistribution(info.GitVersion).String(),
+	}, nil
+}
+
+// setLatestRelease sets the latest version of component name to the release returned by latest
+func setLatestRelease(report *versionReport, name string, latest func() (pluginversion.GithubRelease, error)) {
+	release, err := latest()
+	if err != nil {
+		report.warnf(latestVersionWarning, name, err)
+		return
+	}
+	component := report.component(name)
+	component.Latest = release.TagName
+	component.LatestURL = release.HTMLURL
+}
+
+// setInstalledVersion sets the installed version of component name to the version returned by
+// installed, a component which is not found is not installed
+func setInstalledVersion(report *versionReport, name string, installed func() (string, error)) {
+	version, err := installed()
+	if err != nil {
+		if kerrors.IsNotFound(errors.Cause(err)) {
+			return
+		}
+		report.warnf(installedVersionWarning, name, err)
+		version = unknownVersion
+	}
+	report.component(name).Installed = version
+}
+
+// upgradeHints returns the commands upgrading the components of report which are older than their
+// latest release
+func upgradeHints(report *versionReport) []string {
+	hints := []string{}
+	if operator := report.component(operatorComponent); operator != nil && operator.Installed == "" && report.Kubernetes != nil {
+		hints = append(hints, operatorNotInstalledHint)
+	}
+
+	for _, component := range report.Components {
+		if !isOutdated(component) {
+			continue
+		}
+		switch component.Name {
+		case pluginComponent:
+			hints = append(hints, fmt.Sprintf(pluginUpgradeHint, component.Latest, component.LatestURL))
+		case operatorComponent:
+			hints = append(hints, fmt.Sprintf(operatorUpgradeHint, component.Latest, component.Latest))
+		case etcdOperatorComponent:
+			hints = append(hints, fmt.Sprintf(etcdOperatorUpgradeHint, component.Latest, component.Latest))
+		}
+	}
+
+	return hints
+}
+
+// isOutdated returns true if the installed version of component is older than its latest release.
+// Development versions are never outdated.
+func isOutdated(component componentVersion) bool {
+	installed := component.Installed
+	if installed == "" || installed == unknownVersion || component.Latest == "" || pluginversion.IsDevelop(installed) {
+		return false
+	}
+	lessThan, err := pluginversion.VersionIsLessThan(installed, component.Latest)
+
+	return err == nil && lessThan
+}
+
+// printVersionReport prints report in output format
+func printVersionReport(report *versionReport, output string, log *logger.Logger) error {
+	if output == VersionOutputJSON {
+		data, err := json.MarshalIndent(report, "", "    ")
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		fmt.Fprintln(log.Writer, string(data))
+		return nil
+	}
+
+	log.Table(versionReportHeaders, versionReportRows(report))
+	if report.Kubernetes != nil {
+		log.Successf(kubernetesVersionMessage, report.Kubernetes.Version, report.Kubernetes.Distribution)
+	}
+	for _, warning := range report.Warnings {
+		log.Warnf("%s", warning)
+	}
+	for _, hint := range report.Hints {
+		log.Warnf("%s", hint)
+	}
+
+	return nil
+}
+
+var versionReportHeaders = []string{"COMPONENT", "INSTALLED", "LATEST"}
+
+// versionReportRows returns the table rows of report, one per component
+func versionReportRows(report *versionReport) [][]string {
+	rows := make([][]string, 0, len(report.Components))
+	for _, component := range report.Components {
+		installed := component.Installed
+		if installed == "" {
+			installed = notInstalledVersion
+		}
+		rows = append(rows, []string{component.Name, installed, tableValue(component.Latest)})
+	}
+	return rows
+}
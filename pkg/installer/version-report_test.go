@@ -0,0 +1,81 @@
+package installer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestUpgradeHints(t *testing.T) {
+	tcases := []struct {
+		name       string
+		components []componentVersion
+		kubernetes *kubernetesVersion
+		expHints   []string
+	}{
+		{
+			name: "up to date",
+			components: []componentVersion{
+				{Name: pluginComponent, Installed: "v1.3.0", Latest: "v1.3.0"},
+				{Name: operatorComponent, Installed: "v2.8.0", Latest: "v2.8.0"},
+				{Name: etcdOperatorComponent, Installed: "v0.3.1", Latest: "v0.3.1"},
+			},
+			kubernetes: &kubernetesVersion{Version: "v1.24.0"},
+			expHints:   []string{},
+		},
+		{
+			name: "outdated",
+			components: []componentVersion{
+				{Name: pluginComponent, Installed: "v1.2.0", Latest: "v1.3.0", LatestURL: "https://example.com/v1.3.0"},
+				{Name: operatorComponent, Installed: "v2.7.0", Latest: "v2.8.0"},
+				{Name: etcdOperatorComponent, Installed: "v0.3.0", Latest: "v0.3.1"},
+				{Name: portalManagerComponent, Installed: "v1.0.0"},
+			},
+			kubernetes: &kubernetesVersion{Version: "v1.24.0"},
+			expHints: []string{
+				"kubectl storageos v1.3.0 is available at https://example.com/v1.3.0",
+				"StorageOS v2.8.0 is available, upgrade with: kubectl storageos upgrade --stos-version=v2.8.0",
+				"ETCD operator v0.3.1 is available, upgrade with: kubectl storageos upgrade etcd --etcd-operator-version=v0.3.1",
+			},
+		},
+		{
+			name: "not installed",
+			components: []componentVersion{
+				{Name: pluginComponent, Installed: "develop", Latest: "v1.3.0"},
+				{Name: operatorComponent, Latest: "v2.8.0"},
+				{Name: etcdOperatorComponent, Installed: unknownVersion, Latest: "v0.3.1"},
+			},
+			kubernetes: &kubernetesVersion{Version: "v1.24.0"},
+			expHints:   []string{operatorNotInstalledHint},
+		},
+		{
+			name: "cluster unreachable",
+			components: []componentVersion{
+				{Name: operatorComponent, Installed: unknownVersion, Latest: "v2.8.0"},
+			},
+			expHints: []string{},
+		},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			hints := upgradeHints(&versionReport{Components: tc.components, Kubernetes: tc.kubernetes})
+			if !reflect.DeepEqual(hints, tc.expHints) {
+				t.Errorf("expected hints %v, got %v", tc.expHints, hints)
+			}
+		})
+	}
+}
+
+func TestVersionReportRows(t *testing.T) {
+	report := &versionReport{Components: []componentVersion{
+		{Name: operatorComponent, Installed: "v2.7.0", Latest: "v2.8.0"},
+		{Name: portalManagerComponent},
+	}}
+	expected := [][]string{
+		{operatorComponent, "v2.7.0", "v2.8.0"},
+		{portalManagerComponent, notInstalledVersion, "-"},
+	}
+	if rows := versionReportRows(report); !reflect.DeepEqual(rows, expected) {
+		t.Errorf("expected rows %v, got %v", expected, rows)
+	}
+}
@@ -0,0 +1,279 @@
+package installer
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+
+	gyaml "github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+	pluginutils "github.com/storageos/kubectl-storageos/pkg/utils"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	volumesRetainedMessage  = `Reclaim policy of %d StorageOS persistent volume(s) set to Retain.`
+	volumesRestoredMessage  = `Restored %d persistent volume(s) and %d persistent volume claim(s), %d released persistent volume(s) made available for re-binding.`
+	volumesRetainPolicyNote = `Restored persistent volumes keep reclaim policy Retain, reset it once their claims are bound if needed.`
+)
+
+// isStorageOSVolume returns true if pv is provisioned by storageos
+func isStorageOSVolume(pv *corev1.PersistentVolume) bool {
+	return pv.Spec.CSI != nil && pv.Spec.CSI.Driver == stosSCProvisioner
+}
+
+// listStorageOSVolumes returns the persistent volumes provisioned by storageos and the persistent
+// volume claims bound to them
+func (in *Installer) listStorageOSVolumes() ([]corev1.PersistentVolume, []corev1.PersistentVolumeClaim, error) {
+	pvList, err := pluginutils.ListPersistentVolumes(in.clientConfig, metav1.ListOptions{})
+	if err != nil {
+		return nil, nil, err
+	}
+	pvs := []corev1.PersistentVolume{}
+	volumeNames := map[string]bool{}
+	for _, pv := range pvList.Items {
+		if isStorageOSVolume(&pv) {
+			pvs = append(pvs, pv)
+			volumeNames[pv.Name] = true
+		}
+	}
+
+	pvcList, err := pluginutils.ListPersistentVolumeClaims(in.clientConfig, metav1.ListOptions{})
+	if err != nil {
+		return nil, nil, err
+	}
+	pvcs := []corev1.PersistentVolumeClaim{}
+	for _, pvc := range pvcList.Items {
+		if volumeNames[pvc.Spec.VolumeName] {
+			pvcs = append(pvcs, pvc)
+		}
+	}
+
+	return pvs, pvcs, nil
+}
+
+// writeVolumesToDisk writes multidoc manifests of the storageos persistent volumes and their claims
+// to backupPath of on-disk filesystem
+func (in *Installer) writeVolumesToDisk(backupPath string) error {
+	pvs, pvcs, err := in.listStorageOSVolumes()
+	if err != nil {
+		return err
+	}
+
+	if len(pvs) != 0 {
+		manifests := make([]string, 0, len(pvs))
+		for i := range pvs {
+			manifest, err := persistentVolumeToManifest(&pvs[i])
+			if err != nil {
+				return err
+			}
+			manifests = append(manifests, string(manifest))
+		}
+		if err = in.onDiskFileSys.WriteFile(filepath.Join(backupPath, stosPVsFile), []byte(makeMultiDoc(manifests...))); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	if len(pvcs) != 0 {
+		manifests := make([]string, 0, len(pvcs))
+		for i := range pvcs {
+			manifest, err := persistentVolumeClaimToManifest(&pvcs[i])
+			if err != nil {
+				return err
+			}
+			manifests = append(manifests, string(manifest))
+		}
+		if err = in.onDiskFileSys.WriteFile(filepath.Join(backupPath, stosPVCsFile), []byte(makeMultiDoc(manifests...))); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	return nil
+}
+
+// retainStorageOSVolumes sets the reclaim policy of every storageos persistent volume to Retain, so
+// that volumes are kept if their claims are deleted while StorageOS is uninstalled
+func (in *Installer) retainStorageOSVolumes() error {
+	pvs, _, err := in.listStorageOSVolumes()
+	if err != nil {
+		return err
+	}
+	retained := 0
+	for i := range pvs {
+		pv := &pvs[i]
+		if pv.Spec.PersistentVolumeReclaimPolicy == corev1.PersistentVolumeReclaimRetain {
+			continue
+		}
+		pv.Spec.PersistentVolumeReclaimPolicy = corev1.PersistentVolumeReclaimRetain
+		if err = pluginutils.UpdatePersistentVolume(in.clientConfig, pv); err != nil {
+			return err
+		}
+		retained++
+	}
+	in.log.Warnf(volumesRetainedMessage, retained)
+
+	return nil
+}
+
+// persistentVolumeToManifest returns the manifest of pv to be created again. Its claim reference
+// keeps the name and namespace of the claim only, so that it can be bound to a new claim of the
+// same name, and its reclaim policy is Retain.
+func persistentVolumeToManifest(pv *corev1.PersistentVolume) ([]byte, error) {
+	newPV := &corev1.PersistentVolume{}
+	newPV.APIVersion = "v1"
+	newPV.Kind = "PersistentVolume"
+	newPV.SetName(pv.GetName())
+	newPV.SetLabels(pv.GetLabels())
+	newPV.SetAnnotations(pv.GetAnnotations())
+	newPV.Spec = *pv.Spec.DeepCopy()
+	newPV.Spec.PersistentVolumeReclaimPolicy = corev1.PersistentVolumeReclaimRetain
+	if newPV.Spec.ClaimRef != nil {
+		newPV.Spec.ClaimRef = &corev1.ObjectReference{
+			APIVersion: pv.Spec.ClaimRef.APIVersion,
+			Kind:       pv.Spec.ClaimRef.Kind,
+			Namespace:  pv.Spec.ClaimRef.Namespace,
+			Name:       pv.Spec.ClaimRef.Name,
+		}
+	}
+
+	return objectToYAML(newPV)
+}
+
+// persistentVolumeClaimToManifest returns the manifest of pvc to be created again, bound to the
+// same persistent volume
+func persistentVolumeClaimToManifest(pvc *corev1.PersistentVolumeClaim) ([]byte, error) {
+	newPVC := &corev1.PersistentVolumeClaim{}
+	newPVC.APIVersion = "v1"
+	newPVC.Kind = "PersistentVolumeClaim"
+	newPVC.SetName(pvc.GetName())
+	newPVC.SetNamespace(pvc.GetNamespace())
+	newPVC.SetLabels(pvc.GetLabels())
+	newPVC.SetAnnotations(pvc.GetAnnotations())
+	newPVC.Spec = *pvc.Spec.DeepCopy()
+
+	return objectToYAML(newPVC)
+}
+
+// objectToYAML returns the yaml manifest of obj
+func objectToYAML(obj interface{}) ([]byte, error) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	data, err = gyaml.JSONToYAML(data)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return data, nil
+}
+
+// rebindPersistentVolume makes existing available to be bound to a new claim of the same name if it
+// has been released by its deleted claim, returning true if existing has been changed
+func rebindPersistentVolume(existing *corev1.PersistentVolume) bool {
+	if existing.Status.Phase != corev1.VolumeReleased || existing.Spec.ClaimRef == nil {
+		return false
+	}
+	if existing.Spec.ClaimRef.UID == "" && existing.Spec.ClaimRef.ResourceVersion == "" {
+		return false
+	}
+	existing.Spec.ClaimRef.UID = ""
+	existing.Spec.ClaimRef.ResourceVersion = ""
+
+	return true
+}
+
+// readBackupVolumes returns the persistent volumes and claims of backup b, none if b was not taken
+// with volumes retained
+func (in *Installer) readBackupVolumes(b backup) ([]corev1.PersistentVolume, []corev1.PersistentVolumeClaim, error) {
+	pvs := []corev1.PersistentVolume{}
+	pvcs := []corev1.PersistentVolumeClaim{}
+	for _, manifest := range in.readBackupManifests(filepath.Join(b.path, stosPVsFile)) {
+		pv := corev1.PersistentVolume{}
+		if err := gyaml.Unmarshal([]byte(manifest), &pv); err != nil {
+			return nil, nil, errors.WithStack(err)
+		}
+		pvs = append(pvs, pv)
+	}
+	for _, manifest := range in.readBackupManifests(filepath.Join(b.path, stosPVCsFile)) {
+		pvc := corev1.PersistentVolumeClaim{}
+		if err := gyaml.Unmarshal([]byte(manifest), &pvc); err != nil {
+			return nil, nil, errors.WithStack(err)
+		}
+		pvcs = append(pvcs, pvc)
+	}
+
+	return pvs, pvcs, nil
+}
+
+// readBackupManifests returns the manifests of the multidoc file at path, none if it does not exist
+func (in *Installer) readBackupManifests(path string) []string {
+	data, err := in.onDiskFileSys.ReadFile(path)
+	if err != nil || strings.TrimSpace(string(data)) == "" {
+		return nil
+	}
+
+	return splitMultiDoc(string(data))
+}
+
+// restoreVolumes re-binds the persistent volumes and claims of backup b. Missing persistent volumes
+// are created, released ones made available to their claim again, and missing claims are created
+// bound to their persistent volume.
+func (in *Installer) restoreVolumes(b backup) error {
+	pvs, pvcs, err := in.readBackupVolumes(b)
+	if err != nil {
+		return err
+	}
+	if len(pvs) == 0 && len(pvcs) == 0 {
+		return nil
+	}
+
+	createdPVs, releasedPVs, createdPVCs := 0, 0, 0
+	for i := range pvs {
+		existing, err := pluginutils.GetPersistentVolume(in.clientConfig, pvs[i].Name)
+		if err != nil {
+			if !kerrors.IsNotFound(err) {
+				return err
+			}
+			if err = pluginutils.CreatePersistentVolume(in.clientConfig, &pvs[i]); err != nil {
+				return err
+			}
+			createdPVs++
+			continue
+		}
+		if !rebindPersistentVolume(existing) {
+			continue
+		}
+		if err = pluginutils.UpdatePersistentVolume(in.clientConfig, existing); err != nil {
+			return err
+		}
+		releasedPVs++
+	}
+
+	for i := range pvcs {
+		pvc := &pvcs[i]
+		_, err := pluginutils.GetPersistentVolumeClaim(in.clientConfig, pvc.Name, pvc.Namespace)
+		if err == nil {
+			continue
+		}
+		if !kerrors.IsNotFound(err) {
+			return err
+		}
+		if err = pluginutils.EnsureNamespace(in.clientConfig, pvc.Namespace); err != nil {
+			return err
+		}
+		if err = pluginutils.CreatePersistentVolumeClaim(in.clientConfig, pvc); err != nil {
+			return err
+		}
+		createdPVCs++
+	}
+
+	in.log.Successf(volumesRestoredMessage, createdPVs, createdPVCs, releasedPVs)
+	if createdPVs != 0 || releasedPVs != 0 {
+		in.log.Warn(volumesRetainPolicyNote)
+	}
+
+	return nil
+}
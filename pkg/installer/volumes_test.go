@@ -0,0 +1,109 @@
+package installer
+
+import (
+	"testing"
+
+	gyaml "github.com/ghodss/yaml"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestPersistentVolumeToManifest(t *testing.T) {
+	pv := &corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "pvc-1234",
+			UID:             types.UID("pv-uid"),
+			ResourceVersion: "42",
+			Finalizers:      []string{"kubernetes.io/pv-protection"},
+		},
+		Spec: corev1.PersistentVolumeSpec{
+			PersistentVolumeReclaimPolicy: corev1.PersistentVolumeReclaimDelete,
+			PersistentVolumeSource: corev1.PersistentVolumeSource{
+				CSI: &corev1.CSIPersistentVolumeSource{Driver: stosSCProvisioner, VolumeHandle: "v.1234"},
+			},
+			ClaimRef: &corev1.ObjectReference{
+				Kind:            "PersistentVolumeClaim",
+				Namespace:       "default",
+				Name:            "data",
+				UID:             types.UID("pvc-uid"),
+				ResourceVersion: "41",
+			},
+		},
+		Status: corev1.PersistentVolumeStatus{Phase: corev1.VolumeBound},
+	}
+
+	manifest, err := persistentVolumeToManifest(pv)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	restored := &corev1.PersistentVolume{}
+	if err = gyaml.Unmarshal(manifest, restored); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if restored.Kind != "PersistentVolume" || restored.Name != pv.Name {
+		t.Errorf("expected PersistentVolume %s, got %s %s", pv.Name, restored.Kind, restored.Name)
+	}
+	if restored.UID != "" || restored.ResourceVersion != "" || len(restored.Finalizers) != 0 {
+		t.Errorf("expected server set metadata to be stripped, got %+v", restored.ObjectMeta)
+	}
+	if restored.Status.Phase != "" {
+		t.Errorf("expected status to be stripped, got %s", restored.Status.Phase)
+	}
+	if restored.Spec.PersistentVolumeReclaimPolicy != corev1.PersistentVolumeReclaimRetain {
+		t.Errorf("expected reclaim policy Retain, got %s", restored.Spec.PersistentVolumeReclaimPolicy)
+	}
+	claimRef := restored.Spec.ClaimRef
+	if claimRef == nil || claimRef.Namespace != "default" || claimRef.Name != "data" || claimRef.UID != "" || claimRef.ResourceVersion != "" {
+		t.Errorf("expected claim reference to default/data only, got %+v", claimRef)
+	}
+	if pv.Spec.PersistentVolumeReclaimPolicy != corev1.PersistentVolumeReclaimDelete || pv.Spec.ClaimRef.UID == "" {
+		t.Errorf("expected pv to be left unchanged")
+	}
+}
+
+func TestRebindPersistentVolume(t *testing.T) {
+	tcases := []struct {
+		name      string
+		phase     corev1.PersistentVolumePhase
+		claimRef  *corev1.ObjectReference
+		expChange bool
+	}{
+		{
+			name:      "released",
+			phase:     corev1.VolumeReleased,
+			claimRef:  &corev1.ObjectReference{Namespace: "default", Name: "data", UID: types.UID("pvc-uid"), ResourceVersion: "41"},
+			expChange: true,
+		},
+		{
+			name:     "released already available to its claim",
+			phase:    corev1.VolumeReleased,
+			claimRef: &corev1.ObjectReference{Namespace: "default", Name: "data"},
+		},
+		{
+			name:     "bound",
+			phase:    corev1.VolumeBound,
+			claimRef: &corev1.ObjectReference{Namespace: "default", Name: "data", UID: types.UID("pvc-uid")},
+		},
+		{
+			name:  "available",
+			phase: corev1.VolumeAvailable,
+		},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			pv := &corev1.PersistentVolume{
+				Spec:   corev1.PersistentVolumeSpec{ClaimRef: tc.claimRef},
+				Status: corev1.PersistentVolumeStatus{Phase: tc.phase},
+			}
+			if changed := rebindPersistentVolume(pv); changed != tc.expChange {
+				t.Errorf("expected change %t, got %t", tc.expChange, changed)
+			}
+			if tc.expChange && (pv.Spec.ClaimRef.UID != "" || pv.Spec.ClaimRef.ResourceVersion != "") {
+				t.Errorf("expected claim reference uid and resource version to be cleared, got %+v", pv.Spec.ClaimRef)
+			}
+		})
+	}
+}
@@ -0,0 +1,249 @@
+package installer
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	pluginutils "github.com/storageos/kubectl-storageos/pkg/utils"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// WorkloadsOutputTable prints the workload report as a table
+	WorkloadsOutputTable = "table"
+	// WorkloadsOutputJSON prints the workload report as JSON
+	WorkloadsOutputJSON = "json"
+
+	errUnknownWorkloadsOutput = `
+	Unknown output format %s, must be one of ` + WorkloadsOutputTable + `, ` + WorkloadsOutputJSON
+
+	noStorageOSPVCsMessage = `No bound PVCs provisioned by StorageOS storageclass provisioner [` + stosSCProvisioner + `] found.`
+)
+
+// workloadReport lists every bound storageos pvc and what is using it
+type workloadReport struct {
+	Volumes []volumeReport `json:"volumes"`
+}
+
+// volumeReport is a bound storageos pvc with the pods mounting it, the workloads owning those
+// pods and the nodes they run on
+type volumeReport struct {
+	Namespace        string   `json:"namespace"`
+	PVC              string   `json:"pvc"`
+	PersistentVolume string   `json:"persistentVolume"`
+	StorageClass     string   `json:"storageClass"`
+	Capacity         string   `json:"capacity,omitempty"`
+	Pods             []string `json:"pods"`
+	Workloads        []string `json:"workloads"`
+	Nodes            []string `json:"nodes"`
+}
+
+// podCount returns the number of pods mounting a storageos pvc
+func (r *workloadReport) podCount() int {
+	count := 0
+	for _, volume := range r.Volumes {
+		count += len(volume.Pods)
+	}
+	return count
+}
+
+// volumesInUse returns the number of storageos pvcs mounted by a pod
+func (r *workloadReport) volumesInUse() int {
+	count := 0
+	for _, volume := range r.Volumes {
+		if len(volume.Pods) != 0 {
+			count++
+		}
+	}
+	return count
+}
+
+// storageOSWorkloadReport returns the workload report of the bound storageos pvcs of the cluster
+func (in *Installer) storageOSWorkloadReport() (*workloadReport, error) {
+	stosPVCs, err := in.storageOSPVCs()
+	if err != nil {
+		return nil, err
+	}
+	pods, err := pluginutils.ListPods(in.clientConfig, "", "")
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	replicaSets, err := pluginutils.ListReplicaSets(in.clientConfig, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	jobs, err := pluginutils.ListJobs(in.clientConfig, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	return buildWorkloadReport(stosPVCs.Items, pods.Items, replicaSets.Items, jobs.Items), nil
+}
+
+// buildWorkloadReport returns the workload report of pvcs. Pods mounting a pvc are resolved to
+// the workload owning them: Deployments through their ReplicaSets and CronJobs through their Jobs.
+func buildWorkloadReport(pvcs []corev1.PersistentVolumeClaim, pods []corev1.Pod, replicaSets []appsv1.ReplicaSet, jobs []batchv1.Job) *workloadReport {
+	replicaSetOwners := map[string][]metav1.OwnerReference{}
+	for _, replicaSet := range replicaSets {
+		replicaSetOwners[replicaSet.Namespace+"/"+replicaSet.Name] = replicaSet.OwnerReferences
+	}
+	jobOwners := map[string][]metav1.OwnerReference{}
+	for _, job := range jobs {
+		jobOwners[job.Namespace+"/"+job.Name] = job.OwnerReferences
+	}
+
+	report := &workloadReport{Volumes: []volumeReport{}}
+	for _, pvc := range pvcs {
+		volume := volumeReport{
+			Namespace:        pvc.Namespace,
+			PVC:              pvc.Name,
+			PersistentVolume: pvc.Spec.VolumeName,
+			StorageClass:     pluginutils.PVCStorageClassName(&pvc),
+			Pods:             []string{},
+			Workloads:        []string{},
+			Nodes:            []string{},
+		}
+		if capacity, ok := pvc.Status.Capacity[corev1.ResourceStorage]; ok {
+			volume.Capacity = capacity.String()
+		}
+
+		workloads := map[string]bool{}
+		nodes := map[string]bool{}
+		for i := range pods {
+			pod := &pods[i]
+			if pod.Namespace != pvc.Namespace || !pluginutils.PodHasPVC(pod, pvc.Name) {
+				continue
+			}
+			// completed pods no longer use the volume
+			if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+				continue
+			}
+			volume.Pods = append(volume.Pods, pod.Name)
+			if pod.Spec.NodeName != "" {
+				nodes[pod.Spec.NodeName] = true
+			}
+			for _, owner := range podWorkloads(pod, replicaSetOwners, jobOwners) {
+				workloads[owner] = true
+			}
+		}
+		sort.Strings(volume.Pods)
+		volume.Workloads = sortedKeys(workloads)
+		volume.Nodes = sortedKeys(nodes)
+
+		report.Volumes = append(report.Volumes, volume)
+	}
+	sort.Slice(report.Volumes, func(i, j int) bool {
+		if report.Volumes[i].Namespace != report.Volumes[j].Namespace {
+			return report.Volumes[i].Namespace < report.Volumes[j].Namespace
+		}
+		return report.Volumes[i].PVC < report.Volumes[j].PVC
+	})
+
+	return report
+}
+
+// podWorkloads returns the workloads owning pod as kind/name, the owners of its ReplicaSets and
+// Jobs taking the place of those when set. A pod without owners is its own workload.
+func podWorkloads(pod *corev1.Pod, replicaSetOwners, jobOwners map[string][]metav1.OwnerReference) []string {
+	if len(pod.OwnerReferences) == 0 {
+		return []string{"Pod/" + pod.Name}
+	}
+
+	workloads := []string{}
+	for _, owner := range pod.OwnerReferences {
+		var parents []metav1.OwnerReference
+		switch owner.Kind {
+		case "ReplicaSet":
+			parents = replicaSetOwners[pod.Namespace+"/"+owner.Name]
+		case "Job":
+			parents = jobOwners[pod.Namespace+"/"+owner.Name]
+		}
+		if len(parents) == 0 {
+			workloads = append(workloads, owner.Kind+"/"+owner.Name)
+			continue
+		}
+		for _, parent := range parents {
+			workloads = append(workloads, parent.Kind+"/"+parent.Name)
+		}
+	}
+
+	return workloads
+}
+
+// sortedKeys returns the keys of set in order
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// PrintWorkloadReport prints the workload report of the bound storageos pvcs of the cluster in
+// output format
+func (in *Installer) PrintWorkloadReport(output string) error {
+	if output != WorkloadsOutputTable && output != WorkloadsOutputJSON {
+		return fmt.Errorf(errUnknownWorkloadsOutput, output)
+	}
+	report, err := in.storageOSWorkloadReport()
+	if err != nil {
+		return err
+	}
+
+	return in.printWorkloadReport(report, output)
+}
+
+// printWorkloadReport prints report in output format
+func (in *Installer) printWorkloadReport(report *workloadReport, output string) error {
+	if output == WorkloadsOutputJSON {
+		data, err := json.MarshalIndent(report, "", "    ")
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		fmt.Fprintln(in.log.Writer, string(data))
+		return nil
+	}
+
+	if len(report.Volumes) == 0 {
+		in.log.Success(noStorageOSPVCsMessage)
+		return nil
+	}
+	in.log.Table(workloadReportHeaders, workloadReportRows(report))
+
+	return nil
+}
+
+var workloadReportHeaders = []string{"NAMESPACE", "PVC", "PV", "STORAGECLASS", "CAPACITY", "PODS", "WORKLOADS", "NODES"}
+
+// workloadReportRows returns the table rows of report, one per pvc
+func workloadReportRows(report *workloadReport) [][]string {
+	rows := make([][]string, 0, len(report.Volumes))
+	for _, volume := range report.Volumes {
+		rows = append(rows, []string{
+			volume.Namespace,
+			volume.PVC,
+			tableValue(volume.PersistentVolume),
+			tableValue(volume.StorageClass),
+			tableValue(volume.Capacity),
+			tableValue(strings.Join(volume.Pods, ",")),
+			tableValue(strings.Join(volume.Workloads, ",")),
+			tableValue(strings.Join(volume.Nodes, ",")),
+		})
+	}
+	return rows
+}
+
+// tableValue returns value, or a placeholder if it is empty
+func tableValue(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
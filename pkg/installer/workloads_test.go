@@ -0,0 +1,129 @@
+package installer
+
+import (
+	"reflect"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestBuildWorkloadReport(t *testing.T) {
+	storageClass := "storageos"
+	pvc := func(namespace, name, volume string) corev1.PersistentVolumeClaim {
+		return corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+			Spec:       corev1.PersistentVolumeClaimSpec{StorageClassName: &storageClass, VolumeName: volume},
+			Status: corev1.PersistentVolumeClaimStatus{
+				Phase:    corev1.ClaimBound,
+				Capacity: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("5Gi")},
+			},
+		}
+	}
+	pod := func(namespace, name, node, claim string, owners ...metav1.OwnerReference) corev1.Pod {
+		return corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, OwnerReferences: owners},
+			Spec: corev1.PodSpec{
+				NodeName: node,
+				Volumes: []corev1.Volume{{
+					Name: "data",
+					VolumeSource: corev1.VolumeSource{
+						PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: claim},
+					},
+				}},
+			},
+		}
+	}
+	owner := func(kind, name string) metav1.OwnerReference {
+		return metav1.OwnerReference{Kind: kind, Name: name}
+	}
+
+	pvcs := []corev1.PersistentVolumeClaim{
+		pvc("default", "web", "pvc-1"),
+		pvc("batch", "reports", "pvc-2"),
+		pvc("default", "db", "pvc-3"),
+		pvc("default", "idle", "pvc-4"),
+	}
+	pods := []corev1.Pod{
+		pod("default", "web-7d4b9-x2", "node-2", "web", owner("ReplicaSet", "web-7d4b9")),
+		pod("default", "web-7d4b9-a1", "node-1", "web", owner("ReplicaSet", "web-7d4b9")),
+		pod("default", "db-0", "node-1", "db", owner("StatefulSet", "db")),
+		pod("batch", "reports-2762-q", "node-3", "reports", owner("Job", "reports-2762")),
+		pod("batch", "debug", "", "reports"),
+		func() corev1.Pod {
+			completed := pod("batch", "reports-2761-z", "node-2", "reports", owner("Job", "reports-2761"))
+			completed.Status.Phase = corev1.PodSucceeded
+			return completed
+		}(),
+		// same claim name in another namespace
+		pod("other", "idle", "node-1", "idle"),
+	}
+	replicaSets := []appsv1.ReplicaSet{{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web-7d4b9", OwnerReferences: []metav1.OwnerReference{owner("Deployment", "web")}},
+	}}
+	jobs := []batchv1.Job{{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "batch", Name: "reports-2762", OwnerReferences: []metav1.OwnerReference{owner("CronJob", "reports")}},
+	}}
+
+	expReport := &workloadReport{Volumes: []volumeReport{
+		{
+			Namespace:        "batch",
+			PVC:              "reports",
+			PersistentVolume: "pvc-2",
+			StorageClass:     storageClass,
+			Capacity:         "5Gi",
+			Pods:             []string{"debug", "reports-2762-q"},
+			Workloads:        []string{"CronJob/reports", "Pod/debug"},
+			Nodes:            []string{"node-3"},
+		},
+		{
+			Namespace:        "default",
+			PVC:              "db",
+			PersistentVolume: "pvc-3",
+			StorageClass:     storageClass,
+			Capacity:         "5Gi",
+			Pods:             []string{"db-0"},
+			Workloads:        []string{"StatefulSet/db"},
+			Nodes:            []string{"node-1"},
+		},
+		{
+			Namespace:        "default",
+			PVC:              "idle",
+			PersistentVolume: "pvc-4",
+			StorageClass:     storageClass,
+			Capacity:         "5Gi",
+			Pods:             []string{},
+			Workloads:        []string{},
+			Nodes:            []string{},
+		},
+		{
+			Namespace:        "default",
+			PVC:              "web",
+			PersistentVolume: "pvc-1",
+			StorageClass:     storageClass,
+			Capacity:         "5Gi",
+			Pods:             []string{"web-7d4b9-a1", "web-7d4b9-x2"},
+			Workloads:        []string{"Deployment/web"},
+			Nodes:            []string{"node-1", "node-2"},
+		},
+	}}
+
+	report := buildWorkloadReport(pvcs, pods, replicaSets, jobs)
+	if !reflect.DeepEqual(report, expReport) {
+		t.Errorf("expected\n%+v\ngot\n%+v", expReport, report)
+	}
+	if report.podCount() != 5 {
+		t.Errorf("expected 5 pods, got %d", report.podCount())
+	}
+	if report.volumesInUse() != 3 {
+		t.Errorf("expected 3 volumes in use, got %d", report.volumesInUse())
+	}
+
+	expRow := []string{"default", "idle", "pvc-4", storageClass, "5Gi", "-", "-", "-"}
+	if row := workloadReportRows(report)[2]; !reflect.DeepEqual(row, expRow) {
+		t.Errorf("expected row %v, got %v", expRow, row)
+	}
+}
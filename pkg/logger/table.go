@@ -0,0 +1,22 @@
+package logger
+
+import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+)
+
+// Table prints rows aligned in columns under the given headers, if any.
+func (l *Logger) Table(headers []string, rows [][]string) {
+	l.writerMu.Lock()
+	defer l.writerMu.Unlock()
+
+	w := tabwriter.NewWriter(l.Writer, 0, 0, 3, ' ', 0)
+	if len(headers) != 0 {
+		fmt.Fprintln(w, strings.Join(headers, "\t"))
+	}
+	for _, row := range rows {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	w.Flush()
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"regexp"
+	"sort"
 	"strings"
 	"time"
 
@@ -15,18 +16,27 @@ import (
 	"github.com/storageos/kubectl-storageos/pkg/consts"
 	operatorapi "github.com/storageos/operator/api/v1"
 
+	appsv1 "k8s.io/api/apps/v1"
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	kstoragev1 "k8s.io/api/storage/v1"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/yaml"
 	kversion "k8s.io/apimachinery/pkg/version"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	storagev1 "k8s.io/client-go/kubernetes/typed/storage/v1"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
 	"k8s.io/client-go/tools/remotecommand"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
@@ -43,8 +53,27 @@ type Distribution int
 const (
 	DistributionGKE Distribution = iota
 	DistributionUnknown
+	DistributionEKS
+	DistributionK3s
+	DistributionRKE2
 )
 
+// String returns the name of the distribution
+func (d Distribution) String() string {
+	switch d {
+	case DistributionGKE:
+		return "GKE"
+	case DistributionEKS:
+		return "EKS"
+	case DistributionK3s:
+		return "k3s"
+	case DistributionRKE2:
+		return "RKE2"
+	default:
+		return "unknown"
+	}
+}
+
 // ResourcesStillExists contains all the existing resource types in namespace
 type ResourcesStillExists struct {
 	namespace string
@@ -71,6 +100,12 @@ func DetermineDistribution(version string) Distribution {
 	switch {
 	case gkeVersionRegexp.Match([]byte(version)):
 		return DistributionGKE
+	case strings.Contains(version, "-eks-"):
+		return DistributionEKS
+	case strings.Contains(version, "+k3s"):
+		return DistributionK3s
+	case strings.Contains(version, "+rke2"):
+		return DistributionRKE2
 	default:
 		return DistributionUnknown
 	}
@@ -116,12 +151,24 @@ func GetKubernetesVersion(config *rest.Config) (*kversion.Info, error) {
 
 // ExecToPod execs into a pod and executes command from inside that pod.
 // containerName can be "" if the pod contains only a single container.
-// Returned are strings represent STDOUT and STDERR respectively.
+// Returned are strings represent STDOUT and STDERR respectively, these are also returned
+// alongside a stream error so that a failed command can be diagnosed.
 // Also returned is any error encountered.
 func ExecToPod(config *rest.Config, command []string, containerName, podName, namespace string, stdin io.Reader) (string, string, error) {
+	var stdout bytes.Buffer
+	stderr, err := StreamFromPod(config, command, containerName, podName, namespace, stdin, &stdout)
+
+	return stdout.String(), stderr, err
+}
+
+// StreamFromPod execs into a pod and executes command from inside that pod, writing its STDOUT to
+// stdout as it is received, so that large outputs such as files are not held in memory.
+// containerName can be "" if the pod contains only a single container.
+// Returned is STDERR alongside any error encountered.
+func StreamFromPod(config *rest.Config, command []string, containerName, podName, namespace string, stdin io.Reader, stdout io.Writer) (string, error) {
 	clientset, err := GetClientsetFromConfig(config)
 	if err != nil {
-		return "", "", err
+		return "", err
 	}
 	req := clientset.CoreV1().RESTClient().Post().
 		Resource("pods").
@@ -130,7 +177,7 @@ func ExecToPod(config *rest.Config, command []string, containerName, podName, na
 		SubResource("exec")
 	scheme := runtime.NewScheme()
 	if err := corev1.AddToScheme(scheme); err != nil {
-		return "", "", errors.WithStack(fmt.Errorf("error adding to scheme: %v", err))
+		return "", errors.WithStack(fmt.Errorf("error adding to scheme: %v", err))
 	}
 
 	parameterCodec := runtime.NewParameterCodec(scheme)
@@ -145,20 +192,20 @@ func ExecToPod(config *rest.Config, command []string, containerName, podName, na
 
 	exec, err := remotecommand.NewSPDYExecutor(config, "POST", req.URL())
 	if err != nil {
-		return "", "", errors.WithStack(fmt.Errorf("error while creating Executor: %v", err))
+		return "", errors.WithStack(fmt.Errorf("error while creating Executor: %v", err))
 	}
 
-	var stdout, stderr bytes.Buffer
+	var stderr bytes.Buffer
 	if err = exec.Stream(remotecommand.StreamOptions{
 		Stdin:  stdin,
-		Stdout: &stdout,
+		Stdout: stdout,
 		Stderr: &stderr,
 		Tty:    false,
 	}); err != nil {
-		return "", "", errors.WithStack(fmt.Errorf("error in Stream: %v", err))
+		return stderr.String(), errors.WithStack(fmt.Errorf("error in Stream: %v", err))
 	}
 
-	return stdout.String(), stderr.String(), nil
+	return stderr.String(), nil
 }
 
 // FetchPodLogs fetches logs of the given pod.
@@ -366,6 +413,226 @@ func IsDeploymentReady(config *rest.Config, name, namespace string) error {
 	return nil
 }
 
+// RestartDeployment restarts the pods of a deployment by name and namespace, the same way as
+// `kubectl rollout restart` does.
+func RestartDeployment(config *rest.Config, name, namespace string) error {
+	clientset, err := GetClientsetFromConfig(config)
+	if err != nil {
+		return err
+	}
+	patch := fmt.Sprintf(`{"spec":{"template":{"metadata":{"annotations":{"kubectl.kubernetes.io/restartedAt":%q}}}}}`, time.Now().Format(time.RFC3339))
+	_, err = clientset.AppsV1().Deployments(namespace).Patch(context.TODO(), name, types.StrategicMergePatchType, []byte(patch), metav1.PatchOptions{})
+
+	return errors.WithStack(err)
+}
+
+// IsDeploymentRolledOut attempts to `get` a deployment by name and namespace, the function returns
+// no error once every replica runs the latest pod template and is available.
+func IsDeploymentRolledOut(config *rest.Config, name, namespace string) error {
+	dep, err := GetDeployment(config, name, namespace)
+	if err != nil {
+		return err
+	}
+
+	return deploymentRolledOut(dep)
+}
+
+// deploymentRolledOut returns an error describing why the rollout of dep is not complete
+func deploymentRolledOut(dep *appsv1.Deployment) error {
+	replicas := int32(1)
+	if dep.Spec.Replicas != nil {
+		replicas = *dep.Spec.Replicas
+	}
+	switch {
+	case dep.Status.ObservedGeneration < dep.Generation:
+		return fmt.Errorf("deployment %s; %s update has not been observed", dep.Name, dep.Namespace)
+	case dep.Status.UpdatedReplicas < replicas:
+		return fmt.Errorf("deployment %s; %s has %d of %d replicas updated", dep.Name, dep.Namespace, dep.Status.UpdatedReplicas, replicas)
+	case dep.Status.Replicas > dep.Status.UpdatedReplicas:
+		return fmt.Errorf("deployment %s; %s has %d old replicas pending termination", dep.Name, dep.Namespace, dep.Status.Replicas-dep.Status.UpdatedReplicas)
+	case dep.Status.AvailableReplicas < replicas:
+		return fmt.Errorf("deployment %s; %s has %d of %d replicas available", dep.Name, dep.Namespace, dep.Status.AvailableReplicas, replicas)
+	}
+	return nil
+}
+
+// GetDeployment returns the deployment of name and namespace.
+func GetDeployment(config *rest.Config, name, namespace string) (*appsv1.Deployment, error) {
+	clientset, err := GetClientsetFromConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	dep, err := clientset.AppsV1().Deployments(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return dep, nil
+}
+
+// GetDeploymentReplicas returns the replicas of a deployment by name and namespace.
+func GetDeploymentReplicas(config *rest.Config, name, namespace string) (int32, error) {
+	clientset, err := GetClientsetFromConfig(config)
+	if err != nil {
+		return 0, err
+	}
+	scale, err := clientset.AppsV1().Deployments(namespace).GetScale(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	return scale.Spec.Replicas, nil
+}
+
+// ScaleDeployment sets the replicas of a deployment by name and namespace, returning the number of
+// replicas before scaling.
+func ScaleDeployment(config *rest.Config, name, namespace string, replicas int32) (int32, error) {
+	clientset, err := GetClientsetFromConfig(config)
+	if err != nil {
+		return 0, err
+	}
+	depClient := clientset.AppsV1().Deployments(namespace)
+
+	scale, err := depClient.GetScale(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	previous := scale.Spec.Replicas
+	scale.Spec.Replicas = replicas
+	if _, err = depClient.UpdateScale(context.TODO(), name, scale, metav1.UpdateOptions{}); err != nil {
+		return 0, errors.WithStack(err)
+	}
+	return previous, nil
+}
+
+// ScaleStatefulSet sets the replicas of a statefulset by name and namespace, returning the number of
+// replicas before scaling.
+func ScaleStatefulSet(config *rest.Config, name, namespace string, replicas int32) (int32, error) {
+	clientset, err := GetClientsetFromConfig(config)
+	if err != nil {
+		return 0, err
+	}
+	stsClient := clientset.AppsV1().StatefulSets(namespace)
+
+	scale, err := stsClient.GetScale(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	previous := scale.Spec.Replicas
+	scale.Spec.Replicas = replicas
+	if _, err = stsClient.UpdateScale(context.TODO(), name, scale, metav1.UpdateOptions{}); err != nil {
+		return 0, errors.WithStack(err)
+	}
+	return previous, nil
+}
+
+// GetStatefulSetReplicas returns the replicas of a statefulset by name and namespace.
+func GetStatefulSetReplicas(config *rest.Config, name, namespace string) (int32, error) {
+	clientset, err := GetClientsetFromConfig(config)
+	if err != nil {
+		return 0, err
+	}
+	scale, err := clientset.AppsV1().StatefulSets(namespace).GetScale(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	return scale.Spec.Replicas, nil
+}
+
+// CronJobIsSuspended returns whether a cronjob by name and namespace is suspended.
+func CronJobIsSuspended(config *rest.Config, name, namespace string) (bool, error) {
+	clientset, err := GetClientsetFromConfig(config)
+	if err != nil {
+		return false, err
+	}
+	cronJob, err := clientset.BatchV1().CronJobs(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+	return cronJob.Spec.Suspend != nil && *cronJob.Spec.Suspend, nil
+}
+
+// SuspendCronJob sets whether a cronjob by name and namespace is suspended, returning whether it was
+// suspended before.
+func SuspendCronJob(config *rest.Config, name, namespace string, suspend bool) (bool, error) {
+	clientset, err := GetClientsetFromConfig(config)
+	if err != nil {
+		return false, err
+	}
+	cronJobClient := clientset.BatchV1().CronJobs(namespace)
+
+	cronJob, err := cronJobClient.Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+	previous := cronJob.Spec.Suspend != nil && *cronJob.Spec.Suspend
+	cronJob.Spec.Suspend = &suspend
+	if _, err = cronJobClient.Update(context.TODO(), cronJob, metav1.UpdateOptions{}); err != nil {
+		return false, errors.WithStack(err)
+	}
+	return previous, nil
+}
+
+// DeleteDaemonSet deletes a daemonset by name and namespace, its pods are deleted in the foreground.
+// No error is returned if the daemonset does not exist.
+func DeleteDaemonSet(config *rest.Config, name, namespace string) error {
+	clientset, err := GetClientsetFromConfig(config)
+	if err != nil {
+		return err
+	}
+	propagationPolicy := metav1.DeletePropagationForeground
+	err = clientset.AppsV1().DaemonSets(namespace).Delete(context.TODO(), name, metav1.DeleteOptions{PropagationPolicy: &propagationPolicy})
+	if err != nil && !kerrors.IsNotFound(err) {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// DaemonSetDoesNotExist returns no error only if the daemonset of name and namespace does not exist
+func DaemonSetDoesNotExist(config *rest.Config, name, namespace string) error {
+	clientset, err := GetClientsetFromConfig(config)
+	if err != nil {
+		return err
+	}
+	_, err = clientset.AppsV1().DaemonSets(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	if err == nil {
+		return fmt.Errorf("daemonset %s; %s still exists", name, namespace)
+	}
+	if kerrors.IsNotFound(err) {
+		return nil
+	}
+	return errors.WithStack(err)
+}
+
+// IsDaemonSetRolledOut attempts to `get` a daemonset by name and namespace, the function returns
+// no error once it is scheduled on at least one node and every pod runs the latest pod template
+// and is ready.
+func IsDaemonSetRolledOut(config *rest.Config, name, namespace string) error {
+	clientset, err := GetClientsetFromConfig(config)
+	if err != nil {
+		return err
+	}
+	ds, err := clientset.AppsV1().DaemonSets(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	return daemonSetRolledOut(ds)
+}
+
+// daemonSetRolledOut returns an error describing why the rollout of ds is not complete
+func daemonSetRolledOut(ds *appsv1.DaemonSet) error {
+	switch {
+	case ds.Status.ObservedGeneration < ds.Generation:
+		return fmt.Errorf("daemonset %s; %s update has not been observed", ds.Name, ds.Namespace)
+	case ds.Status.DesiredNumberScheduled == 0:
+		return fmt.Errorf("daemonset %s; %s is not scheduled on any node", ds.Name, ds.Namespace)
+	case ds.Status.UpdatedNumberScheduled < ds.Status.DesiredNumberScheduled:
+		return fmt.Errorf("daemonset %s; %s has %d of %d pods updated", ds.Name, ds.Namespace, ds.Status.UpdatedNumberScheduled, ds.Status.DesiredNumberScheduled)
+	case ds.Status.NumberReady < ds.Status.DesiredNumberScheduled:
+		return fmt.Errorf("daemonset %s; %s has %d of %d pods ready", ds.Name, ds.Namespace, ds.Status.NumberReady, ds.Status.DesiredNumberScheduled)
+	}
+	return nil
+}
+
 // IsServiceReady attempts to `get` a service by name and namespace, the function returns no error
 // if the service doesn't have a ClusterIP or any ready endpoints.
 func IsServiceReady(config *rest.Config, name, namespace string) error {
@@ -410,11 +677,30 @@ func IsPodRunning(config *rest.Config, name, namespace string) error {
 		return err
 	}
 	if pod.Status.Phase != corev1.PodRunning {
+		if reason := podPendingReason(pod); reason != "" {
+			return fmt.Errorf("pod %s; %s is not in running phase: %s", name, namespace, reason)
+		}
 		return fmt.Errorf("pod %s; %s is not in running phase", name, namespace)
 	}
 	return nil
 }
 
+// podPendingReason returns the reason a pod is not yet running, such as being unschedulable or
+// failing to pull its image, or an empty string if no reason is known.
+func podPendingReason(pod *corev1.Pod) string {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodScheduled && condition.Status == corev1.ConditionFalse && condition.Message != "" {
+			return condition.Message
+		}
+	}
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.State.Waiting != nil && status.State.Waiting.Reason != "" {
+			return strings.TrimSpace(fmt.Sprintf("%s %s", status.State.Waiting.Reason, status.State.Waiting.Message))
+		}
+	}
+	return ""
+}
+
 // GetNamespace return namespace object
 func GetNamespace(config *rest.Config, namespace string) (*corev1.Namespace, error) {
 	clientset, err := GetClientsetFromConfig(config)
@@ -491,6 +777,125 @@ func ListPersistentVolumeClaims(config *rest.Config, listOptions metav1.ListOpti
 	return pvcs, nil
 }
 
+// ListPersistentVolumes returns PersistentVolumeList
+func ListPersistentVolumes(config *rest.Config, listOptions metav1.ListOptions) (*corev1.PersistentVolumeList, error) {
+	clientset, err := GetClientsetFromConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	pvs, err := clientset.CoreV1().PersistentVolumes().List(context.TODO(), listOptions)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return pvs, nil
+}
+
+// GetPersistentVolume returns the persistent volume of name
+func GetPersistentVolume(config *rest.Config, name string) (*corev1.PersistentVolume, error) {
+	clientset, err := GetClientsetFromConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	pv, err := clientset.CoreV1().PersistentVolumes().Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return pv, nil
+}
+
+// CreatePersistentVolume creates k8s persistent volume.
+func CreatePersistentVolume(config *rest.Config, pv *corev1.PersistentVolume) error {
+	clientset, err := GetClientsetFromConfig(config)
+	if err != nil {
+		return err
+	}
+	_, err = clientset.CoreV1().PersistentVolumes().Create(context.TODO(), pv, metav1.CreateOptions{})
+
+	return errors.WithStack(err)
+}
+
+// UpdatePersistentVolume updates k8s persistent volume.
+func UpdatePersistentVolume(config *rest.Config, pv *corev1.PersistentVolume) error {
+	clientset, err := GetClientsetFromConfig(config)
+	if err != nil {
+		return err
+	}
+	_, err = clientset.CoreV1().PersistentVolumes().Update(context.TODO(), pv, metav1.UpdateOptions{})
+
+	return errors.WithStack(err)
+}
+
+// GetPersistentVolumeClaim returns the persistent volume claim of name and namespace
+func GetPersistentVolumeClaim(config *rest.Config, name, namespace string) (*corev1.PersistentVolumeClaim, error) {
+	clientset, err := GetClientsetFromConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	pvc, err := clientset.CoreV1().PersistentVolumeClaims(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return pvc, nil
+}
+
+// CreatePersistentVolumeClaim creates k8s persistent volume claim.
+func CreatePersistentVolumeClaim(config *rest.Config, pvc *corev1.PersistentVolumeClaim) error {
+	clientset, err := GetClientsetFromConfig(config)
+	if err != nil {
+		return err
+	}
+	_, err = clientset.CoreV1().PersistentVolumeClaims(pvc.Namespace).Create(context.TODO(), pvc, metav1.CreateOptions{})
+
+	return errors.WithStack(err)
+}
+
+// ListDeployments returns the DeploymentList of all namespaces
+func ListDeployments(config *rest.Config, listOptions metav1.ListOptions) (*appsv1.DeploymentList, error) {
+	clientset, err := GetClientsetFromConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	deployments, err := clientset.AppsV1().Deployments("").List(context.TODO(), listOptions)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return deployments, nil
+}
+
+// ListReplicaSets returns ReplicaSetList
+func ListReplicaSets(config *rest.Config, listOptions metav1.ListOptions) (*appsv1.ReplicaSetList, error) {
+	clientset, err := GetClientsetFromConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	replicaSets, err := clientset.AppsV1().ReplicaSets("").List(context.TODO(), listOptions)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return replicaSets, nil
+}
+
+// ListJobs returns JobList
+func ListJobs(config *rest.Config, listOptions metav1.ListOptions) (*batchv1.JobList, error) {
+	clientset, err := GetClientsetFromConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	jobs, err := clientset.BatchV1().Jobs("").List(context.TODO(), listOptions)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return jobs, nil
+}
+
 // ListConfigMaps returns ConfigMapList
 func ListConfigMaps(config *rest.Config, listOptions metav1.ListOptions) (*corev1.ConfigMapList, error) {
 	clientset, err := GetClientsetFromConfig(config)
@@ -505,6 +910,55 @@ func ListConfigMaps(config *rest.Config, listOptions metav1.ListOptions) (*corev
 	return configMaps, nil
 }
 
+// GetConfigMap returns configmap name/namespace
+func GetConfigMap(config *rest.Config, name, namespace string) (*corev1.ConfigMap, error) {
+	clientset, err := GetClientsetFromConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	configMap, err := clientset.CoreV1().ConfigMaps(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return configMap, nil
+}
+
+// CreateOrUpdateConfigMap creates configMap, or updates its data if it already exists
+func CreateOrUpdateConfigMap(config *rest.Config, configMap *corev1.ConfigMap) error {
+	clientset, err := GetClientsetFromConfig(config)
+	if err != nil {
+		return err
+	}
+	configMapClient := clientset.CoreV1().ConfigMaps(configMap.Namespace)
+	existing, err := configMapClient.Get(context.TODO(), configMap.Name, metav1.GetOptions{})
+	if err != nil {
+		if !kerrors.IsNotFound(err) {
+			return errors.WithStack(err)
+		}
+		_, err = configMapClient.Create(context.TODO(), configMap, metav1.CreateOptions{})
+		return errors.WithStack(err)
+	}
+	existing.Data = configMap.Data
+	_, err = configMapClient.Update(context.TODO(), existing, metav1.UpdateOptions{})
+
+	return errors.WithStack(err)
+}
+
+// DeleteConfigMap deletes configmap name/namespace. No error is returned if it does not exist.
+func DeleteConfigMap(config *rest.Config, name, namespace string) error {
+	clientset, err := GetClientsetFromConfig(config)
+	if err != nil {
+		return err
+	}
+	err = clientset.CoreV1().ConfigMaps(namespace).Delete(context.TODO(), name, metav1.DeleteOptions{})
+	if err != nil && !kerrors.IsNotFound(err) {
+		return errors.WithStack(err)
+	}
+
+	return nil
+}
+
 // CreateStorageClass creates k8s storage class.
 func CreateStorageClass(config *rest.Config, storageClass *kstoragev1.StorageClass) error {
 	clientset, err := GetClientsetFromConfig(config)
@@ -600,6 +1054,24 @@ func GetFirstStorageOSCluster(config *rest.Config) (*operatorapi.StorageOSCluste
 	return stosCluster, nil
 }
 
+// ListStorageOSClusters returns the storageos clusters of all namespaces, none if the
+// StorageOSCluster CRD is not served
+func ListStorageOSClusters(config *rest.Config) ([]operatorapi.StorageOSCluster, error) {
+	stosClusterList := &operatorapi.StorageOSClusterList{}
+	newClient, err := storageOSOperatorClient(config)
+	if err != nil {
+		return nil, err
+	}
+	if err = newClient.List(context.TODO(), stosClusterList, &client.ListOptions{}); err != nil {
+		if meta.IsNoMatchError(err) || kerrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, errors.WithStack(err)
+	}
+
+	return stosClusterList.Items, nil
+}
+
 func storageOSOperatorClient(config *rest.Config) (client.Client, error) {
 	scheme := runtime.NewScheme()
 	if err := operatorapi.AddToScheme(scheme); err != nil {
@@ -633,6 +1105,15 @@ func UpdateStorageOSClusterWithoutFinalizers(config *rest.Config, storageosClust
 	return newClient.Update(context.TODO(), storageosCluster)
 }
 
+// UpdateStorageOSCluster updates the storageoscluster object.
+func UpdateStorageOSCluster(config *rest.Config, storageosCluster *operatorapi.StorageOSCluster) error {
+	newClient, err := storageOSOperatorClient(config)
+	if err != nil {
+		return err
+	}
+	return newClient.Update(context.TODO(), storageosCluster)
+}
+
 // GetEtcdCluster returns the etcdcluster object of name and namespace.
 func GetEtcdCluster(config *rest.Config, name, namespace string) (*etcdoperatorapi.EtcdCluster, error) {
 	etcdCluster := &etcdoperatorapi.EtcdCluster{}
@@ -646,6 +1127,55 @@ func GetEtcdCluster(config *rest.Config, name, namespace string) (*etcdoperatora
 	return etcdCluster, nil
 }
 
+// GetFirstEtcdCluster returns the first etcdcluster object found in namespace.
+func GetFirstEtcdCluster(config *rest.Config, namespace string) (*etcdoperatorapi.EtcdCluster, error) {
+	etcdCluster := &etcdoperatorapi.EtcdCluster{}
+	etcdClusterList := &etcdoperatorapi.EtcdClusterList{}
+	newClient, err := etcdOperatorClient(config)
+	if err != nil {
+		return etcdCluster, err
+	}
+	if err = newClient.List(context.TODO(), etcdClusterList, &client.ListOptions{Namespace: namespace}); err != nil {
+		return etcdCluster, errors.WithStack(err)
+	}
+
+	if len(etcdClusterList.Items) == 0 {
+		return etcdCluster, kerrors.NewNotFound(etcdoperatorapi.GroupVersion.WithResource("EtcdCluster").GroupResource(), "")
+	}
+
+	etcdCluster = &etcdClusterList.Items[0]
+	return etcdCluster, nil
+}
+
+// ListEtcdPeers returns the etcdpeer objects of the etcdcluster of name and namespace.
+func ListEtcdPeers(config *rest.Config, clusterName, namespace string) ([]etcdoperatorapi.EtcdPeer, error) {
+	newClient, err := etcdOperatorClient(config)
+	if err != nil {
+		return nil, err
+	}
+	etcdPeerList := &etcdoperatorapi.EtcdPeerList{}
+	if err = newClient.List(context.TODO(), etcdPeerList, &client.ListOptions{Namespace: namespace}); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	peers := []etcdoperatorapi.EtcdPeer{}
+	for _, peer := range etcdPeerList.Items {
+		if peer.Spec.ClusterName == clusterName {
+			peers = append(peers, peer)
+		}
+	}
+	return peers, nil
+}
+
+// UpdateEtcdCluster updates the etcdcluster object.
+func UpdateEtcdCluster(config *rest.Config, etcdCluster *etcdoperatorapi.EtcdCluster) error {
+	newClient, err := etcdOperatorClient(config)
+	if err != nil {
+		return err
+	}
+	return newClient.Update(context.TODO(), etcdCluster)
+}
+
 func etcdOperatorClient(config *rest.Config) (client.Client, error) {
 	scheme := runtime.NewScheme()
 	if err := etcdoperatorapi.AddToScheme(scheme); err != nil {
@@ -792,3 +1322,232 @@ func CreateJobAndFetchResult(config *rest.Config, name, namespace, image, cmd st
 		return FetchPodLogs(config, pod.Name, namespace)
 	}
 }
+
+// ClusterResource is a cluster-scoped resource served by the API server
+type ClusterResource struct {
+	Resource schema.GroupVersionResource
+	Kind     string
+}
+
+// ListClusterResources returns the cluster-scoped resources of every API group that can be listed
+// and deleted, at the preferred version of their group. Groups that fail discovery are skipped.
+func ListClusterResources(config *rest.Config) ([]ClusterResource, error) {
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	resourceLists, err := discoveryClient.ServerPreferredResources()
+	if err != nil && !discovery.IsGroupDiscoveryFailedError(err) {
+		return nil, errors.WithStack(err)
+	}
+
+	resources := []ClusterResource{}
+	for _, resourceList := range resourceLists {
+		groupVersion, err := schema.ParseGroupVersion(resourceList.GroupVersion)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		for _, resource := range resourceList.APIResources {
+			if resource.Namespaced || !hasVerbs(resource.Verbs, "list", "delete") {
+				continue
+			}
+			resources = append(resources, ClusterResource{
+				Resource: groupVersion.WithResource(resource.Name),
+				Kind:     resource.Kind,
+			})
+		}
+	}
+
+	return resources, nil
+}
+
+// hasVerbs returns true if verbs holds every verb of wanted
+func hasVerbs(verbs metav1.Verbs, wanted ...string) bool {
+	for _, verb := range wanted {
+		found := false
+		for _, v := range verbs {
+			if v == verb {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// ListObjects returns the objects of resource in namespace, of all namespaces if namespace is empty
+func ListObjects(config *rest.Config, resource schema.GroupVersionResource, namespace string) ([]unstructured.Unstructured, error) {
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	list, err := dynamicClient.Resource(resource).Namespace(namespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return list.Items, nil
+}
+
+// GetObject returns the object of resource by name and namespace
+func GetObject(config *rest.Config, resource schema.GroupVersionResource, name, namespace string) (*unstructured.Unstructured, error) {
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	obj, err := dynamicClient.Resource(resource).Namespace(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return obj, nil
+}
+
+// DeleteObject deletes the object of resource by name and namespace. No error is returned if the
+// object does not exist.
+func DeleteObject(config *rest.Config, resource schema.GroupVersionResource, name, namespace string) error {
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	err = dynamicClient.Resource(resource).Namespace(namespace).Delete(context.TODO(), name, metav1.DeleteOptions{})
+	if err != nil && !kerrors.IsNotFound(err) {
+		return errors.WithStack(err)
+	}
+
+	return nil
+}
+
+// ObjectDoesNotExist returns no error only if the object of resource by name and namespace does not exist
+func ObjectDoesNotExist(config *rest.Config, resource schema.GroupVersionResource, name, namespace string) error {
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	_, err = dynamicClient.Resource(resource).Namespace(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	if err == nil {
+		return fmt.Errorf("%s %s still exists", resource.Resource, name)
+	}
+	if kerrors.IsNotFound(err) {
+		return nil
+	}
+
+	return errors.WithStack(err)
+}
+
+// RemoveObjectFinalizers removes the finalizers of the object of resource by name and namespace. No
+// error is returned if the object does not exist.
+func RemoveObjectFinalizers(config *rest.Config, resource schema.GroupVersionResource, name, namespace string) error {
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	patch := []byte(`{"metadata":{"finalizers":null}}`)
+	_, err = dynamicClient.Resource(resource).Namespace(namespace).Patch(context.TODO(), name, types.MergePatchType, patch, metav1.PatchOptions{})
+	if err != nil && !kerrors.IsNotFound(err) {
+		return errors.WithStack(err)
+	}
+
+	return nil
+}
+
+// UpdateObject updates obj of resource
+func UpdateObject(config *rest.Config, resource schema.GroupVersionResource, obj *unstructured.Unstructured) error {
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	_, err = dynamicClient.Resource(resource).Namespace(obj.GetNamespace()).Update(context.TODO(), obj, metav1.UpdateOptions{})
+
+	return errors.WithStack(err)
+}
+
+// PatchObject patches the object of resource by name and namespace, or its subresources if set
+func PatchObject(config *rest.Config, resource schema.GroupVersionResource, name, namespace string, patchType types.PatchType, patch []byte, subresources ...string) error {
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	_, err = dynamicClient.Resource(resource).Namespace(namespace).Patch(context.TODO(), name, patchType, patch, metav1.PatchOptions{}, subresources...)
+
+	return errors.WithStack(err)
+}
+
+// ServerSideApply applies every document of the multi-doc manifest with server-side apply as
+// fieldManager, taking over the fields owned by other managers. Namespaces and CRDs are applied
+// first, so that the resources defined by the CRDs can be mapped once established.
+func ServerSideApply(config *rest.Config, manifest, fieldManager string) error {
+	objects := []*unstructured.Unstructured{}
+	decoder := yaml.NewYAMLOrJSONDecoder(strings.NewReader(manifest), 4096)
+	for {
+		obj := &unstructured.Unstructured{}
+		if err := decoder.Decode(&obj.Object); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return errors.WithStack(err)
+		}
+		if len(obj.Object) == 0 {
+			continue
+		}
+		objects = append(objects, obj)
+	}
+	applyOrder := func(obj *unstructured.Unstructured) int {
+		switch obj.GetKind() {
+		case "Namespace":
+			return 0
+		case "CustomResourceDefinition":
+			return 1
+		default:
+			return 2
+		}
+	}
+	sort.SliceStable(objects, func(i, j int) bool {
+		return applyOrder(objects[i]) < applyOrder(objects[j])
+	})
+
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	mapper := restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(discoveryClient))
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	force := true
+	for _, obj := range objects {
+		gvk := obj.GroupVersionKind()
+		mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+		if meta.IsNoMatchError(err) {
+			// the kind is defined by a CRD applied above, wait for it to be served
+			err = WaitFor(func() error {
+				mapper.Reset()
+				var mappingErr error
+				mapping, mappingErr = mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+				return mappingErr
+			}, 30, 2)
+		}
+		if err != nil {
+			return errors.WithStack(err)
+		}
+
+		data, err := obj.MarshalJSON()
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		var resourceClient dynamic.ResourceInterface = dynamicClient.Resource(mapping.Resource)
+		if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+			resourceClient = dynamicClient.Resource(mapping.Resource).Namespace(obj.GetNamespace())
+		}
+		if _, err = resourceClient.Patch(context.TODO(), obj.GetName(), types.ApplyPatchType, data, metav1.PatchOptions{FieldManager: fieldManager, Force: &force}); err != nil {
+			return errors.Wrapf(err, "failed to apply %s %s", gvk.Kind, obj.GetName())
+		}
+	}
+
+	return nil
+}
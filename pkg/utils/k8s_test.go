@@ -1,6 +1,10 @@
 package utils
 
-import "testing"
+import (
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+)
 
 func TestDetermineDistribution(t *testing.T) {
 	tests := map[string]struct {
@@ -11,6 +15,18 @@ func TestDetermineDistribution(t *testing.T) {
 			input:    "v1.20.10-gke.301",
 			expected: DistributionGKE,
 		},
+		"EKS": {
+			input:    "v1.22.9-eks-a64ea69",
+			expected: DistributionEKS,
+		},
+		"k3s": {
+			input:    "v1.23.6+k3s1",
+			expected: DistributionK3s,
+		},
+		"RKE2": {
+			input:    "v1.23.7+rke2r2",
+			expected: DistributionRKE2,
+		},
 		"Unknown": {
 			input:    "v1.22.8",
 			expected: DistributionUnknown,
@@ -31,3 +47,90 @@ func TestDetermineDistribution(t *testing.T) {
 		})
 	}
 }
+
+func TestDeploymentRolledOut(t *testing.T) {
+	replicas := int32(2)
+	tests := map[string]struct {
+		generation int64
+		status     appsv1.DeploymentStatus
+		expectErr  bool
+	}{
+		"rolled out": {
+			generation: 2,
+			status:     appsv1.DeploymentStatus{ObservedGeneration: 2, Replicas: 2, UpdatedReplicas: 2, AvailableReplicas: 2},
+		},
+		"update not observed": {
+			generation: 3,
+			status:     appsv1.DeploymentStatus{ObservedGeneration: 2, Replicas: 2, UpdatedReplicas: 2, AvailableReplicas: 2},
+			expectErr:  true,
+		},
+		"replicas not updated": {
+			generation: 2,
+			status:     appsv1.DeploymentStatus{ObservedGeneration: 2, Replicas: 2, UpdatedReplicas: 1, AvailableReplicas: 2},
+			expectErr:  true,
+		},
+		"old replicas pending termination": {
+			generation: 2,
+			status:     appsv1.DeploymentStatus{ObservedGeneration: 2, Replicas: 3, UpdatedReplicas: 2, AvailableReplicas: 2},
+			expectErr:  true,
+		},
+		"replicas not available": {
+			generation: 2,
+			status:     appsv1.DeploymentStatus{ObservedGeneration: 2, Replicas: 2, UpdatedReplicas: 2, AvailableReplicas: 1},
+			expectErr:  true,
+		},
+	}
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			dep := &appsv1.Deployment{Spec: appsv1.DeploymentSpec{Replicas: &replicas}, Status: tc.status}
+			dep.Generation = tc.generation
+			if err := deploymentRolledOut(dep); (err != nil) != tc.expectErr {
+				t.Errorf("expected error %v, got %v", tc.expectErr, err)
+			}
+		})
+	}
+}
+
+func TestDaemonSetRolledOut(t *testing.T) {
+	tests := map[string]struct {
+		generation int64
+		status     appsv1.DaemonSetStatus
+		expectErr  bool
+	}{
+		"rolled out": {
+			generation: 1,
+			status:     appsv1.DaemonSetStatus{ObservedGeneration: 1, DesiredNumberScheduled: 3, UpdatedNumberScheduled: 3, NumberReady: 3},
+		},
+		"update not observed": {
+			generation: 2,
+			status:     appsv1.DaemonSetStatus{ObservedGeneration: 1, DesiredNumberScheduled: 3, UpdatedNumberScheduled: 3, NumberReady: 3},
+			expectErr:  true,
+		},
+		"not scheduled": {
+			generation: 1,
+			status:     appsv1.DaemonSetStatus{ObservedGeneration: 1},
+			expectErr:  true,
+		},
+		"pods not updated": {
+			generation: 1,
+			status:     appsv1.DaemonSetStatus{ObservedGeneration: 1, DesiredNumberScheduled: 3, UpdatedNumberScheduled: 2, NumberReady: 3},
+			expectErr:  true,
+		},
+		"pods not ready": {
+			generation: 1,
+			status:     appsv1.DaemonSetStatus{ObservedGeneration: 1, DesiredNumberScheduled: 3, UpdatedNumberScheduled: 3, NumberReady: 2},
+			expectErr:  true,
+		},
+	}
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			ds := &appsv1.DaemonSet{Status: tc.status}
+			ds.Generation = tc.generation
+			if err := daemonSetRolledOut(ds); (err != nil) != tc.expectErr {
+				t.Errorf("expected error %v, got %v", tc.expectErr, err)
+			}
+		})
+	}
+}
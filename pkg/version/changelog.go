@@ -0,0 +1,152 @@
+package version
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	// breakingRegexp matches the titles of release note sections describing breaking changes
+	breakingRegexp = regexp.MustCompile(`(?i)breaking`)
+	// boldTitleRegexp matches release note lines used as section titles, such as **Breaking changes**
+	boldTitleRegexp = regexp.MustCompile(`^\*\*([^*]+)\*\*:?$`)
+)
+
+// ReleaseNotes holds the parsed release notes of a single release
+type ReleaseNotes struct {
+	Version    string
+	Prerelease bool
+	URL        string
+	Sections   []ReleaseNotesSection
+}
+
+// ReleaseNotesSection is a section of release notes. The first section has no title if the notes
+// do not start with one.
+type ReleaseNotesSection struct {
+	Title    string
+	Lines    []string
+	Breaking bool
+}
+
+// HasBreakingChanges returns true if any section of the release notes describes breaking changes
+func (r ReleaseNotes) HasBreakingChanges() bool {
+	for _, section := range r.Sections {
+		if section.Breaking {
+			return true
+		}
+	}
+
+	return false
+}
+
+// FetchOperatorReleases returns the releases of the StorageOS operator
+func FetchOperatorReleases() ([]GithubRelease, error) {
+	return fetchVersions(operatorReleasesUrl)
+}
+
+// ReleasesBetween returns the releases after version from up to and including version to, oldest
+// first. Drafts are skipped, as are prereleases and release candidates other than to unless
+// the release channel includes them. No releases are returned for develop versions.
+func ReleasesBetween(releases []GithubRelease, from, to string) ([]GithubRelease, error) {
+	between := []GithubRelease{}
+	if IsDevelop(from) || IsDevelop(to) {
+		return between, nil
+	}
+
+	for _, release := range releases {
+		version := cleanupVersion(release.TagName)
+		if release.Draft || version == "" {
+			continue
+		}
+		if !releaseChannel.includesPrereleases() && release.TagName != to && (release.Prerelease || !isReleaseTag(release.TagName)) {
+			continue
+		}
+		// the final release comes after the release candidate to
+		if !isReleaseTag(to) && release.TagName != to && version == cleanupVersion(to) {
+			continue
+		}
+
+		afterFrom, err := VersionIsLessThan(from, version)
+		if err != nil {
+			return nil, err
+		}
+		upToTo, err := VersionIsLessThanOrEqual(version, to)
+		if err != nil {
+			return nil, err
+		}
+		if afterFrom && upToTo {
+			between = append(between, release)
+		}
+	}
+
+	sort.SliceStable(between, func(i, j int) bool {
+		versionI := cleanupVersion(between[i].TagName)
+		versionJ := cleanupVersion(between[j].TagName)
+		if versionI == versionJ {
+			return between[i].CreatedAt.Before(between[j].CreatedAt)
+		}
+		less, _ := VersionIsLessThan(versionI, versionJ)
+		return less
+	})
+
+	return between, nil
+}
+
+// isReleaseTag returns true if tag is a version without suffix, such as a release candidate suffix
+func isReleaseTag(tag string) bool {
+	return strings.TrimPrefix(tag, "v") == strings.TrimPrefix(cleanupVersion(tag), "v")
+}
+
+// ParseReleaseNotes splits the markdown body of release into sections. Markdown headings and lines
+// in bold only start a new section, sections titled as breaking changes are marked as such.
+func ParseReleaseNotes(release GithubRelease) ReleaseNotes {
+	notes := ReleaseNotes{
+		Version:    release.TagName,
+		Prerelease: release.Prerelease,
+		URL:        release.HTMLURL,
+	}
+
+	section := ReleaseNotesSection{}
+	for _, line := range strings.Split(strings.ReplaceAll(release.Body, "\r\n", "\n"), "\n") {
+		line = strings.TrimRight(line, " \t")
+		title, isTitle := releaseNotesTitle(line)
+		if !isTitle {
+			if line != "" || len(section.Lines) != 0 {
+				section.Lines = append(section.Lines, line)
+			}
+			continue
+		}
+		notes.Sections = appendReleaseNotesSection(notes.Sections, section)
+		section = ReleaseNotesSection{Title: title, Breaking: breakingRegexp.MatchString(title)}
+	}
+	notes.Sections = appendReleaseNotesSection(notes.Sections, section)
+
+	return notes
+}
+
+// releaseNotesTitle returns the title of a markdown heading or a line in bold only
+func releaseNotesTitle(line string) (string, bool) {
+	trimmed := strings.TrimSpace(line)
+	if strings.HasPrefix(trimmed, "#") {
+		return strings.TrimSpace(strings.TrimLeft(trimmed, "#")), true
+	}
+	if matches := boldTitleRegexp.FindStringSubmatch(trimmed); matches != nil {
+		return strings.TrimSpace(matches[1]), true
+	}
+
+	return "", false
+}
+
+// appendReleaseNotesSection appends section to sections without its trailing blank lines, unless it
+// is empty
+func appendReleaseNotesSection(sections []ReleaseNotesSection, section ReleaseNotesSection) []ReleaseNotesSection {
+	for len(section.Lines) != 0 && section.Lines[len(section.Lines)-1] == "" {
+		section.Lines = section.Lines[:len(section.Lines)-1]
+	}
+	if section.Title == "" && len(section.Lines) == 0 {
+		return sections
+	}
+
+	return append(sections, section)
+}
@@ -0,0 +1,106 @@
+package version
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"reflect"
+	"testing"
+)
+
+func TestReleasesBetween(t *testing.T) {
+	rawVersions, err := ioutil.ReadFile("test-data/cluster-operator-releases.json")
+	if err != nil {
+		t.Fatalf("failed to read testdata: %s", err.Error())
+	}
+	releases := []GithubRelease{}
+	if err = json.Unmarshal(rawVersions, &releases); err != nil {
+		t.Fatalf("failed to parse testdata: %s", err.Error())
+	}
+
+	tcases := []struct {
+		name        string
+		from        string
+		to          string
+		unofficial  bool
+		expVersions []string
+	}{
+		{
+			name:        "official releases",
+			from:        "v2.3.4",
+			to:          "v2.4.4",
+			expVersions: []string{"v2.4.0", "v2.4.1", "v2.4.2", "v2.4.3", "v2.4.4"},
+		},
+		{
+			name:        "target release candidate",
+			from:        "v2.3.4",
+			to:          "v2.4.0-rc.1",
+			expVersions: []string{"v2.4.0-rc.1"},
+		},
+		{
+			name:        "unofficial releases",
+			from:        "v2.4.3",
+			to:          "v2.4.4",
+			unofficial:  true,
+			expVersions: []string{"v2.4.4-pre", "v2.4.4"},
+		},
+		{
+			name:        "same version",
+			from:        "v2.4.4",
+			to:          "v2.4.4",
+			expVersions: []string{},
+		},
+		{
+			name:        "develop",
+			from:        "develop",
+			to:          "v2.4.4",
+			expVersions: []string{},
+		},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.unofficial {
+				releaseChannel = ChannelPrerelease
+			}
+			defer func() {
+				releaseChannel = ChannelStable
+			}()
+
+			between, err := ReleasesBetween(releases, tc.from, tc.to)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			versions := []string{}
+			for _, release := range between {
+				versions = append(versions, release.TagName)
+			}
+			if !reflect.DeepEqual(versions, tc.expVersions) {
+				t.Errorf("expected versions %v, got %v", tc.expVersions, versions)
+			}
+		})
+	}
+}
+
+func TestParseReleaseNotes(t *testing.T) {
+	release := GithubRelease{
+		TagName: "v2.5.0",
+		Body:    "IMPORTANT: Kubernetes 1.18 or later is required.\r\n\r\n## Breaking changes\r\n- Removed v1alpha1 API\r\n\r\n**Fixes**\r\n- Fixed node label sync (#350)\r\n",
+	}
+
+	notes := ParseReleaseNotes(release)
+	expected := []ReleaseNotesSection{
+		{Lines: []string{"IMPORTANT: Kubernetes 1.18 or later is required."}},
+		{Title: "Breaking changes", Lines: []string{"- Removed v1alpha1 API"}, Breaking: true},
+		{Title: "Fixes", Lines: []string{"- Fixed node label sync (#350)"}},
+	}
+	if !reflect.DeepEqual(notes.Sections, expected) {
+		t.Errorf("expected sections %+v, got %+v", expected, notes.Sections)
+	}
+	if !notes.HasBreakingChanges() {
+		t.Error("expected breaking changes")
+	}
+
+	if ParseReleaseNotes(GithubRelease{TagName: "v2.4.4", Body: "- Prep for v2.4.4 release (#349)"}).HasBreakingChanges() {
+		t.Error("expected no breaking changes")
+	}
+}
@@ -0,0 +1,61 @@
+package version
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Channel selects which releases are considered when looking up the latest version of the
+// StorageOS operator, the ETCD operator and the portal manager.
+type Channel string
+
+const (
+	// ChannelStable only considers official releases
+	ChannelStable Channel = "stable"
+	// ChannelPrerelease also considers prereleases and release candidates
+	ChannelPrerelease Channel = "prerelease"
+	// ChannelDevelop installs the develop version of every component
+	ChannelDevelop Channel = "develop"
+
+	developVersion = "develop"
+
+	errUnknownChannel = `
+	Unknown release channel %s, must be one of ` + string(ChannelStable) + `, ` + string(ChannelPrerelease) + `, ` + string(ChannelDevelop)
+)
+
+var releaseChannel = ChannelStable
+
+// ParseChannel returns the channel named name, the stable channel if name is empty
+func ParseChannel(name string) (Channel, error) {
+	switch channel := Channel(strings.ToLower(strings.TrimSpace(name))); channel {
+	case "":
+		return ChannelStable, nil
+	case ChannelStable, ChannelPrerelease, ChannelDevelop:
+		return channel, nil
+	default:
+		return "", fmt.Errorf(errUnknownChannel, name)
+	}
+}
+
+// SetReleaseChannel sets the channel the latest versions are selected from
+func SetReleaseChannel(channel Channel) {
+	releaseChannel = channel
+}
+
+// ReleaseChannel returns the channel the latest versions are selected from
+func ReleaseChannel() Channel {
+	return releaseChannel
+}
+
+// includesPrereleases returns true if prereleases and release candidates are considered
+func (c Channel) includesPrereleases() bool {
+	return c == ChannelPrerelease || c == ChannelDevelop
+}
+
+// latestVersion returns the develop version on the develop channel, otherwise the result of latest
+func (c Channel) latestVersion(latest func() string) string {
+	if c == ChannelDevelop {
+		return developVersion
+	}
+	return latest()
+}
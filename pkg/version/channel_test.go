@@ -0,0 +1,42 @@
+package version
+
+import "testing"
+
+func TestParseChannel(t *testing.T) {
+	tcases := []struct {
+		name       string
+		expChannel Channel
+		expErr     bool
+	}{
+		{name: "", expChannel: ChannelStable},
+		{name: "stable", expChannel: ChannelStable},
+		{name: "Prerelease", expChannel: ChannelPrerelease},
+		{name: "develop", expChannel: ChannelDevelop},
+		{name: "nightly", expErr: true},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			channel, err := ParseChannel(tc.name)
+			if (err != nil) != tc.expErr {
+				t.Fatalf("expected error %v, got %v", tc.expErr, err)
+			}
+			if channel != tc.expChannel {
+				t.Errorf("expected channel %s, got %s", tc.expChannel, channel)
+			}
+		})
+	}
+}
+
+func TestChannelLatestVersion(t *testing.T) {
+	latest := func() string {
+		return "v2.8.0"
+	}
+
+	if version := ChannelStable.latestVersion(latest); version != "v2.8.0" {
+		t.Errorf("expected stable version v2.8.0, got %s", version)
+	}
+	if version := ChannelDevelop.latestVersion(latest); !IsDevelop(version) {
+		t.Errorf("expected develop version, got %s", version)
+	}
+}
@@ -0,0 +1,247 @@
+package version
+
+import (
+	"fmt"
+	"strings"
+
+	goversion "github.com/hashicorp/go-version"
+	"github.com/pkg/errors"
+)
+
+const (
+	errDowngradeNotSupported = `
+	Upgrade from StorageOS %s to %s is a downgrade, which is not supported.`
+
+	errUpgradePathNotSupported = `
+	Upgrade from StorageOS %s to %s is not supported, StorageOS %s requires %s or later to be installed.
+	Upgrade through the recommended intermediate version(s) first: %s`
+
+	errKubernetesVersionTooOld = `
+	Kubernetes %s is lower than the minimum version %s required by StorageOS %s.`
+
+	errKubernetesVersionTooNew = `
+	Kubernetes %s is greater than the maximum version %s supported by StorageOS %s.`
+
+	kubernetesVersionUnknownMessage = `Kubernetes %s is newer than every version known to this version of kubectl storageos, the highest being %s. Check that StorageOS %s supports it before upgrading.`
+
+	errEtcdOperatorVersionTooOld = `
+	StorageOS ETCD operator %s is lower than the minimum version %s required by StorageOS %s.
+	Upgrade the ETCD operator first.`
+)
+
+// Compatibility is a StorageOS release line of the compatibility matrix
+type Compatibility struct {
+	// Series is the major.minor release line, such as v2.5
+	Series string
+	// UpgradeFrom is the oldest StorageOS version which can be upgraded to the series directly
+	UpgradeFrom string
+	// MaxKubeVersion is the highest major.minor version of Kubernetes supported by the series. The
+	// lowest one is read from the MIN_KUBE_VERSION file of the operator manifests.
+	MaxKubeVersion string
+	// MinEtcdOperatorVersion is the oldest StorageOS ETCD operator version supported by the
+	// series, empty if any version is supported
+	MinEtcdOperatorVersion string
+}
+
+// compatibilityMatrix lists every release line supported by kubectl storageos, oldest first. The
+// MaxKubeVersion of a series is the highest Kubernetes version listed as supported by the StorageOS
+// release notes and platform prerequisites of that series when it was added. Raise it, or add a
+// series, when StorageOS announces support for a newer Kubernetes version.
+var compatibilityMatrix = []Compatibility{
+	{Series: "v2.2", UpgradeFrom: "v2.2.0", MaxKubeVersion: "v1.21"},
+	{Series: "v2.3", UpgradeFrom: "v2.2.0", MaxKubeVersion: "v1.21"},
+	{Series: "v2.4", UpgradeFrom: "v2.2.0", MaxKubeVersion: "v1.22"},
+	{Series: "v2.5", UpgradeFrom: "v2.4.0", MaxKubeVersion: "v1.23", MinEtcdOperatorVersion: "v0.3.0"},
+	{Series: "v2.6", UpgradeFrom: "v2.4.4", MaxKubeVersion: "v1.23", MinEtcdOperatorVersion: "v0.3.0"},
+	{Series: "v2.7", UpgradeFrom: "v2.5.0", MaxKubeVersion: "v1.24", MinEtcdOperatorVersion: "v0.3.1"},
+	{Series: "v2.8", UpgradeFrom: "v2.6.0", MaxKubeVersion: "v1.24", MinEtcdOperatorVersion: "v0.3.1"},
+	{Series: "v2.9", UpgradeFrom: "v2.7.0", MaxKubeVersion: "v1.25", MinEtcdOperatorVersion: "v0.3.1"},
+}
+
+// GetCompatibility returns the compatibility of the release line of version, false if version is
+// not part of the compatibility matrix
+func GetCompatibility(version string) (Compatibility, bool) {
+	series, err := releaseSeries(version)
+	if err != nil {
+		return Compatibility{}, false
+	}
+	for _, compatibility := range compatibilityMatrix {
+		if compatibility.Series == series {
+			return compatibility, true
+		}
+	}
+
+	return Compatibility{}, false
+}
+
+// releaseSeries returns the major.minor release line of version
+func releaseSeries(version string) (string, error) {
+	ver, err := goversion.NewVersion(cleanupVersion(version))
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	segments := ver.Segments()
+
+	return fmt.Sprintf("v%d.%d", segments[0], segments[1]), nil
+}
+
+// UpgradePath returns the intermediate versions to upgrade through from version from to version
+// to, empty if to can be upgraded to directly. Each step is the oldest version its next step can
+// be upgraded from.
+func UpgradePath(from, to string) ([]string, error) {
+	path := []string{}
+	target := to
+	for {
+		compatibility, ok := GetCompatibility(target)
+		if !ok {
+			return path, nil
+		}
+		lessThan, err := VersionIsLessThan(from, compatibility.UpgradeFrom)
+		if err != nil {
+			return nil, err
+		}
+		if !lessThan {
+			return path, nil
+		}
+		// the oldest version of a series must be upgradable from an older series, stop otherwise
+		// rather than looping
+		isEqual, err := VersionIsEqualTo(target, compatibility.UpgradeFrom)
+		if err != nil {
+			return nil, err
+		}
+		if isEqual {
+			return path, nil
+		}
+		path = append([]string{compatibility.UpgradeFrom}, path...)
+		target = compatibility.UpgradeFrom
+	}
+}
+
+// CheckUpgradePath returns an error if StorageOS cannot be upgraded from version from to version
+// to directly, listing the recommended intermediate versions
+func CheckUpgradePath(from, to string) error {
+	if IsDevelop(from) || IsDevelop(to) {
+		return nil
+	}
+	lessThan, err := VersionIsLessThan(to, from)
+	if err != nil {
+		return err
+	}
+	if lessThan {
+		return fmt.Errorf(errDowngradeNotSupported, from, to)
+	}
+
+	path, err := UpgradePath(from, to)
+	if err != nil {
+		return err
+	}
+	if len(path) == 0 {
+		return nil
+	}
+	compatibility, _ := GetCompatibility(to)
+
+	return fmt.Errorf(errUpgradePathNotSupported, from, to, to, compatibility.UpgradeFrom, strings.Join(append(path, to), " -> "))
+}
+
+// InPlaceUpgradeSupported returns true if StorageOS can be upgraded from version from to version to
+// by applying the operator manifests of to over those of from, which requires both versions to be
+// deployed by the new operator. Develop versions are always reinstalled.
+func InPlaceUpgradeSupported(from, to string) (bool, error) {
+	if IsDevelop(from) || IsDevelop(to) {
+		return false, nil
+	}
+	for _, version := range []string{from, to} {
+		lessThanOrEqual, err := VersionIsLessThanOrEqual(version, ClusterOperatorLastVersion())
+		if err != nil {
+			return false, err
+		}
+		if lessThanOrEqual {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// CheckKubernetesVersion returns an error if Kubernetes version kubeVersion is lower than
+// minKubeVersion, or greater than the maximum version supported by StorageOS version
+// stosVersion. minKubeVersion is ignored if empty. If kubeVersion is newer than every version of
+// the compatibility matrix, which may predate it, a warning is returned rather than an error.
+func CheckKubernetesVersion(kubeVersion, minKubeVersion, stosVersion string) (string, error) {
+	if minKubeVersion != "" {
+		supported, err := IsSupported(kubeVersion, minKubeVersion)
+		if err != nil {
+			return "", err
+		}
+		if !supported {
+			return "", fmt.Errorf(errKubernetesVersionTooOld, kubeVersion, minKubeVersion, stosVersion)
+		}
+	}
+
+	compatibility, ok := GetCompatibility(stosVersion)
+	if !ok || compatibility.MaxKubeVersion == "" {
+		return "", nil
+	}
+	kubeSeries, err := releaseSeries(kubeVersion)
+	if err != nil {
+		return "", err
+	}
+	// compare release lines, every patch version of the maximum one is supported
+	greaterThan, err := VersionIsLessThan(compatibility.MaxKubeVersion+".0", kubeSeries+".0")
+	if err != nil {
+		return "", err
+	}
+	if !greaterThan {
+		return "", nil
+	}
+	maxKubeVersion, err := maxMatrixKubeVersion()
+	if err != nil {
+		return "", err
+	}
+	if compatibility.MaxKubeVersion == maxKubeVersion {
+		return fmt.Sprintf(kubernetesVersionUnknownMessage, kubeVersion, maxKubeVersion, stosVersion), nil
+	}
+
+	return "", fmt.Errorf(errKubernetesVersionTooNew, kubeVersion, compatibility.MaxKubeVersion, stosVersion)
+}
+
+// maxMatrixKubeVersion returns the highest MaxKubeVersion of the compatibility matrix
+func maxMatrixKubeVersion() (string, error) {
+	maxKubeVersion := ""
+	for _, compatibility := range compatibilityMatrix {
+		if compatibility.MaxKubeVersion == "" {
+			continue
+		}
+		if maxKubeVersion == "" {
+			maxKubeVersion = compatibility.MaxKubeVersion
+			continue
+		}
+		lessThan, err := VersionIsLessThan(maxKubeVersion+".0", compatibility.MaxKubeVersion+".0")
+		if err != nil {
+			return "", err
+		}
+		if lessThan {
+			maxKubeVersion = compatibility.MaxKubeVersion
+		}
+	}
+
+	return maxKubeVersion, nil
+}
+
+// CheckEtcdOperatorVersion returns an error if StorageOS ETCD operator version etcdOperatorVersion
+// is lower than the minimum version required by StorageOS version stosVersion
+func CheckEtcdOperatorVersion(etcdOperatorVersion, stosVersion string) error {
+	compatibility, ok := GetCompatibility(stosVersion)
+	if !ok || compatibility.MinEtcdOperatorVersion == "" || IsDevelop(etcdOperatorVersion) {
+		return nil
+	}
+	lessThan, err := VersionIsLessThan(etcdOperatorVersion, compatibility.MinEtcdOperatorVersion)
+	if err != nil {
+		return err
+	}
+	if lessThan {
+		return fmt.Errorf(errEtcdOperatorVersionTooOld, etcdOperatorVersion, compatibility.MinEtcdOperatorVersion, stosVersion)
+	}
+
+	return nil
+}
@@ -0,0 +1,229 @@
+package version
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestUpgradePath(t *testing.T) {
+	tests := map[string]struct {
+		from     string
+		to       string
+		expected []string
+	}{
+		"direct": {
+			from:     "v2.7.0",
+			to:       "v2.9.1",
+			expected: []string{},
+		},
+		"one intermediate version": {
+			from:     "v2.5.2",
+			to:       "v2.8.0",
+			expected: []string{"v2.6.0"},
+		},
+		"several intermediate versions": {
+			from:     "v2.3.0",
+			to:       "v2.9.1",
+			expected: []string{"v2.4.0", "v2.5.0", "v2.7.0"},
+		},
+		"series not in matrix": {
+			from:     "v2.2.0",
+			to:       "v3.0.0",
+			expected: []string{},
+		},
+	}
+
+	for name, test := range tests {
+		tt := test
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			actual, err := UpgradePath(tt.from, tt.to)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(tt.expected, actual) {
+				t.Errorf("upgrade path doesn't match: %v != %v", tt.expected, actual)
+			}
+		})
+	}
+}
+
+func TestCheckUpgradePath(t *testing.T) {
+	tests := map[string]struct {
+		from      string
+		to        string
+		expectErr bool
+	}{
+		"supported": {
+			from: "v2.6.0",
+			to:   "v2.8.0",
+		},
+		"unsupported jump": {
+			from:      "v2.4.4",
+			to:        "v2.9.0",
+			expectErr: true,
+		},
+		"downgrade": {
+			from:      "v2.8.0",
+			to:        "v2.7.0",
+			expectErr: true,
+		},
+		"develop": {
+			from: "v2.2.0",
+			to:   "develop",
+		},
+	}
+
+	for name, test := range tests {
+		tt := test
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			err := CheckUpgradePath(tt.from, tt.to)
+			if tt.expectErr != (err != nil) {
+				t.Errorf("error doesn't match: expected error %t, got %v", tt.expectErr, err)
+			}
+		})
+	}
+}
+
+func TestInPlaceUpgradeSupported(t *testing.T) {
+	tests := map[string]struct {
+		from     string
+		to       string
+		expected bool
+	}{
+		"new operator": {
+			from:     "v2.5.0",
+			to:       "v2.7.0",
+			expected: true,
+		},
+		"crossing cluster operator last version": {
+			from: "v2.4.4",
+			to:   "v2.5.0",
+		},
+		"cluster operator": {
+			from: "v2.3.0",
+			to:   "v2.4.4",
+		},
+		"develop": {
+			from: "v2.8.0",
+			to:   "develop",
+		},
+	}
+
+	for name, test := range tests {
+		tt := test
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			actual, err := InPlaceUpgradeSupported(tt.from, tt.to)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.expected != actual {
+				t.Errorf("in-place upgrade doesn't match: %t != %t", tt.expected, actual)
+			}
+		})
+	}
+}
+
+func TestCheckKubernetesVersion(t *testing.T) {
+	tests := map[string]struct {
+		kubeVersion    string
+		minKubeVersion string
+		stosVersion    string
+		expectErr      bool
+		expectWarning  bool
+	}{
+		"supported": {
+			kubeVersion:    "v1.22.4",
+			minKubeVersion: "1.19.0",
+			stosVersion:    "v2.7.0",
+		},
+		"maximum series patch": {
+			kubeVersion: "v1.24.9-gke.100",
+			stosVersion: "v2.8.0",
+		},
+		"too old": {
+			kubeVersion:    "v1.18.2",
+			minKubeVersion: "1.19.0",
+			stosVersion:    "v2.7.0",
+			expectErr:      true,
+		},
+		"too new": {
+			kubeVersion: "v1.25.0",
+			stosVersion: "v2.8.0",
+			expectErr:   true,
+		},
+		"too new for an older series than the matrix knows": {
+			kubeVersion: "v1.30.0",
+			stosVersion: "v2.8.0",
+			expectErr:   true,
+		},
+		"newer than the matrix": {
+			kubeVersion:   "v1.26.1",
+			stosVersion:   "v2.9.0",
+			expectWarning: true,
+		},
+		"series not in matrix": {
+			kubeVersion: "v1.30.0",
+			stosVersion: "v3.0.0",
+		},
+	}
+
+	for name, test := range tests {
+		tt := test
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			warning, err := CheckKubernetesVersion(tt.kubeVersion, tt.minKubeVersion, tt.stosVersion)
+			if tt.expectErr != (err != nil) {
+				t.Errorf("error doesn't match: expected error %t, got %v", tt.expectErr, err)
+			}
+			if tt.expectWarning != (warning != "") {
+				t.Errorf("warning doesn't match: expected warning %t, got %q", tt.expectWarning, warning)
+			}
+		})
+	}
+}
+
+func TestCheckEtcdOperatorVersion(t *testing.T) {
+	tests := map[string]struct {
+		etcdOperatorVersion string
+		stosVersion         string
+		expectErr           bool
+	}{
+		"supported": {
+			etcdOperatorVersion: "v0.3.1",
+			stosVersion:         "v2.8.0",
+		},
+		"too old": {
+			etcdOperatorVersion: "v0.3.0",
+			stosVersion:         "v2.8.0",
+			expectErr:           true,
+		},
+		"no requirement": {
+			etcdOperatorVersion: "v0.2.0",
+			stosVersion:         "v2.4.4",
+		},
+	}
+
+	for name, test := range tests {
+		tt := test
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			err := CheckEtcdOperatorVersion(tt.etcdOperatorVersion, tt.stosVersion)
+			if tt.expectErr != (err != nil) {
+				t.Errorf("error doesn't match: expected error %t, got %v", tt.expectErr, err)
+			}
+		})
+	}
+}
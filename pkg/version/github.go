@@ -6,6 +6,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/pkg/errors"
 	"github.com/storageos/kubectl-storageos/pkg/consts"
 	pluginutils "github.com/storageos/kubectl-storageos/pkg/utils"
 )
@@ -13,6 +14,7 @@ import (
 const (
 	operatorReleasesUrl     = "https://api.github.com/repos/storageos/operator/releases"
 	etcdOperatorReleasesUrl = "https://api.github.com/repos/storageos/etcd-cluster-operator/releases"
+	pluginReleasesUrl       = "https://api.github.com/repos/storageos/kubectl-storageos/releases"
 	// TODO: No release exists for portal-manager yet
 	// portalManagerReleasesUrl   = "https://api.github.com/repos/storageos/portal-manager/releases"
 )
@@ -73,8 +75,9 @@ func OperatorLatestSupportedVersion() string {
 		if operatorLatestVersion != "" {
 			return
 		}
-		releases := fetchVersionsOrPanic(operatorReleasesUrl)
-		operatorLatestVersion = selectLatestVersionOrPanic(releases)
+		operatorLatestVersion = releaseChannel.latestVersion(func() string {
+			return selectLatestVersionOrPanic(fetchVersionsOrPanic(operatorReleasesUrl))
+		})
 	})
 
 	return operatorLatestVersion
@@ -85,8 +88,9 @@ func EtcdOperatorLatestSupportedVersion() string {
 		if etcdOperatorLatestVersion != "" {
 			return
 		}
-		releases := fetchVersionsOrPanic(etcdOperatorReleasesUrl)
-		etcdOperatorLatestVersion = selectLatestVersionOrPanic(releases)
+		etcdOperatorLatestVersion = releaseChannel.latestVersion(func() string {
+			return selectLatestVersionOrPanic(fetchVersionsOrPanic(etcdOperatorReleasesUrl))
+		})
 	})
 
 	return etcdOperatorLatestVersion
@@ -105,6 +109,10 @@ func PortalManagerLatestSupportedVersion() string {
 
 		return portalManagerLatestVersion
 	*/
+	// until then, every channel falls back to the develop version
+	if portalManagerLatestVersion == "" {
+		return developVersion
+	}
 	return portalManagerLatestVersion
 }
 
@@ -129,29 +137,78 @@ func ClusterOperatorLastVersion() string {
 	return consts.ClusterOperatorLastVersion
 }
 
+// LatestOperatorRelease returns the latest release of the StorageOS operator on the release channel
+func LatestOperatorRelease() (GithubRelease, error) {
+	return latestChannelRelease(operatorReleasesUrl)
+}
+
+// LatestEtcdOperatorRelease returns the latest release of the StorageOS etcd operator on the
+// release channel
+func LatestEtcdOperatorRelease() (GithubRelease, error) {
+	return latestChannelRelease(etcdOperatorReleasesUrl)
+}
+
+// LatestPluginRelease returns the latest release of kubectl storageos
+func LatestPluginRelease() (GithubRelease, error) {
+	return latestRelease(pluginReleasesUrl)
+}
+
+func latestChannelRelease(url string) (GithubRelease, error) {
+	if releaseChannel == ChannelDevelop {
+		return GithubRelease{TagName: developVersion}, nil
+	}
+	return latestRelease(url)
+}
+
+func latestRelease(url string) (GithubRelease, error) {
+	releases, err := fetchVersions(url)
+	if err != nil {
+		return GithubRelease{}, err
+	}
+
+	return selectLatestVersion(releases)
+}
+
 func fetchVersionsOrPanic(url string) []GithubRelease {
-	rawVersions, err := pluginutils.FetchHttpContent(url, nil)
+	releases, err := fetchVersions(url)
 	if err != nil {
 		panic(err)
 	}
 
+	return releases
+}
+
+func fetchVersions(url string) ([]GithubRelease, error) {
+	rawVersions, err := pluginutils.FetchHttpContent(url, nil)
+	if err != nil {
+		return nil, err
+	}
+
 	releases := []GithubRelease{}
-	err = json.Unmarshal(rawVersions, &releases)
+	if err = json.Unmarshal(rawVersions, &releases); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return releases, nil
+}
+
+func selectLatestVersionOrPanic(releases []GithubRelease) string {
+	release, err := selectLatestVersion(releases)
 	if err != nil {
 		panic(err)
 	}
 
-	return releases
+	return release.TagName
 }
 
-func selectLatestVersionOrPanic(releases []GithubRelease) string {
+func selectLatestVersion(releases []GithubRelease) (GithubRelease, error) {
 	versions := []GithubRelease{}
 
 	for _, release := range releases {
 		if release.Draft {
 			continue
 		}
-		if !enableUnofficialRelease && release.Prerelease {
+		if !releaseChannel.includesPrereleases() && (release.Prerelease || !isReleaseTag(release.TagName)) {
 			continue
 		}
 
@@ -161,9 +218,10 @@ func selectLatestVersionOrPanic(releases []GithubRelease) string {
 	}
 
 	if len(versions) == 0 {
-		panic("release not found")
+		return GithubRelease{}, errors.New("release not found")
 	}
 
+	var sortErr error
 	sort.SliceStable(versions, func(i, j int) bool {
 		versionI := cleanupVersion(versions[i].TagName)
 		versionJ := cleanupVersion(versions[j].TagName)
@@ -174,10 +232,13 @@ func selectLatestVersionOrPanic(releases []GithubRelease) string {
 
 		less, err := VersionIsLessThan(versionI, versionJ)
 		if err != nil {
-			panic(err)
+			sortErr = err
 		}
 		return !less
 	})
+	if sortErr != nil {
+		return GithubRelease{}, sortErr
+	}
 
-	return versions[0].TagName
+	return versions[0], nil
 }
@@ -38,7 +38,7 @@ func startGithubServerMock(t *testing.T) (string, func()) {
 	return ms.URL, ms.Close
 }
 
-func TestSelectLatestVersionEnableUnofficialReleaseFalse(t *testing.T) {
+func TestSelectLatestVersionStableChannel(t *testing.T) {
 	defer func() {
 		if r := recover(); r != nil {
 			t.Errorf("panic not allowed: %v", r)
@@ -63,16 +63,16 @@ func TestSelectLatestVersionEnableUnofficialReleaseFalse(t *testing.T) {
 	}
 }
 
-func TestSelectLatestVersionEnableUnofficialReleaseTrue(t *testing.T) {
+func TestSelectLatestVersionPrereleaseChannel(t *testing.T) {
 	defer func() {
 		if r := recover(); r != nil {
 			t.Errorf("panic not allowed: %v", r)
 		}
 	}()
 
-	enableUnofficialRelease = true
+	releaseChannel = ChannelPrerelease
 	defer func() {
-		enableUnofficialRelease = false
+		releaseChannel = ChannelStable
 	}()
 
 	rawVersions, err := ioutil.ReadFile("test-data/cluster-operator-releases.json")
@@ -42,11 +42,12 @@ const (
 )
 
 var (
-	// EnableUnofficialRelease allows the installer to install not official of operator.
+	// DefaultReleaseChannel is the release channel used when none is given at runtime.
 	// This could be change with build flag:
-	// -X github.com/storageos/kubectl-storageos/pkg/version.EnableUnofficialRelease=true
+	// -X github.com/storageos/kubectl-storageos/pkg/version.DefaultReleaseChannel=prerelease
+	DefaultReleaseChannel string
+	// EnableUnofficialRelease is deprecated, set DefaultReleaseChannel=prerelease instead.
 	EnableUnofficialRelease string
-	enableUnofficialRelease bool
 
 	versionRegexp *regexp.Regexp
 	shaRegexp     *regexp.Regexp
@@ -65,7 +66,17 @@ func init() {
 	var err error
 
 	if EnableUnofficialRelease != "" {
-		enableUnofficialRelease, err = strconv.ParseBool(EnableUnofficialRelease)
+		enableUnofficialRelease, err := strconv.ParseBool(EnableUnofficialRelease)
+		if err != nil {
+			panic(err)
+		}
+		if enableUnofficialRelease {
+			releaseChannel = ChannelPrerelease
+		}
+	}
+
+	if DefaultReleaseChannel != "" {
+		releaseChannel, err = ParseChannel(DefaultReleaseChannel)
 		if err != nil {
 			panic(err)
 		}
@@ -162,6 +173,23 @@ func GetExistingEtcdOperatorVersion(namespace string) (string, error) {
 	return version, nil
 }
 
+// GetExistingPortalManagerVersion returns the image tag of the portal manager deployed in the
+// storageos cluster namespace
+func GetExistingPortalManagerVersion(namespace string) (string, error) {
+	config, err := pluginutils.NewClientConfig()
+	if err != nil {
+		return "", err
+	}
+	deployment, err := pluginutils.GetDeployment(config, consts.PortalManagerName, namespace)
+	if err != nil {
+		return "", err
+	}
+	imageName := deployment.Spec.Template.Spec.Containers[0].Image
+	splitImageName := strings.SplitAfter(imageName, ":")
+
+	return splitImageName[len(splitImageName)-1], nil
+}
+
 func OperatorImageUrlByVersion(operatorVersion string) (string, error) {
 	lessThanOrEqual, err := VersionIsLessThanOrEqual(operatorVersion, ClusterOperatorLastVersion())
 	if err != nil {